@@ -0,0 +1,409 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: exchange/v1/exchange.proto
+
+// The gRPC API of the exchange. It serves the same exchange as the HTTP API and takes the same credentials, see
+// ExchangeService for how calls are authenticated.
+
+package exchangev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ExchangeService_PlaceOrder_FullMethodName          = "/exchange.v1.ExchangeService/PlaceOrder"
+	ExchangeService_CancelOrder_FullMethodName         = "/exchange.v1.ExchangeService/CancelOrder"
+	ExchangeService_AmendOrder_FullMethodName          = "/exchange.v1.ExchangeService/AmendOrder"
+	ExchangeService_GetOrderbook_FullMethodName        = "/exchange.v1.ExchangeService/GetOrderbook"
+	ExchangeService_GetOrders_FullMethodName           = "/exchange.v1.ExchangeService/GetOrders"
+	ExchangeService_SubscribeBook_FullMethodName       = "/exchange.v1.ExchangeService/SubscribeBook"
+	ExchangeService_SubscribeExecutions_FullMethodName = "/exchange.v1.ExchangeService/SubscribeExecutions"
+)
+
+// ExchangeServiceClient is the client API for ExchangeService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ExchangeServiceClient interface {
+	// PlaceOrder places an order for the caller, it needs the trade permission
+	PlaceOrder(ctx context.Context, in *PlaceOrderRequest, opts ...grpc.CallOption) (*PlaceOrderResponse, error)
+	// CancelOrder cancels a resting order of the caller, admins cancel any order; it needs the trade permission
+	CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*CancelOrderResponse, error)
+	// AmendOrder replaces a resting order of the caller with one at a new price or amount, the replacement gets a new
+	// ID and loses the time priority; it needs the trade permission
+	AmendOrder(ctx context.Context, in *AmendOrderRequest, opts ...grpc.CallOption) (*AmendOrderResponse, error)
+	// GetOrderbook returns the aggregated L2 orderbook of a market, it is public
+	GetOrderbook(ctx context.Context, in *GetOrderbookRequest, opts ...grpc.CallOption) (*Orderbook, error)
+	// GetOrders returns the open orders of the caller, admins may read another user; it needs the read permission
+	GetOrders(ctx context.Context, in *GetOrdersRequest, opts ...grpc.CallOption) (*GetOrdersResponse, error)
+	// SubscribeBook streams a snapshot of the L2 orderbook followed by sequenced deltas, it is public. A client that
+	// sees a sequence number other than the last one plus one has missed an update and must subscribe again.
+	SubscribeBook(ctx context.Context, in *SubscribeBookRequest, opts ...grpc.CallOption) (ExchangeService_SubscribeBookClient, error)
+	// SubscribeExecutions streams the execution reports of the caller, admins may follow another user; it needs the
+	// read permission
+	SubscribeExecutions(ctx context.Context, in *SubscribeExecutionsRequest, opts ...grpc.CallOption) (ExchangeService_SubscribeExecutionsClient, error)
+}
+
+type exchangeServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewExchangeServiceClient(cc grpc.ClientConnInterface) ExchangeServiceClient {
+	return &exchangeServiceClient{cc}
+}
+
+func (c *exchangeServiceClient) PlaceOrder(ctx context.Context, in *PlaceOrderRequest, opts ...grpc.CallOption) (*PlaceOrderResponse, error) {
+	out := new(PlaceOrderResponse)
+	err := c.cc.Invoke(ctx, ExchangeService_PlaceOrder_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exchangeServiceClient) CancelOrder(ctx context.Context, in *CancelOrderRequest, opts ...grpc.CallOption) (*CancelOrderResponse, error) {
+	out := new(CancelOrderResponse)
+	err := c.cc.Invoke(ctx, ExchangeService_CancelOrder_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exchangeServiceClient) AmendOrder(ctx context.Context, in *AmendOrderRequest, opts ...grpc.CallOption) (*AmendOrderResponse, error) {
+	out := new(AmendOrderResponse)
+	err := c.cc.Invoke(ctx, ExchangeService_AmendOrder_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exchangeServiceClient) GetOrderbook(ctx context.Context, in *GetOrderbookRequest, opts ...grpc.CallOption) (*Orderbook, error) {
+	out := new(Orderbook)
+	err := c.cc.Invoke(ctx, ExchangeService_GetOrderbook_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exchangeServiceClient) GetOrders(ctx context.Context, in *GetOrdersRequest, opts ...grpc.CallOption) (*GetOrdersResponse, error) {
+	out := new(GetOrdersResponse)
+	err := c.cc.Invoke(ctx, ExchangeService_GetOrders_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *exchangeServiceClient) SubscribeBook(ctx context.Context, in *SubscribeBookRequest, opts ...grpc.CallOption) (ExchangeService_SubscribeBookClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ExchangeService_ServiceDesc.Streams[0], ExchangeService_SubscribeBook_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &exchangeServiceSubscribeBookClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ExchangeService_SubscribeBookClient interface {
+	Recv() (*BookUpdate, error)
+	grpc.ClientStream
+}
+
+type exchangeServiceSubscribeBookClient struct {
+	grpc.ClientStream
+}
+
+func (x *exchangeServiceSubscribeBookClient) Recv() (*BookUpdate, error) {
+	m := new(BookUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *exchangeServiceClient) SubscribeExecutions(ctx context.Context, in *SubscribeExecutionsRequest, opts ...grpc.CallOption) (ExchangeService_SubscribeExecutionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ExchangeService_ServiceDesc.Streams[1], ExchangeService_SubscribeExecutions_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &exchangeServiceSubscribeExecutionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ExchangeService_SubscribeExecutionsClient interface {
+	Recv() (*ExecutionReport, error)
+	grpc.ClientStream
+}
+
+type exchangeServiceSubscribeExecutionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *exchangeServiceSubscribeExecutionsClient) Recv() (*ExecutionReport, error) {
+	m := new(ExecutionReport)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ExchangeServiceServer is the server API for ExchangeService service.
+// All implementations must embed UnimplementedExchangeServiceServer
+// for forward compatibility
+type ExchangeServiceServer interface {
+	// PlaceOrder places an order for the caller, it needs the trade permission
+	PlaceOrder(context.Context, *PlaceOrderRequest) (*PlaceOrderResponse, error)
+	// CancelOrder cancels a resting order of the caller, admins cancel any order; it needs the trade permission
+	CancelOrder(context.Context, *CancelOrderRequest) (*CancelOrderResponse, error)
+	// AmendOrder replaces a resting order of the caller with one at a new price or amount, the replacement gets a new
+	// ID and loses the time priority; it needs the trade permission
+	AmendOrder(context.Context, *AmendOrderRequest) (*AmendOrderResponse, error)
+	// GetOrderbook returns the aggregated L2 orderbook of a market, it is public
+	GetOrderbook(context.Context, *GetOrderbookRequest) (*Orderbook, error)
+	// GetOrders returns the open orders of the caller, admins may read another user; it needs the read permission
+	GetOrders(context.Context, *GetOrdersRequest) (*GetOrdersResponse, error)
+	// SubscribeBook streams a snapshot of the L2 orderbook followed by sequenced deltas, it is public. A client that
+	// sees a sequence number other than the last one plus one has missed an update and must subscribe again.
+	SubscribeBook(*SubscribeBookRequest, ExchangeService_SubscribeBookServer) error
+	// SubscribeExecutions streams the execution reports of the caller, admins may follow another user; it needs the
+	// read permission
+	SubscribeExecutions(*SubscribeExecutionsRequest, ExchangeService_SubscribeExecutionsServer) error
+	mustEmbedUnimplementedExchangeServiceServer()
+}
+
+// UnimplementedExchangeServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedExchangeServiceServer struct {
+}
+
+func (UnimplementedExchangeServiceServer) PlaceOrder(context.Context, *PlaceOrderRequest) (*PlaceOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PlaceOrder not implemented")
+}
+func (UnimplementedExchangeServiceServer) CancelOrder(context.Context, *CancelOrderRequest) (*CancelOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelOrder not implemented")
+}
+func (UnimplementedExchangeServiceServer) AmendOrder(context.Context, *AmendOrderRequest) (*AmendOrderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AmendOrder not implemented")
+}
+func (UnimplementedExchangeServiceServer) GetOrderbook(context.Context, *GetOrderbookRequest) (*Orderbook, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrderbook not implemented")
+}
+func (UnimplementedExchangeServiceServer) GetOrders(context.Context, *GetOrdersRequest) (*GetOrdersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOrders not implemented")
+}
+func (UnimplementedExchangeServiceServer) SubscribeBook(*SubscribeBookRequest, ExchangeService_SubscribeBookServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBook not implemented")
+}
+func (UnimplementedExchangeServiceServer) SubscribeExecutions(*SubscribeExecutionsRequest, ExchangeService_SubscribeExecutionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeExecutions not implemented")
+}
+func (UnimplementedExchangeServiceServer) mustEmbedUnimplementedExchangeServiceServer() {}
+
+// UnsafeExchangeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ExchangeServiceServer will
+// result in compilation errors.
+type UnsafeExchangeServiceServer interface {
+	mustEmbedUnimplementedExchangeServiceServer()
+}
+
+func RegisterExchangeServiceServer(s grpc.ServiceRegistrar, srv ExchangeServiceServer) {
+	s.RegisterService(&ExchangeService_ServiceDesc, srv)
+}
+
+func _ExchangeService_PlaceOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PlaceOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangeServiceServer).PlaceOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangeService_PlaceOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangeServiceServer).PlaceOrder(ctx, req.(*PlaceOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExchangeService_CancelOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangeServiceServer).CancelOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangeService_CancelOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangeServiceServer).CancelOrder(ctx, req.(*CancelOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExchangeService_AmendOrder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AmendOrderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangeServiceServer).AmendOrder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangeService_AmendOrder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangeServiceServer).AmendOrder(ctx, req.(*AmendOrderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExchangeService_GetOrderbook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrderbookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangeServiceServer).GetOrderbook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangeService_GetOrderbook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangeServiceServer).GetOrderbook(ctx, req.(*GetOrderbookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExchangeService_GetOrders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetOrdersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangeServiceServer).GetOrders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangeService_GetOrders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangeServiceServer).GetOrders(ctx, req.(*GetOrdersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ExchangeService_SubscribeBook_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeBookRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExchangeServiceServer).SubscribeBook(m, &exchangeServiceSubscribeBookServer{stream})
+}
+
+type ExchangeService_SubscribeBookServer interface {
+	Send(*BookUpdate) error
+	grpc.ServerStream
+}
+
+type exchangeServiceSubscribeBookServer struct {
+	grpc.ServerStream
+}
+
+func (x *exchangeServiceSubscribeBookServer) Send(m *BookUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ExchangeService_SubscribeExecutions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeExecutionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExchangeServiceServer).SubscribeExecutions(m, &exchangeServiceSubscribeExecutionsServer{stream})
+}
+
+type ExchangeService_SubscribeExecutionsServer interface {
+	Send(*ExecutionReport) error
+	grpc.ServerStream
+}
+
+type exchangeServiceSubscribeExecutionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *exchangeServiceSubscribeExecutionsServer) Send(m *ExecutionReport) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// ExchangeService_ServiceDesc is the grpc.ServiceDesc for ExchangeService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ExchangeService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "exchange.v1.ExchangeService",
+	HandlerType: (*ExchangeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PlaceOrder",
+			Handler:    _ExchangeService_PlaceOrder_Handler,
+		},
+		{
+			MethodName: "CancelOrder",
+			Handler:    _ExchangeService_CancelOrder_Handler,
+		},
+		{
+			MethodName: "AmendOrder",
+			Handler:    _ExchangeService_AmendOrder_Handler,
+		},
+		{
+			MethodName: "GetOrderbook",
+			Handler:    _ExchangeService_GetOrderbook_Handler,
+		},
+		{
+			MethodName: "GetOrders",
+			Handler:    _ExchangeService_GetOrders_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeBook",
+			Handler:       _ExchangeService_SubscribeBook_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeExecutions",
+			Handler:       _ExchangeService_SubscribeExecutions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "exchange/v1/exchange.proto",
+}
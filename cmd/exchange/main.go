@@ -31,21 +31,42 @@ func main() {
 	// Wait for the server to start
 	time.Sleep(1 * time.Second)
 
+	// The market maker funds its accounts and designates the maker with the operator key
+	if cfg.AdminAPIKey == "" || cfg.AdminAPISecret == "" {
+		log.Fatal("The market maker needs AdminAPIKey and AdminAPISecret to fund its accounts")
+	}
+
 	// Initialize market maker client
 	mm := marketmaker.NewClinet()
+	mm.SetAdminKey(cfg.AdminAPIKey, cfg.AdminAPISecret)
+
+	// Register the market maker accounts
+	accounts, err := marketmaker.RegisterAccounts(mm)
+	if err != nil {
+		log.Fatalf("Register accounts error: %v\n", err)
+	}
+
+	if err := marketmaker.FundAccounts(mm, accounts); err != nil {
+		log.Fatalf("Fund accounts error: %v\n", err)
+	}
+
+	// Quotes of the maker account earn the market maker rebate
+	if err := mm.DesignateMarketMaker(accounts.Maker); err != nil {
+		log.Printf("Designate market maker error: %v\n", err)
+	}
 
 	// SeedMarket to add liquidity
-	if err := marketmaker.SeedMarket(mm); err != nil {
+	if err := marketmaker.SeedMarket(mm, accounts); err != nil {
 		log.Fatalf("Seed Market error: %v\n", err)
 	}
 
 	// Start market making algorithm in a goroutine
-	go marketmaker.MakeMarketSimple(mm)
+	go marketmaker.MakeMarketSimple(mm, accounts)
 
 	time.Sleep(2 * time.Second)
 
 	// Regular users add some market orders
-	marketmaker.MarketOrderPlacer(mm)
+	marketmaker.MarketOrderPlacer(mm, accounts)
 
 	// Keep the program running
 	select {}
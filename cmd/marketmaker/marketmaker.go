@@ -5,17 +5,60 @@ import (
 	"log"
 	"math"
 	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
 )
 
 var (
 	tick = 1 * time.Second
+
+	// funding is what every market making account is credited with, enough for the order sizes below
+	funding = map[string]float64{
+		string(exchanges.MarketETH): 1_000_000,
+		exchanges.QuoteAsset:        10_000_000_000,
+	}
 )
 
-func MarketOrderPlacer(c *MMClient) {
+// Accounts are the exchange users the market making algorithms trade with
+type Accounts struct {
+	Maker  uint64 // quotes both sides of the book
+	Seeder uint64 // seeds the initial liquidity and sells into the book
+	Taker  uint64 // places market orders like a regular user
+}
+
+// RegisterAccounts registers a fresh exchange user for every market making role
+func RegisterAccounts(c *MMClient) (*Accounts, error) {
+	var accounts Accounts
+
+	for _, id := range []*uint64{&accounts.Maker, &accounts.Seeder, &accounts.Taker} {
+		userID, err := c.RegisterUser()
+		if err != nil {
+			return nil, err
+		}
+		*id = userID
+	}
+
+	return &accounts, nil
+}
+
+// FundAccounts credits every market making account with funding, the exchange only accepts orders it can settle
+func FundAccounts(c *MMClient, accounts *Accounts) error {
+	for _, userID := range []uint64{accounts.Maker, accounts.Seeder, accounts.Taker} {
+		for asset, amount := range funding {
+			if err := c.CreditBalance(userID, asset, amount); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func MarketOrderPlacer(c *MMClient, accounts *Accounts) {
 	ticker := time.NewTicker(1 * time.Second)
 
 	for {
-		trades, err := c.GetTrades("ETH")
+		trades, err := c.GetTrades("ETH", 1)
 		if err != nil {
 			panic(err)
 		}
@@ -25,7 +68,7 @@ func MarketOrderPlacer(c *MMClient) {
 		}
 
 		otherMarketSell := &PlaceOrderParams{
-			UserID: 8,
+			UserID: accounts.Seeder,
 			Bid:    false,
 			Amount: 1000,
 		}
@@ -35,7 +78,7 @@ func MarketOrderPlacer(c *MMClient) {
 		}
 
 		marketSell := &PlaceOrderParams{
-			UserID: 666,
+			UserID: accounts.Taker,
 			Bid:    false,
 			Amount: 100,
 		}
@@ -45,7 +88,7 @@ func MarketOrderPlacer(c *MMClient) {
 		}
 
 		marketBuyOrder := &PlaceOrderParams{
-			UserID: 666,
+			UserID: accounts.Taker,
 			Bid:    true,
 			Amount: 100,
 		}
@@ -58,13 +101,11 @@ func MarketOrderPlacer(c *MMClient) {
 	}
 }
 
-const userID = 7
-
-func MakeMarketSimple(c *MMClient) {
+func MakeMarketSimple(c *MMClient, accounts *Accounts) {
 	ticker := time.NewTicker(tick)
 
 	for {
-		orders, err := c.GetOrders(userID)
+		orders, err := c.GetOrders(accounts.Maker)
 
 		if err != nil {
 			log.Println(err)
@@ -86,7 +127,7 @@ func MakeMarketSimple(c *MMClient) {
 		// place the bid
 		if len(orders.Bids) < 3 {
 			bidLimit := &PlaceOrderParams{
-				UserID: 7,
+				UserID: accounts.Maker,
 				Bid:    true,
 				Price:  bestBid + 100,
 				Amount: 1000,
@@ -101,7 +142,7 @@ func MakeMarketSimple(c *MMClient) {
 		// place the ask
 		if len(orders.Asks) < 3 {
 			askLimit := &PlaceOrderParams{
-				UserID: 7,
+				UserID: accounts.Maker,
 				Bid:    false,
 				Price:  bestAsk - 100,
 				Amount: 1000,
@@ -121,16 +162,16 @@ func MakeMarketSimple(c *MMClient) {
 	}
 }
 
-func SeedMarket(c *MMClient) error {
+func SeedMarket(c *MMClient, accounts *Accounts) error {
 	ask := &PlaceOrderParams{
-		UserID: 8,
+		UserID: accounts.Seeder,
 		Bid:    false,
 		Price:  10_000,
 		Amount: 1_0000,
 	}
 
 	bid := &PlaceOrderParams{
-		UserID: 8,
+		UserID: accounts.Seeder,
 		Bid:    true,
 		Price:  9_000,
 		Amount: 1_0000,
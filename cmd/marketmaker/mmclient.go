@@ -4,55 +4,251 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
-	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/matchingengine"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
 )
 
-const Endpoint = "http://localhost:3000"
+const Endpoint = "http://localhost:3000/v1"
+
+// maxRateLimitRetries is how often a rate limited request is retried after waiting for Retry-After
+const maxRateLimitRetries = 3
 
 // MMClient (Market Making Client) For interact with market making algorithm we need a client API to our own exchange to place limit/market orders,
 // retrieve and cancel them
 type MMClient struct {
 	*http.Client
+
+	// credentials holds the API key of every user registered through this client, requests are signed with them
+	credentials map[uint64]*exchanges.APIKeyResponse
+	// admin is the operator API key, it is needed to fund accounts and designate market makers
+	admin *exchanges.APIKeyResponse
 }
 
 func NewClinet() *MMClient {
 	return &MMClient{
-		Client: http.DefaultClient,
+		Client:      http.DefaultClient,
+		credentials: make(map[uint64]*exchanges.APIKeyResponse),
+	}
+}
+
+// SetAdminKey sets the operator API key the client funds accounts and designates market makers with
+func (c *MMClient) SetAdminKey(key, secret string) {
+	c.admin = &exchanges.APIKeyResponse{APIKey: key, APISecret: secret}
+}
+
+// newSignedRequest creates a request signed with the API key of the given user
+func (c *MMClient) newSignedRequest(userID uint64, method, e string, body []byte) (*http.Request, error) {
+	creds, ok := c.credentials[userID]
+	if !ok {
+		return nil, fmt.Errorf("no API key for user %d", userID)
+	}
+
+	return signedRequest(creds, method, e, body)
+}
+
+// signedRequest creates a request signed with the given API key
+func signedRequest(creds *exchanges.APIKeyResponse, method, e string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(method, e, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	sign(req, creds, body)
+
+	return req, nil
+}
+
+// sign sets the API key headers of the request with the current time as the timestamp
+func sign(req *http.Request, creds *exchanges.APIKeyResponse, body []byte) {
+	timestamp := time.Now().UnixMilli()
+	req.Header.Set(middleware.HeaderAPIKey, creds.APIKey)
+	req.Header.Set(middleware.HeaderAPITimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(middleware.HeaderAPISignature, auth.Sign(creds.APISecret, timestamp, req.Method, req.URL.RequestURI(), body))
+}
+
+// do sends the request, waiting out rate limiting responses
+func (c *MMClient) do(req *http.Request) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		resp, err := c.Do(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || retry == maxRateLimitRetries {
+			return resp, err
+		}
+		resp.Body.Close()
+
+		wait, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if err != nil {
+			wait = 1
+		}
+		time.Sleep(time.Duration(wait) * time.Second)
+
+		if err := c.rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rewind prepares a request to be sent again. A signed request is signed again with a new timestamp, the exchange
+// records a signature once it is authenticated, which can be before the request is rate limited.
+func (c *MMClient) rewind(req *http.Request) error {
+	var body []byte
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	key := req.Header.Get(middleware.HeaderAPIKey)
+	if key == "" {
+		return nil
+	}
+	creds := c.credentialsOf(key)
+	if creds == nil {
+		return fmt.Errorf("no secret for API key %s", key)
+	}
+	sign(req, creds, body)
+
+	return nil
+}
+
+// credentialsOf returns the credentials of an API key the client holds, nil if it holds none
+func (c *MMClient) credentialsOf(key string) *exchanges.APIKeyResponse {
+	if c.admin != nil && c.admin.APIKey == key {
+		return c.admin
+	}
+	for _, creds := range c.credentials {
+		if creds.APIKey == key {
+			return creds
+		}
+	}
+
+	return nil
+}
+
+// RegisterUser registers a new exchange account and returns its ID
+func (c *MMClient) RegisterUser() (uint64, error) {
+	e := Endpoint + "/users"
+	req, err := http.NewRequest(http.MethodPost, e, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	user := &exchanges.RegisterUserResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(user); err != nil {
+		return 0, err
 	}
+
+	c.credentials[user.ID] = &user.APIKeyResponse
+
+	return user.ID, nil
+}
+
+// DesignateMarketMaker puts the user on the market maker fee schedule, which pays rebates on maker fills
+func (c *MMClient) DesignateMarketMaker(userID uint64) error {
+	if c.admin == nil {
+		return fmt.Errorf("no admin API key to designate user %d", userID)
+	}
+
+	body, err := json.Marshal(&exchanges.UpdateFeeScheduleRequest{Schedule: exchanges.FeeScheduleMarketMaker})
+	if err != nil {
+		return err
+	}
+
+	e := fmt.Sprintf("%s/users/%d/fee-schedule", Endpoint, userID)
+	req, err := signedRequest(c.admin, http.MethodPut, e, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to designate market maker %d: %s", userID, resp.Status)
+	}
+
+	return nil
+}
+
+// CreditBalance credits the asset to the user's ledger balance with the operator key
+func (c *MMClient) CreditBalance(userID uint64, asset string, amount float64) error {
+	if c.admin == nil {
+		return fmt.Errorf("no admin API key to fund user %d", userID)
+	}
+
+	body, err := json.Marshal(&exchanges.CreditBalanceRequest{Asset: asset, Amount: amount})
+	if err != nil {
+		return err
+	}
+
+	e := fmt.Sprintf("%s/users/%d/credits", Endpoint, userID)
+	req, err := signedRequest(c.admin, http.MethodPost, e, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("failed to fund user %d: %s", userID, resp.Status)
+	}
+
+	return nil
 }
 
-func (c *MMClient) GetTrades(market string) ([]*matchingengine.Trade, error) {
-	e := fmt.Sprintf("%s/trades/%s", Endpoint, market)
+// GetTrades returns the latest limit trades of the market, oldest first
+func (c *MMClient) GetTrades(market string, limit int) ([]*trades.Trade, error) {
+	e := fmt.Sprintf("%s/trades/%s?limit=%d", Endpoint, market, limit)
 	req, err := http.NewRequest(http.MethodGet, e, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 
-	trades := []*matchingengine.Trade{}
-	if err := json.NewDecoder(resp.Body).Decode(&trades); err != nil {
+	result := []*trades.Trade{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
 
-	return trades, nil
+	return result, nil
 }
 
-func (c *MMClient) GetOrders(userID int64) (*exchanges.GetOrdersResponse, error) {
+func (c *MMClient) GetOrders(userID uint64) (*exchanges.GetOrdersResponse, error) {
 	e := fmt.Sprintf("%s/orders/%d", Endpoint, userID)
 
-	req, err := http.NewRequest(http.MethodGet, e, nil)
+	req, err := c.newSignedRequest(userID, http.MethodGet, e, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +269,6 @@ type PlaceOrderParams struct {
 
 func (c *MMClient) PlaceLimitOrder(p *PlaceOrderParams) (*exchanges.PlaceOrderResponse, error) {
 	params := &exchanges.PlaceOrderRequest{
-		UserID: p.UserID,
 		Type:   exchanges.LimitOrder,
 		IsBid:  p.Bid,
 		Amount: p.Amount,
@@ -88,12 +283,12 @@ func (c *MMClient) PlaceLimitOrder(p *PlaceOrderParams) (*exchanges.PlaceOrderRe
 
 	e := Endpoint + "/orders"
 
-	req, err := http.NewRequest(http.MethodPost, e, bytes.NewReader(body))
+	req, err := c.newSignedRequest(p.UserID, http.MethodPost, e, body)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -108,19 +303,22 @@ func (c *MMClient) PlaceLimitOrder(p *PlaceOrderParams) (*exchanges.PlaceOrderRe
 func (c *MMClient) PlaceMarketOrder(params *PlaceOrderParams) (*exchanges.PlaceOrderResponse, error) {
 	endpoint := Endpoint + "/orders"
 	data := exchanges.PlaceOrderRequest{
-		UserID: params.UserID,
 		Type:   exchanges.MarketOrder,
 		IsBid:  params.Bid,
 		Amount: params.Amount,
 		Market: exchanges.MarketETH, // Hard coded ETH because we just support ETH for now
 	}
 	body, err := json.Marshal(data)
-	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.Do(req)
+	req, err := c.newSignedRequest(params.UserID, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -132,14 +330,14 @@ func (c *MMClient) PlaceMarketOrder(params *PlaceOrderParams) (*exchanges.PlaceO
 	return result, nil
 }
 
-func (c *MMClient) CancelOrder(orderID uint64) error {
+func (c *MMClient) CancelOrder(userID, orderID uint64) error {
 	endpoint := fmt.Sprintf("%s/orders/%d", Endpoint, orderID)
-	req, err := http.NewRequest(http.MethodDelete, endpoint, nil)
+	req, err := c.newSignedRequest(userID, http.MethodDelete, endpoint, nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := c.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -156,7 +354,7 @@ func (c *MMClient) GetBestBid() (float64, error) {
 		return 0.0, err
 	}
 
-	resp, err := c.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return 0.0, err
 	}
@@ -176,7 +374,7 @@ func (c *MMClient) GetBestAsk() (float64, error) {
 		return 0, err
 	}
 
-	resp, err := c.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return 0, err
 	}
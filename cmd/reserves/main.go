@@ -0,0 +1,61 @@
+// Reserves asks a running exchange to publish a new proof-of-reserves snapshot and prints the result. Publishing
+// needs the operator API key, the request is signed with its secret.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", "http://localhost:3000", "exchange API endpoint")
+	key := flag.String("key", "", "admin API key (AdminAPIKey of the exchange)")
+	secret := flag.String("secret", "", "admin API secret (AdminAPISecret of the exchange)")
+	flag.Parse()
+
+	if *key == "" || *secret == "" {
+		log.Fatal("Publishing reserves needs the admin API key: set -key and -secret")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *endpoint+"/v1/reserves", nil)
+	if err != nil {
+		log.Fatalf("Failed to create request: %v", err)
+	}
+
+	timestamp := time.Now().UnixMilli()
+	req.Header.Set(middleware.HeaderAPIKey, *key)
+	req.Header.Set(middleware.HeaderAPITimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(middleware.HeaderAPISignature, auth.Sign(*secret, timestamp, req.Method, req.URL.RequestURI(), nil))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Fatalf("Failed to publish reserves: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		log.Fatalf("Failed to publish reserves: unexpected status %s", resp.Status)
+	}
+
+	var reports []*exchanges.ReservesReport
+	if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+		log.Fatalf("Failed to decode reserves: %v", err)
+	}
+
+	for _, report := range reports {
+		fmt.Printf("market [%s] | root [%s] | liabilities [%.8f]", report.Market, report.Root, report.Liabilities)
+		if report.OnChainVerified {
+			fmt.Printf(" | on-chain [%.8f] | solvent [%t]", report.OnChainReserves, report.Solvent)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrInvalidAPIKey is returned for API keys that were never issued or have been revoked
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKey is a credential issued to a user. Requests are signed with the secret, only the key is sent over the wire.
+// The store keeps the SHA-256 of the secret, which is what the requests are signed with, the secret itself is only
+// set on a key just issued so it can be handed to the user.
+type APIKey struct {
+	Key         string
+	Secret      string
+	SecretHash  string
+	UserID      uint64
+	Permissions Permissions
+	CreatedAt   int64
+}
+
+// KeyStore keeps the API keys issued to users
+type KeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+	// save and remove write the issued, granted and revoked keys through, nil when the keys are kept in memory only,
+	// see Persist
+	save   func(apiKey *APIKey) error
+	remove func(key string) error
+}
+
+// NewKeyStore is constructor of KeyStore struct.
+func NewKeyStore() *KeyStore {
+	return &KeyStore{
+		keys: make(map[string]*APIKey),
+	}
+}
+
+// Persist makes the store write every key it issues or grants permissions to with save, and remove every key it
+// revokes. A key that cannot be written is not changed in memory either. Keys added with Add are not written, they
+// are either restored or come from the configuration.
+func (s *KeyStore) Persist(save func(apiKey *APIKey) error, remove func(key string) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.save = save
+	s.remove = remove
+}
+
+// Issue creates a new random API key and secret for the user with the given permissions. The returned key is the
+// only one holding the secret.
+func (s *KeyStore) Issue(userID uint64, permissions ...Permission) (*APIKey, error) {
+	key, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &APIKey{
+		Key:         key,
+		SecretHash:  HashSecret(secret),
+		UserID:      userID,
+		Permissions: permissions,
+		CreatedAt:   time.Now().UnixNano(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.save != nil {
+		if err := s.save(stored); err != nil {
+			return nil, err
+		}
+	}
+	s.keys[key] = stored
+
+	issued := *stored
+	issued.Secret = secret
+	return &issued, nil
+}
+
+// Add stores an API key created elsewhere, such as the operator key from the configuration or a restored key. The
+// secret is hashed if the key has no hash yet, the store does not keep it.
+func (s *KeyStore) Add(apiKey *APIKey) {
+	stored := *apiKey
+	if stored.SecretHash == "" {
+		stored.SecretHash = HashSecret(stored.Secret)
+	}
+	stored.Secret = ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[stored.Key] = &stored
+}
+
+// Lookup returns the API key with the given public key
+func (s *KeyStore) Lookup(key string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apiKey, ok := s.keys[key]
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return apiKey, nil
+}
+
+// Grant adds permissions to an issued API key. The stored key is replaced, not changed, so requests already holding
+// it keep a consistent view.
+func (s *KeyStore) Grant(key string, permissions ...Permission) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apiKey, ok := s.keys[key]
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	granted := *apiKey
+	granted.Permissions = append(Permissions{}, apiKey.Permissions...)
+	for _, p := range permissions {
+		if !granted.Permissions.Has(p) {
+			granted.Permissions = append(granted.Permissions, p)
+		}
+	}
+	if s.save != nil {
+		if err := s.save(&granted); err != nil {
+			return nil, err
+		}
+	}
+	s.keys[key] = &granted
+
+	return &granted, nil
+}
+
+// Revoke removes the API key so it can no longer authenticate requests
+func (s *KeyStore) Revoke(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.remove != nil {
+		if err := s.remove(key); err != nil {
+			return err
+		}
+	}
+	delete(s.keys, key)
+
+	return nil
+}
+
+// HashSecret returns the hex encoded SHA-256 of an API secret, the key requests are signed with
+func HashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of a request, keyed with the hash of the secret, see HashSecret.
+// The signed payload is the timestamp (unix milliseconds), the HTTP method, the request URI including the query
+// string and the raw body, concatenated.
+func Sign(secret string, timestamp int64, method, requestURI string, body []byte) string {
+	return sign(HashSecret(secret), timestamp, method, requestURI, body)
+}
+
+// VerifySignature checks the signature of a request against the hash of the secret in constant time
+func VerifySignature(secretHash, signature string, timestamp int64, method, requestURI string, body []byte) bool {
+	expected := sign(secretHash, timestamp, method, requestURI, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func sign(secretHash string, timestamp int64, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secretHash))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte(method))
+	mac.Write([]byte(requestURI))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
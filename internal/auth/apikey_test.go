@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyStore(t *testing.T) {
+	store := NewKeyStore()
+
+	key, err := store.Issue(7)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), key.UserID)
+
+	// Test case 1: issued keys can be looked up, the store keeps only the hash of the secret
+	found, err := store.Lookup(key.Key)
+	require.NoError(t, err)
+	require.Equal(t, key.Key, found.Key)
+	require.Empty(t, found.Secret)
+	require.Equal(t, HashSecret(key.Secret), found.SecretHash)
+
+	// Test case 2: granted permissions are added once, the issued key is left alone
+	granted, err := store.Grant(key.Key, PermissionWithdraw, PermissionRead, PermissionWithdraw)
+	require.NoError(t, err)
+	require.Equal(t, Permissions{PermissionWithdraw, PermissionRead}, granted.Permissions)
+	require.Empty(t, key.Permissions)
+	_, err = store.Grant("unknown", PermissionWithdraw)
+	require.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	// Test case 3: revoked keys are gone
+	require.NoError(t, store.Revoke(key.Key))
+	_, err = store.Lookup(key.Key)
+	require.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	// Test case 4: a persisted store writes issued, granted and revoked keys, and changes nothing it cannot write
+	saved := map[string]*APIKey{}
+	var saveErr error
+	store.Persist(func(apiKey *APIKey) error {
+		if saveErr != nil {
+			return saveErr
+		}
+		saved[apiKey.Key] = apiKey
+		return nil
+	}, func(key string) error {
+		delete(saved, key)
+		return nil
+	})
+
+	key, err = store.Issue(7, PermissionRead)
+	require.NoError(t, err)
+	require.Empty(t, saved[key.Key].Secret)
+	require.Equal(t, HashSecret(key.Secret), saved[key.Key].SecretHash)
+
+	saveErr = errors.New("disk full")
+	_, err = store.Grant(key.Key, PermissionTrade)
+	require.ErrorIs(t, err, saveErr)
+	found, err = store.Lookup(key.Key)
+	require.NoError(t, err)
+	require.Equal(t, Permissions{PermissionRead}, found.Permissions)
+	_, err = store.Issue(8)
+	require.ErrorIs(t, err, saveErr)
+
+	require.NoError(t, store.Revoke(key.Key))
+	require.Empty(t, saved)
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"Type":"LIMIT"}`)
+	signature := Sign("secret", 1700000000000, http.MethodPost, "/orders", body)
+	hash := HashSecret("secret")
+
+	require.True(t, VerifySignature(hash, signature, 1700000000000, http.MethodPost, "/orders", body))
+
+	// Any change to the signed payload invalidates the signature
+	require.False(t, VerifySignature(HashSecret("other"), signature, 1700000000000, http.MethodPost, "/orders", body))
+	require.False(t, VerifySignature(hash, signature, 1700000000001, http.MethodPost, "/orders", body))
+	require.False(t, VerifySignature(hash, signature, 1700000000000, http.MethodDelete, "/orders", body))
+	require.False(t, VerifySignature(hash, signature, 1700000000000, http.MethodPost, "/orders/1", body))
+	require.False(t, VerifySignature(hash, signature, 1700000000000, http.MethodPost, "/orders", []byte(`{}`)))
+}
+
+func TestPermissions(t *testing.T) {
+	trader := Permissions{PermissionTrade, PermissionRead}
+	admin := Permissions{PermissionAdmin}
+
+	require.True(t, trader.Has(PermissionRead))
+	require.False(t, trader.Has(PermissionWithdraw))
+	require.True(t, admin.Has(PermissionWithdraw))
+
+	require.True(t, trader.Covers(Permissions{PermissionRead}))
+	require.False(t, trader.Covers(admin))
+	require.True(t, admin.Covers(trader))
+
+	_, err := ParsePermission("root")
+	require.Error(t, err)
+}
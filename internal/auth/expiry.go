@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiry is a key that is forgotten at a point in time
+type expiry struct {
+	key string
+	at  time.Time
+}
+
+// expiries orders keys by the time they expire, so the expired ones are found without walking every entry. Callers
+// hold their own lock, it is not safe for concurrent use.
+type expiries []expiry
+
+func (e expiries) Len() int            { return len(e) }
+func (e expiries) Less(i, j int) bool  { return e[i].at.Before(e[j].at) }
+func (e expiries) Swap(i, j int)       { e[i], e[j] = e[j], e[i] }
+func (e *expiries) Push(x interface{}) { *e = append(*e, x.(expiry)) }
+
+func (e *expiries) Pop() interface{} {
+	old := *e
+	last := old[len(old)-1]
+	*e = old[:len(old)-1]
+	return last
+}
+
+// add schedules the key to expire at the given time
+func (e *expiries) add(key string, at time.Time) {
+	heap.Push(e, expiry{key: key, at: at})
+}
+
+// expire calls remove with every key that expired before now, soonest first
+func (e *expiries) expire(now time.Time, remove func(key string)) {
+	for e.Len() > 0 && (*e)[0].at.Before(now) {
+		remove(heap.Pop(e).(expiry).key)
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpiries(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var e expiries
+	for i, key := range []string{"c", "a", "d", "b"} {
+		e.add(key, now.Add(time.Duration([]int{3, 1, 4, 2}[i])*time.Second))
+	}
+	expired := func(at time.Time) []string {
+		keys := []string{}
+		e.expire(at, func(key string) { keys = append(keys, key) })
+		return keys
+	}
+
+	// Test case 1: keys expire soonest first, whatever order they were added in
+	require.Equal(t, []string{"a", "b"}, expired(now.Add(2500*time.Millisecond)))
+
+	// Test case 2: nothing expires twice, keys expire strictly after their time
+	require.Empty(t, expired(now.Add(2500*time.Millisecond)))
+	require.Empty(t, expired(now.Add(3*time.Second)))
+	require.Equal(t, []string{"c", "d"}, expired(now.Add(time.Minute)))
+	require.Zero(t, e.Len())
+}
+
+func TestSignatureCache(t *testing.T) {
+	cache := newSignatureCache()
+
+	// Test case 1: a signature is accepted once while it is in the replay window
+	require.True(t, cache.add("a", time.Now().Add(time.Minute)))
+	require.False(t, cache.add("a", time.Now().Add(time.Minute)))
+
+	// Test case 2: signatures out of the window are dropped on the next add
+	require.True(t, cache.add("b", time.Now().Add(-time.Second)))
+	require.True(t, cache.add("c", time.Now().Add(time.Minute)))
+	require.Len(t, cache.seen, 2)
+	require.Equal(t, 2, cache.expiries.Len())
+}
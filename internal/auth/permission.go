@@ -0,0 +1,55 @@
+package auth
+
+import "fmt"
+
+// Permission is a right attached to a credential
+type Permission string
+
+const (
+	// PermissionTrade allows placing and cancelling the owner's orders
+	PermissionTrade Permission = "trade"
+	// PermissionRead allows reading the owner's orders, balances and account
+	PermissionRead Permission = "read"
+	// PermissionWithdraw allows moving the owner's funds out of the exchange
+	PermissionWithdraw Permission = "withdraw"
+	// PermissionAdmin is the operator role, it allows every operation on every user
+	PermissionAdmin Permission = "admin"
+)
+
+// DefaultPermissions are granted to the API key a user gets on registration
+var DefaultPermissions = []Permission{PermissionTrade, PermissionRead}
+
+// ParsePermission validates a permission name
+func ParsePermission(name string) (Permission, error) {
+	switch p := Permission(name); p {
+	case PermissionTrade, PermissionRead, PermissionWithdraw, PermissionAdmin:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown permission %q", name)
+	}
+}
+
+// Permissions is the set of permissions of a credential
+type Permissions []Permission
+
+// Has reports whether the set grants the permission, admin grants everything
+func (ps Permissions) Has(p Permission) bool {
+	for _, granted := range ps {
+		if granted == p || granted == PermissionAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Covers reports whether every permission in other is granted by this set
+func (ps Permissions) Covers(other Permissions) bool {
+	for _, p := range other {
+		if !ps.Has(p) {
+			return false
+		}
+	}
+
+	return true
+}
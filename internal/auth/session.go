@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	// ErrInvalidChallenge is returned when a login refers to an unknown, used or expired nonce
+	ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+	// ErrInvalidWalletSignature is returned when a login signature was not made by the claimed address
+	ErrInvalidWalletSignature = errors.New("invalid wallet signature")
+	// ErrInvalidToken is returned for malformed, expired or revoked session tokens
+	ErrInvalidToken = errors.New("invalid session token")
+)
+
+// challengeTTL is how long a login nonce can be signed for
+const challengeTTL = 5 * time.Minute
+
+// Challenge is a sign-in-with-Ethereum style message the wallet signs to prove it owns an address
+type Challenge struct {
+	Address   string `json:"address"`
+	Nonce     string `json:"nonce"`
+	Message   string `json:"message"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// TokenPair is a short-lived access token together with the refresh token that renews it
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	AccessExpiresAt  int64  `json:"access_expires_at"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+}
+
+// Claims are the claims of an access token
+type Claims struct {
+	jwt.StandardClaims
+	UserID      uint64      `json:"uid"`
+	Permissions Permissions `json:"perms"`
+}
+
+// SessionsConfig holds the settings of browser sessions
+type SessionsConfig struct {
+	// Secret signs the access tokens (HS256)
+	Secret []byte
+	// Domain and ChainID are part of the login message the wallet signs
+	Domain  string
+	ChainID int64
+	// AccessTTL and RefreshTTL are the lifetimes of access and refresh tokens
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// Sessions issues login challenges for wallets and the JWT sessions browsers trade with
+type Sessions struct {
+	config SessionsConfig
+
+	mu         sync.Mutex
+	challenges map[string]*Challenge // by nonce
+	refresh    map[string]*refreshToken
+	// challengeExpiries and refreshExpiries hold the nonces and refresh tokens in the order they expire, used ones
+	// stay until then
+	challengeExpiries expiries
+	refreshExpiries   expiries
+}
+
+type refreshToken struct {
+	userID      uint64
+	permissions Permissions
+	expiresAt   time.Time
+}
+
+// NewSessions is constructor of Sessions struct.
+func NewSessions(config SessionsConfig) (*Sessions, error) {
+	if len(config.Secret) < 32 {
+		return nil, errors.New("session secret must be at least 32 bytes")
+	}
+
+	if config.AccessTTL <= 0 || config.RefreshTTL <= 0 {
+		return nil, errors.New("session token lifetimes must be positive")
+	}
+
+	return &Sessions{
+		config:     config,
+		challenges: make(map[string]*Challenge),
+		refresh:    make(map[string]*refreshToken),
+	}, nil
+}
+
+// NewChallenge creates a single-use login challenge for the address
+func (s *Sessions) NewChallenge(address string) (*Challenge, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid address %q", address)
+	}
+	checksummed := common.HexToAddress(address).Hex()
+
+	nonce, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	challenge := &Challenge{
+		Address:   checksummed,
+		Nonce:     nonce,
+		ExpiresAt: now.Add(challengeTTL).UnixNano(),
+		Message: s.config.Domain + " wants you to sign in with your Ethereum account:\n" +
+			checksummed + "\n\n" +
+			"Sign in to trade on the exchange.\n\n" +
+			"Version: 1\n" +
+			"Chain ID: " + strconv.FormatInt(s.config.ChainID, 10) + "\n" +
+			"Nonce: " + nonce + "\n" +
+			"Issued At: " + now.UTC().Format(time.RFC3339) + "\n" +
+			"Expiration Time: " + now.Add(challengeTTL).UTC().Format(time.RFC3339),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.challengeExpiries.expire(now, func(n string) {
+		delete(s.challenges, n)
+	})
+	s.challenges[nonce] = challenge
+	s.challengeExpiries.add(nonce, time.Unix(0, challenge.ExpiresAt))
+
+	return challenge, nil
+}
+
+// VerifyChallenge consumes the challenge and checks that the signature of its message (personal_sign) was made by
+// the challenged address. It returns the checksummed address.
+func (s *Sessions) VerifyChallenge(nonce, signature string) (string, error) {
+	s.mu.Lock()
+	challenge, ok := s.challenges[nonce]
+	delete(s.challenges, nonce)
+	s.mu.Unlock()
+
+	if !ok || challenge.ExpiresAt < time.Now().UnixNano() {
+		return "", ErrInvalidChallenge
+	}
+
+	sig, err := hexutil.Decode(signature)
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return "", ErrInvalidWalletSignature
+	}
+
+	// Wallets return the recovery id as 27/28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(challenge.Message)), sig)
+	if err != nil {
+		return "", ErrInvalidWalletSignature
+	}
+
+	if crypto.PubkeyToAddress(*pub).Hex() != challenge.Address {
+		return "", ErrInvalidWalletSignature
+	}
+
+	return challenge.Address, nil
+}
+
+// Issue creates a new session for the user
+func (s *Sessions) Issue(userID uint64, permissions Permissions) (*TokenPair, error) {
+	now := time.Now()
+	accessExpiresAt := now.Add(s.config.AccessTTL)
+	refreshExpiresAt := now.Add(s.config.RefreshTTL)
+
+	claims := &Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:   strconv.FormatUint(userID, 10),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: accessExpiresAt.Unix(),
+		},
+		UserID:      userID,
+		Permissions: permissions,
+	}
+
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.config.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.refreshExpiries.expire(now, func(t string) {
+		delete(s.refresh, t)
+	})
+	s.refresh[refresh] = &refreshToken{
+		userID:      userID,
+		permissions: permissions,
+		expiresAt:   refreshExpiresAt,
+	}
+	s.refreshExpiries.add(refresh, refreshExpiresAt)
+	s.mu.Unlock()
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refresh,
+		AccessExpiresAt:  accessExpiresAt.UnixNano(),
+		RefreshExpiresAt: refreshExpiresAt.UnixNano(),
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new session. Refresh tokens are single use, the old one is revoked.
+// checkUser is called with the user of the token and no session is issued if it returns an error.
+func (s *Sessions) Refresh(token string, checkUser func(userID uint64) error) (*TokenPair, error) {
+	s.mu.Lock()
+	refresh, ok := s.refresh[token]
+	delete(s.refresh, token)
+	s.mu.Unlock()
+
+	if !ok || refresh.expiresAt.Before(time.Now()) {
+		return nil, ErrInvalidToken
+	}
+
+	if err := checkUser(refresh.userID); err != nil {
+		return nil, err
+	}
+
+	return s.Issue(refresh.userID, refresh.permissions)
+}
+
+// Revoke invalidates a refresh token, access tokens expire on their own
+func (s *Sessions) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.refresh, token)
+}
+
+// ParseAccessToken validates an access token and returns its claims
+func (s *Sessions) ParseAccessToken(token string) (*Claims, error) {
+	claims := &Claims{}
+	parsed, err := jwt.ParseWithClaims(strings.TrimSpace(token), claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Header["alg"])
+		}
+		return s.config.Secret, nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
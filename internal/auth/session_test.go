@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSessions(t *testing.T) *Sessions {
+	s, err := NewSessions(SessionsConfig{
+		Secret:     []byte("0123456789abcdef0123456789abcdef"),
+		Domain:     "localhost",
+		ChainID:    1337,
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func TestSessions_Login(t *testing.T) {
+	s := newTestSessions(t)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	sign := func(message string) string {
+		sig, err := crypto.Sign(accounts.TextHash([]byte(message)), key)
+		require.NoError(t, err)
+		sig[crypto.RecoveryIDOffset] += 27
+		return hexutil.Encode(sig)
+	}
+
+	// Test case 1: the wallet signature of the challenge proves the address
+	challenge, err := s.NewChallenge(address)
+	require.NoError(t, err)
+	verified, err := s.VerifyChallenge(challenge.Nonce, sign(challenge.Message))
+	require.NoError(t, err)
+	require.Equal(t, address, verified)
+
+	// Test case 2: challenges are single use
+	_, err = s.VerifyChallenge(challenge.Nonce, sign(challenge.Message))
+	require.ErrorIs(t, err, ErrInvalidChallenge)
+
+	// Test case 3: a signature from another wallet is rejected
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	challenge, err = s.NewChallenge(crypto.PubkeyToAddress(other.PublicKey).Hex())
+	require.NoError(t, err)
+	_, err = s.VerifyChallenge(challenge.Nonce, sign(challenge.Message))
+	require.ErrorIs(t, err, ErrInvalidWalletSignature)
+}
+
+func TestSessions_Tokens(t *testing.T) {
+	s := newTestSessions(t)
+
+	pair, err := s.Issue(3, DefaultPermissions)
+	require.NoError(t, err)
+
+	// Test case 1: access tokens carry the user and permissions
+	claims, err := s.ParseAccessToken(pair.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), claims.UserID)
+	require.Equal(t, Permissions(DefaultPermissions), claims.Permissions)
+
+	// Test case 2: tampered tokens are rejected
+	_, err = s.ParseAccessToken(pair.AccessToken + "x")
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// Test case 3: refresh tokens rotate
+	active := func(uint64) error { return nil }
+	renewed, err := s.Refresh(pair.RefreshToken, active)
+	require.NoError(t, err)
+	require.NotEqual(t, pair.RefreshToken, renewed.RefreshToken)
+	_, err = s.Refresh(pair.RefreshToken, active)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	// Test case 4: users that fail the check get no new session
+	frozen := errors.New("user is frozen")
+	_, err = s.Refresh(renewed.RefreshToken, func(userID uint64) error {
+		require.Equal(t, uint64(3), userID)
+		return frozen
+	})
+	require.ErrorIs(t, err, frozen)
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidTimestamp is returned for signed requests without a unix millisecond timestamp
+	ErrInvalidTimestamp = errors.New("invalid request timestamp")
+	// ErrStaleTimestamp is returned for signed requests whose timestamp is outside of the replay window
+	ErrStaleTimestamp = errors.New("request timestamp outside of the replay window")
+	// ErrInvalidSignature is returned for requests not signed with the secret of their API key
+	ErrInvalidSignature = errors.New("invalid request signature")
+	// ErrReplayedRequest is returned for a signature that was already accepted
+	ErrReplayedRequest = errors.New("request has already been used")
+)
+
+// DefaultReplayWindow is how far a request timestamp may drift from the server clock when no window is configured
+const DefaultReplayWindow = 30 * time.Second
+
+// Verifier authenticates signed requests against a key store. A signature is accepted once, every transport
+// verifying with the same verifier shares that guarantee.
+type Verifier struct {
+	keys         *KeyStore
+	replayWindow time.Duration
+	seen         *signatureCache
+}
+
+// NewVerifier is constructor of Verifier struct. replayWindow is how far a request timestamp may be from the server
+// clock.
+func NewVerifier(keys *KeyStore, replayWindow time.Duration) *Verifier {
+	if replayWindow <= 0 {
+		replayWindow = DefaultReplayWindow
+	}
+
+	return &Verifier{
+		keys:         keys,
+		replayWindow: replayWindow,
+		seen:         newSignatureCache(),
+	}
+}
+
+// Verify returns the API key a request was signed with, timestamp is the unix millisecond time as sent by the client.
+// See Sign for the signed payload.
+func (v *Verifier) Verify(key, timestamp, signature, method, requestURI string, body []byte) (*APIKey, error) {
+	apiKey, err := v.keys.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidTimestamp
+	}
+
+	now := time.Now()
+	requestTime := time.UnixMilli(millis)
+	if requestTime.Before(now.Add(-v.replayWindow)) || requestTime.After(now.Add(v.replayWindow)) {
+		return nil, ErrStaleTimestamp
+	}
+
+	if !VerifySignature(apiKey.SecretHash, signature, millis, method, requestURI, body) {
+		return nil, ErrInvalidSignature
+	}
+
+	if !v.seen.add(signature, requestTime.Add(v.replayWindow)) {
+		return nil, ErrReplayedRequest
+	}
+
+	return apiKey, nil
+}
+
+// signatureCache remembers signatures until their timestamp leaves the replay window
+type signatureCache struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+	// expiries holds the seen signatures in the order they leave the replay window
+	expiries expiries
+}
+
+func newSignatureCache() *signatureCache {
+	return &signatureCache{
+		seen: make(map[string]struct{}),
+	}
+}
+
+// add returns false if the signature was already seen
+func (s *signatureCache) add(signature string, expiry time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expiries.expire(time.Now(), func(sig string) {
+		delete(s.seen, sig)
+	})
+
+	if _, ok := s.seen[signature]; ok {
+		return false
+	}
+	s.seen[signature] = struct{}{}
+	s.expiries.add(signature, expiry)
+
+	return true
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+	keys := NewKeyStore()
+	key, err := keys.Issue(7, PermissionRead)
+	require.NoError(t, err)
+	verifier := NewVerifier(keys, time.Minute)
+
+	sign := func(at time.Time) (string, string) {
+		timestamp := at.UnixMilli()
+		return strconv.FormatInt(timestamp, 10), Sign(key.Secret, timestamp, http.MethodGet, "/v1/fills", nil)
+	}
+
+	// Test case 1: a signed request is accepted once
+	timestamp, signature := sign(time.Now())
+	found, err := verifier.Verify(key.Key, timestamp, signature, http.MethodGet, "/v1/fills", nil)
+	require.NoError(t, err)
+	require.Equal(t, key.Key, found.Key)
+
+	_, err = verifier.Verify(key.Key, timestamp, signature, http.MethodGet, "/v1/fills", nil)
+	require.ErrorIs(t, err, ErrReplayedRequest)
+
+	// Test case 2: timestamps outside of the replay window are rejected
+	timestamp, signature = sign(time.Now().Add(-2 * time.Minute))
+	_, err = verifier.Verify(key.Key, timestamp, signature, http.MethodGet, "/v1/fills", nil)
+	require.ErrorIs(t, err, ErrStaleTimestamp)
+
+	_, err = verifier.Verify(key.Key, "yesterday", signature, http.MethodGet, "/v1/fills", nil)
+	require.ErrorIs(t, err, ErrInvalidTimestamp)
+
+	// Test case 3: the signature must match the request and the key
+	timestamp, signature = sign(time.Now())
+	_, err = verifier.Verify(key.Key, timestamp, signature, http.MethodGet, "/v1/orders", nil)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = verifier.Verify("unknown", timestamp, signature, http.MethodGet, "/v1/fills", nil)
+	require.ErrorIs(t, err, ErrInvalidAPIKey)
+}
@@ -2,15 +2,85 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	ExchangePrivateKey string
-	ETHHost            string
-	ServerPort         string
+	// KeystoreDir is the go-ethereum keystore directory holding the encrypted exchange and user keys
+	KeystoreDir string
+	// KeystorePassphrase decrypts the keystore files, keys are never kept in plaintext
+	KeystorePassphrase string
+	// ExchangeAddress is the keystore account the exchange signs its own transactions with
+	ExchangeAddress string
+	// HDSeedFile is the encrypted master seed user deposit addresses are derived from, it uses KeystorePassphrase
+	HDSeedFile string
+	ETHHost    string
+	ServerPort string
+	// GRPCPort is the port of the gRPC API, it is not served when empty
+	GRPCPort string
+	// FIXPort is the port of the FIX 4.4 order entry gateway, it is not served when empty
+	FIXPort string
+	// FIXCompID is the CompID of the gateway, EXCHANGE when empty
+	FIXCompID string
+	// FIXStorePath is the file FIX sequence numbers and sent messages are persisted in, sessions start over on
+	// restart when it is empty
+	FIXStorePath string
+
+	// DatabaseURL is the PostgreSQL database users, orders, fills, balances, settlements and trades are persisted
+	// in, it takes precedence over DatabasePath
+	DatabaseURL string
+	// DatabasePath is the embedded database file the exchange state is persisted in when there is no DatabaseURL,
+	// the exchange state is lost on restart when both are empty
+	DatabasePath string
+	// TradeStoreDir is the directory the trade history is persisted in when there is no database, only the latest
+	// trades are kept in memory when both are empty
+	TradeStoreDir string
+	// TradeRingSize is how many of the latest trades of every market are served from memory
+	TradeRingSize int
+
+	// ColdWalletAddress enables the treasury, hot wallet funds above the high watermark are swept into it
+	ColdWalletAddress string
+	// HotWalletLowWatermark and HotWalletHighWatermark bound the hot wallet balance, in wei
+	HotWalletLowWatermark  string
+	HotWalletHighWatermark string
+	// TreasuryInterval is how often the treasury sweeps deposits and rebalances the hot wallet
+	TreasuryInterval time.Duration
+
+	// APIKeyReplayWindow is how far a signed request timestamp may drift from the server clock
+	APIKeyReplayWindow time.Duration
+	// AdminAPIKey and AdminAPISecret are the operator credential, it holds the admin permission
+	AdminAPIKey    string
+	AdminAPISecret string
+
+	// JWTSecret signs web UI session tokens, a random secret is used when empty so sessions end on restart
+	JWTSecret string
+	// SessionDomain is the domain shown in the wallet login message
+	SessionDomain string
+	// AccessTokenTTL and RefreshTokenTTL are the lifetimes of web UI session tokens
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// RateLimitIPRate and RateLimitIPBurst are the token bucket refill rate per second and size per client IP
+	RateLimitIPRate  float64
+	RateLimitIPBurst int
+	// RateLimitKeyRate and RateLimitKeyBurst are the token bucket refill rate per second and size per user, shared by
+	// the user's API keys and sessions
+	RateLimitKeyRate  float64
+	RateLimitKeyBurst int
+	// RateLimitRegisterRate and RateLimitRegisterBurst are the refill rate per second and size of the registration
+	// bucket per client IP
+	RateLimitRegisterRate  float64
+	RateLimitRegisterBurst int
+	// RateLimitOrderWeight, RateLimitCancelWeight and RateLimitMarketDataWeight are the tokens a request takes
+	RateLimitOrderWeight      int
+	RateLimitCancelWeight     int
+	RateLimitMarketDataWeight int
+	// TrustedProxies are the CIDR ranges of the proxies in front of the server, X-Forwarded-For is only read from
+	// them. The client IP is the connection address when it is empty.
+	TrustedProxies []string
 }
 
 // LoadConfig loads configuration from the given file path
@@ -24,8 +94,45 @@ func LoadConfig(configPath string) (*Config, error) {
 	}
 
 	return &Config{
-		ExchangePrivateKey: viper.GetString("ExchangePrivateKey"),
+		KeystoreDir:        viper.GetString("KeystoreDir"),
+		KeystorePassphrase: viper.GetString("KeystorePassphrase"),
+		ExchangeAddress:    viper.GetString("ExchangeAddress"),
+		HDSeedFile:         viper.GetString("HDSeedFile"),
 		ETHHost:            viper.GetString("ETHHost"),
 		ServerPort:         viper.GetString("ServerPort"),
+		GRPCPort:           viper.GetString("GRPCPort"),
+		FIXPort:            viper.GetString("FIXPort"),
+		FIXCompID:          viper.GetString("FIXCompID"),
+		FIXStorePath:       viper.GetString("FIXStorePath"),
+
+		DatabaseURL:   viper.GetString("DatabaseURL"),
+		DatabasePath:  viper.GetString("DatabasePath"),
+		TradeStoreDir: viper.GetString("TradeStoreDir"),
+		TradeRingSize: viper.GetInt("TradeRingSize"),
+
+		ColdWalletAddress:      viper.GetString("ColdWalletAddress"),
+		HotWalletLowWatermark:  viper.GetString("HotWalletLowWatermark"),
+		HotWalletHighWatermark: viper.GetString("HotWalletHighWatermark"),
+		TreasuryInterval:       viper.GetDuration("TreasuryInterval"),
+
+		APIKeyReplayWindow: viper.GetDuration("APIKeyReplayWindow"),
+		AdminAPIKey:        viper.GetString("AdminAPIKey"),
+		AdminAPISecret:     viper.GetString("AdminAPISecret"),
+
+		JWTSecret:       viper.GetString("JWTSecret"),
+		SessionDomain:   viper.GetString("SessionDomain"),
+		AccessTokenTTL:  viper.GetDuration("AccessTokenTTL"),
+		RefreshTokenTTL: viper.GetDuration("RefreshTokenTTL"),
+
+		RateLimitIPRate:           viper.GetFloat64("RateLimitIPRate"),
+		RateLimitIPBurst:          viper.GetInt("RateLimitIPBurst"),
+		RateLimitKeyRate:          viper.GetFloat64("RateLimitKeyRate"),
+		RateLimitKeyBurst:         viper.GetInt("RateLimitKeyBurst"),
+		RateLimitRegisterRate:     viper.GetFloat64("RateLimitRegisterRate"),
+		RateLimitRegisterBurst:    viper.GetInt("RateLimitRegisterBurst"),
+		RateLimitOrderWeight:      viper.GetInt("RateLimitOrderWeight"),
+		RateLimitCancelWeight:     viper.GetInt("RateLimitCancelWeight"),
+		RateLimitMarketDataWeight: viper.GetInt("RateLimitMarketDataWeight"),
+		TrustedProxies:            viper.GetStringSlice("TrustedProxies"),
 	}, nil
 }
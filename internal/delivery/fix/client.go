@@ -0,0 +1,217 @@
+package gateway
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/fix"
+)
+
+const (
+	// requestBufferSize is how many order entry messages may wait while the previous ones are placed
+	requestBufferSize = 64
+	// reportBufferSize is how many execution reports may wait for a slow session before it is logged out
+	reportBufferSize = 1024
+)
+
+// client serves the order entry of a logged on session. Requests and execution reports are handled by one goroutine,
+// so the state of the client's orders needs no locking.
+type client struct {
+	gateway  *Gateway
+	exchange *exchanges.Exchange
+	session  *fix.Session
+	userID   uint64
+
+	requests chan *fix.Message
+	reports  *reportSink
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// orders are the client's open orders by exchange order ID
+	orders map[uint64]*order
+	// cancels are the ClOrdIDs of the OrderCancelRequests waiting for the cancellation of their order
+	cancels map[uint64]string
+	// replaced are the orders cancelled by an OrderCancelReplaceRequest, their cancellation is not reported
+	replaced map[uint64]bool
+	// replacements are the new orders of OrderCancelReplaceRequests by exchange order ID
+	replacements map[uint64]*order
+}
+
+// order is what the client knows about one of its orders. Quantities follow FIX: OrderQty is the cumulative plus the
+// open quantity, a replacement continues the quantities of the order it replaced.
+type order struct {
+	id          uint64
+	clOrdID     string
+	origClOrdID string
+	market      exchanges.Market
+	orderType   exchanges.OrderType
+	isBid       bool
+	price       float64
+	cumQty      float64
+	notional    float64
+}
+
+func newClient(g *Gateway, s *fix.Session, userID uint64) *client {
+	return &client{
+		gateway:      g,
+		exchange:     g.exchange,
+		session:      s,
+		userID:       userID,
+		requests:     make(chan *fix.Message, requestBufferSize),
+		reports:      newReportSink(),
+		done:         make(chan struct{}),
+		orders:       make(map[uint64]*order),
+		cancels:      make(map[uint64]string),
+		replaced:     make(map[uint64]bool),
+		replacements: make(map[uint64]*order),
+	}
+}
+
+// start loads the open orders of the user, subscribes to its execution reports and starts serving the session
+func (c *client) start() {
+	c.exchange.SubscribeUser(c.userID, c.reports)
+
+	open, err := c.exchange.GetUserOrders(c.userID)
+	if err == nil {
+		for _, r := range append(open.Bids, open.Asks...) {
+			c.orders[r.ID] = &order{
+				id:        r.ID,
+				clOrdID:   r.ClientOrderID,
+				market:    r.Market,
+				orderType: r.Type,
+				isBid:     r.IsBid,
+				price:     r.Price,
+				cumQty:    r.FilledAmount,
+				notional:  r.FilledAmount * r.AvgFillPrice,
+			}
+		}
+	}
+
+	go c.run()
+}
+
+func (c *client) stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// enqueue hands a message to the client, it waits while the client is busy with earlier ones
+func (c *client) enqueue(msg *fix.Message) {
+	select {
+	case c.requests <- msg:
+	case <-c.done:
+	}
+}
+
+func (c *client) run() {
+	defer c.exchange.UnsubscribeUser(c.userID, c.reports)
+
+	for {
+		select {
+		case msg := <-c.requests:
+			// The reports of earlier requests are sent first, a request may refer to the orders they announce
+			c.drainReports()
+			c.handle(msg)
+		case report := <-c.reports.send:
+			c.report(report)
+		case <-c.reports.done:
+			log.Printf("fix: %s: logging out, the session cannot keep up with its execution reports", c.session.ID())
+			go c.session.Logout("too many pending execution reports")
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *client) drainReports() {
+	for {
+		select {
+		case report := <-c.reports.send:
+			c.report(report)
+		default:
+			return
+		}
+	}
+}
+
+func (c *client) handle(msg *fix.Message) {
+	defer c.recoverRequest(msg)
+
+	switch msg.Type() {
+	case fix.MsgTypeNewOrderSingle:
+		c.newOrder(msg)
+	case fix.MsgTypeOrderCancelRequest:
+		c.cancelOrder(msg)
+	case fix.MsgTypeOrderCancelReplaceRequest:
+		c.replaceOrder(msg)
+	default:
+		c.send(businessReject(msg, businessRejectUnsupportedMsgType, "unsupported message type"))
+	}
+}
+
+// recoverRequest turns a panic while handling the request into a business reject, the session keeps serving the
+// following requests
+func (c *client) recoverRequest(msg *fix.Message) {
+	if r := recover(); r != nil {
+		log.Printf("fix: %s: %s: panic: %v\n%s", c.session.ID(), msg.Type(), r, debug.Stack())
+		c.send(businessReject(msg, businessRejectOther, "internal error"))
+	}
+}
+
+// recoverReport logs a panic while sending the execution report, the report is dropped
+func (c *client) recoverReport(r *exchanges.ExecutionReport) {
+	if p := recover(); p != nil {
+		log.Printf("fix: %s: execution report of order %d: panic: %v\n%s", c.session.ID(), r.OrderID, p, debug.Stack())
+	}
+}
+
+// send sends a message on the session, messages of a session that just ended are dropped
+func (c *client) send(msg *fix.Message) {
+	if err := c.session.Send(msg); err != nil && err != fix.ErrNotLoggedOn {
+		log.Printf("fix: %s: failed to send %s: %v", c.session.ID(), msg.Type(), err)
+	}
+}
+
+// reportSink queues the execution reports of the user, it is a marketdata.Sink
+type reportSink struct {
+	send chan *exchanges.ExecutionReport
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newReportSink() *reportSink {
+	return &reportSink{
+		send: make(chan *exchanges.ExecutionReport, reportBufferSize),
+		done: make(chan struct{}),
+	}
+}
+
+// Send queues an execution report without blocking, balance updates are not part of FIX order entry. A full queue
+// ends the subscription.
+func (s *reportSink) Send(msg interface{}) bool {
+	report, ok := msg.(*exchanges.ExecutionReport)
+	if !ok {
+		return true
+	}
+
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+
+	select {
+	case s.send <- report:
+		return true
+	default:
+		s.closeOnce.Do(func() {
+			close(s.done)
+		})
+		return false
+	}
+}
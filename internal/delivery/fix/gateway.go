@@ -0,0 +1,110 @@
+// Package gateway serves FIX 4.4 order entry on the exchange. Institutional clients log on with an API key holding
+// the trade permission, send NewOrderSingle, OrderCancelRequest and OrderCancelReplaceRequest and receive the
+// execution reports of all their orders, whichever API placed them.
+package gateway
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/fix"
+)
+
+// ErrTradePermission refuses the logon of API keys without the trade permission
+var ErrTradePermission = errors.New("API key lacks the trade permission")
+
+// Gateway maps FIX sessions onto the exchange, it is a fix.Application. A client's SenderCompID is its API key and
+// the Logon carries a signature in RawData, see SignLogon.
+type Gateway struct {
+	exchange *exchanges.Exchange
+	verifier *auth.Verifier
+	compID   string
+
+	mu      sync.Mutex
+	clients map[*fix.Session]*client
+	// lastExecID numbers the execution reports, it starts at the clock so IDs stay unique across restarts
+	lastExecID uint64
+}
+
+// New creates the gateway for the acceptor with the CompID. Logons are checked by verifier, sharing it with the other
+// APIs makes a signature usable only once on any of them.
+func New(exchange *exchanges.Exchange, verifier *auth.Verifier, compID string) *Gateway {
+	return &Gateway{
+		exchange:   exchange,
+		verifier:   verifier,
+		compID:     compID,
+		clients:    make(map[*fix.Session]*client),
+		lastExecID: uint64(time.Now().UnixNano()),
+	}
+}
+
+// SignLogon returns the RawData of a Logon sent at sendingTime to the acceptor with the CompID, it is the hex
+// HMAC-SHA256 of the SendingTime in unix milliseconds, the MsgType A and the TargetCompID, see auth.Sign
+func SignLogon(secret string, sendingTime time.Time, targetCompID string) string {
+	return auth.Sign(secret, sendingTime.UnixMilli(), fix.MsgTypeLogon, targetCompID, nil)
+}
+
+// FromLogon authenticates the API key in the SenderCompID with the signature in RawData
+func (g *Gateway) FromLogon(s *fix.Session, logon *fix.Message) error {
+	sendingTime, err := logon.Time(fix.TagSendingTime)
+	if err != nil {
+		return auth.ErrInvalidTimestamp
+	}
+
+	key, err := g.verifier.Verify(s.ID().TargetCompID, strconv.FormatInt(sendingTime.UnixMilli(), 10),
+		logon.Get(fix.TagRawData), fix.MsgTypeLogon, g.compID, nil)
+	if err != nil {
+		return err
+	}
+	if !key.Permissions.Has(auth.PermissionTrade) {
+		return ErrTradePermission
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.clients[s] = newClient(g, s, key.UserID)
+
+	return nil
+}
+
+// OnLogon starts serving the client of the session
+func (g *Gateway) OnLogon(s *fix.Session) {
+	if c := g.client(s); c != nil {
+		c.start()
+	}
+}
+
+// FromApp queues an order entry message of the client
+func (g *Gateway) FromApp(s *fix.Session, msg *fix.Message) {
+	if c := g.client(s); c != nil {
+		c.enqueue(msg)
+	}
+}
+
+// OnLogout stops serving the client of the session
+func (g *Gateway) OnLogout(s *fix.Session) {
+	g.mu.Lock()
+	c := g.clients[s]
+	delete(g.clients, s)
+	g.mu.Unlock()
+
+	if c != nil {
+		c.stop()
+	}
+}
+
+func (g *Gateway) client(s *fix.Session) *client {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.clients[s]
+}
+
+func (g *Gateway) nextExecID() string {
+	return strconv.FormatUint(atomic.AddUint64(&g.lastExecID, 1), 10)
+}
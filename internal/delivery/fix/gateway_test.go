@@ -0,0 +1,322 @@
+package gateway
+
+import (
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/fix"
+)
+
+type fakeSigner struct{ address common.Address }
+
+func (s fakeSigner) Address() common.Address { return s.address }
+func (s fakeSigner) SignTx(tx *types.Transaction, _ *big.Int) (*types.Transaction, error) {
+	return tx, nil
+}
+
+// testGateway serves FIX order entry on an exchange with users 1 and 2, who hold funds of every asset
+type testGateway struct {
+	exchange *exchanges.Exchange
+	keys     *auth.KeyStore
+	addr     string
+}
+
+func newTestGateway(t *testing.T) *testGateway {
+	ex, err := exchanges.New(fakeSigner{address: common.HexToAddress("0xff")}, nil, nil)
+	require.NoError(t, err)
+	for _, id := range []uint64{1, 2} {
+		user, err := models.NewUser(fakeSigner{address: common.BigToAddress(new(big.Int).SetUint64(id))}, id)
+		require.NoError(t, err)
+		require.NoError(t, ex.AddUser(user))
+		for _, asset := range []string{string(exchanges.MarketETH), string(exchanges.MarketBTC), exchanges.QuoteAsset} {
+			ex.Ledger.Credit(id, asset, 1_000_000)
+		}
+	}
+
+	keys := auth.NewKeyStore()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	acceptor := fix.NewAcceptor(fix.AcceptorConfig{CompID: "EXCHANGE"}, New(ex, auth.NewVerifier(keys, time.Minute), "EXCHANGE"))
+	go acceptor.Serve(l)
+	t.Cleanup(func() { acceptor.Close() })
+
+	return &testGateway{exchange: ex, keys: keys, addr: l.Addr().String()}
+}
+
+// testClient is a FIX initiator recording the messages it receives
+type testClient struct {
+	session  *fix.Session
+	messages chan *fix.Message
+}
+
+func (c *testClient) FromLogon(*fix.Session, *fix.Message) error { return nil }
+func (c *testClient) OnLogon(*fix.Session)                       {}
+func (c *testClient) FromApp(_ *fix.Session, msg *fix.Message)   { c.messages <- msg }
+func (c *testClient) OnLogout(*fix.Session)                      {}
+
+// dial logs on with the API key, the session is kept in store
+func (g *testGateway) dial(key *auth.APIKey, store fix.Store) (*testClient, error) {
+	client := &testClient{messages: make(chan *fix.Message, 100)}
+	config := fix.InitiatorConfig{
+		SenderCompID: key.Key,
+		TargetCompID: "EXCHANGE",
+		HeartBtInt:   30 * time.Second,
+		Store:        store,
+		Logon: func(logon *fix.Message) {
+			sendingTime, _ := logon.Time(fix.TagSendingTime)
+			signature := SignLogon(key.Secret, sendingTime, "EXCHANGE")
+			logon.SetInt(fix.TagRawDataLength, int64(len(signature))).Set(fix.TagRawData, signature)
+		},
+	}
+
+	s, err := fix.Dial(g.addr, config, client)
+	if err != nil {
+		return nil, err
+	}
+	client.session = s
+	return client, nil
+}
+
+func (g *testGateway) trader(t *testing.T, userID uint64) *testClient {
+	t.Helper()
+	key, err := g.keys.Issue(userID, auth.DefaultPermissions...)
+	require.NoError(t, err)
+	client, err := g.dial(key, nil)
+	require.NoError(t, err)
+	t.Cleanup(client.session.Close)
+	return client
+}
+
+func (c *testClient) send(t *testing.T, msg *fix.Message) {
+	t.Helper()
+	require.NoError(t, c.session.Send(msg))
+}
+
+// next returns the next message of the type
+func (c *testClient) next(t *testing.T, msgType string) *fix.Message {
+	t.Helper()
+	select {
+	case msg := <-c.messages:
+		require.Equal(t, msgType, msg.Type(), msg.String())
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s received", msgType)
+		return nil
+	}
+}
+
+func limitOrder(clOrdID, side string, qty, price float64) *fix.Message {
+	return fix.NewMessage(fix.MsgTypeNewOrderSingle).
+		Set(fix.TagClOrdID, clOrdID).
+		Set(fix.TagSymbol, "ETH").
+		Set(fix.TagSide, side).
+		Set(fix.TagOrdType, ordTypeLimit).
+		SetFloat(fix.TagOrderQty, qty).
+		SetFloat(fix.TagPrice, price).
+		SetTime(fix.TagTransactTime, time.Now())
+}
+
+func TestLogon(t *testing.T) {
+	g := newTestGateway(t)
+
+	// Test case 1: a signed logon with a trading key is accepted
+	key, err := g.keys.Issue(1, auth.DefaultPermissions...)
+	require.NoError(t, err)
+	client, err := g.dial(key, nil)
+	require.NoError(t, err)
+	client.session.Logout("")
+
+	// Test case 2: a wrong signature is refused
+	forged := *key
+	forged.Secret = "not the secret"
+	_, err = g.dial(&forged, nil)
+	require.ErrorIs(t, err, fix.ErrLogonRefused)
+	require.Contains(t, err.Error(), auth.ErrInvalidSignature.Error())
+
+	// Test case 3: keys without the trade permission are refused
+	readOnly, err := g.keys.Issue(1, auth.PermissionRead)
+	require.NoError(t, err)
+	_, err = g.dial(readOnly, nil)
+	require.ErrorIs(t, err, fix.ErrLogonRefused)
+	require.Contains(t, err.Error(), ErrTradePermission.Error())
+}
+
+func TestOrderEntry(t *testing.T) {
+	g := newTestGateway(t)
+	client := g.trader(t, 1)
+
+	// Test case 1: a limit order is acknowledged with the exchange's order ID
+	client.send(t, limitOrder("order-1", sideSell, 3, 100))
+	ack := client.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeNew, ack.Get(fix.TagExecType))
+	require.Equal(t, ordStatusNew, ack.Get(fix.TagOrdStatus))
+	require.Equal(t, "order-1", ack.Get(fix.TagClOrdID))
+	require.Equal(t, "3", ack.Get(fix.TagLeavesQty))
+	orderID, err := ack.Uint(fix.TagOrderID)
+	require.NoError(t, err)
+	require.NotZero(t, orderID)
+
+	orders, err := g.exchange.GetUserOrders(1)
+	require.NoError(t, err)
+	require.Len(t, orders.Asks, 1)
+	require.Equal(t, "order-1", orders.Asks[0].ClientOrderID)
+
+	// Test case 2: invalid orders and duplicate ClOrdIDs are rejected
+	client.send(t, limitOrder("order-2", sideSell, 0, 100))
+	rejected := client.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeRejected, rejected.Get(fix.TagExecType))
+	require.Equal(t, noOrderID, rejected.Get(fix.TagOrderID))
+	require.NotEmpty(t, rejected.Get(fix.TagText))
+
+	client.send(t, limitOrder("order-1", sideSell, 1, 100))
+	rejected = client.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeRejected, rejected.Get(fix.TagExecType))
+	require.Equal(t, "6", rejected.Get(fix.TagOrdRejReason))
+
+	unknown := limitOrder("order-3", sideSell, 1, 100).Set(fix.TagSymbol, "DOGE")
+	client.send(t, unknown)
+	rejected = client.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeRejected, rejected.Get(fix.TagExecType))
+	require.Equal(t, "1", rejected.Get(fix.TagOrdRejReason))
+	require.Equal(t, "order-3", rejected.Get(fix.TagClOrdID))
+
+	// Test case 3: a replace amends the order, the new order is reported as Replaced
+	client.send(t, fix.NewMessage(fix.MsgTypeOrderCancelReplaceRequest).
+		Set(fix.TagClOrdID, "order-1-replace").
+		Set(fix.TagOrigClOrdID, "order-1").
+		Set(fix.TagSymbol, "ETH").
+		Set(fix.TagSide, sideSell).
+		Set(fix.TagOrdType, ordTypeLimit).
+		SetFloat(fix.TagOrderQty, 2).
+		SetFloat(fix.TagPrice, 101).
+		SetTime(fix.TagTransactTime, time.Now()))
+	replaced := client.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeReplaced, replaced.Get(fix.TagExecType))
+	require.Equal(t, "order-1-replace", replaced.Get(fix.TagClOrdID))
+	require.Equal(t, "order-1", replaced.Get(fix.TagOrigClOrdID))
+	require.Equal(t, "101", replaced.Get(fix.TagPrice))
+	require.Equal(t, "2", replaced.Get(fix.TagOrderQty))
+	require.NotEqual(t, ack.Get(fix.TagOrderID), replaced.Get(fix.TagOrderID))
+
+	// Test case 4: a cancel of an unknown order is refused
+	client.send(t, fix.NewMessage(fix.MsgTypeOrderCancelRequest).
+		Set(fix.TagClOrdID, "cancel-1").
+		Set(fix.TagOrigClOrdID, "order-1").
+		Set(fix.TagSymbol, "ETH").
+		Set(fix.TagSide, sideSell).
+		SetTime(fix.TagTransactTime, time.Now()))
+	cxlReject := client.next(t, fix.MsgTypeOrderCancelReject)
+	require.Equal(t, "cancel-1", cxlReject.Get(fix.TagClOrdID))
+	require.Equal(t, cxlRejResponseToCancel, cxlReject.Get(fix.TagCxlRejResponseTo))
+	require.Equal(t, "1", cxlReject.Get(fix.TagCxlRejReason))
+
+	// Test case 5: the replacement is cancelled by its ClOrdID
+	client.send(t, fix.NewMessage(fix.MsgTypeOrderCancelRequest).
+		Set(fix.TagClOrdID, "cancel-2").
+		Set(fix.TagOrigClOrdID, "order-1-replace").
+		Set(fix.TagSymbol, "ETH").
+		Set(fix.TagSide, sideSell).
+		SetTime(fix.TagTransactTime, time.Now()))
+	cancelled := client.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeCanceled, cancelled.Get(fix.TagExecType))
+	require.Equal(t, "cancel-2", cancelled.Get(fix.TagClOrdID))
+	require.Equal(t, "order-1-replace", cancelled.Get(fix.TagOrigClOrdID))
+	require.Equal(t, "0", cancelled.Get(fix.TagLeavesQty))
+
+	orders, err = g.exchange.GetUserOrders(1)
+	require.NoError(t, err)
+	require.Empty(t, orders.Asks)
+
+	// Test case 6: unsupported application messages get a business reject
+	client.send(t, fix.NewMessage("V").Set(fix.TagText, "market data please"))
+	businessReject := client.next(t, fix.MsgTypeBusinessReject)
+	require.Equal(t, "V", businessReject.Get(fix.TagRefMsgType))
+}
+
+func TestFills(t *testing.T) {
+	g := newTestGateway(t)
+	maker := g.trader(t, 1)
+	taker := g.trader(t, 2)
+
+	maker.send(t, limitOrder("ask", sideSell, 5, 100))
+	maker.next(t, fix.MsgTypeExecutionReport)
+
+	// Test case 1: both sides of a match receive a Trade report
+	taker.send(t, fix.NewMessage(fix.MsgTypeNewOrderSingle).
+		Set(fix.TagClOrdID, "buy").
+		Set(fix.TagSymbol, "ETH").
+		Set(fix.TagSide, sideBuy).
+		Set(fix.TagOrdType, ordTypeMarket).
+		SetFloat(fix.TagOrderQty, 2).
+		SetTime(fix.TagTransactTime, time.Now()))
+	require.Equal(t, execTypeNew, taker.next(t, fix.MsgTypeExecutionReport).Get(fix.TagExecType))
+	takerFill := taker.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeTrade, takerFill.Get(fix.TagExecType))
+	require.Equal(t, ordStatusFilled, takerFill.Get(fix.TagOrdStatus))
+	require.Equal(t, "buy", takerFill.Get(fix.TagClOrdID))
+	require.Equal(t, "2", takerFill.Get(fix.TagLastQty))
+	require.Equal(t, "100", takerFill.Get(fix.TagLastPx))
+	require.Equal(t, "100", takerFill.Get(fix.TagAvgPx))
+
+	makerFill := maker.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeTrade, makerFill.Get(fix.TagExecType))
+	require.Equal(t, ordStatusPartiallyFilled, makerFill.Get(fix.TagOrdStatus))
+	require.Equal(t, "ask", makerFill.Get(fix.TagClOrdID))
+	require.Equal(t, "2", makerFill.Get(fix.TagCumQty))
+	require.Equal(t, "3", makerFill.Get(fix.TagLeavesQty))
+
+	// Test case 2: a replace keeps the filled quantity, OrderQty counts it
+	maker.send(t, fix.NewMessage(fix.MsgTypeOrderCancelReplaceRequest).
+		Set(fix.TagClOrdID, "ask-2").
+		Set(fix.TagOrigClOrdID, "ask").
+		SetFloat(fix.TagOrderQty, 4).
+		SetTime(fix.TagTransactTime, time.Now()))
+	replaced := maker.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeReplaced, replaced.Get(fix.TagExecType))
+	require.Equal(t, ordStatusPartiallyFilled, replaced.Get(fix.TagOrdStatus))
+	require.Equal(t, "2", replaced.Get(fix.TagCumQty))
+	require.Equal(t, "2", replaced.Get(fix.TagLeavesQty))
+	require.Equal(t, "4", replaced.Get(fix.TagOrderQty))
+}
+
+func TestReconnect(t *testing.T) {
+	g := newTestGateway(t)
+	key, err := g.keys.Issue(1, auth.DefaultPermissions...)
+	require.NoError(t, err)
+	store := fix.NewMemoryStore()
+
+	client, err := g.dial(key, store)
+	require.NoError(t, err)
+	client.send(t, limitOrder("resting", sideBuy, 1, 90))
+	client.next(t, fix.MsgTypeExecutionReport)
+	client.session.Logout("")
+	<-client.session.Done()
+
+	// Test case 1: the next logon continues the sequence numbers, its signature differs by the SendingTime
+	sender, target := client.session.SeqNums()
+	time.Sleep(2 * time.Millisecond)
+	client, err = g.dial(key, store)
+	require.NoError(t, err)
+	defer client.session.Close()
+	nextSender, nextTarget := client.session.SeqNums()
+	require.Equal(t, sender+1, nextSender)
+	require.Equal(t, target+1, nextTarget)
+
+	// Test case 2: orders of earlier sessions are known by their ClOrdID
+	client.send(t, fix.NewMessage(fix.MsgTypeOrderCancelRequest).
+		Set(fix.TagClOrdID, "cancel").
+		Set(fix.TagOrigClOrdID, "resting").
+		SetTime(fix.TagTransactTime, time.Now()))
+	cancelled := client.next(t, fix.MsgTypeExecutionReport)
+	require.Equal(t, execTypeCanceled, cancelled.Get(fix.TagExecType))
+	require.Equal(t, "resting", cancelled.Get(fix.TagOrigClOrdID))
+	require.Equal(t, "90", cancelled.Get(fix.TagPrice))
+}
@@ -0,0 +1,429 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/fix"
+)
+
+// Values of the FIX enumerations the gateway uses
+const (
+	sideBuy  = "1"
+	sideSell = "2"
+
+	ordTypeMarket = "1"
+	ordTypeLimit  = "2"
+
+	execTypeNew      = "0"
+	execTypeCanceled = "4"
+	execTypeReplaced = "5"
+	execTypeRejected = "8"
+	execTypeExpired  = "C"
+	execTypeTrade    = "F"
+
+	ordStatusNew             = "0"
+	ordStatusPartiallyFilled = "1"
+	ordStatusFilled          = "2"
+	ordStatusCanceled        = "4"
+	ordStatusRejected        = "8"
+	ordStatusExpired         = "C"
+
+	ordRejReasonUnknownSymbol  = 1
+	ordRejReasonDuplicateOrder = 6
+	ordRejReasonOther          = 99
+
+	cxlRejReasonUnknownOrder = 1
+	cxlRejReasonOther        = 99
+
+	cxlRejResponseToCancel  = "1"
+	cxlRejResponseToReplace = "2"
+
+	businessRejectOther              = 0
+	businessRejectUnsupportedMsgType = 3
+)
+
+// noOrderID is the OrderID of reports on orders the exchange never saw
+const noOrderID = "NONE"
+
+// newOrder places a NewOrderSingle, the client learns the outcome from the execution reports of the exchange
+func (c *client) newOrder(msg *fix.Message) {
+	clOrdID := msg.Get(fix.TagClOrdID)
+	if clOrdID == "" {
+		c.session.Reject(msg, fix.RejectReasonRequiredTagMissing, "ClOrdID is required")
+		return
+	}
+
+	req, err := placeRequest(msg)
+	if err != nil {
+		c.send(c.requestRejected(msg, ordRejReasonOther, err.Error()))
+		return
+	}
+	if c.findByClOrdID(clOrdID) != nil {
+		c.send(c.requestRejected(msg, ordRejReasonDuplicateOrder, "ClOrdID "+clOrdID+" is used by an open order"))
+		return
+	}
+	req.UserID = c.userID
+	req.ClientOrderID = clOrdID
+	if err := req.Validate(); err != nil {
+		c.send(c.requestRejected(msg, ordRejReasonOther, err.Error()))
+		return
+	}
+
+	// The exchange reports the outcome through the execution reports, a refused order included
+	c.exchange.PlaceOrder(req)
+}
+
+// placeRequest maps a NewOrderSingle onto an order of the exchange
+func placeRequest(msg *fix.Message) (*exchanges.PlaceOrderRequest, error) {
+	req := &exchanges.PlaceOrderRequest{Market: exchanges.Market(msg.Get(fix.TagSymbol))}
+	if req.Market == "" {
+		return nil, errors.New("Symbol is required")
+	}
+
+	switch msg.Get(fix.TagSide) {
+	case sideBuy:
+		req.IsBid = true
+	case sideSell:
+	default:
+		return nil, fmt.Errorf("unsupported Side %q", msg.Get(fix.TagSide))
+	}
+
+	switch msg.Get(fix.TagOrdType) {
+	case ordTypeMarket:
+		req.Type = exchanges.MarketOrder
+	case ordTypeLimit:
+		req.Type = exchanges.LimitOrder
+	default:
+		return nil, fmt.Errorf("unsupported OrdType %q", msg.Get(fix.TagOrdType))
+	}
+
+	var err error
+	if req.Amount, err = msg.Float(fix.TagOrderQty); err != nil {
+		return nil, errors.New("OrderQty is required")
+	}
+	if req.Type == exchanges.LimitOrder {
+		if req.Price, err = msg.Float(fix.TagPrice); err != nil {
+			return nil, errors.New("Price is required for limit orders")
+		}
+	}
+
+	return req, nil
+}
+
+// cancelOrder cancels the order of an OrderCancelRequest, the cancellation is reported with the request's ClOrdID
+func (c *client) cancelOrder(msg *fix.Message) {
+	if !c.requireClOrdIDs(msg) {
+		return
+	}
+
+	o := c.findOrder(msg)
+	if o == nil {
+		c.send(cancelReject(msg, cxlRejResponseToCancel, cxlRejReasonUnknownOrder, "unknown order"))
+		return
+	}
+
+	if err := c.exchange.CancelOrder(c.userID, o.id); err != nil {
+		c.send(cancelReject(msg, cxlRejResponseToCancel, cxlRejReason(err), err.Error()))
+		return
+	}
+	c.cancels[o.id] = msg.Get(fix.TagClOrdID)
+}
+
+// replaceOrder amends the price or quantity of a resting limit order with an OrderCancelReplaceRequest. The exchange
+// replaces the order with a new one, which is reported as Replaced with the request's ClOrdID.
+func (c *client) replaceOrder(msg *fix.Message) {
+	if !c.requireClOrdIDs(msg) {
+		return
+	}
+
+	o := c.findOrder(msg)
+	if o == nil {
+		c.send(cancelReject(msg, cxlRejResponseToReplace, cxlRejReasonUnknownOrder, "unknown order"))
+		return
+	}
+	if err := checkReplace(msg, o); err != nil {
+		c.send(cancelReject(msg, cxlRejResponseToReplace, cxlRejReasonOther, err.Error()))
+		return
+	}
+
+	qty, err := msg.Float(fix.TagOrderQty)
+	if err != nil {
+		c.send(cancelReject(msg, cxlRejResponseToReplace, cxlRejReasonOther, "OrderQty is required"))
+		return
+	}
+	amend := &exchanges.AmendOrderRequest{
+		UserID:        c.userID,
+		OrderID:       o.id,
+		ClientOrderID: msg.Get(fix.TagClOrdID),
+		Amount:        qty - o.cumQty,
+	}
+	if msg.Has(fix.TagPrice) {
+		if amend.Price, err = msg.Float(fix.TagPrice); err != nil {
+			c.send(cancelReject(msg, cxlRejResponseToReplace, cxlRejReasonOther, "Price is not a number"))
+			return
+		}
+	}
+	if amend.Amount <= 0 {
+		c.send(cancelReject(msg, cxlRejResponseToReplace, cxlRejReasonOther, "OrderQty must exceed the filled quantity"))
+		return
+	}
+	if err := amend.Validate(); err != nil {
+		c.send(cancelReject(msg, cxlRejResponseToReplace, cxlRejReasonOther, err.Error()))
+		return
+	}
+
+	resp, err := c.exchange.AmendOrder(amend)
+	if err != nil {
+		c.send(cancelReject(msg, cxlRejResponseToReplace, cxlRejReason(err), err.Error()))
+		return
+	}
+
+	c.replaced[o.id] = true
+	c.replacements[resp.OrderID] = &order{
+		clOrdID:     amend.ClientOrderID,
+		origClOrdID: o.clOrdID,
+		cumQty:      o.cumQty,
+		notional:    o.notional,
+	}
+}
+
+// checkReplace refuses changes the exchange cannot amend
+func checkReplace(msg *fix.Message, o *order) error {
+	if o.orderType != exchanges.LimitOrder {
+		return errors.New("only limit orders can be replaced")
+	}
+	if symbol := msg.Get(fix.TagSymbol); symbol != "" && exchanges.Market(symbol) != o.market {
+		return errors.New("Symbol cannot be changed")
+	}
+	if side := msg.Get(fix.TagSide); side != "" && side != sideOf(o.isBid) {
+		return errors.New("Side cannot be changed")
+	}
+	if ordType := msg.Get(fix.TagOrdType); ordType != "" && ordType != ordTypeLimit {
+		return errors.New("OrdType cannot be changed")
+	}
+	return nil
+}
+
+// requireClOrdIDs rejects cancel and replace requests without ClOrdID or OrigClOrdID
+func (c *client) requireClOrdIDs(msg *fix.Message) bool {
+	for _, tag := range []fix.Tag{fix.TagClOrdID, fix.TagOrigClOrdID} {
+		if !msg.Has(tag) {
+			c.session.Reject(msg, fix.RejectReasonRequiredTagMissing, fmt.Sprintf("tag %d is required", tag))
+			return false
+		}
+	}
+	return true
+}
+
+// findOrder returns the open order a cancel or replace request refers to, by OrderID when given and by OrigClOrdID
+// otherwise
+func (c *client) findOrder(msg *fix.Message) *order {
+	if msg.Has(fix.TagOrderID) {
+		id, err := msg.Uint(fix.TagOrderID)
+		if err != nil {
+			return nil
+		}
+		return c.orders[id]
+	}
+	return c.findByClOrdID(msg.Get(fix.TagOrigClOrdID))
+}
+
+func (c *client) findByClOrdID(clOrdID string) *order {
+	if clOrdID == "" {
+		return nil
+	}
+	for _, o := range c.orders {
+		if o.clOrdID == clOrdID {
+			return o
+		}
+	}
+	return nil
+}
+
+func cxlRejReason(err error) int {
+	if errors.Is(err, exchanges.ErrOrderNotFound) {
+		return cxlRejReasonUnknownOrder
+	}
+	return cxlRejReasonOther
+}
+
+// report turns an execution report of the exchange into a FIX ExecutionReport
+func (c *client) report(r *exchanges.ExecutionReport) {
+	defer c.recoverReport(r)
+
+	o, known := c.orders[r.OrderID]
+	if !known {
+		o = &order{
+			id:        r.OrderID,
+			clOrdID:   r.ClientOrderID,
+			market:    r.Market,
+			orderType: r.Type,
+			isBid:     r.IsBid,
+			price:     r.Price,
+		}
+	}
+
+	switch r.Status {
+	case exchanges.ExecAccepted:
+		execType := execTypeNew
+		if replacement, ok := c.replacements[r.OrderID]; ok {
+			delete(c.replacements, r.OrderID)
+			o.origClOrdID = replacement.origClOrdID
+			o.cumQty, o.notional = replacement.cumQty, replacement.notional
+			execType = execTypeReplaced
+		}
+		c.orders[r.OrderID] = o
+
+		status := ordStatusNew
+		if o.cumQty > 0 {
+			status = ordStatusPartiallyFilled
+		}
+		c.send(c.execReport(o, execType, status, o.cumQty+r.Remaining, r.Remaining, r.Timestamp))
+
+	case exchanges.ExecPartiallyFilled, exchanges.ExecFilled:
+		o.cumQty += r.LastAmount
+		o.notional += r.LastAmount * r.LastPrice
+
+		status := ordStatusPartiallyFilled
+		if r.Status == exchanges.ExecFilled {
+			status = ordStatusFilled
+			delete(c.orders, r.OrderID)
+		} else {
+			c.orders[r.OrderID] = o
+		}
+
+		msg := c.execReport(o, execTypeTrade, status, o.cumQty+r.Remaining, r.Remaining, r.Timestamp).
+			SetFloat(fix.TagLastQty, r.LastAmount).
+			SetFloat(fix.TagLastPx, r.LastPrice).
+			SetFloat(fix.TagCommission, r.Fee)
+		c.send(msg)
+
+	case exchanges.ExecCancelled, exchanges.ExecExpired:
+		delete(c.orders, r.OrderID)
+		if c.replaced[r.OrderID] {
+			delete(c.replaced, r.OrderID)
+			return
+		}
+		if clOrdID, ok := c.cancels[r.OrderID]; ok {
+			delete(c.cancels, r.OrderID)
+			o.origClOrdID, o.clOrdID = o.clOrdID, clOrdID
+		}
+
+		execType, status := execTypeCanceled, ordStatusCanceled
+		if r.Status == exchanges.ExecExpired {
+			execType, status = execTypeExpired, ordStatusExpired
+		}
+		c.send(c.execReport(o, execType, status, o.cumQty+r.Remaining, 0, r.Timestamp))
+
+	case exchanges.ExecRejected:
+		msg := c.execReport(o, execTypeRejected, ordStatusRejected, r.Remaining, 0, r.Timestamp).
+			SetInt(fix.TagOrdRejReason, ordRejReason(r.Reason)).
+			Set(fix.TagText, r.Reason)
+		c.send(msg)
+	}
+}
+
+// ordRejReason maps the reason the exchange rejected an order with, the reports only carry its text
+func ordRejReason(reason string) int64 {
+	if strings.HasPrefix(reason, exchanges.ErrUnknownMarket.Error()) {
+		return ordRejReasonUnknownSymbol
+	}
+	return ordRejReasonOther
+}
+
+// execReport builds an ExecutionReport of the order, timestamp is in unix nanoseconds
+func (c *client) execReport(o *order, execType, ordStatus string, orderQty, leavesQty float64, timestamp int64) *fix.Message {
+	msg := fix.NewMessage(fix.MsgTypeExecutionReport).
+		SetUint(fix.TagOrderID, o.id)
+	if o.clOrdID != "" {
+		msg.Set(fix.TagClOrdID, o.clOrdID)
+	}
+	if o.origClOrdID != "" {
+		msg.Set(fix.TagOrigClOrdID, o.origClOrdID)
+	}
+	msg.Set(fix.TagExecID, c.gateway.nextExecID()).
+		Set(fix.TagExecType, execType).
+		Set(fix.TagOrdStatus, ordStatus).
+		Set(fix.TagSymbol, string(o.market)).
+		Set(fix.TagSide, sideOf(o.isBid)).
+		Set(fix.TagOrdType, ordTypeOf(o.orderType)).
+		SetFloat(fix.TagOrderQty, orderQty)
+	if o.orderType == exchanges.LimitOrder {
+		msg.SetFloat(fix.TagPrice, o.price)
+	}
+
+	avgPx := 0.0
+	if o.cumQty > 0 {
+		avgPx = o.notional / o.cumQty
+	}
+	return msg.SetFloat(fix.TagLeavesQty, leavesQty).
+		SetFloat(fix.TagCumQty, o.cumQty).
+		SetFloat(fix.TagAvgPx, avgPx).
+		SetTime(fix.TagTransactTime, time.Unix(0, timestamp))
+}
+
+// requestRejected reports a NewOrderSingle the gateway refused before it reached the exchange, the fields of the
+// request are echoed as sent
+func (c *client) requestRejected(req *fix.Message, reason int64, text string) *fix.Message {
+	msg := fix.NewMessage(fix.MsgTypeExecutionReport).
+		Set(fix.TagOrderID, noOrderID).
+		Set(fix.TagClOrdID, req.Get(fix.TagClOrdID)).
+		Set(fix.TagExecID, c.gateway.nextExecID()).
+		Set(fix.TagExecType, execTypeRejected).
+		Set(fix.TagOrdStatus, ordStatusRejected)
+	for _, tag := range []fix.Tag{fix.TagSymbol, fix.TagSide, fix.TagOrdType, fix.TagOrderQty, fix.TagPrice} {
+		if req.Has(tag) {
+			msg.Set(tag, req.Get(tag))
+		}
+	}
+	return msg.SetInt(fix.TagLeavesQty, 0).
+		SetInt(fix.TagCumQty, 0).
+		SetInt(fix.TagAvgPx, 0).
+		SetInt(fix.TagOrdRejReason, reason).
+		Set(fix.TagText, text).
+		SetTime(fix.TagTransactTime, time.Now())
+}
+
+// cancelReject answers a cancel or replace request that could not be carried out
+func cancelReject(req *fix.Message, responseTo string, reason int, text string) *fix.Message {
+	orderID := req.Get(fix.TagOrderID)
+	if orderID == "" {
+		orderID = noOrderID
+	}
+
+	return fix.NewMessage(fix.MsgTypeOrderCancelReject).
+		Set(fix.TagOrderID, orderID).
+		Set(fix.TagClOrdID, req.Get(fix.TagClOrdID)).
+		Set(fix.TagOrigClOrdID, req.Get(fix.TagOrigClOrdID)).
+		Set(fix.TagOrdStatus, ordStatusRejected).
+		Set(fix.TagCxlRejResponseTo, responseTo).
+		SetInt(fix.TagCxlRejReason, int64(reason)).
+		Set(fix.TagText, text)
+}
+
+// businessReject answers an application message the gateway does not support or failed to handle
+func businessReject(req *fix.Message, reason int, text string) *fix.Message {
+	return fix.NewMessage(fix.MsgTypeBusinessReject).
+		Set(fix.TagRefSeqNum, req.Get(fix.TagMsgSeqNum)).
+		Set(fix.TagRefMsgType, req.Type()).
+		SetInt(fix.TagBusinessRejectReason, int64(reason)).
+		Set(fix.TagText, text)
+}
+
+func sideOf(isBid bool) string {
+	if isBid {
+		return sideBuy
+	}
+	return sideSell
+}
+
+func ordTypeOf(orderType exchanges.OrderType) string {
+	if orderType == exchanges.MarketOrder {
+		return ordTypeMarket
+	}
+	return ordTypeLimit
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+)
+
+// HandleAuthNonce handles the POST /v1/auth/nonce endpoint
+func (h *Handler) HandleAuthNonce(c echo.Context) error {
+	var req exchanges.AuthNonceRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	challenge, err := h.Sessions.NewChallenge(req.Address)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusCreated, challenge)
+}
+
+// HandleAuthLogin handles the POST /v1/auth/login endpoint
+func (h *Handler) HandleAuthLogin(c echo.Context) error {
+	var req exchanges.AuthLoginRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	address, err := h.Sessions.VerifyChallenge(req.Nonce, req.Signature)
+	if err != nil {
+		return apiError(err, http.StatusUnauthorized)
+	}
+
+	user, err := h.Exchange.GetUserByAddress(address)
+	if err != nil {
+		return middleware.NewError(http.StatusUnauthorized, middleware.CodeUnauthenticated, "no user is registered with this address")
+	}
+
+	if !user.IsActive() {
+		return apiError(exchanges.ErrUserNotActive, http.StatusForbidden)
+	}
+
+	tokens, err := h.Sessions.Issue(user.ID, auth.DefaultPermissions)
+	if err != nil {
+		return apiError(err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, tokens)
+}
+
+// HandleAuthRefresh handles the POST /v1/auth/refresh endpoint
+func (h *Handler) HandleAuthRefresh(c echo.Context) error {
+	var req exchanges.AuthRefreshRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	tokens, err := h.Sessions.Refresh(req.RefreshToken, h.checkUserActive)
+	if err != nil {
+		return apiError(err, http.StatusUnauthorized)
+	}
+
+	return c.JSON(http.StatusCreated, tokens)
+}
+
+// HandleAuthLogout handles the POST /v1/auth/logout endpoint
+func (h *Handler) HandleAuthLogout(c echo.Context) error {
+	var req exchanges.AuthRefreshRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	h.Sessions.Revoke(req.RefreshToken)
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// checkUserActive refuses new sessions to users whose account was frozen or closed since they logged in
+func (h *Handler) checkUserActive(userID uint64) error {
+	user, err := h.Exchange.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.IsActive() {
+		return fmt.Errorf("%w: %d is %s", exchanges.ErrUserNotActive, userID, user.Status)
+	}
+
+	return nil
+}
@@ -1,15 +1,49 @@
 package handler
 
 import (
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
 )
 
 // Handler handles HTTP requests
 type Handler struct {
 	Exchange *exchanges.Exchange
+	Keys     *auth.KeyStore
+	Sessions *auth.Sessions
 }
 
 // New creates a new handler
-func New(exchange *exchanges.Exchange) *Handler {
-	return &Handler{Exchange: exchange}
+func New(exchange *exchanges.Exchange, keys *auth.KeyStore, sessions *auth.Sessions) *Handler {
+	return &Handler{Exchange: exchange, Keys: keys, Sessions: sessions}
+}
+
+// authorizeUser reports whether the caller acts on its own account or holds the admin permission
+func authorizeUser(c echo.Context, userID uint64) bool {
+	callerID, ok := middleware.UserID(c)
+	if !ok {
+		return false
+	}
+
+	return callerID == userID || middleware.Permissions(c).Has(auth.PermissionAdmin)
+}
+
+// errForbiddenUser is returned by targetUser when the caller may not act on behalf of the requested user
+var errForbiddenUser = errors.New("cannot access data of another user")
+
+// targetUser returns the user a request is about, the authenticated user unless an admin requests another one
+func targetUser(c echo.Context, requested uint64) (uint64, error) {
+	userID, _ := middleware.UserID(c)
+	if requested == 0 {
+		return userID, nil
+	}
+
+	if !authorizeUser(c, requested) {
+		return 0, errForbiddenUser
+	}
+
+	return requested, nil
 }
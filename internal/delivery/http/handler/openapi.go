@@ -0,0 +1,490 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/marketdata"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/reserves"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/openapi"
+)
+
+// endpoint describes a route of RegisterRoutes in the OpenAPI document, every route needs one
+type endpoint struct {
+	method      string
+	path        string
+	id          string
+	tag         string
+	summary     string
+	description string
+	// request is the bound request, its `param` and `query` fields are the parameters and the others the JSON body
+	request interface{}
+	// response is the body answered with status, nil for none
+	response interface{}
+	status   int
+	// authenticated endpoints take a signed API key request or an access token, and permission if one is given
+	authenticated bool
+	permission    auth.Permission
+	// errors are the codes of the endpoint besides the ones of every endpoint
+	errors []middleware.ErrorCode
+}
+
+var endpoints = []endpoint{
+	{
+		method: http.MethodGet, path: "/v1/books/:market", id: "getDepth", tag: "books",
+		summary:     "Get the aggregated L2 orderbook",
+		description: "Returns up to depth levels per side, grouped into price buckets of grouping when given.",
+		request:     exchanges.GetDepthRequest{}, response: exchanges.DepthResponse{}, status: http.StatusOK,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket},
+	},
+	{
+		method: http.MethodGet, path: "/v1/books/:market/l3", id: "getOrderbookL3", tag: "books",
+		summary:     "Get every resting order",
+		description: "User IDs are only shown to admins.",
+		request:     exchanges.MarketRequest{}, response: exchanges.OrderbookResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionRead,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket},
+	},
+	{
+		method: http.MethodGet, path: "/v1/books/:market/best/bid", id: "getBestBid", tag: "books",
+		summary: "Get the best bid price",
+		request: exchanges.MarketRequest{}, response: exchanges.PriceResponse{}, status: http.StatusOK,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket, middleware.CodeEmptyBook},
+	},
+	{
+		method: http.MethodGet, path: "/v1/books/:market/best/ask", id: "getBestAsk", tag: "books",
+		summary: "Get the best ask price",
+		request: exchanges.MarketRequest{}, response: exchanges.PriceResponse{}, status: http.StatusOK,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket, middleware.CodeEmptyBook},
+	},
+	{
+		method: http.MethodGet, path: "/v1/markets/tickers", id: "getTickers", tag: "markets",
+		summary:  "Get the 24 hour statistics of every market",
+		response: []*marketdata.Ticker24h{}, status: http.StatusOK,
+	},
+	{
+		method: http.MethodGet, path: "/v1/markets/:market/ticker", id: "getTicker", tag: "markets",
+		summary: "Get the 24 hour statistics of a market",
+		request: exchanges.MarketRequest{}, response: marketdata.Ticker24h{}, status: http.StatusOK,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket},
+	},
+	{
+		method: http.MethodGet, path: "/v1/markets/:market/candles", id: "getCandles", tag: "markets",
+		summary:     "Get OHLCV candles",
+		description: "from and to bound the candle open time in unix nanoseconds.",
+		request:     exchanges.GetCandlesRequest{}, response: []*marketdata.Candle{}, status: http.StatusOK,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket, middleware.CodeUnknownInterval},
+	},
+	{
+		method: http.MethodGet, path: "/v1/orders", id: "listOrders", tag: "orders",
+		summary: "List the order history",
+		description: "Newest first. status is a comma separated list, times are unix nanoseconds and the next_cursor " +
+			"of a response is the cursor of the next page. Admins may list another user with user_id.",
+		request: exchanges.ListOrdersRequest{}, response: exchanges.OrderHistoryResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionRead,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket, middleware.CodeInvalidCursor},
+	},
+	{
+		method: http.MethodGet, path: "/v1/orders/:user_id", id: "getOpenOrders", tag: "orders",
+		summary: "Get the open orders of a user",
+		request: exchanges.UserOrdersRequest{}, response: exchanges.GetOrdersResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionRead,
+	},
+	{
+		method: http.MethodPost, path: "/v1/orders", id: "placeOrder", tag: "orders",
+		summary: "Place an order",
+		description: "A limit order answers with its ID, a market order with the resting orders it matched. The " +
+			"execution is reported on the private stream.",
+		request:  exchanges.PlaceOrderRequest{},
+		response: openapi.OneOf{exchanges.PlaceOrderResponse{}, []*exchanges.MatchedOrder{}}, status: http.StatusCreated,
+		authenticated: true, permission: auth.PermissionTrade,
+		errors: []middleware.ErrorCode{
+			middleware.CodeUnknownMarket, middleware.CodeUnknownOrderType, middleware.CodeInsufficientBalance,
+			middleware.CodeInsufficientLiquidity, middleware.CodeUserNotFound, middleware.CodeUserNotActive,
+		},
+	},
+	{
+		method: http.MethodDelete, path: "/v1/orders/:id", id: "cancelOrder", tag: "orders",
+		summary:     "Cancel an order",
+		description: "Admins cancel on behalf of the order owner.",
+		request:     exchanges.CancelOrderRequest{}, response: exchanges.CancelOrderResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionTrade,
+		errors: []middleware.ErrorCode{middleware.CodeOrderNotFound},
+	},
+	{
+		method: http.MethodGet, path: "/v1/fills", id: "listFills", tag: "orders",
+		summary: "List fills",
+		description: "Newest first, times are unix nanoseconds and paginated like the order history. Admins may " +
+			"list another user with user_id.",
+		request: exchanges.ListFillsRequest{}, response: exchanges.FillsResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionRead,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket, middleware.CodeInvalidCursor},
+	},
+	{
+		method: http.MethodGet, path: "/v1/trades/:market", id: "getTrades", tag: "markets",
+		summary: "Get the trade history",
+		description: "Oldest first. Without from_id or start_time the latest limit trades are returned, times are " +
+			"unix nanoseconds.",
+		request: exchanges.GetTradesRequest{}, response: []*trades.Trade{}, status: http.StatusOK,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket},
+	},
+	{
+		method: http.MethodGet, path: "/v1/ws", id: "streamMarketData", tag: "streams",
+		summary: "Stream market data over a WebSocket",
+		description: "Clients send StreamRequest messages to subscribe to the book, trades and ticker channels of a " +
+			"market and get a StreamResponse, then BookMessage, TradeMessage and TickerMessage messages.",
+		status: http.StatusSwitchingProtocols,
+	},
+	{
+		method: http.MethodGet, path: "/v1/ws/private", id: "streamAccount", tag: "streams",
+		summary: "Stream executions and balances over a WebSocket",
+		description: "Streams the ExecutionReport and BalanceUpdate messages of the caller, admins may follow " +
+			"another user with user_id. Browsers pass the access token as access_token.",
+		request: exchanges.UserQuery{}, status: http.StatusSwitchingProtocols,
+		authenticated: true, permission: auth.PermissionRead,
+	},
+	{
+		method: http.MethodPost, path: "/v1/users", id: "registerUser", tag: "users",
+		summary: "Register a user",
+		description: "The response carries the first API key of the user, its secret is never shown again. " +
+			"An address must come with a login challenge of it signed by its wallet.",
+		request: exchanges.RegisterUserRequest{}, response: exchanges.RegisterUserResponse{}, status: http.StatusCreated,
+		errors: []middleware.ErrorCode{middleware.CodeInvalidAddress, middleware.CodeAddressTaken, middleware.CodeUnauthenticated},
+	},
+	{
+		method: http.MethodGet, path: "/v1/users/:id", id: "getUser", tag: "users",
+		summary: "Get a user",
+		request: exchanges.UserRequest{}, response: exchanges.UserResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionRead,
+		errors: []middleware.ErrorCode{middleware.CodeUserNotFound},
+	},
+	{
+		method: http.MethodPut, path: "/v1/users/:id/status", id: "updateUserStatus", tag: "users",
+		summary:     "Change the account status of a user",
+		description: "Freezing or closing an account cancels its open orders.",
+		request:     exchanges.UpdateUserStatusRequest{}, response: exchanges.UserResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionAdmin,
+		errors: []middleware.ErrorCode{middleware.CodeUserNotFound, middleware.CodeInvalidStatus},
+	},
+	{
+		method: http.MethodPut, path: "/v1/users/:id/fee-schedule", id: "updateFeeSchedule", tag: "fees",
+		summary: "Assign a fee schedule to a user",
+		request: exchanges.UpdateFeeScheduleRequest{}, response: exchanges.FeeStatusResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionAdmin,
+		errors: []middleware.ErrorCode{middleware.CodeUserNotFound, middleware.CodeUnknownFeeSchedule},
+	},
+	{
+		method: http.MethodPost, path: "/v1/users/:id/credits", id: "creditBalance", tag: "users",
+		summary:     "Credit an asset to a user",
+		description: "Funds the account without an on-chain deposit, the credit counts towards the reserves liabilities.",
+		request:     exchanges.CreditBalanceRequest{}, response: exchanges.BalanceResponse{}, status: http.StatusCreated,
+		authenticated: true, permission: auth.PermissionAdmin,
+		errors: []middleware.ErrorCode{middleware.CodeUserNotFound, middleware.CodeUnknownMarket, middleware.CodeTradingHalted},
+	},
+	{
+		method: http.MethodGet, path: "/v1/fees", id: "getFees", tag: "fees",
+		summary:     "Get the fee schedule, 30 day volume and current rates",
+		description: "Admins may read another user with user_id.",
+		request:     exchanges.UserQuery{}, response: exchanges.FeeStatusResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionRead,
+		errors: []middleware.ErrorCode{middleware.CodeUserNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/v1/api-keys", id: "createAPIKey", tag: "users",
+		summary:     "Issue an API key",
+		description: "The key gets the permissions of the caller unless fewer are requested.",
+		request:     exchanges.CreateAPIKeyRequest{}, response: exchanges.APIKeyResponse{}, status: http.StatusCreated,
+		authenticated: true,
+	},
+	{
+		method: http.MethodPost, path: "/v1/api-keys/:key/permissions", id: "grantPermissions", tag: "users",
+		summary:     "Grant permissions to an API key",
+		description: "Withdrawals need a key the operator granted withdraw to. The admin permission cannot be granted.",
+		request:     exchanges.GrantPermissionsRequest{}, response: exchanges.APIKeyPermissionsResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionAdmin,
+		errors: []middleware.ErrorCode{middleware.CodeNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/v1/auth/nonce", id: "authNonce", tag: "auth",
+		summary: "Get a wallet login challenge",
+		request: exchanges.AuthNonceRequest{}, response: auth.Challenge{}, status: http.StatusCreated,
+	},
+	{
+		method: http.MethodPost, path: "/v1/auth/login", id: "authLogin", tag: "auth",
+		summary: "Log in with a signed challenge",
+		request: exchanges.AuthLoginRequest{}, response: auth.TokenPair{}, status: http.StatusCreated,
+		errors: []middleware.ErrorCode{middleware.CodeUnauthenticated, middleware.CodeUserNotActive},
+	},
+	{
+		method: http.MethodPost, path: "/v1/auth/refresh", id: "authRefresh", tag: "auth",
+		summary:     "Renew a session",
+		description: "The old refresh token is invalidated.",
+		request:     exchanges.AuthRefreshRequest{}, response: auth.TokenPair{}, status: http.StatusCreated,
+		errors: []middleware.ErrorCode{middleware.CodeUnauthenticated, middleware.CodeUserNotActive},
+	},
+	{
+		method: http.MethodPost, path: "/v1/auth/logout", id: "authLogout", tag: "auth",
+		summary: "End a session",
+		request: exchanges.AuthRefreshRequest{}, status: http.StatusNoContent,
+	},
+	{
+		method: http.MethodGet, path: "/v1/deposits/:user_id/:market", id: "getDepositAddress", tag: "deposits",
+		summary: "Get the deposit address of a user",
+		request: exchanges.DepositAddressRequest{}, response: exchanges.DepositAddressResponse{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionRead,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket, middleware.CodeDepositUnavailable, middleware.CodeUserNotFound},
+	},
+	{
+		method: http.MethodPost, path: "/v1/withdrawals", id: "withdraw", tag: "withdrawals",
+		summary:     "Withdraw ETH to an external wallet",
+		description: "The amount is debited from the available balance and sent from the exchange hot wallet.",
+		request:     exchanges.WithdrawRequest{}, response: exchanges.WithdrawalResponse{}, status: http.StatusCreated,
+		authenticated: true, permission: auth.PermissionWithdraw,
+		errors: []middleware.ErrorCode{
+			middleware.CodeUnknownMarket, middleware.CodeInvalidAddress, middleware.CodeInsufficientBalance,
+			middleware.CodeUserNotActive, middleware.CodeWithdrawalUnavailable,
+		},
+	},
+	{
+		method: http.MethodPost, path: "/v1/reserves", id: "publishReserves", tag: "reserves",
+		summary:  "Publish a proof of reserves snapshot",
+		response: []*exchanges.ReservesReport{}, status: http.StatusCreated,
+		authenticated: true, permission: auth.PermissionAdmin,
+	},
+	{
+		method: http.MethodGet, path: "/v1/reserves", id: "getReserves", tag: "reserves",
+		summary:  "Get the latest proof of reserves snapshot",
+		response: []*exchanges.ReservesReport{}, status: http.StatusOK,
+	},
+	{
+		method: http.MethodGet, path: "/v1/reserves/proof", id: "getReservesProof", tag: "reserves",
+		summary: "Get the Merkle proof of a balance",
+		description: "market is a market or the quote asset USD and defaults to ETH, admins may read the proof of " +
+			"another user with user_id.",
+		request: exchanges.ReservesProofRequest{}, response: reserves.Proof{}, status: http.StatusOK,
+		authenticated: true, permission: auth.PermissionRead,
+		errors: []middleware.ErrorCode{middleware.CodeUnknownMarket},
+	},
+	{
+		method: http.MethodGet, path: "/openapi.json", id: "getOpenAPI", tag: "docs",
+		summary:  "Get this document",
+		response: map[string]interface{}{}, status: http.StatusOK,
+	},
+}
+
+// credentials are the ways to authenticate: the three signed API key headers together, or a bearer access token
+var credentials = []openapi.SecurityRequirement{
+	{"apiKey": {}, "apiTimestamp": {}, "apiSignature": {}},
+	{"accessToken": {}},
+}
+
+// newDocument builds the OpenAPI document of the endpoints
+func newDocument() (*openapi.Document, error) {
+	doc := openapi.New(openapi.Info{
+		Title:   "Cryptocurrency exchange API",
+		Version: "1",
+		Description: "Failed requests answer with an ErrorResponse, clients branch on its code. Every endpoint is " +
+			"rate limited.",
+	})
+
+	doc.Enum(exchanges.MarketETH, exchanges.MarketBTC)
+	doc.Enum(exchanges.MarketOrder, exchanges.LimitOrder)
+	doc.Enum(exchanges.OrderOpen, exchanges.OrderPartiallyFilled, exchanges.OrderFilled, exchanges.OrderCancelled,
+		exchanges.OrderRejected, exchanges.OrderExpired)
+	doc.Enum(exchanges.ExecAccepted, exchanges.ExecPartiallyFilled, exchanges.ExecFilled, exchanges.ExecCancelled,
+		exchanges.ExecRejected, exchanges.ExecExpired)
+	doc.Enum(exchanges.LiquidityMaker, exchanges.LiquidityTaker)
+	doc.Enum(exchanges.StreamSubscribe, exchanges.StreamUnsubscribe)
+	doc.Enum(models.UserActive, models.UserFrozen, models.UserClosed)
+	doc.Enum(trades.SideBuy, trades.SideSell)
+	doc.Enum(marketdata.ChannelBook, marketdata.ChannelTrades, marketdata.ChannelTicker)
+	doc.Enum(marketdata.MessageSnapshot, marketdata.MessageDelta, marketdata.MessageUpdate)
+	doc.Enum(errorCodes()...)
+
+	doc.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: middleware.HeaderAPIKey,
+		Description: "The API key, signed requests carry all three API key headers",
+	}
+	doc.Components.SecuritySchemes["apiTimestamp"] = &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: middleware.HeaderAPITimestamp,
+		Description: "The request time in unix milliseconds",
+	}
+	doc.Components.SecuritySchemes["apiSignature"] = &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: middleware.HeaderAPISignature,
+		Description: "hex(HMAC-SHA256(secret, timestamp + method + request URI + body))",
+	}
+	doc.Components.SecuritySchemes["accessToken"] = &openapi.SecurityScheme{
+		Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+		Description: "A session access token of the wallet login",
+	}
+
+	// Stream messages are no operation bodies, they are described as components only
+	for _, message := range []interface{}{
+		exchanges.StreamRequest{}, exchanges.StreamResponse{}, marketdata.BookMessage{}, marketdata.TradeMessage{},
+		marketdata.TickerMessage{}, exchanges.ExecutionReport{}, exchanges.BalanceUpdate{},
+	} {
+		doc.Schema(message)
+	}
+
+	errorSchema := doc.Schema(middleware.ErrorResponse{})
+	for _, ep := range endpoints {
+		description := ep.description
+		if ep.permission != "" {
+			description = strings.TrimSpace(description + " Requires the " + string(ep.permission) + " permission.")
+		}
+
+		op := &openapi.Operation{
+			OperationID: ep.id,
+			Summary:     ep.summary,
+			Description: description,
+			Tags:        []string{ep.tag},
+			Responses:   make(map[string]*openapi.Response),
+		}
+		if ep.request != nil {
+			op.Parameters = doc.Parameters(ep.request)
+			if body := doc.Body(ep.request); body != nil {
+				op.RequestBody = &openapi.RequestBody{Required: true, Content: jsonContent(body)}
+			}
+		}
+		if ep.authenticated {
+			op.Security = credentials
+		}
+
+		success := &openapi.Response{Description: http.StatusText(ep.status)}
+		if ep.response != nil {
+			success.Content = jsonContent(doc.Schema(ep.response))
+		}
+		op.Responses[strconv.Itoa(ep.status)] = success
+
+		for status, codes := range ep.errorCodes() {
+			op.Responses[strconv.Itoa(status)] = &openapi.Response{
+				Description: strings.Join(codes, ", "),
+				Content:     jsonContent(errorSchema),
+			}
+		}
+
+		if err := doc.Add(ep.method, openapi.PathTemplate(ep.path), op); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// errorCodes returns the error codes of the endpoint by status, including the ones every endpoint may answer with
+func (ep *endpoint) errorCodes() map[int][]string {
+	codes := []middleware.ErrorCode{middleware.CodeRateLimited, middleware.CodeInternal}
+	if ep.request != nil {
+		codes = append(codes, middleware.CodeInvalidRequest)
+	}
+	if ep.authenticated {
+		codes = append(codes, middleware.CodeUnauthenticated, middleware.CodeForbidden)
+	}
+	codes = append(codes, ep.errors...)
+
+	byStatus := make(map[int][]string)
+	for _, code := range codes {
+		status := errorStatus(code)
+		if !contains(byStatus[status], string(code)) {
+			byStatus[status] = append(byStatus[status], string(code))
+		}
+	}
+	return byStatus
+}
+
+// errorStatus is the HTTP status an error code is answered with
+func errorStatus(code middleware.ErrorCode) int {
+	for _, known := range knownErrors {
+		if known.code == code {
+			return known.status
+		}
+	}
+
+	for _, status := range []int{
+		http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusMethodNotAllowed,
+		http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError,
+	} {
+		if middleware.CodeForStatus(status) == code {
+			return status
+		}
+	}
+	return http.StatusBadRequest
+}
+
+func errorCodes() []interface{} {
+	codes := make([]interface{}, len(middleware.ErrorCodes))
+	for i, code := range middleware.ErrorCodes {
+		codes[i] = code
+	}
+	return codes
+}
+
+func jsonContent(schema *openapi.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{echo.MIMEApplicationJSON: {Schema: schema}}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	documentOnce sync.Once
+	documentJSON []byte
+	documentErr  error
+)
+
+// HandleOpenAPI handles the GET /openapi.json endpoint
+func (h *Handler) HandleOpenAPI(c echo.Context) error {
+	documentOnce.Do(func() {
+		var doc *openapi.Document
+		if doc, documentErr = newDocument(); documentErr == nil {
+			documentJSON, documentErr = json.Marshal(doc)
+		}
+	})
+	if documentErr != nil {
+		return documentErr
+	}
+
+	return c.JSONBlob(http.StatusOK, documentJSON)
+}
+
+// swaggerInitializer replaces the initializer of the Swagger UI distribution, which opens an example document
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// HandleDocs handles the GET /docs/* endpoint, it serves the Swagger UI of /openapi.json
+func (h *Handler) HandleDocs(c echo.Context) error {
+	name := c.Param("*")
+	switch name {
+	case "":
+		name = "index.html"
+	case "swagger-initializer.js":
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJavaScript, []byte(swaggerInitializer))
+	}
+
+	return echo.StaticFileHandler(name, swaggerFiles.FS)(c)
+}
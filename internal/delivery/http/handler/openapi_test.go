@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/openapi"
+)
+
+func newTestServer() *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = middleware.HTTPErrorHandler
+
+	authenticate := func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	New(nil, nil, nil).RegisterRoutes(e, authenticate, middleware.NewRateLimiter(middleware.RateLimitConfig{}))
+	return e
+}
+
+func TestEndpointsCoverRoutes(t *testing.T) {
+	e := newTestServer()
+
+	documented := make(map[string]bool)
+	for _, ep := range endpoints {
+		documented[ep.method+" "+ep.path] = true
+	}
+
+	// Test case 1: every registered route is described
+	registered := make(map[string]bool)
+	for _, route := range e.Routes() {
+		if strings.HasPrefix(route.Path, docsPath+"/") {
+			continue
+		}
+
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		require.True(t, documented[key], "route %s has no entry in the OpenAPI document", key)
+	}
+
+	// Test case 2: every description belongs to a registered route
+	for key := range documented {
+		require.True(t, registered[key], "OpenAPI entry %s has no route", key)
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+	doc, err := newDocument()
+	require.NoError(t, err)
+	require.Equal(t, openapi.Version, doc.OpenAPI)
+
+	// Test case 1: every path parameter is declared
+	template := regexp.MustCompile(`{([^}]+)}`)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			declared := make(map[string]bool)
+			for _, param := range op.Parameters {
+				if param.In == "path" {
+					declared[param.Name] = true
+				}
+			}
+			for _, match := range template.FindAllStringSubmatch(path, -1) {
+				require.True(t, declared[match[1]], "%s %s does not declare path parameter %s", method, path, match[1])
+			}
+			require.Equal(t, len(declared), len(template.FindAllString(path, -1)), "%s %s", method, path)
+		}
+	}
+
+	// Test case 2: parameters and bodies come from the request types
+	trades := doc.Operation(http.MethodGet, "/v1/trades/{market}")
+	require.NotNil(t, trades)
+	var names []string
+	for _, param := range trades.Parameters {
+		names = append(names, param.In+":"+param.Name)
+	}
+	require.Equal(t, []string{"path:market", "query:from_id", "query:start_time", "query:end_time", "query:limit"}, names)
+	require.Nil(t, trades.RequestBody)
+
+	placeOrder := doc.Operation(http.MethodPost, "/v1/orders")
+	require.NotNil(t, placeOrder.RequestBody)
+	require.Equal(t, "#/components/schemas/PlaceOrderRequest", placeOrder.RequestBody.Content[echo.MIMEApplicationJSON].Schema.Ref)
+	body := doc.Components.Schemas["PlaceOrderRequest"]
+	require.Contains(t, body.Properties, "is_bid")
+	require.NotContains(t, body.Properties, "UserID")
+	require.NotContains(t, doc.Components.Schemas["UpdateUserStatusRequest"].Properties, "id")
+	require.Len(t, placeOrder.Responses["201"].Content[echo.MIMEApplicationJSON].Schema.OneOf, 2)
+
+	// Test case 3: error codes are listed under their status
+	require.Contains(t, placeOrder.Responses["422"].Description, string(middleware.CodeInsufficientBalance))
+	require.Contains(t, placeOrder.Responses["422"].Description, string(middleware.CodeInsufficientLiquidity))
+	require.Contains(t, placeOrder.Responses["401"].Description, string(middleware.CodeUnauthenticated))
+	require.Contains(t, placeOrder.Responses["429"].Description, string(middleware.CodeRateLimited))
+	require.NotEmpty(t, placeOrder.Security)
+	require.Empty(t, doc.Operation(http.MethodGet, "/v1/books/{market}").Security)
+
+	code := doc.Components.Schemas["ErrorCode"]
+	require.Len(t, code.Enum, len(middleware.ErrorCodes))
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	e := newTestServer()
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// Test case 1: the document is served as JSON
+	rec := serve("/openapi.json")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	require.Equal(t, openapi.Version, doc["openapi"])
+
+	// Test case 2: the Swagger UI opens the document
+	rec = serve("/docs/")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "swagger-initializer.js")
+
+	rec = serve("/docs/swagger-initializer.js")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `url: "/openapi.json"`)
+
+	rec = serve("/docs/swagger-ui-bundle.js")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Test case 3: unknown files are answered with the error envelope
+	rec = serve("/docs/missing.js")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Contains(t, rec.Body.String(), string(middleware.CodeNotFound))
+}
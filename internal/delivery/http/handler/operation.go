@@ -1,105 +1,332 @@
 package handler
 
 import (
-	"encoding/json"
 	"net/http"
-	"strconv"
 
 	"github.com/labstack/echo/v4"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/marketdata"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
 )
 
-// HandleGetMarket handles the GET /books/:market endpoint
+// HandleGetMarket handles the GET /v1/books/:market endpoint. It returns the aggregated L2 orderbook with up to
+// ?depth= levels per side, grouped into price buckets of ?grouping= when given.
 func (h *Handler) HandleGetMarket(c echo.Context) error {
-	market := exchanges.Market(c.Param("market"))
+	var req exchanges.GetDepthRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	orderbook, err := h.Exchange.GetDepth(req.Market, req.Depth, req.Grouping)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, orderbook)
+}
+
+// HandleGetOrderbookL3 handles the GET /v1/books/:market/l3 endpoint. It returns every resting order, user IDs are
+// only shown to admins.
+func (h *Handler) HandleGetOrderbookL3(c echo.Context) error {
+	var req exchanges.MarketRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
 
-	orderbook, err := h.Exchange.GetOrderbook(market)
+	orderbook, err := h.Exchange.GetOrderbook(req.Market)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	if !middleware.Permissions(c).Has(auth.PermissionAdmin) {
+		for _, orders := range [][]*exchanges.Order{orderbook.Asks, orderbook.Bids} {
+			for _, order := range orders {
+				order.UserID = 0
+			}
+		}
 	}
 
 	return c.JSON(http.StatusOK, orderbook)
 }
 
-// HandleGetBestBidLimit handles the GET /books/:market/best/bid endpoint
+// HandleGetBestBidLimit handles the GET /v1/books/:market/best/bid endpoint
 func (h *Handler) HandleGetBestBidLimit(c echo.Context) error {
-	market := exchanges.Market(c.Param("market"))
+	var req exchanges.MarketRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
 
-	price, err := h.Exchange.GetBestBidPrice(market)
+	price, err := h.Exchange.GetBestBidPrice(req.Market)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return apiError(err, http.StatusBadRequest)
 	}
 
 	return c.JSON(http.StatusOK, exchanges.PriceResponse{Price: price})
 }
 
-// HandleGetBestAskLimit handles the GET /books/:market/best/ask endpoint
+// HandleGetBestAskLimit handles the GET /v1/books/:market/best/ask endpoint
 func (h *Handler) HandleGetBestAskLimit(c echo.Context) error {
-	market := exchanges.Market(c.Param("market"))
+	var req exchanges.MarketRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
 
-	price, err := h.Exchange.GetBestAskPrice(market)
+	price, err := h.Exchange.GetBestAskPrice(req.Market)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return apiError(err, http.StatusBadRequest)
 	}
 
 	return c.JSON(http.StatusOK, exchanges.PriceResponse{Price: price})
 }
 
-// HandleGetTrades handles the GET /trades/:market endpoint
+// HandleGetTrades handles the GET /v1/trades/:market endpoint. It returns the latest ?limit= trades oldest first, or
+// the ones from the trade ID ?from_id= or from ?start_time= on, bounded by ?end_time= in unix nanoseconds.
 func (h *Handler) HandleGetTrades(c echo.Context) error {
-	market := exchanges.Market(c.Param("market"))
+	var req exchanges.GetTradesRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
 
-	trades, err := h.Exchange.GetTrades(market)
+	trades, err := h.Exchange.GetTrades(req.Market, trades.Query{
+		FromID:    req.FromID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Limit:     req.Limit,
+	})
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return apiError(err, http.StatusBadRequest)
 	}
 
 	return c.JSON(http.StatusOK, trades)
 }
 
-// HandleGetOrder handles the GET /orders/:userID endpoint
+// HandleGetCandles handles the GET /v1/markets/:market/candles endpoint. ?interval= is one of 1m, 5m, 15m, 1h and
+// 1d, ?from= and ?to= bound the candle open time in unix nanoseconds.
+func (h *Handler) HandleGetCandles(c echo.Context) error {
+	var req exchanges.GetCandlesRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	candles, err := h.Exchange.GetCandles(req.Market, marketdata.Interval(req.Interval), req.From, req.To)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, candles)
+}
+
+// HandleGetTicker handles the GET /v1/markets/:market/ticker endpoint
+func (h *Handler) HandleGetTicker(c echo.Context) error {
+	var req exchanges.MarketRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	ticker, err := h.Exchange.GetTicker(req.Market)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, ticker)
+}
+
+// HandleGetTickers handles the GET /v1/markets/tickers endpoint
+func (h *Handler) HandleGetTickers(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.Exchange.GetTickers())
+}
+
+// HandleGetOrder handles the GET /v1/orders/:user_id endpoint
 func (h *Handler) HandleGetOrder(c echo.Context) error {
-	userIDStr := c.Param("userID")
-	userID, err := strconv.ParseUint(userIDStr, 10, 64)
+	var req exchanges.UserOrdersRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	if !authorizeUser(c, req.UserID) {
+		return apiError(errForbiddenUser, http.StatusForbidden)
+	}
+
+	orders, err := h.Exchange.GetUserOrders(req.UserID)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "invalid user ID"})
+		return apiError(err, http.StatusInternalServerError)
 	}
 
-	orders, err := h.Exchange.GetUserOrders(userID)
+	return c.JSON(http.StatusOK, orders)
+}
+
+// HandleListOrders handles the GET /v1/orders endpoint. It lists the order history of the authenticated user, newest
+// first, filtered by ?status= (comma separated), ?market=, ?start_time= and ?end_time= in unix nanoseconds and
+// paginated with ?limit= and the ?cursor= of the previous page. Admins may list another user with ?user_id=.
+func (h *Handler) HandleListOrders(c echo.Context) error {
+	var req exchanges.ListOrdersRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := targetUser(c, req.UserID)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	orders, err := h.Exchange.ListOrders(req.Filter(userID))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return apiError(err, http.StatusBadRequest)
 	}
 
 	return c.JSON(http.StatusOK, orders)
 }
 
-// HandlePlaceOrder handles the POST /orders endpoint
+// HandleListFills handles the GET /v1/fills endpoint. It lists the fills of the authenticated user, newest first,
+// filtered by ?market=, ?order_id=, ?start_time= and ?end_time= in unix nanoseconds and paginated with ?limit= and
+// the ?cursor= of the previous page. Admins may list another user with ?user_id=.
+func (h *Handler) HandleListFills(c echo.Context) error {
+	var req exchanges.ListFillsRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := targetUser(c, req.UserID)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	fills, err := h.Exchange.ListFills(req.Filter(userID))
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, fills)
+}
+
+// HandlePlaceOrder handles the POST /v1/orders endpoint
 func (h *Handler) HandlePlaceOrder(c echo.Context) error {
-	var placeOrderData exchanges.PlaceOrderRequest
-	if err := json.NewDecoder(c.Request().Body).Decode(&placeOrderData); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "invalid request body"})
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		return middleware.NewError(http.StatusUnauthorized, middleware.CodeUnauthenticated, "unauthenticated")
+	}
+
+	var req exchanges.PlaceOrderRequest
+	if err := bind(c, &req); err != nil {
+		return err
 	}
+	req.UserID = userID
 
-	result, err := h.Exchange.PlaceOrder(&placeOrderData)
+	result, err := h.Exchange.PlaceOrder(&req)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return apiError(err, http.StatusInternalServerError)
 	}
 
 	return c.JSON(http.StatusCreated, result)
 }
 
-// HandleCancelOrder handles the DELETE /orders/:id endpoint
+// HandleCancelOrder handles the DELETE /v1/orders/:id endpoint
 func (h *Handler) HandleCancelOrder(c echo.Context) error {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
+	var req exchanges.CancelOrderRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		return middleware.NewError(http.StatusUnauthorized, middleware.CodeUnauthenticated, "unauthenticated")
+	}
+
+	// Operators cancel on behalf of the order owner
+	if middleware.Permissions(c).Has(auth.PermissionAdmin) {
+		order, err := h.Exchange.GetOrder(req.OrderID)
+		if err != nil {
+			return apiError(err, http.StatusNotFound)
+		}
+		userID = order.UserID
+	}
+
+	if err := h.Exchange.CancelOrder(userID, req.OrderID); err != nil {
+		return apiError(err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, exchanges.CancelOrderResponse{Message: "order cancelled successfully"})
+}
+
+// HandleGetDepositAddress handles the GET /v1/deposits/:user_id/:market endpoint
+func (h *Handler) HandleGetDepositAddress(c echo.Context) error {
+	var req exchanges.DepositAddressRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	if !authorizeUser(c, req.UserID) {
+		return apiError(errForbiddenUser, http.StatusForbidden)
+	}
+
+	address, err := h.Exchange.DepositAddress(req.UserID, req.Market)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, exchanges.DepositAddressResponse{Market: req.Market, Address: address})
+}
+
+// HandleWithdraw handles the POST /v1/withdrawals endpoint
+func (h *Handler) HandleWithdraw(c echo.Context) error {
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		return middleware.NewError(http.StatusUnauthorized, middleware.CodeUnauthenticated, "unauthenticated")
+	}
+
+	var req exchanges.WithdrawRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+	req.UserID = userID
+
+	withdrawal, err := h.Exchange.Withdraw(&req)
+	if err != nil {
+		return apiError(err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, withdrawal)
+}
+
+// HandlePublishReserves handles the POST /v1/reserves endpoint
+func (h *Handler) HandlePublishReserves(c echo.Context) error {
+	reports, err := h.Exchange.PublishReserves()
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]interface{}{"error": "invalid order ID"})
+		return apiError(err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, reports)
+}
+
+// HandleGetReserves handles the GET /v1/reserves endpoint
+func (h *Handler) HandleGetReserves(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.Exchange.GetReserves())
+}
+
+// HandleGetReservesProof handles the GET /v1/reserves/proof endpoint, operators can fetch the proof of any user with
+// ?user_id=
+func (h *Handler) HandleGetReservesProof(c echo.Context) error {
+	var req exchanges.ReservesProofRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := targetUser(c, req.UserID)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	market := req.Market
+	if market == "" {
+		market = exchanges.MarketETH
 	}
 
-	err = h.Exchange.CancelOrder(id)
+	proof, err := h.Exchange.GetReservesProof(userID, market)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
+		return apiError(err, http.StatusNotFound)
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{"message": "order cancelled successfully"})
+	return c.JSON(http.StatusOK, proof)
 }
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/ledger"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/marketdata"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+// validator is a request that checks its own fields after binding
+type validator interface {
+	Validate() error
+}
+
+// bind is where every request is parsed: it fills req from the `param` tagged path parameters, the `query` tagged
+// query parameters and the JSON body, then validates it. Unknown body fields are rejected.
+func bind(c echo.Context, req interface{}) error {
+	binder := &echo.DefaultBinder{}
+	if err := binder.BindPathParams(c, req); err != nil {
+		return invalidParameter("path", err)
+	}
+	if err := binder.BindQueryParams(c, req); err != nil {
+		return invalidParameter("query", err)
+	}
+
+	if c.Request().ContentLength != 0 {
+		decoder := json.NewDecoder(c.Request().Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(req); err != nil {
+			return middleware.NewError(http.StatusBadRequest, middleware.CodeInvalidRequest, "invalid request body: "+err.Error())
+		}
+	}
+
+	if v, ok := req.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return apiError(err, http.StatusBadRequest)
+		}
+	}
+
+	return nil
+}
+
+// invalidParameter is the error of a path or query parameter echo failed to bind
+func invalidParameter(kind string, err error) error {
+	message := "invalid " + kind + " parameter"
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		message = fmt.Sprintf("%s: %v", message, httpErr.Message)
+	}
+	return middleware.NewError(http.StatusBadRequest, middleware.CodeInvalidRequest, message)
+}
+
+// knownErrors are the errors of the exchange clients can act on, with their status and code
+var knownErrors = []struct {
+	err    error
+	status int
+	code   middleware.ErrorCode
+}{
+	{exchanges.ErrUnknownMarket, http.StatusBadRequest, middleware.CodeUnknownMarket},
+	{exchanges.ErrUnknownOrderType, http.StatusBadRequest, middleware.CodeUnknownOrderType},
+	{marketdata.ErrUnknownInterval, http.StatusBadRequest, middleware.CodeUnknownInterval},
+	{exchanges.ErrUnknownFeeSchedule, http.StatusBadRequest, middleware.CodeUnknownFeeSchedule},
+	{ledger.ErrInsufficientBalance, http.StatusUnprocessableEntity, middleware.CodeInsufficientBalance},
+	{exchanges.ErrInsufficientLiquidity, http.StatusUnprocessableEntity, middleware.CodeInsufficientLiquidity},
+	{exchanges.ErrEmptyBook, http.StatusNotFound, middleware.CodeEmptyBook},
+	{exchanges.ErrUserNotFound, http.StatusNotFound, middleware.CodeUserNotFound},
+	{exchanges.ErrUserNotActive, http.StatusForbidden, middleware.CodeUserNotActive},
+	{exchanges.ErrOrderNotFound, http.StatusNotFound, middleware.CodeOrderNotFound},
+	{exchanges.ErrForbidden, http.StatusForbidden, middleware.CodeForbidden},
+	{exchanges.ErrInvalidAddress, http.StatusBadRequest, middleware.CodeInvalidAddress},
+	{exchanges.ErrAddressTaken, http.StatusConflict, middleware.CodeAddressTaken},
+	{exchanges.ErrInvalidStatus, http.StatusBadRequest, middleware.CodeInvalidStatus},
+	{exchanges.ErrInvalidCursor, http.StatusBadRequest, middleware.CodeInvalidCursor},
+	{exchanges.ErrTradingHalted, http.StatusServiceUnavailable, middleware.CodeTradingHalted},
+	{exchanges.ErrDepositUnavailable, http.StatusUnprocessableEntity, middleware.CodeDepositUnavailable},
+	{exchanges.ErrWithdrawalUnavailable, http.StatusUnprocessableEntity, middleware.CodeWithdrawalUnavailable},
+	{trades.ErrInvalidQuery, http.StatusBadRequest, middleware.CodeInvalidRequest},
+	{errForbiddenUser, http.StatusForbidden, middleware.CodeForbidden},
+}
+
+// apiError returns the API error of err, errors that are not known get the fallback status and its generic code
+func apiError(err error, fallback int) error {
+	for _, known := range knownErrors {
+		if errors.Is(err, known.err) {
+			return middleware.NewError(known.status, known.code, err.Error())
+		}
+	}
+
+	return middleware.NewError(fallback, middleware.CodeForStatus(fallback), err.Error())
+}
@@ -2,15 +2,68 @@ package handler
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
 )
 
-// RegisterRoutes registers all routes with the Echo server
-func (h *Handler) RegisterRoutes(e *echo.Echo) {
-	e.GET("/books/:market", h.HandleGetMarket)
-	e.GET("/books/:market/best/bid", h.HandleGetBestBidLimit)
-	e.GET("/books/:market/best/ask", h.HandleGetBestAskLimit)
-	e.GET("/orders/:userID", h.HandleGetOrder)
-	e.POST("/orders", h.HandlePlaceOrder)
-	e.GET("/trades/:market", h.HandleGetTrades)
-	e.DELETE("/orders/:id", h.HandleCancelOrder)
+// RegisterRoutes registers all routes of the v1 API with the Echo server, authenticate guards the routes acting on
+// behalf of a user and accepts either a signed API key request or a session access token. Every route is rate limited
+// by limiter per client IP before authentication, and per credential after it.
+func (h *Handler) RegisterRoutes(e *echo.Echo, authenticate echo.MiddlewareFunc, limiter *middleware.RateLimiter) {
+	verify, credentialLimit := authenticate, limiter.Credential()
+	authenticate = func(next echo.HandlerFunc) echo.HandlerFunc {
+		return verify(credentialLimit(next))
+	}
+
+	trade := middleware.RequirePermission(auth.PermissionTrade)
+	read := middleware.RequirePermission(auth.PermissionRead)
+	admin := middleware.RequirePermission(auth.PermissionAdmin)
+	withdraw := middleware.RequirePermission(auth.PermissionWithdraw)
+
+	orderEntry := limiter.OrderEntry()
+	cancel := limiter.Cancel()
+	marketData := limiter.MarketData()
+	limit := limiter.Default()
+	register := limiter.Registration()
+
+	v1 := e.Group("/v1")
+	v1.GET("/books/:market", h.HandleGetMarket, marketData)
+	v1.GET("/books/:market/l3", h.HandleGetOrderbookL3, marketData, authenticate, read)
+	v1.GET("/books/:market/best/bid", h.HandleGetBestBidLimit, marketData)
+	v1.GET("/books/:market/best/ask", h.HandleGetBestAskLimit, marketData)
+	v1.GET("/markets/tickers", h.HandleGetTickers, marketData)
+	v1.GET("/markets/:market/ticker", h.HandleGetTicker, marketData)
+	v1.GET("/markets/:market/candles", h.HandleGetCandles, marketData)
+	v1.GET("/orders", h.HandleListOrders, limit, authenticate, read)
+	v1.GET("/orders/:user_id", h.HandleGetOrder, limit, authenticate, read)
+	v1.POST("/orders", h.HandlePlaceOrder, orderEntry, authenticate, trade)
+	v1.GET("/fills", h.HandleListFills, limit, authenticate, read)
+	v1.GET("/trades/:market", h.HandleGetTrades, marketData)
+	v1.GET("/ws", h.HandleStream, marketData)
+	v1.GET("/ws/private", h.HandlePrivateStream, middleware.QueryToken(), limit, authenticate, read)
+	v1.DELETE("/orders/:id", h.HandleCancelOrder, cancel, authenticate, trade)
+	v1.POST("/users", h.HandleRegisterUser, limit, register)
+	v1.GET("/users/:id", h.HandleGetUser, limit, authenticate, read)
+	v1.PUT("/users/:id/status", h.HandleUpdateUserStatus, limit, authenticate, admin)
+	v1.PUT("/users/:id/fee-schedule", h.HandleUpdateFeeSchedule, limit, authenticate, admin)
+	v1.POST("/users/:id/credits", h.HandleCreditBalance, limit, authenticate, admin)
+	v1.GET("/fees", h.HandleGetFees, limit, authenticate, read)
+	v1.POST("/api-keys", h.HandleCreateAPIKey, limit, authenticate)
+	v1.POST("/api-keys/:key/permissions", h.HandleGrantPermissions, limit, authenticate, admin)
+	v1.POST("/auth/nonce", h.HandleAuthNonce, limit)
+	v1.POST("/auth/login", h.HandleAuthLogin, limit)
+	v1.POST("/auth/refresh", h.HandleAuthRefresh, limit)
+	v1.POST("/auth/logout", h.HandleAuthLogout, limit)
+	v1.GET("/deposits/:user_id/:market", h.HandleGetDepositAddress, limit, authenticate, read)
+	v1.POST("/withdrawals", h.HandleWithdraw, limit, authenticate, withdraw)
+	v1.POST("/reserves", h.HandlePublishReserves, limit, authenticate, admin)
+	v1.GET("/reserves", h.HandleGetReserves, marketData)
+	v1.GET("/reserves/proof", h.HandleGetReservesProof, limit, authenticate, read)
+
+	// The API description and the Swagger UI browsing it
+	e.GET("/openapi.json", h.HandleOpenAPI, limit)
+	e.GET(docsPath+"/*", h.HandleDocs, limit)
 }
+
+// docsPath is where the Swagger UI is served, its files are not part of the API description
+const docsPath = "/docs"
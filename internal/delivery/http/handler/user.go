@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+)
+
+// HandleRegisterUser handles the POST /v1/users endpoint
+func (h *Handler) HandleRegisterUser(c echo.Context) error {
+	var req exchanges.RegisterUserRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	address := req.Address
+	if address != "" {
+		signer, err := h.Sessions.VerifyChallenge(req.Nonce, req.Signature)
+		if err != nil {
+			return apiError(err, http.StatusUnauthorized)
+		}
+		if !strings.EqualFold(signer, address) {
+			return middleware.NewError(http.StatusUnauthorized, middleware.CodeUnauthenticated, "the challenge was not issued for this address")
+		}
+		address = signer
+	}
+
+	user, err := h.Exchange.RegisterUser(address)
+	if err != nil {
+		return apiError(err, http.StatusInternalServerError)
+	}
+
+	apiKey, err := h.Keys.Issue(user.ID, auth.DefaultPermissions...)
+	if err != nil {
+		return apiError(err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, &exchanges.RegisterUserResponse{
+		UserResponse: *newUserResponse(user),
+		APIKeyResponse: exchanges.APIKeyResponse{
+			APIKey:      apiKey.Key,
+			APISecret:   apiKey.Secret,
+			Permissions: permissionNames(apiKey.Permissions),
+		},
+	})
+}
+
+// HandleCreateAPIKey handles the POST /v1/api-keys endpoint
+func (h *Handler) HandleCreateAPIKey(c echo.Context) error {
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		return middleware.NewError(http.StatusUnauthorized, middleware.CodeUnauthenticated, "unauthenticated")
+	}
+
+	var req exchanges.CreateAPIKeyRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	// New keys get the caller's permissions unless fewer are requested, never more
+	callerPermissions := middleware.Permissions(c)
+	permissions := callerPermissions
+	if len(req.Permissions) > 0 {
+		permissions = make(auth.Permissions, 0, len(req.Permissions))
+		for _, name := range req.Permissions {
+			p, err := auth.ParsePermission(name)
+			if err != nil {
+				return apiError(err, http.StatusBadRequest)
+			}
+			permissions = append(permissions, p)
+		}
+	}
+
+	if !callerPermissions.Covers(permissions) {
+		return middleware.NewError(http.StatusForbidden, middleware.CodeForbidden, "cannot grant permissions the caller does not hold")
+	}
+
+	apiKey, err := h.Keys.Issue(userID, permissions...)
+	if err != nil {
+		return apiError(err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, &exchanges.APIKeyResponse{
+		APIKey:      apiKey.Key,
+		APISecret:   apiKey.Secret,
+		Permissions: permissionNames(apiKey.Permissions),
+	})
+}
+
+// HandleGrantPermissions handles the POST /v1/api-keys/:key/permissions endpoint. It is how the operator lets a user
+// withdraw, keys never get more than the permissions of the caller that issued them.
+func (h *Handler) HandleGrantPermissions(c echo.Context) error {
+	var req exchanges.GrantPermissionsRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	permissions := make(auth.Permissions, 0, len(req.Permissions))
+	for _, name := range req.Permissions {
+		p, err := auth.ParsePermission(name)
+		if err != nil {
+			return apiError(err, http.StatusBadRequest)
+		}
+		if p == auth.PermissionAdmin {
+			return middleware.NewError(http.StatusForbidden, middleware.CodeForbidden, "the admin permission cannot be granted")
+		}
+		permissions = append(permissions, p)
+	}
+
+	apiKey, err := h.Keys.Grant(req.Key, permissions...)
+	if err != nil {
+		return apiError(err, http.StatusNotFound)
+	}
+
+	return c.JSON(http.StatusOK, &exchanges.APIKeyPermissionsResponse{
+		APIKey:      apiKey.Key,
+		UserID:      apiKey.UserID,
+		Permissions: permissionNames(apiKey.Permissions),
+	})
+}
+
+// HandleGetUser handles the GET /v1/users/:id endpoint
+func (h *Handler) HandleGetUser(c echo.Context) error {
+	var req exchanges.UserRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	if !authorizeUser(c, req.UserID) {
+		return apiError(errForbiddenUser, http.StatusForbidden)
+	}
+
+	user, err := h.Exchange.GetUser(req.UserID)
+	if err != nil {
+		return apiError(err, http.StatusNotFound)
+	}
+
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// HandleUpdateUserStatus handles the PUT /v1/users/:id/status endpoint
+func (h *Handler) HandleUpdateUserStatus(c echo.Context) error {
+	var req exchanges.UpdateUserStatusRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.Exchange.SetUserStatus(req.UserID, req.Status)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// HandleUpdateFeeSchedule handles the PUT /v1/users/:id/fee-schedule endpoint
+func (h *Handler) HandleUpdateFeeSchedule(c echo.Context) error {
+	var req exchanges.UpdateFeeScheduleRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	status, err := h.Exchange.SetFeeSchedule(req.UserID, req.Schedule)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+// HandleCreditBalance handles the POST /v1/users/:id/credits endpoint
+func (h *Handler) HandleCreditBalance(c echo.Context) error {
+	var req exchanges.CreditBalanceRequest
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	balance, err := h.Exchange.CreditBalance(&req)
+	if err != nil {
+		return apiError(err, http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusCreated, balance)
+}
+
+// HandleGetFees handles the GET /v1/fees endpoint. It returns the fee schedule, 30 day volume and current rates of
+// the authenticated user, admins may read another user with ?user_id=.
+func (h *Handler) HandleGetFees(c echo.Context) error {
+	var req exchanges.UserQuery
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := targetUser(c, req.UserID)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	status, err := h.Exchange.FeeStatus(userID)
+	if err != nil {
+		return apiError(err, http.StatusNotFound)
+	}
+
+	return c.JSON(http.StatusOK, status)
+}
+
+func newUserResponse(user *models.User) *exchanges.UserResponse {
+	return &exchanges.UserResponse{
+		ID:      user.ID,
+		Address: user.Address,
+		Status:  user.Status,
+	}
+}
+
+func permissionNames(permissions auth.Permissions) []string {
+	names := make([]string, len(permissions))
+	for i, p := range permissions {
+		names[i] = string(p)
+	}
+
+	return names
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/hdwallet"
+)
+
+func TestWithdrawPermission(t *testing.T) {
+	seedPath := filepath.Join(t.TempDir(), "seed.json")
+	seed, err := hdwallet.NewSeed()
+	require.NoError(t, err)
+	require.NoError(t, hdwallet.CreateSeedFile(seedPath, "secret", seed, keystore.LightScryptN, keystore.LightScryptP))
+	wallet, err := hdwallet.LoadWallet(seedPath, "secret")
+	require.NoError(t, err)
+	hotWallet, err := wallet.Signer(100, 0)
+	require.NoError(t, err)
+	exchange, err := exchanges.New(hotWallet, wallet, nil)
+	require.NoError(t, err)
+
+	keys := auth.NewKeyStore()
+	admin := &exchanges.APIKeyResponse{APIKey: "admin", APISecret: "admin-secret"}
+	keys.Add(&auth.APIKey{Key: admin.APIKey, Secret: admin.APISecret, Permissions: auth.Permissions{auth.PermissionAdmin}})
+
+	e := echo.New()
+	e.HTTPErrorHandler = middleware.HTTPErrorHandler
+	New(exchange, keys, nil).RegisterRoutes(e, middleware.APIKeyAuth(middleware.APIKeyAuthConfig{Keys: keys}),
+		middleware.NewRateLimiter(middleware.RateLimitConfig{}))
+
+	// Every request gets its own timestamp, the same signature is only accepted once
+	sent := int64(0)
+	serve := func(creds *exchanges.APIKeyResponse, method, path string, req interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if req != nil {
+			body, err = json.Marshal(req)
+			require.NoError(t, err)
+		}
+		r := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if creds != nil {
+			sent++
+			timestamp := time.Now().UnixMilli() + sent
+			r.Header.Set(middleware.HeaderAPIKey, creds.APIKey)
+			r.Header.Set(middleware.HeaderAPITimestamp, strconv.FormatInt(timestamp, 10))
+			r.Header.Set(middleware.HeaderAPISignature, auth.Sign(creds.APISecret, timestamp, method, path, body))
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := serve(nil, http.MethodPost, "/v1/users", nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var user exchanges.RegisterUserResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+	withdrawal := &exchanges.WithdrawRequest{Market: exchanges.MarketETH, Address: "0x00000000000000000000000000000000000000aa", Amount: 1}
+	grant := &exchanges.GrantPermissionsRequest{Permissions: []string{string(auth.PermissionWithdraw)}}
+	grantPath := "/v1/api-keys/" + user.APIKey + "/permissions"
+
+	// Test case 1: registration keys cannot withdraw, nor grant themselves the permission
+	rec = serve(&user.APIKeyResponse, http.MethodPost, "/v1/withdrawals", withdrawal)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	rec = serve(&user.APIKeyResponse, http.MethodPost, grantPath, grant)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Test case 2: the operator cannot hand out admin
+	rec = serve(admin, http.MethodPost, grantPath, &exchanges.GrantPermissionsRequest{Permissions: []string{string(auth.PermissionAdmin)}})
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	// Test case 3: once the operator granted withdraw, the user's withdrawals reach the exchange, which has no
+	// treasury to pay them from here
+	rec = serve(admin, http.MethodPost, grantPath, grant)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"withdraw"`)
+
+	rec = serve(&user.APIKeyResponse, http.MethodPost, "/v1/withdrawals", withdrawal)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	require.Contains(t, rec.Body.String(), `"code":"WITHDRAWAL_UNAVAILABLE"`)
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v4"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/marketdata"
+)
+
+const (
+	// streamBufferSize is how many messages may wait for a slow client before it is disconnected
+	streamBufferSize = 256
+	// streamWriteTimeout is how long writing a message to a client may take
+	streamWriteTimeout = 10 * time.Second
+	// streamPongTimeout is how long a client may stay silent before it is considered gone
+	streamPongTimeout = 60 * time.Second
+	// streamPingInterval must be shorter than streamPongTimeout
+	streamPingInterval = streamPongTimeout * 9 / 10
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// The REST API allows every origin as well, the private stream is authenticated by credentials and not cookies
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// HandleStream handles the GET /v1/ws endpoint. Clients send StreamRequest messages to subscribe to the book, trades
+// and ticker channels of a market. A client that cannot keep up is disconnected and has to resubscribe, which gives
+// it a fresh book snapshot.
+func (h *Handler) HandleStream(c echo.Context) error {
+	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		// The upgrader has already replied with an error
+		return nil
+	}
+
+	client := newStreamClient(conn)
+	go client.writePump()
+	client.readPump(func(req *exchanges.StreamRequest) *exchanges.StreamResponse {
+		return client.handle(h.Exchange.MarketData, req)
+	})
+
+	for sub, feed := range client.subscriptions {
+		feed.Unsubscribe(sub.channel, client)
+	}
+
+	return nil
+}
+
+// HandlePrivateStream handles the GET /v1/ws/private endpoint. It streams the execution reports and balance updates
+// of the authenticated user, admins may follow another user with ?user_id=.
+func (h *Handler) HandlePrivateStream(c echo.Context) error {
+	var req exchanges.UserQuery
+	if err := bind(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := targetUser(c, req.UserID)
+	if err != nil {
+		return apiError(err, http.StatusBadRequest)
+	}
+
+	conn, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
+	if err != nil {
+		return nil
+	}
+
+	client := newStreamClient(conn)
+	go client.writePump()
+
+	h.Exchange.SubscribeUser(userID, client)
+	defer h.Exchange.UnsubscribeUser(userID, client)
+
+	// The private stream has no subscriptions to manage, reading only keeps the connection alive
+	client.readPump(func(req *exchanges.StreamRequest) *exchanges.StreamResponse {
+		return &exchanges.StreamResponse{Type: "error", Channel: req.Channel, Market: req.Market, Error: "the private stream takes no requests"}
+	})
+
+	return nil
+}
+
+// subscription is a channel of a market a stream client subscribed to
+type subscription struct {
+	channel marketdata.Channel
+	market  exchanges.Market
+}
+
+// streamClient is a WebSocket connection, it is the marketdata.Sink of all its subscriptions
+type streamClient struct {
+	conn *websocket.Conn
+	send chan interface{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	subscriptions map[subscription]*marketdata.Feed
+}
+
+func newStreamClient(conn *websocket.Conn) *streamClient {
+	return &streamClient{
+		conn:          conn,
+		send:          make(chan interface{}, streamBufferSize),
+		done:          make(chan struct{}),
+		subscriptions: make(map[subscription]*marketdata.Feed),
+	}
+}
+
+// Send queues a message for the client without blocking, a full queue disconnects the client
+func (s *streamClient) Send(msg interface{}) bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+
+	select {
+	case s.send <- msg:
+		return true
+	default:
+		s.close()
+		return false
+	}
+}
+
+func (s *streamClient) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// readPump passes the requests of the client to handle until the connection ends
+func (s *streamClient) readPump(handle func(req *exchanges.StreamRequest) *exchanges.StreamResponse) {
+	defer s.close()
+
+	s.conn.SetReadLimit(4096)
+	_ = s.conn.SetReadDeadline(time.Now().Add(streamPongTimeout))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(streamPongTimeout))
+	})
+
+	for {
+		var req exchanges.StreamRequest
+		if err := s.conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		if resp := handle(&req); resp != nil && !s.Send(resp) {
+			return
+		}
+	}
+}
+
+// handle applies a request, it returns the response to send or nil if it was already sent
+func (s *streamClient) handle(feeds map[exchanges.Market]*marketdata.Feed, req *exchanges.StreamRequest) *exchanges.StreamResponse {
+	resp := &exchanges.StreamResponse{Channel: req.Channel, Market: req.Market}
+
+	feed, ok := feeds[req.Market]
+	if !ok {
+		resp.Type = "error"
+		resp.Error = fmt.Sprintf("market %s does not exist", req.Market)
+		return resp
+	}
+
+	sub := subscription{channel: marketdata.Channel(req.Channel), market: req.Market}
+	if !sub.channel.Valid() {
+		resp.Type = "error"
+		resp.Error = fmt.Sprintf("%s: %s", marketdata.ErrUnknownChannel, req.Channel)
+		return resp
+	}
+
+	switch req.Op {
+	case exchanges.StreamSubscribe:
+		// The acknowledgement goes out before the snapshot the feed sends on subscribe
+		resp.Type = "subscribed"
+		if !s.Send(resp) {
+			return resp
+		}
+		if err := feed.Subscribe(sub.channel, s); err != nil {
+			resp.Type = "error"
+			resp.Error = err.Error()
+			return resp
+		}
+		s.subscriptions[sub] = feed
+		return nil
+
+	case exchanges.StreamUnsubscribe:
+		feed.Unsubscribe(sub.channel, s)
+		delete(s.subscriptions, sub)
+		resp.Type = "unsubscribed"
+		return resp
+
+	default:
+		resp.Type = "error"
+		resp.Error = fmt.Sprintf("unknown op %q", req.Op)
+		return resp
+	}
+}
+
+// writePump writes queued messages and pings to the connection until the client is closed
+func (s *streamClient) writePump() {
+	ticker := time.NewTicker(streamPingInterval)
+	defer func() {
+		ticker.Stop()
+		s.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-s.send:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := s.conn.WriteJSON(msg); err != nil {
+				s.close()
+				return
+			}
+
+		case <-ticker.C:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := s.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				s.close()
+				return
+			}
+
+		case <-s.done:
+			_ = s.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(streamWriteTimeout))
+			return
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+)
+
+const (
+	// HeaderAPIKey carries the public API key of the caller
+	HeaderAPIKey = "X-API-KEY"
+	// HeaderAPITimestamp carries the request time in unix milliseconds
+	HeaderAPITimestamp = "X-API-TIMESTAMP"
+	// HeaderAPISignature carries the hex encoded HMAC-SHA256 signature of the request
+	HeaderAPISignature = "X-API-SIGNATURE"
+
+	// userIDContextKey is the echo context key the authenticated user ID is stored under
+	userIDContextKey = "auth.userID"
+	// permissionsContextKey is the echo context key the permissions of the credential are stored under
+	permissionsContextKey = "auth.permissions"
+)
+
+// APIKeyAuthConfig defines the config for the API key authentication middleware
+type APIKeyAuthConfig struct {
+	// Skipper defines a function to skip middleware execution
+	Skipper middleware.Skipper
+
+	// Keys is the store API keys are looked up in
+	Keys *auth.KeyStore
+
+	// ReplayWindow is how far a request timestamp may be from the server clock, a signature is only accepted once
+	// within the window
+	ReplayWindow time.Duration
+
+	// Verifier checks the signatures, one is created from Keys and ReplayWindow when nil. Transports sharing a
+	// verifier accept a signature only once between them.
+	Verifier *auth.Verifier
+
+	// MaxBodySize is the largest request body in bytes that is read to check its signature, larger requests are
+	// rejected with 413
+	MaxBodySize int64
+}
+
+// DefaultAPIKeyAuthConfig is the default config for the API key authentication middleware
+var DefaultAPIKeyAuthConfig = APIKeyAuthConfig{
+	Skipper:      middleware.DefaultSkipper,
+	ReplayWindow: auth.DefaultReplayWindow,
+	MaxBodySize:  64 << 10,
+}
+
+// APIKeyAuth returns a middleware that authenticates HMAC signed requests and stores the caller's user ID in the
+// context, see UserID
+func APIKeyAuth(config APIKeyAuthConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultAPIKeyAuthConfig.Skipper
+	}
+	if config.ReplayWindow <= 0 {
+		config.ReplayWindow = DefaultAPIKeyAuthConfig.ReplayWindow
+	}
+	if config.Verifier == nil {
+		config.Verifier = auth.NewVerifier(config.Keys, config.ReplayWindow)
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultAPIKeyAuthConfig.MaxBodySize
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			req := c.Request()
+
+			var body []byte
+			if req.Body != nil {
+				var err error
+				body, err = io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, config.MaxBodySize))
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					return NewError(http.StatusRequestEntityTooLarge, CodeInvalidRequest, "request body too large")
+				}
+				if err != nil {
+					return NewError(http.StatusBadRequest, CodeInvalidRequest, "invalid request body")
+				}
+				req.Body = io.NopCloser(bytes.NewBuffer(body))
+			}
+
+			apiKey, err := config.Verifier.Verify(req.Header.Get(HeaderAPIKey), req.Header.Get(HeaderAPITimestamp),
+				req.Header.Get(HeaderAPISignature), req.Method, req.RequestURI, body)
+			if err != nil {
+				return NewError(http.StatusUnauthorized, CodeUnauthenticated, err.Error())
+			}
+
+			c.Set(userIDContextKey, apiKey.UserID)
+			c.Set(permissionsContextKey, apiKey.Permissions)
+
+			return next(c)
+		}
+	}
+}
+
+// SessionAuthConfig defines the config for the session authentication middleware
+type SessionAuthConfig struct {
+	// Skipper defines a function to skip middleware execution
+	Skipper middleware.Skipper
+
+	// Sessions validates the access tokens
+	Sessions *auth.Sessions
+}
+
+// SessionAuth returns a middleware that authenticates requests carrying a JWT access token in the Authorization
+// header and stores the caller's user ID in the context, see UserID
+func SessionAuth(config SessionAuthConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+
+			token, ok := bearerToken(c)
+			if !ok {
+				return NewError(http.StatusUnauthorized, CodeUnauthenticated, "missing access token")
+			}
+
+			claims, err := config.Sessions.ParseAccessToken(token)
+			if err != nil {
+				return NewError(http.StatusUnauthorized, CodeUnauthenticated, err.Error())
+			}
+
+			c.Set(userIDContextKey, claims.UserID)
+			c.Set(permissionsContextKey, claims.Permissions)
+
+			return next(c)
+		}
+	}
+}
+
+// HasBearerToken reports whether the request carries an access token, it lets API key and session authentication
+// skip each other
+func HasBearerToken(c echo.Context) bool {
+	_, ok := bearerToken(c)
+	return ok
+}
+
+// QueryToken returns a middleware that moves an access token passed in the access_token query parameter into the
+// Authorization header. Browsers cannot set headers on WebSocket connections, it must only guard those routes.
+func QueryToken() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if token := req.URL.Query().Get("access_token"); token != "" && req.Header.Get(echo.HeaderAuthorization) == "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func bearerToken(c echo.Context) (string, bool) {
+	header := c.Request().Header.Get(echo.HeaderAuthorization)
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+
+	return strings.TrimPrefix(header, "Bearer "), true
+}
+
+// UserID returns the ID of the authenticated user of the request
+func UserID(c echo.Context) (uint64, bool) {
+	userID, ok := c.Get(userIDContextKey).(uint64)
+	return userID, ok
+}
+
+// Permissions returns the permissions of the credential the request was authenticated with
+func Permissions(c echo.Context) auth.Permissions {
+	permissions, _ := c.Get(permissionsContextKey).(auth.Permissions)
+	return permissions
+}
+
+// RequirePermission returns a middleware that rejects authenticated requests whose credential lacks the permission.
+// It must run after an authentication middleware.
+func RequirePermission(permission auth.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !Permissions(c).Has(permission) {
+				return NewError(http.StatusForbidden, CodeForbidden, "missing permission: "+string(permission))
+			}
+
+			return next(c)
+		}
+	}
+}
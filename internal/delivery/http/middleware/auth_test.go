@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys := auth.NewKeyStore()
+	apiKey, err := keys.Issue(7)
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.POST("/orders", func(c echo.Context) error {
+		userID, _ := UserID(c)
+		return c.String(http.StatusOK, strconv.FormatUint(userID, 10))
+	}, APIKeyAuth(APIKeyAuthConfig{Keys: keys, ReplayWindow: time.Minute, MaxBodySize: 64}))
+
+	body := `{"Type":"LIMIT"}`
+	newRequest := func(timestamp int64, signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		req.Header.Set(HeaderAPIKey, apiKey.Key)
+		req.Header.Set(HeaderAPITimestamp, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(HeaderAPISignature, signature)
+		return req
+	}
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	now := time.Now().UnixMilli()
+	signature := auth.Sign(apiKey.Secret, now, http.MethodPost, "/orders", []byte(body))
+
+	// Test case 1: a valid signature authenticates the key's user
+	rec := serve(newRequest(now, signature))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "7", rec.Body.String())
+
+	// Test case 2: the same request cannot be replayed
+	rec = serve(newRequest(now, signature))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.JSONEq(t, `{"error":{"code":"UNAUTHENTICATED","message":"request has already been used"}}`, rec.Body.String())
+
+	// Test case 3: a bad signature is rejected
+	rec = serve(newRequest(now+1, signature))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Test case 4: a timestamp outside of the replay window is rejected
+	old := time.Now().Add(-2 * time.Minute).UnixMilli()
+	rec = serve(newRequest(old, auth.Sign(apiKey.Secret, old, http.MethodPost, "/orders", []byte(body))))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Test case 5: unknown keys are rejected
+	req := newRequest(now, signature)
+	req.Header.Set(HeaderAPIKey, "unknown")
+	rec = serve(req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Test case 6: bodies over the limit are refused before their signature is checked
+	large := strings.Repeat("x", 65)
+	req = httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(large))
+	req.Header.Set(HeaderAPIKey, apiKey.Key)
+	req.Header.Set(HeaderAPITimestamp, strconv.FormatInt(now, 10))
+	req.Header.Set(HeaderAPISignature, auth.Sign(apiKey.Secret, now, http.MethodPost, "/orders", []byte(large)))
+	rec = serve(req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	require.Contains(t, rec.Body.String(), `"code":"INVALID_REQUEST"`)
+}
+
+func TestSessionAuth(t *testing.T) {
+	sessions, err := auth.NewSessions(auth.SessionsConfig{
+		Secret:     []byte("0123456789abcdef0123456789abcdef"),
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/orders", func(c echo.Context) error {
+		userID, _ := UserID(c)
+		return c.String(http.StatusOK, strconv.FormatUint(userID, 10))
+	}, SessionAuth(SessionAuthConfig{Sessions: sessions}))
+
+	tokens, err := sessions.Issue(3, auth.DefaultPermissions)
+	require.NoError(t, err)
+
+	// Test case 1: a valid access token authenticates its user
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokens.AccessToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "3", rec.Body.String())
+
+	// Test case 2: a missing or invalid token is rejected
+	req = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokens.RefreshToken)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
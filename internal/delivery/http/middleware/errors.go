@@ -1,55 +1,133 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 )
 
-// ErrorResponse represents a standardized error response
+// ErrorCode is the machine readable reason of a failed request, clients branch on it and never on the message
+type ErrorCode string
+
+const (
+	CodeInvalidRequest   ErrorCode = "INVALID_REQUEST"
+	CodeUnauthenticated  ErrorCode = "UNAUTHENTICATED"
+	CodeForbidden        ErrorCode = "FORBIDDEN"
+	CodeNotFound         ErrorCode = "NOT_FOUND"
+	CodeMethodNotAllowed ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeConflict         ErrorCode = "CONFLICT"
+	CodeRateLimited      ErrorCode = "RATE_LIMITED"
+	CodeInternal         ErrorCode = "INTERNAL_ERROR"
+
+	CodeUnknownMarket         ErrorCode = "UNKNOWN_MARKET"
+	CodeUnknownOrderType      ErrorCode = "UNKNOWN_ORDER_TYPE"
+	CodeUnknownInterval       ErrorCode = "UNKNOWN_INTERVAL"
+	CodeUnknownFeeSchedule    ErrorCode = "UNKNOWN_FEE_SCHEDULE"
+	CodeInsufficientBalance   ErrorCode = "INSUFFICIENT_BALANCE"
+	CodeInsufficientLiquidity ErrorCode = "INSUFFICIENT_LIQUIDITY"
+	CodeEmptyBook             ErrorCode = "EMPTY_BOOK"
+	CodeUserNotFound          ErrorCode = "USER_NOT_FOUND"
+	CodeUserNotActive         ErrorCode = "USER_NOT_ACTIVE"
+	CodeOrderNotFound         ErrorCode = "ORDER_NOT_FOUND"
+	CodeInvalidAddress        ErrorCode = "INVALID_ADDRESS"
+	CodeAddressTaken          ErrorCode = "ADDRESS_TAKEN"
+	CodeInvalidStatus         ErrorCode = "INVALID_STATUS"
+	CodeInvalidCursor         ErrorCode = "INVALID_CURSOR"
+	CodeTradingHalted         ErrorCode = "TRADING_HALTED"
+	CodeDepositUnavailable    ErrorCode = "DEPOSIT_UNAVAILABLE"
+	CodeWithdrawalUnavailable ErrorCode = "WITHDRAWAL_UNAVAILABLE"
+)
+
+// ErrorCodes lists every error code
+var ErrorCodes = []ErrorCode{
+	CodeInvalidRequest, CodeUnauthenticated, CodeForbidden, CodeNotFound, CodeMethodNotAllowed, CodeConflict,
+	CodeRateLimited, CodeInternal, CodeUnknownMarket, CodeUnknownOrderType, CodeUnknownInterval, CodeUnknownFeeSchedule,
+	CodeInsufficientBalance, CodeInsufficientLiquidity, CodeEmptyBook, CodeUserNotFound, CodeUserNotActive,
+	CodeOrderNotFound, CodeInvalidAddress, CodeAddressTaken, CodeInvalidStatus, CodeInvalidCursor,
+	CodeTradingHalted, CodeDepositUnavailable, CodeWithdrawalUnavailable,
+}
+
+// Error is a failed request, HTTPErrorHandler writes it as an ErrorResponse with the HTTP status
+type Error struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+}
+
+// NewError is constructor of Error struct.
+func NewError(status int, code ErrorCode, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// ErrorResponse is the body of every failed request
 type ErrorResponse struct {
-	Message string `json:"message"`
-	Code    int    `json:"code"`
-}
-
-// ErrorHandler is a middleware that handles all errors
-func ErrorHandler() echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			err := next(c)
-			if err != nil {
-				// Already handled errors
-				if c.Response().Committed {
-					return err
-				}
-
-				// Get the error code and message
-				code := http.StatusInternalServerError
-				message := "Internal Server Error"
-
-				// Check if it's an HTTPError
-				if he, ok := err.(*echo.HTTPError); ok {
-					code = he.Code
-					if m, ok := he.Message.(string); ok {
-						message = m
-					} else {
-						message = he.Error()
-					}
-				} else {
-					// For regular errors, use the error message
-					message = err.Error()
-				}
-
-				// Log the error
-				c.Logger().Error(err)
-
-				// Return a standardized error response
-				return c.JSON(code, ErrorResponse{
-					Message: message,
-					Code:    code,
-				})
-			}
-			return nil
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes why a request failed
+type ErrorBody struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// CodeForStatus returns the generic error code of an HTTP status
+func CodeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	}
+
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// HTTPErrorHandler writes every error returned by a handler or middleware as an ErrorResponse, it is the
+// echo.HTTPErrorHandler of the server. Errors that are neither an Error nor an echo.HTTPError are logged and hidden
+// behind an internal error.
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var apiErr *Error
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &httpErr):
+		message := http.StatusText(httpErr.Code)
+		if m, ok := httpErr.Message.(string); ok {
+			message = m
 		}
+		apiErr = NewError(httpErr.Code, CodeForStatus(httpErr.Code), message)
+	default:
+		c.Logger().Error(err)
+		apiErr = NewError(http.StatusInternalServerError, CodeInternal, "internal server error")
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.Status)
+	} else {
+		err = c.JSON(apiErr.Status, ErrorResponse{Error: ErrorBody{Code: apiErr.Code, Message: apiErr.Message}})
+	}
+	if err != nil {
+		c.Logger().Error(err)
 	}
 }
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.GET("/api", func(c echo.Context) error {
+		return NewError(http.StatusBadRequest, CodeUnknownMarket, "unknown market: XRP")
+	})
+	e.GET("/http", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "body too large")
+	})
+	e.GET("/internal", func(c echo.Context) error {
+		return errors.New("database password is wrong")
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	// Test case 1: API errors keep their status, code and message
+	rec := serve(http.MethodGet, "/api")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.JSONEq(t, `{"error":{"code":"UNKNOWN_MARKET","message":"unknown market: XRP"}}`, rec.Body.String())
+
+	// Test case 2: echo errors get the code of their status, unknown routes included
+	rec = serve(http.MethodGet, "/http")
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	require.JSONEq(t, `{"error":{"code":"INVALID_REQUEST","message":"body too large"}}`, rec.Body.String())
+
+	rec = serve(http.MethodGet, "/missing")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.JSONEq(t, `{"error":{"code":"NOT_FOUND","message":"Not Found"}}`, rec.Body.String())
+
+	// Test case 3: other errors are hidden behind an internal error
+	rec = serve(http.MethodGet, "/internal")
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.JSONEq(t, `{"error":{"code":"INTERNAL_ERROR","message":"internal server error"}}`, rec.Body.String())
+}
@@ -19,6 +19,9 @@ type RequestLoggerConfig struct {
 	// LogRequestBody determines if the request body should be logged
 	LogRequestBody bool
 
+	// SkipRequestBody defines a function to leave the body of a request out of the log, e.g. for credentials
+	SkipRequestBody middleware.Skipper
+
 	// LogResponseBody determines if the response body should be logged
 	LogResponseBody bool
 
@@ -30,6 +33,7 @@ type RequestLoggerConfig struct {
 var DefaultRequestLoggerConfig = RequestLoggerConfig{
 	Skipper:         middleware.DefaultSkipper,
 	LogRequestBody:  false,
+	SkipRequestBody: middleware.DefaultSkipper,
 	LogResponseBody: false,
 	LogLevel:        1,
 }
@@ -39,6 +43,9 @@ func RequestLogger(config RequestLoggerConfig) echo.MiddlewareFunc {
 	if config.Skipper == nil {
 		config.Skipper = DefaultRequestLoggerConfig.Skipper
 	}
+	if config.SkipRequestBody == nil {
+		config.SkipRequestBody = DefaultRequestLoggerConfig.SkipRequestBody
+	}
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
@@ -51,7 +58,8 @@ func RequestLogger(config RequestLoggerConfig) echo.MiddlewareFunc {
 			res := c.Response()
 
 			var reqBody []byte
-			if config.LogRequestBody {
+			logRequestBody := config.LogRequestBody && !config.SkipRequestBody(c)
+			if logRequestBody {
 				if req.Body != nil {
 					reqBody, _ = io.ReadAll(req.Body)
 					req.Body = io.NopCloser(bytes.NewBuffer(reqBody))
@@ -91,7 +99,7 @@ func RequestLogger(config RequestLoggerConfig) echo.MiddlewareFunc {
 				", BYTES_OUT: " + strconv.FormatInt(res.Size, 10)
 
 			// Log request body if enabled
-			if config.LogRequestBody && len(reqBody) > 0 {
+			if logRequestBody && len(reqBody) > 0 {
 				logLine += "\nREQUEST_BODY: " + string(reqBody)
 			}
 
@@ -0,0 +1,377 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	// HeaderRateLimitLimit carries the bucket size of the tightest limit applied to the request
+	HeaderRateLimitLimit = "X-RateLimit-Limit"
+	// HeaderRateLimitRemaining carries the tokens left in that bucket after the request
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	// HeaderRateLimitReset carries the seconds until that bucket is full again
+	HeaderRateLimitReset = "X-RateLimit-Reset"
+
+	// bucketCleanupInterval is how often buckets that refilled completely are dropped
+	bucketCleanupInterval = time.Minute
+
+	// rateLimitWeightKey holds the weight of the request in the context, for the credential bucket
+	rateLimitWeightKey = "rate_limit_weight"
+)
+
+// RateLimitWeights are the tokens a request of each kind takes from its buckets
+type RateLimitWeights struct {
+	OrderEntry int
+	Cancel     int
+	MarketData int
+	Default    int
+}
+
+// RateLimitConfig defines the config for the rate limiting middleware
+type RateLimitConfig struct {
+	// Skipper defines a function to skip middleware execution
+	Skipper middleware.Skipper
+
+	// IPRate and IPBurst are the refill rate in tokens per second and the bucket size per client IP
+	IPRate  float64
+	IPBurst int
+
+	// KeyRate and KeyBurst are the refill rate in tokens per second and the bucket size per user, shared by all of its
+	// API keys and sessions
+	KeyRate  float64
+	KeyBurst int
+
+	// RegisterRate and RegisterBurst are the refill rate in registrations per second and the bucket size per client
+	// IP for new accounts
+	RegisterRate  float64
+	RegisterBurst int
+
+	Weights RateLimitWeights
+}
+
+// DefaultRateLimitConfig is the default config for the rate limiting middleware
+var DefaultRateLimitConfig = RateLimitConfig{
+	Skipper:  middleware.DefaultSkipper,
+	IPRate:   50,
+	IPBurst:  100,
+	KeyRate:  20,
+	KeyBurst: 40,
+	// One account a minute, five at once
+	RegisterRate:  1.0 / 60,
+	RegisterBurst: 5,
+	Weights: RateLimitWeights{
+		OrderEntry: 2,
+		Cancel:     1,
+		MarketData: 1,
+		Default:    1,
+	},
+}
+
+// RateLimiter keeps a token bucket per client IP and per credential. A request takes its weight from the IP bucket
+// before authentication and, once its API key or access token is verified, from that credential's bucket too; it is
+// rejected with 429 if either is short. Client IPs come from the Echo IPExtractor, see IPExtractor.
+type RateLimiter struct {
+	config RateLimitConfig
+
+	mu          sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+// NewRateLimiter is constructor of RateLimiter struct.
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.Skipper == nil {
+		config.Skipper = DefaultRateLimitConfig.Skipper
+	}
+	if config.IPRate <= 0 || config.IPBurst <= 0 {
+		config.IPRate, config.IPBurst = DefaultRateLimitConfig.IPRate, DefaultRateLimitConfig.IPBurst
+	}
+	if config.KeyRate <= 0 || config.KeyBurst <= 0 {
+		config.KeyRate, config.KeyBurst = DefaultRateLimitConfig.KeyRate, DefaultRateLimitConfig.KeyBurst
+	}
+	if config.RegisterRate <= 0 || config.RegisterBurst <= 0 {
+		config.RegisterRate, config.RegisterBurst = DefaultRateLimitConfig.RegisterRate, DefaultRateLimitConfig.RegisterBurst
+	}
+	if config.Weights.OrderEntry <= 0 {
+		config.Weights.OrderEntry = DefaultRateLimitConfig.Weights.OrderEntry
+	}
+	if config.Weights.Cancel <= 0 {
+		config.Weights.Cancel = DefaultRateLimitConfig.Weights.Cancel
+	}
+	if config.Weights.MarketData <= 0 {
+		config.Weights.MarketData = DefaultRateLimitConfig.Weights.MarketData
+	}
+	if config.Weights.Default <= 0 {
+		config.Weights.Default = DefaultRateLimitConfig.Weights.Default
+	}
+
+	return &RateLimiter{
+		config:  config,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// OrderEntry returns the middleware for order entry routes
+func (l *RateLimiter) OrderEntry() echo.MiddlewareFunc {
+	return l.Limit(l.config.Weights.OrderEntry)
+}
+
+// Cancel returns the middleware for order cancel routes
+func (l *RateLimiter) Cancel() echo.MiddlewareFunc {
+	return l.Limit(l.config.Weights.Cancel)
+}
+
+// MarketData returns the middleware for public market data routes
+func (l *RateLimiter) MarketData() echo.MiddlewareFunc {
+	return l.Limit(l.config.Weights.MarketData)
+}
+
+// Default returns the middleware for all other routes
+func (l *RateLimiter) Default() echo.MiddlewareFunc {
+	return l.Limit(l.config.Weights.Default)
+}
+
+// Limit returns a middleware taking weight tokens per request from the bucket of the client IP. It should run before
+// authentication so rejected requests cost no signature checks.
+func (l *RateLimiter) Limit(weight int) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if l.config.Skipper(c) {
+				return next(c)
+			}
+
+			c.Set(rateLimitWeightKey, weight)
+			result := l.take("ip:"+c.RealIP(), l.config.IPRate, l.config.IPBurst, weight)
+			setRateLimitHeaders(c, result)
+			if !result.allowed {
+				return rateLimited(c, result)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Credential returns a middleware taking the weight of Limit from the bucket of the user the request was authenticated
+// as. It runs after authentication, so only verified credentials take tokens and nobody can drain the bucket of a user
+// whose credentials they do not hold. All API keys and sessions of a user share the bucket, issuing more of them does
+// not raise the limit.
+func (l *RateLimiter) Credential() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := credential(c)
+			if l.config.Skipper(c) || key == "" {
+				return next(c)
+			}
+
+			weight, ok := c.Get(rateLimitWeightKey).(int)
+			if !ok {
+				weight = l.config.Weights.Default
+			}
+			result := l.take(key, l.config.KeyRate, l.config.KeyBurst, weight)
+			setTighterRateLimitHeaders(c, result)
+			if !result.allowed {
+				// Rejected requests take no tokens
+				l.refund("ip:"+c.RealIP(), weight)
+				return rateLimited(c, result)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// Registration returns the middleware for account registration. Besides the weight of Limit, every registration takes
+// a token from a separate bucket of the client IP so accounts cannot be created in bulk.
+func (l *RateLimiter) Registration() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if l.config.Skipper(c) {
+				return next(c)
+			}
+
+			result := l.take("register:"+c.RealIP(), l.config.RegisterRate, l.config.RegisterBurst, 1)
+			setTighterRateLimitHeaders(c, result)
+			if !result.allowed {
+				if weight, ok := c.Get(rateLimitWeightKey).(int); ok {
+					l.refund("ip:"+c.RealIP(), weight)
+				}
+				return rateLimited(c, result)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func setRateLimitHeaders(c echo.Context, result rateLimitResult) {
+	header := c.Response().Header()
+	header.Set(HeaderRateLimitLimit, strconv.Itoa(result.limit))
+	header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.remaining))
+	header.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(result.reset)))
+}
+
+// setTighterRateLimitHeaders reports the bucket of result if it is closer to running out than the one already reported
+func setTighterRateLimitHeaders(c echo.Context, result rateLimitResult) {
+	remaining, err := strconv.Atoi(c.Response().Header().Get(HeaderRateLimitRemaining))
+	if err != nil || result.remaining <= remaining || !result.allowed {
+		setRateLimitHeaders(c, result)
+	}
+}
+
+func rateLimited(c echo.Context, result rateLimitResult) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.retryAfter)))
+	return NewError(http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+}
+
+// credential identifies the bucket of the authenticated user of a request, it is empty for anonymous requests
+func credential(c echo.Context) string {
+	if userID, ok := UserID(c); ok {
+		return "user:" + strconv.FormatUint(userID, 10)
+	}
+
+	return ""
+}
+
+// IPExtractor returns how the client IP of a request is found. Without trusted proxies it is the address of the
+// connection; X-Forwarded-For is only read when the request came through one of the proxy ranges, and then only the
+// hops those proxies added are trusted, so clients cannot pick their own IP and bucket.
+func IPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range trustedProxies {
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy range %q: %w", proxy, err)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+type rateLimitResult struct {
+	allowed    bool
+	limit      int
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// take removes weight tokens from the bucket of key if it holds enough, otherwise it leaves it alone
+func (l *RateLimiter) take(key string, rate float64, burst, weight int) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+
+	b := l.bucket(key, rate, burst, now)
+	b.refill(now)
+	wait := b.wait(weight)
+	if wait == 0 {
+		b.take(weight)
+	}
+
+	return rateLimitResult{
+		allowed:    wait == 0,
+		limit:      b.burst,
+		remaining:  int(math.Max(0, math.Floor(b.tokens))),
+		reset:      b.untilFull(),
+		retryAfter: wait,
+	}
+}
+
+// refund gives weight tokens taken by Limit back to the bucket of key
+func (l *RateLimiter) refund(key string, weight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(float64(b.burst), b.tokens+b.cost(weight))
+	}
+}
+
+func (l *RateLimiter) bucket(key string, rate float64, burst int, now time.Time) *tokenBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{rate: rate, burst: burst, tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+
+	return b
+}
+
+// cleanup drops full buckets, they behave exactly like new ones so unknown keys and IPs do not pile up
+func (l *RateLimiter) cleanup(now time.Time) {
+	if l.lastCleanup.IsZero() {
+		l.lastCleanup = now
+	}
+	if now.Sub(l.lastCleanup) < bucketCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// tokenBucket holds up to burst tokens and refills at rate tokens per second
+type tokenBucket struct {
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.burst), b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+}
+
+// take removes weight tokens, a weight above the burst empties a full bucket
+func (b *tokenBucket) take(weight int) {
+	b.tokens -= b.cost(weight)
+}
+
+// wait returns how long until the bucket holds enough tokens for weight
+func (b *tokenBucket) wait(weight int) time.Duration {
+	missing := b.cost(weight) - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+
+	return time.Duration(missing / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) cost(weight int) float64 {
+	return math.Min(float64(weight), float64(b.burst))
+}
+
+func (b *tokenBucket) untilFull() time.Duration {
+	return time.Duration((float64(b.burst) - b.tokens) / b.rate * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
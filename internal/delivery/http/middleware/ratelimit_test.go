@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{
+		IPRate:   10,
+		IPBurst:  10,
+		KeyRate:  1,
+		KeyBurst: 4,
+		Weights:  RateLimitWeights{OrderEntry: 2, MarketData: 1},
+	})
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	// authenticate accepts the requests signed with "valid", key-a and key-c belong to the same user
+	owners := map[string]uint64{"key-a": 1, "key-b": 2, "key-c": 1}
+	authenticate := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get(HeaderAPISignature) != "valid" {
+				return NewError(http.StatusUnauthorized, CodeUnauthenticated, "invalid signature")
+			}
+			c.Set(userIDContextKey, owners[c.Request().Header.Get(HeaderAPIKey)])
+			return next(c)
+		}
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.POST("/orders", ok, limiter.OrderEntry(), authenticate, limiter.Credential())
+	e.GET("/books/:market", ok, limiter.MarketData())
+
+	serveSigned := func(method, path, apiKey, ip, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if apiKey != "" {
+			req.Header.Set(HeaderAPIKey, apiKey)
+			req.Header.Set(HeaderAPISignature, signature)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	serve := func(method, path, apiKey, ip string) *httptest.ResponseRecorder {
+		return serveSigned(method, path, apiKey, ip, "valid")
+	}
+
+	// Test case 1: order entry takes two tokens from the key bucket
+	rec := serve(http.MethodPost, "/orders", "key-a", "10.0.0.1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "4", rec.Header().Get(HeaderRateLimitLimit))
+	require.Equal(t, "2", rec.Header().Get(HeaderRateLimitRemaining))
+
+	rec = serve(http.MethodPost, "/orders", "key-a", "10.0.0.1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+
+	// Test case 2: an empty bucket is rejected with the time until enough tokens are back, other keys of the same user
+	// share it
+	rec = serve(http.MethodPost, "/orders", "key-a", "10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "2", rec.Header().Get(echo.HeaderRetryAfter))
+	require.Contains(t, rec.Body.String(), `"code":"RATE_LIMITED"`)
+	rec = serve(http.MethodPost, "/orders", "key-c", "10.0.0.5")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	// Test case 3: other users from the same IP have their own bucket, requests failing authentication take none of
+	// its tokens
+	for i := 0; i < 3; i++ {
+		rec = serveSigned(http.MethodPost, "/orders", "key-b", "10.0.0.9", "forged")
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	rec = serve(http.MethodPost, "/orders", "key-b", "10.0.0.1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "2", rec.Header().Get(HeaderRateLimitRemaining))
+
+	// Test case 4: the IP bucket limits all requests of a client, rejected requests take no tokens
+	for i := 0; i < 4; i++ {
+		rec = serve(http.MethodGet, "/books/ETH", "", "10.0.0.1")
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+	rec = serve(http.MethodGet, "/books/ETH", "", "10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+
+	rec = serve(http.MethodGet, "/books/ETH", "", "10.0.0.2")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Test case 5: buckets refill over time
+	now = now.Add(2 * time.Second)
+	rec = serve(http.MethodPost, "/orders", "key-a", "10.0.0.1")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Test case 6: full buckets are dropped
+	now = now.Add(time.Hour)
+	serve(http.MethodGet, "/books/ETH", "", "10.0.0.3")
+	require.Len(t, limiter.buckets, 1)
+}
+
+func TestRegistrationLimit(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{RegisterRate: 0.5, RegisterBurst: 2})
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.POST("/users", func(c echo.Context) error { return c.NoContent(http.StatusCreated) }, limiter.Default(), limiter.Registration())
+	register := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Test case 1: an IP registers up to the burst, then waits for the refill
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusCreated, register("10.0.0.1").Code)
+	}
+	rec := register("10.0.0.1")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "2", rec.Header().Get(echo.HeaderRetryAfter))
+	require.Equal(t, "2", rec.Header().Get(HeaderRateLimitLimit))
+
+	// Test case 2: other IPs are not affected, and the IP registers again once refilled
+	require.Equal(t, http.StatusCreated, register("10.0.0.2").Code)
+	now = now.Add(2 * time.Second)
+	require.Equal(t, http.StatusCreated, register("10.0.0.1").Code)
+}
+
+func TestIPExtractor(t *testing.T) {
+	request := func(remoteAddr, forwardedFor string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr + ":1234"
+		req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		return req
+	}
+
+	// Test case 1: without trusted proxies X-Forwarded-For is ignored
+	extract, err := IPExtractor(nil)
+	require.NoError(t, err)
+	require.Equal(t, "203.0.113.7", extract(request("203.0.113.7", "198.51.100.1")))
+
+	// Test case 2: behind a trusted proxy the client is the address the proxy saw
+	extract, err = IPExtractor([]string{"10.1.0.0/16"})
+	require.NoError(t, err)
+	require.Equal(t, "198.51.100.1", extract(request("10.1.2.3", "198.51.100.1")))
+	require.Equal(t, "198.51.100.1", extract(request("10.1.2.3", "192.0.2.99, 198.51.100.1")))
+
+	// Test case 3: other peers cannot set their IP, private networks are not trusted by default
+	require.Equal(t, "203.0.113.7", extract(request("203.0.113.7", "198.51.100.1")))
+	require.Equal(t, "192.168.1.5", extract(request("192.168.1.5", "198.51.100.1")))
+
+	// Test case 4: ranges must be CIDRs
+	_, err = IPExtractor([]string{"10.1.2.3"})
+	require.Error(t, err)
+}
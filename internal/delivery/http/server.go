@@ -2,29 +2,53 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
+	"io"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/labstack/echo/v4"
 	echoMiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/config"
+	gateway "github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/fix"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/handler"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/rpc"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
-	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage/boltdb"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage/postgres"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/treasury"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/ethclient"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/fix"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/hdwallet"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/signer"
+	"google.golang.org/grpc"
 )
 
-// Server represents the HTTP server for the exchange
+// Server represents the HTTP server for the exchange, the gRPC API and the FIX gateway are served next to it
 type Server struct {
-	echo    *echo.Echo
-	handler *handler.Handler
-	config  *config.Config
+	echo     *echo.Echo
+	grpc     *grpc.Server
+	fix      *fix.Acceptor
+	handler  *handler.Handler
+	verifier *auth.Verifier
+	config   *config.Config
+	treasury *treasury.Treasury
+	// stores persist the exchange state, they are closed on shutdown
+	stores []io.Closer
 }
 
 // New creates a new HTTP server
@@ -32,20 +56,40 @@ func New(cfg *config.Config) (*Server, error) {
 	// Create Echo instance
 	e := echo.New()
 
+	// Every error is answered with the same error envelope
+	e.HTTPErrorHandler = middleware.HTTPErrorHandler
+
+	// Client IPs identify the rate limit buckets, they are only taken from headers set by trusted proxies
+	ipExtractor, err := middleware.IPExtractor(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	e.IPExtractor = ipExtractor
+
 	// Set up middleware
 	e.Use(echoMiddleware.Recover())
 	e.Use(echoMiddleware.CORSWithConfig(echoMiddleware.CORSConfig{
 		AllowOrigins: []string{"*"},
 		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		AllowHeaders: []string{
+			echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization,
+			middleware.HeaderAPIKey, middleware.HeaderAPITimestamp, middleware.HeaderAPISignature,
+		},
+		ExposeHeaders: []string{
+			echo.HeaderRetryAfter, middleware.HeaderRateLimitLimit, middleware.HeaderRateLimitRemaining,
+			middleware.HeaderRateLimitReset,
+		},
 	}))
 
 	// Use custom middleware
 	e.Use(middleware.RequestLogger(middleware.RequestLoggerConfig{
-		LogRequestBody:  true,
+		LogRequestBody: true,
+		// Login signatures and refresh tokens stay out of the log
+		SkipRequestBody: func(c echo.Context) bool {
+			return strings.HasPrefix(c.Path(), "/v1/auth/")
+		},
 		LogResponseBody: false,
 	}))
-	e.Use(middleware.ErrorHandler())
 
 	// Create ETH client
 	ethClient, err := ethclient.New(cfg.ETHHost)
@@ -53,40 +97,177 @@ func New(cfg *config.Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to create ETH client: %w", err)
 	}
 
+	// Open the encrypted keystore holding the exchange and user keys
+	if cfg.KeystoreDir == "" {
+		return nil, fmt.Errorf("keystore directory is not configured")
+	}
+	ks := signer.OpenKeystore(cfg.KeystoreDir)
+
+	exchangeAddress := common.HexToAddress(cfg.ExchangeAddress)
+	exchangeSigner, err := signer.NewKeystoreSigner(ks, exchangeAddress, cfg.KeystorePassphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exchange signer: %w", err)
+	}
+
+	// Load the HD wallet user deposit addresses are derived from, a fresh seed is generated on first start
+	wallet, err := loadWallet(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load HD wallet: %w", err)
+	}
+
 	// Create exchange
-	exchange, err := exchanges.New(cfg.ExchangePrivateKey, ethClient)
+	exchange, err := exchanges.New(exchangeSigner, wallet, ethClient)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create exchange: %w", err)
 	}
 
-	// Add test users
-	user1, err := models.NewUser("0c4678963e0aa2cf580300be0536f69e0b77f7dea52ba9de5f18a739e4c26d3c", 1)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create user1: %w", err)
+	// Persist the account state and the trade history if a database is configured, otherwise the trade history
+	// alone if a trade store is configured
+	var stores []io.Closer
+	var tradeStore trades.Store
+	var db interface {
+		storage.Repository
+		trades.Store
+	}
+	if cfg.DatabaseURL != "" {
+		pg, err := postgres.Open(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+		db = pg
+	} else if cfg.DatabasePath != "" {
+		bolt, err := boltdb.Open(cfg.DatabasePath)
+		if err != nil {
+			return nil, err
+		}
+		db = bolt
+	}
+	if db != nil {
+		stores = append(stores, db)
+		tradeStore = db
+
+		if err := exchange.UseRepository(db); err != nil {
+			return nil, fmt.Errorf("failed to restore exchange state: %w", err)
+		}
+	} else if cfg.TradeStoreDir != "" {
+		fileStore, err := trades.NewFileStore(cfg.TradeStoreDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trade store: %w", err)
+		}
+		stores = append(stores, fileStore)
+		tradeStore = fileStore
+	}
+	if tradeStore != nil {
+		if err := exchange.UseTradeStore(tradeStore, cfg.TradeRingSize); err != nil {
+			return nil, fmt.Errorf("failed to load trade history: %w", err)
+		}
 	}
 
-	user2, err := models.NewUser("2ab26e4ce65e1d4f36aad9b48f39dde64b263b6134b8998015caa981124bb867", 2)
+	// Add test users, unless they were restored from the database
+	for _, userID := range []uint64{1, 2} {
+		if _, err := exchange.GetUser(userID); err == nil {
+			continue
+		}
+		if _, err := exchange.OnboardUser(userID); err != nil {
+			return nil, fmt.Errorf("failed to create user%d: %w", userID, err)
+		}
+	}
+
+	// Create treasury if a cold wallet is configured
+	var tr *treasury.Treasury
+	if cfg.ColdWalletAddress != "" {
+		exchange.ReserveAddresses = append(exchange.ReserveAddresses, cfg.ColdWalletAddress)
+
+		tr, err = newTreasury(cfg, ethClient, exchange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create treasury: %w", err)
+		}
+		exchange.Treasury = tr
+	}
+
+	// Add the operator credential to the API keys if one is configured, it is not stored with the user keys
+	keys := exchange.Keys
+	if cfg.AdminAPIKey != "" {
+		if cfg.AdminAPISecret == "" {
+			return nil, fmt.Errorf("admin API secret is not configured")
+		}
+
+		keys.Add(&auth.APIKey{
+			Key:         cfg.AdminAPIKey,
+			Secret:      cfg.AdminAPISecret,
+			Permissions: auth.Permissions{auth.PermissionAdmin},
+			CreatedAt:   time.Now().UnixNano(),
+		})
+	}
+
+	// Create web UI sessions
+	sessions, err := newSessions(cfg, ethClient)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create user2: %w", err)
+		return nil, fmt.Errorf("failed to create sessions: %w", err)
 	}
 
-	exchange.AddUser(user1)
-	exchange.AddUser(user2)
+	// Create handler, signed requests are verified once for both APIs so a signature cannot be replayed on the other
+	handler := handler.New(exchange, keys, sessions)
+	verifier := auth.NewVerifier(keys, cfg.APIKeyReplayWindow)
 
-	// Create handler
-	handler := handler.New(exchange)
+	// Create the FIX gateway, its sessions continue across restarts if a store is configured
+	compID := cfg.FIXCompID
+	if compID == "" {
+		compID = "EXCHANGE"
+	}
+	var fixStore fix.Store
+	if cfg.FIXStorePath != "" {
+		boltStore, err := fix.OpenBoltStore(cfg.FIXStorePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open FIX store: %w", err)
+		}
+		stores = append(stores, boltStore)
+		fixStore = boltStore
+	}
+	acceptor := fix.NewAcceptor(fix.AcceptorConfig{CompID: compID, Store: fixStore}, gateway.New(exchange, verifier, compID))
 
 	return &Server{
-		echo:    e,
-		handler: handler,
-		config:  cfg,
+		echo:     e,
+		grpc:     rpc.NewServer(exchange, verifier, sessions),
+		fix:      acceptor,
+		handler:  handler,
+		verifier: verifier,
+		config:   cfg,
+		treasury: tr,
+
+		stores: stores,
 	}, nil
 }
 
 // Start starts the HTTP server
 func (s *Server) Start() error {
-	// Register routes
-	s.handler.RegisterRoutes(s.echo)
+	// Register routes, requests with a bearer token use session authentication and all others signed API keys
+	apiKeyAuth := middleware.APIKeyAuth(middleware.APIKeyAuthConfig{
+		Skipper:      middleware.HasBearerToken,
+		Keys:         s.handler.Keys,
+		ReplayWindow: s.config.APIKeyReplayWindow,
+		Verifier:     s.verifier,
+	})
+	sessionAuth := middleware.SessionAuth(middleware.SessionAuthConfig{
+		Skipper:  func(c echo.Context) bool { return !middleware.HasBearerToken(c) },
+		Sessions: s.handler.Sessions,
+	})
+	limiter := middleware.NewRateLimiter(middleware.RateLimitConfig{
+		IPRate:        s.config.RateLimitIPRate,
+		IPBurst:       s.config.RateLimitIPBurst,
+		KeyRate:       s.config.RateLimitKeyRate,
+		KeyBurst:      s.config.RateLimitKeyBurst,
+		RegisterRate:  s.config.RateLimitRegisterRate,
+		RegisterBurst: s.config.RateLimitRegisterBurst,
+		Weights: middleware.RateLimitWeights{
+			OrderEntry: s.config.RateLimitOrderWeight,
+			Cancel:     s.config.RateLimitCancelWeight,
+			MarketData: s.config.RateLimitMarketDataWeight,
+		},
+	})
+	s.handler.RegisterRoutes(s.echo, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return apiKeyAuth(sessionAuth(next))
+	}, limiter)
 
 	// Get port from config
 	port := s.config.ServerPort
@@ -104,6 +285,55 @@ func (s *Server) Start() error {
 
 	log.Printf("Server started on port %s", port)
 
+	// Serve the gRPC API if a port is configured
+	if s.config.GRPCPort != "" {
+		listener, err := net.Listen("tcp", ":"+s.config.GRPCPort)
+		if err != nil {
+			return fmt.Errorf("failed to listen for gRPC: %w", err)
+		}
+
+		go func() {
+			if err := s.grpc.Serve(listener); err != nil {
+				log.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+
+		log.Printf("gRPC server started on port %s", s.config.GRPCPort)
+	}
+
+	// Serve the FIX gateway if a port is configured
+	if s.config.FIXPort != "" {
+		listener, err := net.Listen("tcp", ":"+s.config.FIXPort)
+		if err != nil {
+			return fmt.Errorf("failed to listen for FIX: %w", err)
+		}
+
+		go func() {
+			if err := s.fix.Serve(listener); err != nil {
+				log.Fatalf("Failed to start FIX gateway: %v", err)
+			}
+		}()
+
+		log.Printf("FIX gateway started on port %s", s.config.FIXPort)
+	}
+
+	// Run the treasury until the server shuts down
+	treasuryCtx, stopTreasury := context.WithCancel(context.Background())
+	defer stopTreasury()
+	if s.treasury != nil {
+		interval := s.config.TreasuryInterval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+
+		go s.treasury.Run(treasuryCtx, interval)
+		go func() {
+			for alert := range s.treasury.Alerts() {
+				log.Printf("TREASURY ALERT => kind [%s] | hot balance [%s] | required [%s]", alert.Kind, alert.HotBalance, alert.Required)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shut down the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -115,6 +345,105 @@ func (s *Server) Start() error {
 	if err := s.echo.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to gracefully shut down server: %w", err)
 	}
+	// Streams only end when their clients leave, they are cut when the timeout expires
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.grpc.Stop()
+	}
+	// FIX sessions are logged out, their sequence numbers are stored for the next logon
+	if err := s.fix.Close(); err != nil {
+		return fmt.Errorf("failed to close FIX gateway: %w", err)
+	}
+	for _, store := range s.stores {
+		if err := store.Close(); err != nil {
+			return fmt.Errorf("failed to close store: %w", err)
+		}
+	}
 
 	return nil
 }
+
+// newTreasury creates the treasury that keeps the exchange hot wallet between the configured watermarks
+func newTreasury(cfg *config.Config, ethClient *ethclient.Client, exchange *exchanges.Exchange) (*treasury.Treasury, error) {
+	low, ok := new(big.Int).SetString(cfg.HotWalletLowWatermark, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid hot wallet low watermark: %q", cfg.HotWalletLowWatermark)
+	}
+
+	high, ok := new(big.Int).SetString(cfg.HotWalletHighWatermark, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid hot wallet high watermark: %q", cfg.HotWalletHighWatermark)
+	}
+
+	return treasury.New(ethClient, exchange.Signer, treasury.Config{
+		ColdAddress:   common.HexToAddress(cfg.ColdWalletAddress),
+		LowWatermark:  low,
+		HighWatermark: high,
+		DepositSweeper: func() (*big.Int, error) {
+			return exchange.SweepDeposits(exchanges.MarketETH)
+		},
+	})
+}
+
+// newSessions creates the web UI session issuer from the configuration
+func newSessions(cfg *config.Config, ethClient *ethclient.Client) (*auth.Sessions, error) {
+	secret := []byte(cfg.JWTSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		log.Printf("No JWT secret configured, web UI sessions will not survive a restart")
+	}
+
+	accessTTL := cfg.AccessTokenTTL
+	if accessTTL <= 0 {
+		accessTTL = 15 * time.Minute
+	}
+
+	refreshTTL := cfg.RefreshTokenTTL
+	if refreshTTL <= 0 {
+		refreshTTL = 7 * 24 * time.Hour
+	}
+
+	domain := cfg.SessionDomain
+	if domain == "" {
+		domain = "localhost"
+	}
+
+	return auth.NewSessions(auth.SessionsConfig{
+		Secret:     secret,
+		Domain:     domain,
+		ChainID:    ethClient.ChainID.Int64(),
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+	})
+}
+
+// loadWallet opens the configured HD wallet seed file, creating it with a random seed if it does not exist yet
+func loadWallet(cfg *config.Config) (*hdwallet.Wallet, error) {
+	if cfg.HDSeedFile == "" {
+		return nil, fmt.Errorf("HD seed file is not configured")
+	}
+
+	if _, err := os.Stat(cfg.HDSeedFile); os.IsNotExist(err) {
+		seed, err := hdwallet.NewSeed()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := hdwallet.CreateSeedFile(cfg.HDSeedFile, cfg.KeystorePassphrase, seed, keystore.StandardScryptN, keystore.StandardScryptP); err != nil {
+			return nil, err
+		}
+
+		log.Printf("Created new HD wallet seed at %s", cfg.HDSeedFile)
+	}
+
+	return hdwallet.LoadWallet(cfg.HDSeedFile, cfg.KeystorePassphrase)
+}
@@ -0,0 +1,144 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	exchangev1 "github.com/taha-ahmadi/cryptocurrency-exchange/api/proto/exchange/v1"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MetadataAPIKey carries the public API key of the caller
+	MetadataAPIKey = "x-api-key"
+	// MetadataAPITimestamp carries the call time in unix milliseconds
+	MetadataAPITimestamp = "x-api-timestamp"
+	// MetadataAPISignature carries the hex encoded HMAC-SHA256 signature of the call
+	MetadataAPISignature = "x-api-signature"
+	// MetadataAuthorization carries a session access token as "Bearer <token>"
+	MetadataAuthorization = "authorization"
+
+	// signedMethod stands in for the HTTP method in the signed payload, gRPC calls are HTTP/2 POST requests
+	signedMethod = "POST"
+)
+
+// permissions are what the methods acting on behalf of a user require, the other methods are public
+var permissions = map[string]auth.Permission{
+	exchangev1.ExchangeService_PlaceOrder_FullMethodName:          auth.PermissionTrade,
+	exchangev1.ExchangeService_CancelOrder_FullMethodName:         auth.PermissionTrade,
+	exchangev1.ExchangeService_AmendOrder_FullMethodName:          auth.PermissionTrade,
+	exchangev1.ExchangeService_GetOrders_FullMethodName:           auth.PermissionRead,
+	exchangev1.ExchangeService_SubscribeExecutions_FullMethodName: auth.PermissionRead,
+}
+
+// caller is the authenticated user of a call
+type caller struct {
+	userID      uint64
+	permissions auth.Permissions
+}
+
+type callerKey struct{}
+
+// callerFrom returns the authenticated user of the call
+func callerFrom(ctx context.Context) (caller, bool) {
+	c, ok := ctx.Value(callerKey{}).(caller)
+	return c, ok
+}
+
+// authenticator authenticates calls with the credentials of the HTTP API
+type authenticator struct {
+	verifier *auth.Verifier
+	sessions *auth.Sessions
+}
+
+// unary is the interceptor of unary calls, the request is part of the signed payload
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	permission, ok := permissions[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil, newError(codes.Internal, middleware.CodeInternal, "request is not a protobuf message")
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, newError(codes.InvalidArgument, middleware.CodeInvalidRequest, "invalid request")
+	}
+
+	ctx, err = a.authenticate(ctx, info.FullMethod, body, permission)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// stream is the interceptor of streaming calls, they are signed with an empty request
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	permission, ok := permissions[info.FullMethod]
+	if !ok {
+		return handler(srv, ss)
+	}
+
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod, nil, permission)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate checks the credentials in the metadata grant the permission and returns the context holding the caller
+func (a *authenticator) authenticate(ctx context.Context, method string, body []byte, permission auth.Permission) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var c caller
+	if header := first(md, MetadataAuthorization); header != "" {
+		if !strings.HasPrefix(header, "Bearer ") {
+			return nil, newError(codes.Unauthenticated, middleware.CodeUnauthenticated, "missing access token")
+		}
+
+		claims, err := a.sessions.ParseAccessToken(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			return nil, newError(codes.Unauthenticated, middleware.CodeUnauthenticated, err.Error())
+		}
+		c = caller{userID: claims.UserID, permissions: claims.Permissions}
+	} else {
+		apiKey, err := a.verifier.Verify(first(md, MetadataAPIKey), first(md, MetadataAPITimestamp),
+			first(md, MetadataAPISignature), signedMethod, method, body)
+		if err != nil {
+			return nil, newError(codes.Unauthenticated, middleware.CodeUnauthenticated, err.Error())
+		}
+		c = caller{userID: apiKey.UserID, permissions: apiKey.Permissions}
+	}
+
+	if !c.permissions.Has(permission) {
+		return nil, newError(codes.PermissionDenied, middleware.CodeForbidden, "missing permission: "+string(permission))
+	}
+
+	return context.WithValue(ctx, callerKey{}, c), nil
+}
+
+// authenticatedStream is a server stream whose context holds the caller
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
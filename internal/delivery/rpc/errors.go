@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"errors"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/ledger"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the google.rpc.ErrorInfo detail of failed calls
+const ErrorDomain = "exchange.v1"
+
+// knownErrors are the errors of the exchange clients can act on, with their gRPC code and the error code of the HTTP
+// API they share
+var knownErrors = []struct {
+	err  error
+	code codes.Code
+	api  middleware.ErrorCode
+}{
+	{exchanges.ErrUnknownMarket, codes.InvalidArgument, middleware.CodeUnknownMarket},
+	{exchanges.ErrUnknownOrderType, codes.InvalidArgument, middleware.CodeUnknownOrderType},
+	{ledger.ErrInsufficientBalance, codes.FailedPrecondition, middleware.CodeInsufficientBalance},
+	{exchanges.ErrInsufficientLiquidity, codes.FailedPrecondition, middleware.CodeInsufficientLiquidity},
+	{exchanges.ErrUserNotFound, codes.NotFound, middleware.CodeUserNotFound},
+	{exchanges.ErrUserNotActive, codes.PermissionDenied, middleware.CodeUserNotActive},
+	{exchanges.ErrOrderNotFound, codes.NotFound, middleware.CodeOrderNotFound},
+	{exchanges.ErrForbidden, codes.PermissionDenied, middleware.CodeForbidden},
+	{exchanges.ErrTradingHalted, codes.Unavailable, middleware.CodeTradingHalted},
+	{errForbiddenUser, codes.PermissionDenied, middleware.CodeForbidden},
+}
+
+// statusError returns the gRPC status of err, errors that are not known get the fallback code
+func statusError(err error, fallback codes.Code) error {
+	for _, known := range knownErrors {
+		if errors.Is(err, known.err) {
+			return newError(known.code, known.api, err.Error())
+		}
+	}
+
+	return newError(fallback, codeForStatus(fallback), err.Error())
+}
+
+// newError returns a status carrying the error code of the HTTP API as the reason of its ErrorInfo
+func newError(code codes.Code, api middleware.ErrorCode, message string) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: string(api), Domain: ErrorDomain}); err == nil {
+		st = detailed
+	}
+
+	return st.Err()
+}
+
+// codeForStatus returns the generic error code of the HTTP API for a gRPC code
+func codeForStatus(code codes.Code) middleware.ErrorCode {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return middleware.CodeInvalidRequest
+	case codes.Unauthenticated:
+		return middleware.CodeUnauthenticated
+	case codes.PermissionDenied:
+		return middleware.CodeForbidden
+	case codes.NotFound:
+		return middleware.CodeNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return middleware.CodeConflict
+	case codes.ResourceExhausted:
+		return middleware.CodeRateLimited
+	default:
+		return middleware.CodeInternal
+	}
+}
+
+// ErrorCode returns the error code of the HTTP API a failed call carries, or an empty code for errors of other
+// servers
+func ErrorCode(err error) middleware.ErrorCode {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.Domain == ErrorDomain {
+			return middleware.ErrorCode(info.Reason)
+		}
+	}
+
+	return ""
+}
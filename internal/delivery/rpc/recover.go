@@ -0,0 +1,38 @@
+package rpc
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// recoverUnary turns a panic of a call into an internal error instead of taking the server down
+func recoverUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(info.FullMethod, r)
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// recoverStream turns a panic of a streaming call into an internal error instead of taking the server down
+func recoverStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = recovered(info.FullMethod, r)
+		}
+	}()
+
+	return handler(srv, ss)
+}
+
+func recovered(method string, r interface{}) error {
+	log.Printf("rpc: %s: panic: %v\n%s", method, r, debug.Stack())
+	return newError(codes.Internal, middleware.CodeInternal, "internal error")
+}
@@ -0,0 +1,32 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecover(t *testing.T) {
+	panics := func(context.Context, interface{}) (interface{}, error) { panic("boom") }
+	info := &grpc.UnaryServerInfo{FullMethod: "/exchange.v1.ExchangeService/PlaceOrder"}
+
+	// Test case 1: a panicking call fails with an internal error
+	resp, err := recoverUnary(context.Background(), nil, info, panics)
+	require.Nil(t, resp)
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	// Test case 2: a panicking stream fails with an internal error
+	err = recoverStream(nil, nil, &grpc.StreamServerInfo{}, func(interface{}, grpc.ServerStream) error { panic("boom") })
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	// Test case 3: other calls are passed through
+	resp, err = recoverUnary(context.Background(), "req", info, func(_ context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "req", resp)
+}
@@ -0,0 +1,71 @@
+// Package rpc serves the gRPC API of the exchange defined in api/proto/exchange/v1. It trades on the same exchange as
+// the HTTP API and accepts the same API keys and session tokens.
+package rpc
+
+import (
+	"sync"
+
+	exchangev1 "github.com/taha-ahmadi/cryptocurrency-exchange/api/proto/exchange/v1"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"google.golang.org/grpc"
+)
+
+// streamBufferSize is how many messages may wait for a slow client before its stream is ended
+const streamBufferSize = 256
+
+// Service implements the ExchangeService of the gRPC API
+type Service struct {
+	exchangev1.UnimplementedExchangeServiceServer
+
+	Exchange *exchanges.Exchange
+}
+
+// NewServer creates the gRPC server of the exchange, a panicking call fails with an internal error. Calls are authenticated with API keys checked by verifier,
+// sharing it with the HTTP API makes a signature usable only once on either, or with access tokens of sessions.
+func NewServer(exchange *exchanges.Exchange, verifier *auth.Verifier, sessions *auth.Sessions, opts ...grpc.ServerOption) *grpc.Server {
+	a := &authenticator{verifier: verifier, sessions: sessions}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(recoverUnary, a.unary),
+		grpc.ChainStreamInterceptor(recoverStream, a.stream),
+	)
+
+	s := grpc.NewServer(opts...)
+	exchangev1.RegisterExchangeServiceServer(s, &Service{Exchange: exchange})
+
+	return s
+}
+
+// streamSink queues the messages of a subscription for a streaming call, it is a marketdata.Sink
+type streamSink struct {
+	send chan interface{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newStreamSink() *streamSink {
+	return &streamSink{
+		send: make(chan interface{}, streamBufferSize),
+		done: make(chan struct{}),
+	}
+}
+
+// Send queues a message without blocking, a full queue ends the stream
+func (s *streamSink) Send(msg interface{}) bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+	}
+
+	select {
+	case s.send <- msg:
+		return true
+	default:
+		s.closeOnce.Do(func() {
+			close(s.done)
+		})
+		return false
+	}
+}
@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"context"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	exchangev1 "github.com/taha-ahmadi/cryptocurrency-exchange/api/proto/exchange/v1"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeSigner struct{ address common.Address }
+
+func (s fakeSigner) Address() common.Address { return s.address }
+func (s fakeSigner) SignTx(tx *types.Transaction, _ *big.Int) (*types.Transaction, error) {
+	return tx, nil
+}
+
+// testServer serves an exchange with users 1 and 2, who hold funds of every asset over an in-memory connection
+type testServer struct {
+	exchange *exchanges.Exchange
+	keys     *auth.KeyStore
+	verifier *auth.Verifier
+	sessions *auth.Sessions
+	listener *bufconn.Listener
+}
+
+func newTestServer(t *testing.T) *testServer {
+	ex, err := exchanges.New(fakeSigner{address: common.HexToAddress("0xff")}, nil, nil)
+	require.NoError(t, err)
+	for _, id := range []uint64{1, 2} {
+		user, err := models.NewUser(fakeSigner{address: common.BigToAddress(new(big.Int).SetUint64(id))}, id)
+		require.NoError(t, err)
+		require.NoError(t, ex.AddUser(user))
+		for _, asset := range []string{string(exchanges.MarketETH), string(exchanges.MarketBTC), exchanges.QuoteAsset} {
+			ex.Ledger.Credit(id, asset, 1_000_000)
+		}
+	}
+
+	sessions, err := auth.NewSessions(auth.SessionsConfig{
+		Secret:     []byte("0123456789abcdef0123456789abcdef"),
+		Domain:     "localhost",
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+	require.NoError(t, err)
+
+	keys := auth.NewKeyStore()
+	s := &testServer{
+		exchange: ex,
+		keys:     keys,
+		verifier: auth.NewVerifier(keys, time.Minute),
+		sessions: sessions,
+		listener: bufconn.Listen(1 << 20),
+	}
+
+	server := NewServer(ex, s.verifier, sessions)
+	go func() { _ = server.Serve(s.listener) }()
+	t.Cleanup(server.Stop)
+
+	return s
+}
+
+// client connects with the given interceptors
+func (s *testServer) client(t *testing.T, opts ...grpc.DialOption) exchangev1.ExchangeServiceClient {
+	opts = append(opts,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return s.listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	conn, err := grpc.Dial("bufnet", opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return exchangev1.NewExchangeServiceClient(conn)
+}
+
+// signedClient connects with a new API key of the user
+func (s *testServer) signedClient(t *testing.T, userID uint64, permissions ...auth.Permission) exchangev1.ExchangeServiceClient {
+	key, err := s.keys.Issue(userID, permissions...)
+	require.NoError(t, err)
+
+	return s.client(t, grpc.WithUnaryInterceptor(SignUnary(key.Key, key.Secret)), grpc.WithStreamInterceptor(SignStream(key.Key, key.Secret)))
+}
+
+func requireCode(t *testing.T, err error, code codes.Code, api middleware.ErrorCode) {
+	t.Helper()
+	require.Error(t, err)
+	require.Equal(t, code, status.Code(err), err.Error())
+	require.Equal(t, api, ErrorCode(err))
+}
+
+func TestAuthentication(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	place := &exchangev1.PlaceOrderRequest{Market: "ETH", Type: exchangev1.OrderType_ORDER_TYPE_LIMIT, Amount: 1, Price: 100}
+
+	// Test case 1: public methods need no credentials, the others do
+	anonymous := s.client(t)
+	_, err := anonymous.GetOrderbook(ctx, &exchangev1.GetOrderbookRequest{Market: "ETH"})
+	require.NoError(t, err)
+	_, err = anonymous.PlaceOrder(ctx, place)
+	requireCode(t, err, codes.Unauthenticated, middleware.CodeUnauthenticated)
+
+	// Test case 2: signed calls are accepted once
+	key, err := s.keys.Issue(1, auth.DefaultPermissions...)
+	require.NoError(t, err)
+	var md metadata.MD
+	replay := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if md != nil {
+			ctx = metadata.NewOutgoingContext(ctx, md)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	capture := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	client := s.client(t, grpc.WithChainUnaryInterceptor(SignUnary(key.Key, key.Secret), capture))
+	_, err = client.PlaceOrder(ctx, place)
+	require.NoError(t, err)
+
+	replayed := s.client(t, grpc.WithUnaryInterceptor(replay))
+	_, err = replayed.PlaceOrder(ctx, place)
+	requireCode(t, err, codes.Unauthenticated, middleware.CodeUnauthenticated)
+	require.Contains(t, status.Convert(err).Message(), auth.ErrReplayedRequest.Error())
+
+	// Test case 3: the signature covers the request
+	md = nil
+	tamper := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		req.(*exchangev1.PlaceOrderRequest).Amount = 5
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	tampered := s.client(t, grpc.WithChainUnaryInterceptor(SignUnary(key.Key, key.Secret), tamper))
+	_, err = tampered.PlaceOrder(ctx, place)
+	requireCode(t, err, codes.Unauthenticated, middleware.CodeUnauthenticated)
+	require.Contains(t, status.Convert(err).Message(), auth.ErrInvalidSignature.Error())
+
+	// Test case 4: credentials need the permission of the method
+	readOnly := s.signedClient(t, 1, auth.PermissionRead)
+	_, err = readOnly.PlaceOrder(ctx, place)
+	requireCode(t, err, codes.PermissionDenied, middleware.CodeForbidden)
+
+	// Test case 5: session access tokens are accepted
+	tokens, err := s.sessions.Issue(2, auth.DefaultPermissions)
+	require.NoError(t, err)
+	bearer := metadata.AppendToOutgoingContext(ctx, MetadataAuthorization, "Bearer "+tokens.AccessToken)
+	_, err = anonymous.PlaceOrder(bearer, place)
+	require.NoError(t, err)
+
+	orders, err := anonymous.GetOrders(bearer, &exchangev1.GetOrdersRequest{})
+	require.NoError(t, err)
+	require.Len(t, orders.Bids, 0)
+	require.Len(t, orders.Asks, 1)
+	require.Equal(t, uint64(2), orders.Asks[0].UserId)
+
+	_, err = anonymous.GetOrders(metadata.AppendToOutgoingContext(ctx, MetadataAuthorization, "Bearer invalid"), &exchangev1.GetOrdersRequest{})
+	requireCode(t, err, codes.Unauthenticated, middleware.CodeUnauthenticated)
+}
+
+func TestOrders(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	client := s.signedClient(t, 1, auth.DefaultPermissions...)
+
+	// Test case 1: limit orders rest in the book
+	placed, err := client.PlaceOrder(ctx, &exchangev1.PlaceOrderRequest{
+		Market: "ETH", Type: exchangev1.OrderType_ORDER_TYPE_LIMIT, Amount: 3, Price: 100,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, placed.OrderId)
+
+	book, err := client.GetOrderbook(ctx, &exchangev1.GetOrderbookRequest{Market: "ETH"})
+	require.NoError(t, err)
+	require.Len(t, book.Asks, 1)
+	require.Equal(t, 100.0, book.Asks[0].Price)
+	require.Equal(t, 3.0, book.Asks[0].Size)
+	require.Equal(t, int32(1), book.Asks[0].Orders)
+
+	// Test case 2: invalid orders and unknown markets are rejected with the codes of the HTTP API
+	_, err = client.PlaceOrder(ctx, &exchangev1.PlaceOrderRequest{Market: "ETH", Amount: 1, Price: 100})
+	requireCode(t, err, codes.InvalidArgument, middleware.CodeUnknownOrderType)
+	_, err = client.PlaceOrder(ctx, &exchangev1.PlaceOrderRequest{Market: "ETH", Type: exchangev1.OrderType_ORDER_TYPE_LIMIT, Price: 100})
+	requireCode(t, err, codes.InvalidArgument, middleware.CodeInvalidRequest)
+	_, err = client.GetOrderbook(ctx, &exchangev1.GetOrderbookRequest{Market: "DOGE"})
+	requireCode(t, err, codes.InvalidArgument, middleware.CodeUnknownMarket)
+
+	// Test case 3: amending replaces the order
+	amended, err := client.AmendOrder(ctx, &exchangev1.AmendOrderRequest{OrderId: placed.OrderId, Price: 101})
+	require.NoError(t, err)
+	require.NotEqual(t, placed.OrderId, amended.OrderId)
+
+	orders, err := client.GetOrders(ctx, &exchangev1.GetOrdersRequest{})
+	require.NoError(t, err)
+	require.Len(t, orders.Asks, 1)
+	require.Equal(t, amended.OrderId, orders.Asks[0].Id)
+	require.Equal(t, 101.0, orders.Asks[0].Price)
+	require.Equal(t, exchangev1.OrderStatus_ORDER_STATUS_OPEN, orders.Asks[0].Status)
+
+	// Test case 4: other users can neither cancel the order nor read the orders
+	other := s.signedClient(t, 2, auth.DefaultPermissions...)
+	_, err = other.CancelOrder(ctx, &exchangev1.CancelOrderRequest{OrderId: amended.OrderId})
+	requireCode(t, err, codes.PermissionDenied, middleware.CodeForbidden)
+	_, err = other.GetOrders(ctx, &exchangev1.GetOrdersRequest{UserId: 1})
+	requireCode(t, err, codes.PermissionDenied, middleware.CodeForbidden)
+
+	// Test case 5: the owner cancels the order
+	_, err = client.CancelOrder(ctx, &exchangev1.CancelOrderRequest{OrderId: amended.OrderId})
+	require.NoError(t, err)
+	_, err = client.CancelOrder(ctx, &exchangev1.CancelOrderRequest{OrderId: amended.OrderId})
+	requireCode(t, err, codes.NotFound, middleware.CodeOrderNotFound)
+
+	// Test case 6: admins read and cancel on behalf of users
+	placed, err = client.PlaceOrder(ctx, &exchangev1.PlaceOrderRequest{
+		Market: "ETH", Type: exchangev1.OrderType_ORDER_TYPE_LIMIT, IsBid: true, Amount: 1, Price: 90,
+	})
+	require.NoError(t, err)
+
+	admin := s.signedClient(t, 0, auth.PermissionAdmin)
+	orders, err = admin.GetOrders(ctx, &exchangev1.GetOrdersRequest{UserId: 1})
+	require.NoError(t, err)
+	require.Len(t, orders.Bids, 1)
+	_, err = admin.CancelOrder(ctx, &exchangev1.CancelOrderRequest{OrderId: placed.OrderId})
+	require.NoError(t, err)
+}
+
+func TestSubscriptions(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := s.signedClient(t, 1, auth.DefaultPermissions...)
+
+	_, err := client.PlaceOrder(ctx, &exchangev1.PlaceOrderRequest{
+		Market: "ETH", Type: exchangev1.OrderType_ORDER_TYPE_LIMIT, Amount: 2, Price: 100,
+	})
+	require.NoError(t, err)
+
+	// Test case 1: the book stream starts with a snapshot followed by sequenced deltas
+	book, err := client.SubscribeBook(ctx, &exchangev1.SubscribeBookRequest{Market: "ETH"})
+	require.NoError(t, err)
+	snapshot, err := book.Recv()
+	require.NoError(t, err)
+	require.Equal(t, exchangev1.BookUpdate_TYPE_SNAPSHOT, snapshot.Type)
+	require.Len(t, snapshot.Asks, 1)
+	require.Equal(t, 2.0, snapshot.Asks[0].Size)
+
+	// Test case 2: the execution stream reports the orders of the caller
+	executions, err := client.SubscribeExecutions(ctx, &exchangev1.SubscribeExecutionsRequest{})
+	require.NoError(t, err)
+	// The header arrives once the subscription is in place
+	_, err = executions.Header()
+	require.NoError(t, err)
+
+	placed, err := client.PlaceOrder(ctx, &exchangev1.PlaceOrderRequest{
+		Market: "ETH", Type: exchangev1.OrderType_ORDER_TYPE_LIMIT, IsBid: true, Amount: 1, Price: 90,
+	})
+	require.NoError(t, err)
+
+	report, err := executions.Recv()
+	require.NoError(t, err)
+	require.Equal(t, placed.OrderId, report.OrderId)
+	require.Equal(t, exchangev1.ExecStatus_EXEC_STATUS_ACCEPTED, report.Status)
+	require.Equal(t, exchangev1.OrderType_ORDER_TYPE_LIMIT, report.Type)
+	require.True(t, report.IsBid)
+
+	delta, err := book.Recv()
+	require.NoError(t, err)
+	require.Equal(t, exchangev1.BookUpdate_TYPE_DELTA, delta.Type)
+	require.Equal(t, snapshot.Seq+1, delta.Seq)
+	require.Len(t, delta.Bids, 1)
+	require.Equal(t, 90.0, delta.Bids[0].Price)
+	require.Equal(t, 1.0, delta.Bids[0].Size)
+
+	// Test case 3: unknown markets and other users cannot be subscribed to
+	unknown, err := client.SubscribeBook(ctx, &exchangev1.SubscribeBookRequest{Market: "DOGE"})
+	require.NoError(t, err)
+	_, err = unknown.Recv()
+	requireCode(t, err, codes.InvalidArgument, middleware.CodeUnknownMarket)
+
+	other, err := client.SubscribeExecutions(ctx, &exchangev1.SubscribeExecutionsRequest{UserId: 2})
+	require.NoError(t, err)
+	_, err = other.Recv()
+	requireCode(t, err, codes.PermissionDenied, middleware.CodeForbidden)
+}
@@ -0,0 +1,332 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	exchangev1 "github.com/taha-ahmadi/cryptocurrency-exchange/api/proto/exchange/v1"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/delivery/http/middleware"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/exchanges"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/marketdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// errForbiddenUser is returned by targetUser when the caller may not act on behalf of the requested user
+var errForbiddenUser = errors.New("cannot access data of another user")
+
+// PlaceOrder places an order for the caller
+func (s *Service) PlaceOrder(ctx context.Context, req *exchangev1.PlaceOrderRequest) (*exchangev1.PlaceOrderResponse, error) {
+	c, ok := callerFrom(ctx)
+	if !ok {
+		return nil, newError(codes.Unauthenticated, middleware.CodeUnauthenticated, "unauthenticated")
+	}
+
+	order := &exchanges.PlaceOrderRequest{
+		UserID: c.userID,
+		Type:   orderTypes[req.Type],
+		IsBid:  req.IsBid,
+		Amount: req.Amount,
+		Price:  req.Price,
+		Market: exchanges.Market(req.Market),
+	}
+	if err := order.Validate(); err != nil {
+		return nil, statusError(err, codes.InvalidArgument)
+	}
+
+	result, err := s.Exchange.PlaceOrder(order)
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+
+	resp := &exchangev1.PlaceOrderResponse{}
+	switch result := result.(type) {
+	case *exchanges.PlaceOrderResponse:
+		resp.OrderId = result.OrderID
+	case []*exchanges.MatchedOrder:
+		for _, match := range result {
+			resp.Matches = append(resp.Matches, &exchangev1.MatchedOrder{
+				Id:           match.ID,
+				UserId:       match.UserID,
+				Price:        match.Price,
+				AmountFilled: match.AmountFilled,
+				Fee:          match.Fee,
+			})
+		}
+	}
+
+	return resp, nil
+}
+
+// CancelOrder cancels a resting order of the caller, operators cancel on behalf of the order owner
+func (s *Service) CancelOrder(ctx context.Context, req *exchangev1.CancelOrderRequest) (*exchangev1.CancelOrderResponse, error) {
+	userID, err := s.orderOwner(ctx, req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Exchange.CancelOrder(userID, req.OrderId); err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+
+	return &exchangev1.CancelOrderResponse{}, nil
+}
+
+// AmendOrder replaces a resting order of the caller, operators amend on behalf of the order owner
+func (s *Service) AmendOrder(ctx context.Context, req *exchangev1.AmendOrderRequest) (*exchangev1.AmendOrderResponse, error) {
+	userID, err := s.orderOwner(ctx, req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+
+	amend := &exchanges.AmendOrderRequest{
+		UserID:  userID,
+		OrderID: req.OrderId,
+		Price:   req.Price,
+		Amount:  req.Amount,
+	}
+	if err := amend.Validate(); err != nil {
+		return nil, statusError(err, codes.InvalidArgument)
+	}
+
+	result, err := s.Exchange.AmendOrder(amend)
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+
+	return &exchangev1.AmendOrderResponse{OrderId: result.OrderID}, nil
+}
+
+// GetOrderbook returns the aggregated L2 orderbook of a market
+func (s *Service) GetOrderbook(_ context.Context, req *exchangev1.GetOrderbookRequest) (*exchangev1.Orderbook, error) {
+	query := &exchanges.GetDepthRequest{Market: exchanges.Market(req.Market), Depth: int(req.Depth), Grouping: req.Grouping}
+	if err := query.Validate(); err != nil {
+		return nil, statusError(err, codes.InvalidArgument)
+	}
+
+	depth, err := s.Exchange.GetDepth(query.Market, query.Depth, query.Grouping)
+	if err != nil {
+		return nil, statusError(err, codes.InvalidArgument)
+	}
+
+	return &exchangev1.Orderbook{
+		Market:          string(depth.Market),
+		TotalAsksVolume: depth.TotalAsksVolume,
+		TotalBidsVolume: depth.TotalBidsVolume,
+		Asks:            depthLevels(depth.Asks),
+		Bids:            depthLevels(depth.Bids),
+	}, nil
+}
+
+// GetOrders returns the open orders of the caller, admins may read another user
+func (s *Service) GetOrders(ctx context.Context, req *exchangev1.GetOrdersRequest) (*exchangev1.GetOrdersResponse, error) {
+	userID, err := targetUser(ctx, req.UserId)
+	if err != nil {
+		return nil, statusError(err, codes.InvalidArgument)
+	}
+
+	orders, err := s.Exchange.GetUserOrders(userID)
+	if err != nil {
+		return nil, statusError(err, codes.Internal)
+	}
+
+	return &exchangev1.GetOrdersResponse{
+		Asks: orderRecords(orders.Asks),
+		Bids: orderRecords(orders.Bids),
+	}, nil
+}
+
+// SubscribeBook streams a snapshot of the L2 orderbook of a market followed by its deltas
+func (s *Service) SubscribeBook(req *exchangev1.SubscribeBookRequest, stream exchangev1.ExchangeService_SubscribeBookServer) error {
+	feed, ok := s.Exchange.MarketData[exchanges.Market(req.Market)]
+	if !ok {
+		return statusError(fmt.Errorf("%w: %s", exchanges.ErrUnknownMarket, req.Market), codes.InvalidArgument)
+	}
+
+	sink := newStreamSink()
+	if err := feed.Subscribe(marketdata.ChannelBook, sink); err != nil {
+		return statusError(err, codes.Internal)
+	}
+	defer feed.Unsubscribe(marketdata.ChannelBook, sink)
+
+	return forward(stream.Context(), sink, func(msg interface{}) error {
+		book, ok := msg.(*marketdata.BookMessage)
+		if !ok {
+			return nil
+		}
+
+		update := &exchangev1.BookUpdate{
+			Market: book.Market,
+			Type:   exchangev1.BookUpdate_TYPE_DELTA,
+			Seq:    book.Seq,
+			Bids:   bookLevels(book.Bids),
+			Asks:   bookLevels(book.Asks),
+		}
+		if book.Type == marketdata.MessageSnapshot {
+			update.Type = exchangev1.BookUpdate_TYPE_SNAPSHOT
+		}
+		return stream.Send(update)
+	})
+}
+
+// SubscribeExecutions streams the execution reports of the caller, admins may follow another user
+func (s *Service) SubscribeExecutions(req *exchangev1.SubscribeExecutionsRequest, stream exchangev1.ExchangeService_SubscribeExecutionsServer) error {
+	userID, err := targetUser(stream.Context(), req.UserId)
+	if err != nil {
+		return statusError(err, codes.InvalidArgument)
+	}
+
+	sink := newStreamSink()
+	s.Exchange.SubscribeUser(userID, sink)
+	defer s.Exchange.UnsubscribeUser(userID, sink)
+
+	// The header tells the client the subscription is in place, it gets the reports of every later change
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	return forward(stream.Context(), sink, func(msg interface{}) error {
+		report, ok := msg.(*exchanges.ExecutionReport)
+		if !ok {
+			// Balance updates are not part of the gRPC API
+			return nil
+		}
+
+		return stream.Send(&exchangev1.ExecutionReport{
+			OrderId:    report.OrderID,
+			TradeId:    report.TradeID,
+			UserId:     report.UserID,
+			Market:     string(report.Market),
+			Type:       orderTypeValues[report.Type],
+			IsBid:      report.IsBid,
+			Status:     execStatuses[report.Status],
+			Price:      report.Price,
+			LastPrice:  report.LastPrice,
+			LastAmount: report.LastAmount,
+			Fee:        report.Fee,
+			Remaining:  report.Remaining,
+			Reason:     report.Reason,
+			Timestamp:  report.Timestamp,
+		})
+	})
+}
+
+// forward sends the messages of the sink to the stream until the client leaves or cannot keep up
+func forward(ctx context.Context, sink *streamSink, send func(msg interface{}) error) error {
+	for {
+		select {
+		case msg := <-sink.send:
+			if err := send(msg); err != nil {
+				return err
+			}
+
+		case <-sink.done:
+			return newError(codes.ResourceExhausted, middleware.CodeRateLimited, "stream cannot keep up, subscribe again")
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// orderOwner returns the user acting on an order, the caller unless an operator acts on behalf of the owner
+func (s *Service) orderOwner(ctx context.Context, orderID uint64) (uint64, error) {
+	c, ok := callerFrom(ctx)
+	if !ok {
+		return 0, newError(codes.Unauthenticated, middleware.CodeUnauthenticated, "unauthenticated")
+	}
+
+	if !c.permissions.Has(auth.PermissionAdmin) {
+		return c.userID, nil
+	}
+
+	order, err := s.Exchange.GetOrder(orderID)
+	if err != nil {
+		return 0, statusError(err, codes.NotFound)
+	}
+	return order.UserID, nil
+}
+
+// targetUser returns the user a call is about, the caller unless an admin requests another one
+func targetUser(ctx context.Context, requested uint64) (uint64, error) {
+	c, _ := callerFrom(ctx)
+	if requested == 0 || requested == c.userID {
+		return c.userID, nil
+	}
+
+	if !c.permissions.Has(auth.PermissionAdmin) {
+		return 0, errForbiddenUser
+	}
+
+	return requested, nil
+}
+
+var (
+	orderTypes = map[exchangev1.OrderType]exchanges.OrderType{
+		exchangev1.OrderType_ORDER_TYPE_MARKET: exchanges.MarketOrder,
+		exchangev1.OrderType_ORDER_TYPE_LIMIT:  exchanges.LimitOrder,
+	}
+	orderTypeValues = map[exchanges.OrderType]exchangev1.OrderType{
+		exchanges.MarketOrder: exchangev1.OrderType_ORDER_TYPE_MARKET,
+		exchanges.LimitOrder:  exchangev1.OrderType_ORDER_TYPE_LIMIT,
+	}
+	orderStatuses = map[exchanges.OrderStatus]exchangev1.OrderStatus{
+		exchanges.OrderOpen:            exchangev1.OrderStatus_ORDER_STATUS_OPEN,
+		exchanges.OrderPartiallyFilled: exchangev1.OrderStatus_ORDER_STATUS_PARTIALLY_FILLED,
+		exchanges.OrderFilled:          exchangev1.OrderStatus_ORDER_STATUS_FILLED,
+		exchanges.OrderCancelled:       exchangev1.OrderStatus_ORDER_STATUS_CANCELLED,
+		exchanges.OrderRejected:        exchangev1.OrderStatus_ORDER_STATUS_REJECTED,
+		exchanges.OrderExpired:         exchangev1.OrderStatus_ORDER_STATUS_EXPIRED,
+	}
+	execStatuses = map[exchanges.ExecStatus]exchangev1.ExecStatus{
+		exchanges.ExecAccepted:        exchangev1.ExecStatus_EXEC_STATUS_ACCEPTED,
+		exchanges.ExecPartiallyFilled: exchangev1.ExecStatus_EXEC_STATUS_PARTIALLY_FILLED,
+		exchanges.ExecFilled:          exchangev1.ExecStatus_EXEC_STATUS_FILLED,
+		exchanges.ExecCancelled:       exchangev1.ExecStatus_EXEC_STATUS_CANCELLED,
+		exchanges.ExecRejected:        exchangev1.ExecStatus_EXEC_STATUS_REJECTED,
+		exchanges.ExecExpired:         exchangev1.ExecStatus_EXEC_STATUS_EXPIRED,
+	}
+)
+
+func orderRecords(records []*exchanges.OrderRecord) []*exchangev1.Order {
+	orders := make([]*exchangev1.Order, len(records))
+	for i, record := range records {
+		orders[i] = &exchangev1.Order{
+			Id:             record.ID,
+			UserId:         record.UserID,
+			Market:         string(record.Market),
+			Type:           orderTypeValues[record.Type],
+			IsBid:          record.IsBid,
+			Price:          record.Price,
+			Status:         orderStatuses[record.Status],
+			OriginalAmount: record.OriginalAmount,
+			Amount:         record.Amount,
+			FilledAmount:   record.FilledAmount,
+			AvgFillPrice:   record.AvgFillPrice,
+			CreatedAt:      record.CreatedAt,
+			UpdatedAt:      record.UpdatedAt,
+		}
+	}
+
+	return orders
+}
+
+func depthLevels(levels []exchanges.DepthLevel) []*exchangev1.Level {
+	converted := make([]*exchangev1.Level, len(levels))
+	for i, level := range levels {
+		converted[i] = &exchangev1.Level{Price: level.Price, Size: level.Size, Orders: int32(level.Orders)}
+	}
+
+	return converted
+}
+
+func bookLevels(levels []marketdata.Level) []*exchangev1.Level {
+	converted := make([]*exchangev1.Level, len(levels))
+	for i, level := range levels {
+		converted[i] = &exchangev1.Level{Price: level.Price, Size: level.Size}
+	}
+
+	return converted
+}
@@ -0,0 +1,88 @@
+// Package events is the in-process bus the exchange publishes its outputs to. Every event gets the next sequence
+// number and all subscribers see all events in that order.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Envelope is a published event with its position in the stream
+type Envelope struct {
+	Seq       uint64
+	Timestamp int64
+	Event     Event
+}
+
+// Handler consumes events
+type Handler func(Envelope)
+
+// Bus fans published events out to its subscribers. Handlers are called synchronously and one event at a time, so
+// they see events in sequence order and the publisher waits for them; a handler must not block and must not publish
+// or subscribe itself.
+type Bus struct {
+	mu          sync.Mutex
+	seq         uint64
+	nextID      int
+	subscribers map[int]Handler
+	order       []int
+}
+
+// NewBus is constructor of Bus struct.
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[int]Handler),
+	}
+}
+
+// Publish assigns the event the next sequence number and delivers it to every subscriber
+func (b *Bus) Publish(e Event) Envelope {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	envelope := Envelope{
+		Seq:       b.seq,
+		Timestamp: time.Now().UnixNano(),
+		Event:     e,
+	}
+
+	for _, id := range b.order {
+		b.subscribers[id](envelope)
+	}
+
+	return envelope
+}
+
+// Subscribe registers the handler for all events published from now on, subscribers are called in the order they
+// subscribed. The returned function removes the subscription.
+func (b *Bus) Subscribe(handler Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = handler
+	b.order = append(b.order, id)
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers, id)
+		for i, subscriber := range b.order {
+			if subscriber == id {
+				b.order = append(b.order[:i], b.order[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Seq returns the sequence number of the last published event
+func (b *Bus) Seq() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.seq
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+
+	var first, second []Envelope
+	unsubscribe := bus.Subscribe(func(e Envelope) { first = append(first, e) })
+	bus.Subscribe(func(e Envelope) { second = append(second, e) })
+
+	// Test case 1: every subscriber gets every event with increasing sequence numbers
+	bus.Publish(OrderAccepted{OrderID: 1})
+	bus.Publish(OrderCancelled{OrderID: 1})
+	require.Len(t, first, 2)
+	require.Equal(t, first, second)
+	require.Equal(t, uint64(1), first[0].Seq)
+	require.Equal(t, uint64(2), first[1].Seq)
+	require.Equal(t, KindOrderCancelled, first[1].Event.Kind())
+
+	// Test case 2: unsubscribed handlers get nothing
+	unsubscribe()
+	bus.Publish(TradeExecuted{Amount: 1})
+	require.Len(t, first, 2)
+	require.Len(t, second, 3)
+	require.Equal(t, uint64(3), bus.Seq())
+}
+
+func TestBusConcurrentPublishers(t *testing.T) {
+	bus := NewBus()
+
+	var seqs []uint64
+	bus.Subscribe(func(e Envelope) { seqs = append(seqs, e.Seq) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bus.Publish(BookLevelChanged{})
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Subscribers see events in sequence order even with concurrent publishers
+	require.Len(t, seqs, 800)
+	for i, seq := range seqs {
+		require.Equal(t, uint64(i+1), seq)
+	}
+}
@@ -0,0 +1,107 @@
+package events
+
+// Kind identifies the type of an event
+type Kind string
+
+const (
+	KindOrderAccepted       Kind = "ORDER_ACCEPTED"
+	KindOrderRejected       Kind = "ORDER_REJECTED"
+	KindOrderCancelled      Kind = "ORDER_CANCELLED"
+	KindTradeExecuted       Kind = "TRADE_EXECUTED"
+	KindBookLevelChanged    Kind = "BOOK_LEVEL_CHANGED"
+	KindSettlementCompleted Kind = "SETTLEMENT_COMPLETED"
+	KindBalanceChanged      Kind = "BALANCE_CHANGED"
+)
+
+// Event is published on the bus, consumers switch on the concrete type
+type Event interface {
+	Kind() Kind
+}
+
+// OrderAccepted is published when an order passed validation and entered the market
+type OrderAccepted struct {
+	Market        string
+	OrderID       uint64
+	ClientOrderID string
+	UserID        uint64
+	Type          string
+	IsBid         bool
+	Price         float64
+	Amount        float64
+}
+
+// OrderRejected is published when an order could not be placed
+type OrderRejected struct {
+	Market        string
+	OrderID       uint64
+	ClientOrderID string
+	UserID        uint64
+	Type          string
+	IsBid         bool
+	Price         float64
+	Amount        float64
+	Reason        string
+}
+
+// OrderCancelled is published when a resting order left the book without being filled completely
+type OrderCancelled struct {
+	Market    string
+	OrderID   uint64
+	UserID    uint64
+	IsBid     bool
+	Price     float64
+	Remaining float64
+}
+
+// TradeExecuted is published for every match of a taker order against a resting maker order. The remaining amounts
+// are what is left of each order after the trade, TradeID is sequential per market. Fees are in the asset of the
+// market, a negative maker fee is a rebate.
+type TradeExecuted struct {
+	Market         string
+	TradeID        uint64
+	Price          float64
+	Amount         float64
+	TakerIsBid     bool
+	TakerOrderID   uint64
+	TakerUserID    uint64
+	TakerType      string
+	TakerRemaining float64
+	MakerOrderID   uint64
+	MakerUserID    uint64
+	MakerRemaining float64
+	TakerFee       float64
+	MakerFee       float64
+}
+
+// BookLevelChanged is published when the total size at a price level changes, a zero size removes the level
+type BookLevelChanged struct {
+	Market string
+	IsBid  bool
+	Price  float64
+	Size   float64
+}
+
+// SettlementCompleted is published when a trade has been settled in the ledger and on-chain
+type SettlementCompleted struct {
+	Market   string
+	Price    float64
+	Amount   float64
+	BuyerID  uint64
+	SellerID uint64
+}
+
+// BalanceChanged is published when a ledger balance changed
+type BalanceChanged struct {
+	UserID  uint64
+	Asset   string
+	Balance float64
+	Change  float64
+}
+
+func (OrderAccepted) Kind() Kind       { return KindOrderAccepted }
+func (OrderRejected) Kind() Kind       { return KindOrderRejected }
+func (OrderCancelled) Kind() Kind      { return KindOrderCancelled }
+func (TradeExecuted) Kind() Kind       { return KindTradeExecuted }
+func (BookLevelChanged) Kind() Kind    { return KindBookLevelChanged }
+func (SettlementCompleted) Kind() Kind { return KindSettlementCompleted }
+func (BalanceChanged) Kind() Kind      { return KindBalanceChanged }
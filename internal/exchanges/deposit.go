@@ -0,0 +1,162 @@
+package exchanges
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/ethclient"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/hdwallet"
+)
+
+// depositAccounts maps the asset of each market that takes deposits to its BIP-44 account in the exchange HD wallet,
+// so every user gets a separate deposit address per asset. The wallet derives Ethereum keys, so only ETH is listed.
+var depositAccounts = map[Market]uint32{
+	MarketETH: 0,
+}
+
+// OnboardUser creates a user whose keys are derived from the exchange HD wallet, no raw key is ever handled. The key
+// is the user's ETH deposit address, it is only ever swept into the hot wallet; trades settle in the ledger and
+// withdrawals are paid from the hot wallet. The derivation index is the user ID, so a user gets the same keys on
+// every start whether or not the exchange state is persisted.
+func (ex *Exchange) OnboardUser(userID uint64) (*models.User, error) {
+	if ex.Wallet == nil {
+		return nil, errors.New("exchange has no HD wallet")
+	}
+	if userID == 0 || userID >= uint64(hdwallet.HardenedKeyStart) {
+		return nil, fmt.Errorf("user %d has no derivation index in the exchange wallet", userID)
+	}
+	index := uint32(userID)
+
+	// Users restored from a repository keep the index they were created with
+	ex.mu.RLock()
+	for _, user := range ex.Users {
+		if user.DerivationIndex == index {
+			ex.mu.RUnlock()
+			return nil, fmt.Errorf("derivation index %d is taken by user %d", index, user.ID)
+		}
+	}
+	ex.mu.RUnlock()
+
+	s, err := ex.Wallet.Signer(depositAccounts[MarketETH], index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive keys for user %d: %w", userID, err)
+	}
+
+	user, err := models.NewUser(s, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.DerivationIndex = index
+
+	if err := ex.AddUser(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DepositAddress returns the user's deposit address for the market's asset
+func (ex *Exchange) DepositAddress(userID uint64, market Market) (string, error) {
+	if _, ok := ex.Orderbooks[market]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownMarket, market)
+	}
+	account, ok := depositAccounts[market]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrDepositUnavailable, market)
+	}
+
+	user, err := ex.GetUser(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if user.DerivationIndex == 0 {
+		return "", fmt.Errorf("user %d has no derived deposit addresses", userID)
+	}
+
+	address, err := ex.Wallet.Address(account, user.DerivationIndex)
+	if err != nil {
+		return "", err
+	}
+
+	return address.Hex(), nil
+}
+
+// SweepDeposits moves the balances of all users' deposit addresses for the market's asset into the exchange hot
+// wallet, credits them to the users' ledger balances and returns the total amount swept. An address that cannot be
+// swept is logged and skipped, the failures are returned together after the other addresses were swept.
+func (ex *Exchange) SweepDeposits(market Market) (*big.Int, error) {
+	account, ok := depositAccounts[market]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrDepositUnavailable, market)
+	}
+
+	ex.mu.RLock()
+	users := make([]*models.User, 0, len(ex.Users))
+	for _, user := range ex.Users {
+		if user.DerivationIndex != 0 {
+			users = append(users, user)
+		}
+	}
+	ex.mu.RUnlock()
+
+	total := big.NewInt(0)
+	hotWallet := ex.Signer.Address()
+	var failed sweepErrors
+
+	for _, user := range users {
+		s, err := ex.Wallet.Signer(account, user.DerivationIndex)
+		if err != nil {
+			err = fmt.Errorf("failed to derive deposit address of user %d: %w", user.ID, err)
+			log.Printf("%v", err)
+			failed = append(failed, err)
+			continue
+		}
+
+		amount, err := ex.ETHClient.SweepETH(s, hotWallet)
+		if err != nil {
+			err = fmt.Errorf("failed to sweep deposit address of user %d: %w", user.ID, err)
+			log.Printf("%v", err)
+			failed = append(failed, err)
+			continue
+		}
+
+		if amount.Sign() > 0 {
+			log.Printf("Swept deposit => user [%d] | address [%s] | amount [%s]", user.ID, s.Address().Hex(), amount)
+			credited := ethclient.WeiToEther(amount)
+			ex.Ledger.Credit(user.ID, string(market), credited)
+			ex.publishBalance(user.ID, string(market), credited)
+			total.Add(total, amount)
+			if err := ex.checkPersisted(); err != nil {
+				return total, err
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return total, failed
+	}
+
+	return total, nil
+}
+
+// sweepErrors are the addresses a sweep failed on, one error each
+type sweepErrors []error
+
+func (e sweepErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the error of every address
+func (e sweepErrors) Unwrap() []error {
+	return e
+}
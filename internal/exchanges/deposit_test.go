@@ -0,0 +1,119 @@
+package exchanges
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/ethclient"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/hdwallet"
+)
+
+// newTestWallet creates an HD wallet with a fresh seed
+func newTestWallet(t *testing.T) *hdwallet.Wallet {
+	seedPath := filepath.Join(t.TempDir(), "seed.json")
+	seed, err := hdwallet.NewSeed()
+	require.NoError(t, err)
+	require.NoError(t, hdwallet.CreateSeedFile(seedPath, "secret", seed, keystore.LightScryptN, keystore.LightScryptP))
+	wallet, err := hdwallet.LoadWallet(seedPath, "secret")
+	require.NoError(t, err)
+
+	return wallet
+}
+
+func TestOnboardUser(t *testing.T) {
+	wallet := newTestWallet(t)
+	start := func() *Exchange {
+		ex, err := New(fakeSigner{address: common.HexToAddress("0xff")}, wallet, nil)
+		require.NoError(t, err)
+		return ex
+	}
+
+	// Test case 1: users get the same keys on every start without a repository
+	ex := start()
+	user, err := ex.RegisterUser("")
+	require.NoError(t, err)
+	address, err := ex.DepositAddress(user.ID, MarketETH)
+	require.NoError(t, err)
+
+	ex = start()
+	restarted, err := ex.RegisterUser("")
+	require.NoError(t, err)
+	require.Equal(t, user.ID, restarted.ID)
+	require.Equal(t, user.Signer.Address(), restarted.Signer.Address())
+	restartedAddress, err := ex.DepositAddress(restarted.ID, MarketETH)
+	require.NoError(t, err)
+	require.Equal(t, address, restartedAddress)
+
+	// Test case 2: a user ID whose keys are derived already is refused
+	_, err = ex.OnboardUser(user.ID)
+	require.Error(t, err)
+
+	// Test case 3: assets the wallet cannot derive addresses for take no deposits
+	_, err = ex.DepositAddress(user.ID, MarketBTC)
+	require.ErrorIs(t, err, ErrDepositUnavailable)
+	_, err = ex.DepositAddress(user.ID, Market("DOGE"))
+	require.ErrorIs(t, err, ErrUnknownMarket)
+}
+
+func TestSweepDeposits(t *testing.T) {
+	ex, err := New(fakeSigner{address: common.HexToAddress("0xff")}, newTestWallet(t), nil)
+	require.NoError(t, err)
+	balances := make(map[string]*big.Int)
+	for i := int64(0); i < 3; i++ {
+		user, err := ex.RegisterUser("")
+		require.NoError(t, err)
+		balances[strings.ToLower(user.Signer.Address().Hex())] = big.NewInt(i * params.Ether)
+	}
+	broken, err := ex.GetUser(1)
+	require.NoError(t, err)
+
+	// The node pays gas at 1 wei and cannot read the balance of the first user's deposit address
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_getBalance":
+			var address string
+			require.NoError(t, json.Unmarshal(req.Params[0], &address))
+			if strings.EqualFold(address, broken.Signer.Address().Hex()) {
+				resp["error"] = map[string]interface{}{"code": -32000, "message": "node unavailable"}
+			} else {
+				resp["result"] = hexutil.EncodeBig(balances[strings.ToLower(address)])
+			}
+		case "eth_gasPrice":
+			resp["result"] = "0x1"
+		case "eth_getTransactionCount":
+			resp["result"] = "0x0"
+		case "eth_sendRawTransaction":
+			resp["result"] = common.Hash{}.Hex()
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer node.Close()
+	ex.ETHClient, err = ethclient.New(node.URL)
+	require.NoError(t, err)
+
+	// Test case 1: a failing address does not stop the sweep of the others, its error is returned at the end
+	total, err := ex.SweepDeposits(MarketETH)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "user 1")
+	require.Equal(t, big.NewInt(3*params.Ether-2*21000), total)
+	require.Equal(t, 0.0, ex.Ledger.Balance(1, string(MarketETH)))
+	require.InDelta(t, 1.0, ex.Ledger.Balance(2, string(MarketETH)), 1e-9)
+	require.InDelta(t, 2.0, ex.Ledger.Balance(3, string(MarketETH)), 1e-9)
+}
@@ -0,0 +1,73 @@
+package exchanges
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/matchingengine"
+)
+
+const (
+	// DefaultDepth is how many levels of each side the depth has when none is requested
+	DefaultDepth = 50
+	// MaxDepth is the most levels of each side the depth has
+	MaxDepth = 500
+)
+
+// GetDepth returns the aggregated L2 orderbook of the market with up to depth levels per side. A positive grouping
+// merges the levels into price buckets of that size, bids are rounded down and asks up to the bucket edge so grouped
+// prices are never better than the orders behind them.
+func (ex *Exchange) GetDepth(market Market, depth int, grouping float64) (*DepthResponse, error) {
+	ob, exists := ex.Orderbooks[market]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
+	}
+
+	if depth < 0 {
+		return nil, fmt.Errorf("invalid depth %d", depth)
+	}
+	if depth == 0 {
+		depth = DefaultDepth
+	}
+	if depth > MaxDepth {
+		depth = MaxDepth
+	}
+	if grouping < 0 || math.IsNaN(grouping) || math.IsInf(grouping, 0) {
+		return nil, fmt.Errorf("invalid grouping %v", grouping)
+	}
+
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
+
+	return &DepthResponse{
+		Market:          market,
+		TotalAsksVolume: ob.AskTotalVolume(),
+		TotalBidsVolume: ob.BidTotalVolume(),
+		Asks:            depthLevels(ob.Asks(), depth, grouping, math.Ceil),
+		Bids:            depthLevels(ob.Bids(), depth, grouping, math.Floor),
+	}, nil
+}
+
+// depthLevels aggregates the limits, sorted best price first, into levels. round moves a price to its bucket.
+func depthLevels(limits []*matchingengine.Limit, depth int, grouping float64, round func(float64) float64) []DepthLevel {
+	levels := []DepthLevel{}
+	for _, limit := range limits {
+		price := limit.Price
+		if grouping > 0 {
+			price = round(price/grouping) * grouping
+		}
+
+		if n := len(levels); n > 0 && levels[n-1].Price == price {
+			levels[n-1].Size += limit.TotalVolume
+			levels[n-1].Orders += len(limit.Orders)
+			continue
+		}
+
+		if len(levels) == depth {
+			break
+		}
+		levels = append(levels, DepthLevel{Price: price, Size: limit.TotalVolume, Orders: len(limit.Orders)})
+	}
+
+	return levels
+}
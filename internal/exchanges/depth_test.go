@@ -0,0 +1,56 @@
+package exchanges
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDepth(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+
+	for _, order := range []struct {
+		userID uint64
+		isBid  bool
+		amount float64
+		price  float64
+	}{
+		{1, false, 1, 101},
+		{2, false, 2, 101},
+		{1, false, 3, 104},
+		{1, false, 4, 111},
+		{1, true, 5, 99},
+		{2, true, 1, 96},
+		{2, true, 2, 89},
+	} {
+		_, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: order.userID, Type: LimitOrder, IsBid: order.isBid, Amount: order.amount, Price: order.price, Market: MarketETH})
+		require.NoError(t, err)
+	}
+
+	// Test case 1: levels aggregate the orders resting at a price, best price first
+	depth, err := ex.GetDepth(MarketETH, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, []DepthLevel{{Price: 101, Size: 3, Orders: 2}, {Price: 104, Size: 3, Orders: 1}, {Price: 111, Size: 4, Orders: 1}}, depth.Asks)
+	require.Equal(t, []DepthLevel{{Price: 99, Size: 5, Orders: 1}, {Price: 96, Size: 1, Orders: 1}, {Price: 89, Size: 2, Orders: 1}}, depth.Bids)
+	require.Equal(t, 10.0, depth.TotalAsksVolume)
+
+	// Test case 2: depth limits the levels per side
+	depth, err = ex.GetDepth(MarketETH, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, depth.Asks, 1)
+	require.Len(t, depth.Bids, 1)
+
+	// Test case 3: grouping rounds asks up and bids down to the bucket
+	depth, err = ex.GetDepth(MarketETH, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []DepthLevel{{Price: 110, Size: 6, Orders: 3}, {Price: 120, Size: 4, Orders: 1}}, depth.Asks)
+	require.Equal(t, []DepthLevel{{Price: 90, Size: 6, Orders: 2}, {Price: 80, Size: 2, Orders: 1}}, depth.Bids)
+
+	// Test case 4: invalid parameters and markets are rejected
+	_, err = ex.GetDepth(MarketETH, -1, 0)
+	require.Error(t, err)
+	_, err = ex.GetDepth(MarketETH, 0, -1)
+	require.Error(t, err)
+	_, err = ex.GetDepth("DOGE", 0, 0)
+	require.Error(t, err)
+}
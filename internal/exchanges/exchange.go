@@ -1,99 +1,282 @@
 package exchanges
 
 import (
-	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"log"
-	"math/big"
+	"math"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/ledger"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/marketdata"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/matchingengine"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/reserves"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/treasury"
 	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/ethclient"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/hdwallet"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/signer"
 )
 
 // Exchange represents the main exchange functionality
 type Exchange struct {
-	Users      map[uint64]*models.User
-	Orders     map[uint64][]*matchingengine.Order
-	PrivateKey *ecdsa.PrivateKey
-	ETHClient  *ethclient.Client
+	Users     map[uint64]*models.User
+	Orders    map[uint64][]*matchingengine.Order
+	Signer    signer.Signer
+	Wallet    *hdwallet.Wallet
+	ETHClient *ethclient.Client
+	// Treasury pays the withdrawals from the hot wallet, withdrawals are refused without one
+	Treasury   *treasury.Treasury
 	Orderbooks map[Market]*matchingengine.Orderbook
-	mu         sync.RWMutex
+	Ledger     *ledger.Ledger
+	// Fees computes the fees of every fill, they are collected in the ledger account FeeAccountID
+	Fees *FeeEngine
+	// Keys holds the API keys issued to the users, they are stored with the account state, see UseRepository
+	Keys *auth.KeyStore
+	mu   sync.RWMutex
+	// bookMu serializes order entry, from every API. Everything that reads or changes an orderbook holds it, reads
+	// too because the orderbook sorts its limits on every read.
+	bookMu sync.Mutex
+	// reservations are the ledger holds of the orders by order ID, guarded by bookMu
+	reservations map[uint64]*reservation
+
+	// Events is the ordered stream of everything the exchange does, see the events package
+	Events *events.Bus
+	// MarketData publishes the L2 book, trades and ticker of every market
+	MarketData map[Market]*marketdata.Feed
+	// Candles aggregates the trades of every market into OHLCV candles
+	Candles map[Market]*marketdata.Candles
+	// Stats keeps the rolling 24 hour statistics of every market
+	Stats map[Market]*marketdata.Stats
+	// Trades keeps the trades of every market, only the latest ones in memory unless a store is set with UseTradeStore
+	Trades *trades.History
+	// streams publishes the execution reports and balance updates of every user
+	streams userStreams
+	// history keeps the lifecycle of every order, including the closed ones
+	history *orderHistory
+	// fills keeps both sides of every trade
+	fills *fillHistory
+	// lastTradeIDs holds the ID of the last trade of every market, trade IDs are sequential per market
+	lastTradeIDs map[Market]*uint64
+	// lastOrderID is the ID of the last order, order IDs are sequential across markets
+	lastOrderID uint64
+	// persist writes the account state to a repository, nil when it is kept in memory only, see UseRepository
+	persist *persister
+
+	// ReserveAddresses are additional addresses holding exchange funds, such as cold storage, counted as on-chain
+	// reserves next to the hot wallet and deposit addresses
+	ReserveAddresses []string
+	reserveTrees     map[Market]*reserves.Tree
+	reserveReports   map[Market]*ReservesReport
+
+	// nextUserID is the ID handed to the next registered user
+	nextUserID uint64
+	// registerMu serializes registrations so wallet addresses stay unique
+	registerMu sync.Mutex
 }
 
-// New creates a new exchange instance
-func New(privateKey string, ethClient *ethclient.Client) (*Exchange, error) {
+// New creates a new exchange instance that signs its own transactions with the given signer and derives user
+// deposit addresses from the given HD wallet
+func New(s signer.Signer, wallet *hdwallet.Wallet, ethClient *ethclient.Client) (*Exchange, error) {
+	if s == nil {
+		return nil, errors.New("exchange signer cannot be nil")
+	}
+
 	orderbooks := make(map[Market]*matchingengine.Orderbook)
 	orderbooks[MarketETH] = matchingengine.NewOrderbook()
 	orderbooks[MarketBTC] = matchingengine.NewOrderbook()
 
-	pk, err := cryptoHexToECDSA(privateKey)
+	fees, err := NewFeeEngine(DefaultFeeSchedules()...)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Exchange{
+	bus := events.NewBus()
+	feeds := make(map[Market]*marketdata.Feed)
+	candles := make(map[Market]*marketdata.Candles)
+	stats := make(map[Market]*marketdata.Stats)
+	lastTradeIDs := make(map[Market]*uint64)
+	for market, ob := range orderbooks {
+		lastTradeIDs[market] = new(uint64)
+		ob.OnEvent(bookListener(bus, market))
+
+		feeds[market] = marketdata.NewFeed(string(market), ob)
+		bus.Subscribe(feeds[market].Apply)
+
+		candles[market] = marketdata.NewCandles(string(market))
+		bus.Subscribe(candles[market].Apply)
+
+		stats[market] = marketdata.NewStats(feeds[market])
+		bus.Subscribe(stats[market].Apply)
+	}
+
+	ex := &Exchange{
 		Users:      make(map[uint64]*models.User),
 		Orders:     make(map[uint64][]*matchingengine.Order),
-		PrivateKey: pk,
+		Signer:     s,
+		Wallet:     wallet,
 		ETHClient:  ethClient,
 		Orderbooks: orderbooks,
-	}, nil
+		Ledger:     ledger.New(),
+		Fees:       fees,
+		Keys:       auth.NewKeyStore(),
+		Events:     bus,
+		MarketData: feeds,
+		Candles:    candles,
+		Stats:      stats,
+		Trades:     trades.NewHistory(nil, trades.DefaultRingSize),
+
+		history:        newOrderHistory(),
+		fills:          newFillHistory(),
+		lastTradeIDs:   lastTradeIDs,
+		reservations:   make(map[uint64]*reservation),
+		reserveTrees:   make(map[Market]*reserves.Tree),
+		reserveReports: make(map[Market]*ReservesReport),
+		nextUserID:     1,
+	}
+	bus.Subscribe(ex.streams.handle)
+	bus.Subscribe(ex.history.handle)
+	bus.Subscribe(ex.fills.handle)
+	bus.Subscribe(ex.recordTrade)
+
+	return ex, nil
 }
 
-// AddUser adds a new user to the exchange
-func (ex *Exchange) AddUser(user *models.User) {
-	ex.Users[user.ID] = user
+// bookListener publishes the level changes of the market's orderbook, trades are published with the order details
+// by the exchange
+func bookListener(bus *events.Bus, market Market) func(matchingengine.Event) {
+	return func(e matchingengine.Event) {
+		if e.Kind != matchingengine.EventLevelChanged {
+			return
+		}
+
+		bus.Publish(events.BookLevelChanged{
+			Market: string(market),
+			IsBid:  e.Bid,
+			Price:  e.Price,
+			Size:   e.Size,
+		})
+	}
 }
 
-// HandleMarketOrder handles a market order
-func (ex *Exchange) HandleMarketOrder(market Market, order *matchingengine.Order) ([]*MatchedOrder, error) {
+// handleMarketOrder handles a market order, the caller holds bookMu. The matches are settled before the order enters
+// the book and published after, an order that cannot settle is rejected and changes nothing. An order whose changes
+// cannot be stored fails with ErrTradingHalted.
+func (ex *Exchange) handleMarketOrder(market Market, order *matchingengine.Order) (matchedOrders []*MatchedOrder, err error) {
 	ob, exists := ex.Orderbooks[market]
 	if !exists {
-		return nil, fmt.Errorf("market %s does not exist", market)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
 	}
 
-	matches := ob.PlaceMarketOrder(order)
-	matchedOrders := make([]*MatchedOrder, len(matches))
-
-	isBid := order.Bid
+	if err := checkLiquidity(ob, order); err != nil {
+		return nil, err
+	}
+	matches, err := ob.PreviewMarketOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	fees := make([]MatchFees, len(matches))
+	for i, match := range matches {
+		fees[i] = ex.Fees.Quote(maker(match, order).UserID, order.UserID, match.AmountFilled)
+	}
 
-	for i := 0; i < len(matchedOrders); i++ {
-		id := matches[i].Bid.ID
-		if isBid {
-			id = matches[i].Ask.ID
+	// The order, its fills and their settlement are persisted together
+	ex.beginBatch(market, matches)
+	defer func() {
+		if commitErr := ex.commitBatch(); commitErr != nil && err == nil {
+			matchedOrders, err = nil, commitErr
 		}
+	}()
+	// Whatever the order reserved and did not spend becomes available again
+	defer ex.releaseReservation(order.ID)
+
+	if err := ex.settleMatches(market, order, matches, fees); err != nil {
+		ex.publishRejected(market, MarketOrder, order, 0, err)
+		return nil, err
+	}
+
+	ex.Events.Publish(events.OrderAccepted{
+		Market:        string(market),
+		OrderID:       order.ID,
+		ClientOrderID: order.ClientOrderID,
+		UserID:        order.UserID,
+		Type:          string(MarketOrder),
+		IsBid:         order.Bid,
+		Amount:        order.Amount,
+	})
+
+	remaining := order.Amount
+	// The preview checked the volume and nothing changed the book since, the matches are the settled ones
+	if matches, err = ob.PlaceMarketOrder(order); err != nil {
+		return nil, err
+	}
+	matchedOrders = make([]*MatchedOrder, len(matches))
+	for i, match := range matches {
+		maker := maker(match, order)
+		ex.Fees.Record(maker.UserID, order.UserID, match.Price, match.AmountFilled)
 
 		matchedOrders[i] = &MatchedOrder{
-			ID:           id,
-			Price:        matches[i].Price,
-			AmountFilled: matches[i].AmountFilled,
+			UserID:       maker.UserID,
+			Price:        match.Price,
+			AmountFilled: match.AmountFilled,
+			ID:           maker.ID,
+			Fee:          fees[i].TakerFee,
+		}
+
+		remaining -= match.AmountFilled
+		ex.Events.Publish(events.TradeExecuted{
+			Market:         string(market),
+			TradeID:        atomic.AddUint64(ex.lastTradeIDs[market], 1),
+			Price:          match.Price,
+			Amount:         match.AmountFilled,
+			TakerIsBid:     order.Bid,
+			TakerOrderID:   order.ID,
+			TakerUserID:    order.UserID,
+			TakerType:      string(MarketOrder),
+			TakerRemaining: remaining,
+			MakerOrderID:   maker.ID,
+			MakerUserID:    maker.UserID,
+			MakerRemaining: maker.Amount,
+			TakerFee:       fees[i].TakerFee,
+			MakerFee:       fees[i].MakerFee,
+		})
+
+		if maker.IsFilled() {
+			ex.releaseReservation(maker.ID)
 		}
 	}
+	ex.publishSettlements(market, matches, fees)
 
 	// Update orders map by removing filled orders
 	ex.UpdateOrdersAfterMatch()
 
-	// Process the actual transfers
-	if err := ex.ProcessMatches(matches); err != nil {
-		return matchedOrders, err
-	}
-
 	return matchedOrders, nil
 }
 
-// HandleLimitOrder handles a limit order
-func (ex *Exchange) HandleLimitOrder(market Market, price float64, order *matchingengine.Order) error {
+// handleLimitOrder handles a limit order, the caller holds bookMu
+func (ex *Exchange) handleLimitOrder(market Market, price float64, order *matchingengine.Order) error {
 	ob, exists := ex.Orderbooks[market]
 	if !exists {
-		return fmt.Errorf("market %s does not exist", market)
+		return fmt.Errorf("%w: %s", ErrUnknownMarket, market)
 	}
 
 	ob.PlaceLimitOrder(price, order)
+	ex.Events.Publish(events.OrderAccepted{
+		Market:        string(market),
+		OrderID:       order.ID,
+		ClientOrderID: order.ClientOrderID,
+		UserID:        order.UserID,
+		Type:          string(LimitOrder),
+		IsBid:         order.Bid,
+		Price:         price,
+		Amount:        order.Amount,
+	})
 
 	ex.mu.Lock()
 	defer ex.mu.Unlock()
@@ -104,9 +287,9 @@ func (ex *Exchange) HandleLimitOrder(market Market, price float64, order *matchi
 	return nil
 }
 
-// UpdateOrdersAfterMatch updates the orders map after matches
+// UpdateOrdersAfterMatch updates the orders map after matches, the closed orders remain in the order history
 func (ex *Exchange) UpdateOrdersAfterMatch() {
-	// Create a new map to hold unfilled orders
+	// Create a new map to hold the orders still resting in a book
 	newOrderMap := make(map[uint64][]*matchingengine.Order)
 
 	ex.mu.Lock()
@@ -114,7 +297,7 @@ func (ex *Exchange) UpdateOrdersAfterMatch() {
 
 	for userID, orders := range ex.Orders {
 		for i := 0; i < len(orders); i++ {
-			if !orders[i].IsFilled() {
+			if !orders[i].IsFilled() && orders[i].Limit != nil {
 				newOrderMap[userID] = append(newOrderMap[userID], orders[i])
 			}
 		}
@@ -123,14 +306,45 @@ func (ex *Exchange) UpdateOrdersAfterMatch() {
 	ex.Orders = newOrderMap
 }
 
-// PlaceOrder places a new order
+// PlaceOrder places a new order, the user learns about its execution from the private stream
 func (ex *Exchange) PlaceOrder(req *PlaceOrderRequest) (interface{}, error) {
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
+
+	if err := ex.checkPersisted(); err != nil {
+		return nil, err
+	}
+
+	result, err := ex.placeOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	// The order was taken, but it could not be stored
+	if err := ex.checkPersisted(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (ex *Exchange) placeOrder(req *PlaceOrderRequest) (interface{}, error) {
 	market := req.Market
-	order := matchingengine.NewOrder(req.IsBid, req.Amount, req.UserID)
+	orderType := OrderType(strings.ToUpper(string(req.Type)))
+	order := ex.newOrder(req.IsBid, req.Amount, req.UserID)
+	order.ClientOrderID = req.ClientOrderID
 
+	if err := ex.validateOrder(market, orderType, order, req.Price); err != nil {
+		ex.publishRejected(market, orderType, order, req.Price, err)
+		return nil, err
+	}
+
+	return ex.enterOrder(market, orderType, order, req.Price)
+}
+
+// enterOrder executes a validated order
+func (ex *Exchange) enterOrder(market Market, orderType OrderType, order *matchingengine.Order, price float64) (interface{}, error) {
 	// Handle market order
-	if strings.ToUpper(string(req.Type)) == string(MarketOrder) {
-		matchedOrders, err := ex.HandleMarketOrder(market, order)
+	if orderType == MarketOrder {
+		matchedOrders, err := ex.handleMarketOrder(market, order)
 		if err != nil {
 			return nil, err
 		}
@@ -138,24 +352,83 @@ func (ex *Exchange) PlaceOrder(req *PlaceOrderRequest) (interface{}, error) {
 	}
 
 	// Handle limit order
-	if strings.ToUpper(string(req.Type)) == string(LimitOrder) {
-		err := ex.HandleLimitOrder(market, req.Price, order)
-		if err != nil {
-			return nil, err
+	err := ex.handleLimitOrder(market, price, order)
+	if err != nil {
+		return nil, err
+	}
+	return &PlaceOrderResponse{OrderID: order.ID}, nil
+}
+
+// publishRejected reports that the order did not enter the market for the reason err
+func (ex *Exchange) publishRejected(market Market, orderType OrderType, order *matchingengine.Order, price float64, err error) {
+	ex.Events.Publish(events.OrderRejected{
+		Market:        string(market),
+		OrderID:       order.ID,
+		ClientOrderID: order.ClientOrderID,
+		UserID:        order.UserID,
+		Type:          string(orderType),
+		IsBid:         order.Bid,
+		Price:         price,
+		Amount:        order.Amount,
+		Reason:        err.Error(),
+	})
+}
+
+// newOrder makes an order with the next order ID
+func (ex *Exchange) newOrder(isBid bool, amount float64, userID uint64) *matchingengine.Order {
+	order := matchingengine.NewOrder(isBid, amount, userID)
+	order.ID = atomic.AddUint64(&ex.lastOrderID, 1)
+	return order
+}
+
+// validateOrder returns the reason an order cannot enter the market and reserves its funds otherwise, errors after
+// that point do not reject it
+func (ex *Exchange) validateOrder(market Market, orderType OrderType, order *matchingengine.Order, price float64) error {
+	if err := ex.checkUserActive(order.UserID); err != nil {
+		return err
+	}
+
+	if orderType != MarketOrder && orderType != LimitOrder {
+		return fmt.Errorf("%w: %s", ErrUnknownOrderType, orderType)
+	}
+
+	ob, exists := ex.Orderbooks[market]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownMarket, market)
+	}
+
+	if orderType == MarketOrder {
+		if err := checkLiquidity(ob, order); err != nil {
+			return err
 		}
-		return &PlaceOrderResponse{OrderID: order.ID}, nil
 	}
 
-	return nil, errors.New("unknown order type")
+	return ex.reserve(market, orderType, order, price)
+}
+
+// checkLiquidity returns an error naming the volume if the opposite side of the orderbook cannot fill the market order
+func checkLiquidity(ob *matchingengine.Orderbook, order *matchingengine.Order) error {
+	available := ob.BidTotalVolume()
+	if order.Bid {
+		available = ob.AskTotalVolume()
+	}
+	if order.Amount > available {
+		return fmt.Errorf("%w: %.2f requested, %.2f available", ErrInsufficientLiquidity, order.Amount, available)
+	}
+
+	return nil
 }
 
 // GetOrderbook gets the orderbook for a market
 func (ex *Exchange) GetOrderbook(market Market) (*OrderbookResponse, error) {
 	ob, exists := ex.Orderbooks[market]
 	if !exists {
-		return nil, fmt.Errorf("market %s does not exist", market)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
 	}
 
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
+
 	var orderbookResponse = OrderbookResponse{
 		TotalAsksVolume: ob.AskTotalVolume(),
 		TotalBidsVolume: ob.BidTotalVolume(),
@@ -194,35 +467,140 @@ func (ex *Exchange) GetOrderbook(market Market) (*OrderbookResponse, error) {
 	return &orderbookResponse, nil
 }
 
-// CancelOrder cancels an order
-func (ex *Exchange) CancelOrder(orderID uint64) error {
-	found := false
+// GetOrder returns the resting order with the given ID
+func (ex *Exchange) GetOrder(orderID uint64) (*matchingengine.Order, error) {
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
 
 	for _, ob := range ex.Orderbooks {
+		// Filled orders stay in the orderbook orders map but are no longer resting at a limit
+		if order, exists := ob.Orders[orderID]; exists && order.Limit != nil {
+			return order, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %d", ErrOrderNotFound, orderID)
+}
+
+// CancelOrder cancels an order on behalf of the given user, who must own it
+func (ex *Exchange) CancelOrder(userID, orderID uint64) error {
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
+
+	if err := ex.checkPersisted(); err != nil {
+		return err
+	}
+	if err := ex.cancelOrder(userID, orderID); err != nil {
+		return err
+	}
+	return ex.checkPersisted()
+}
+
+func (ex *Exchange) cancelOrder(userID, orderID uint64) error {
+	for market, ob := range ex.Orderbooks {
 		order, exists := ob.Orders[orderID]
-		if exists {
-			ob.CancelOrder(order)
-			found = true
-			break
+		if !exists || order.Limit == nil {
+			continue
 		}
+
+		if order.UserID != userID {
+			return fmt.Errorf("%w: order %d belongs to another user", ErrForbidden, orderID)
+		}
+
+		price := order.Limit.Price
+		ob.CancelOrder(order)
+		ex.releaseReservation(order.ID)
+		ex.Events.Publish(events.OrderCancelled{
+			Market:    string(market),
+			OrderID:   order.ID,
+			UserID:    order.UserID,
+			IsBid:     order.Bid,
+			Price:     price,
+			Remaining: order.Amount,
+		})
+		ex.UpdateOrdersAfterMatch()
+		return nil
 	}
 
-	if !found {
-		return fmt.Errorf("order %d not found", orderID)
+	return fmt.Errorf("%w: %d", ErrOrderNotFound, orderID)
+}
+
+// AmendOrder replaces a resting limit order of the user with one at the new price and amount. The replacement is a
+// new order, it gets a new ID and loses the time priority of the original one, which is reported as cancelled. A
+// replacement that is rejected leaves the original order as it was.
+func (ex *Exchange) AmendOrder(req *AmendOrderRequest) (*PlaceOrderResponse, error) {
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
+
+	if err := ex.checkPersisted(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	for market, ob := range ex.Orderbooks {
+		order, exists := ob.Orders[req.OrderID]
+		if !exists || order.Limit == nil {
+			continue
+		}
+
+		if order.UserID != req.UserID {
+			return nil, fmt.Errorf("%w: order %d belongs to another user", ErrForbidden, req.OrderID)
+		}
+		if err := ex.checkUserActive(req.UserID); err != nil {
+			return nil, err
+		}
+
+		amount, price := order.Amount, order.Limit.Price
+		if req.Amount != 0 {
+			amount = req.Amount
+		}
+		if req.Price != 0 {
+			price = req.Price
+		}
+		replacement := ex.newOrder(order.Bid, amount, req.UserID)
+		replacement.ClientOrderID = req.ClientOrderID
+
+		// The funds the original order holds can pay for the replacement, they are held again if it is rejected
+		held, reserved := ex.reservations[order.ID]
+		ex.releaseReservation(order.ID)
+		if err := ex.validateOrder(market, LimitOrder, replacement, price); err != nil {
+			ex.publishRejected(market, LimitOrder, replacement, price, err)
+			if reserved {
+				if holdErr := ex.restoreReservation(order.ID, held); holdErr != nil {
+					_ = ex.cancelOrder(req.UserID, order.ID)
+					return nil, fmt.Errorf("%w, the original order was cancelled: %v", err, holdErr)
+				}
+			}
+			return nil, err
+		}
+
+		if err := ex.cancelOrder(req.UserID, req.OrderID); err != nil {
+			ex.releaseReservation(replacement.ID)
+			return nil, err
+		}
+		if _, err := ex.enterOrder(market, LimitOrder, replacement, price); err != nil {
+			return nil, err
+		}
+		if err := ex.checkPersisted(); err != nil {
+			return nil, err
+		}
+		return &PlaceOrderResponse{OrderID: replacement.ID}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %d", ErrOrderNotFound, req.OrderID)
 }
 
 // GetBestBidPrice gets the best bid price for a market
 func (ex *Exchange) GetBestBidPrice(market Market) (float64, error) {
 	ob, exists := ex.Orderbooks[market]
 	if !exists {
-		return 0, fmt.Errorf("market %s does not exist", market)
+		return 0, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
 	}
 
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
+
 	if len(ob.Bids()) == 0 {
-		return 0, errors.New("no bids available")
+		return 0, fmt.Errorf("%w: no bids in %s", ErrEmptyBook, market)
 	}
 
 	return ob.Bids()[0].Price, nil
@@ -232,55 +610,59 @@ func (ex *Exchange) GetBestBidPrice(market Market) (float64, error) {
 func (ex *Exchange) GetBestAskPrice(market Market) (float64, error) {
 	ob, exists := ex.Orderbooks[market]
 	if !exists {
-		return 0, fmt.Errorf("market %s does not exist", market)
+		return 0, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
 	}
 
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
+
 	if len(ob.Asks()) == 0 {
-		return 0, errors.New("no asks available")
+		return 0, fmt.Errorf("%w: no asks in %s", ErrEmptyBook, market)
 	}
 
 	return ob.Asks()[0].Price, nil
 }
 
-// GetTrades gets all trades for a market
-func (ex *Exchange) GetTrades(market Market) ([]*matchingengine.Trade, error) {
-	ob, exists := ex.Orderbooks[market]
+// GetCandles returns the candles of the market at the interval opening between from and to, in unix nanoseconds
+func (ex *Exchange) GetCandles(market Market, interval marketdata.Interval, from, to int64) ([]*marketdata.Candle, error) {
+	candles, exists := ex.Candles[market]
 	if !exists {
-		return nil, fmt.Errorf("market %s does not exist", market)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
 	}
 
-	return ob.Trades, nil
+	return candles.Query(interval, from, to)
 }
 
-// GetUserOrders gets all orders for a user
-func (ex *Exchange) GetUserOrders(userID uint64) (*GetOrdersResponse, error) {
-	ex.mu.RLock()
-	defer ex.mu.RUnlock()
-
-	orderbookOrders, exists := ex.Orders[userID]
+// GetTicker returns the 24 hour statistics of the market
+func (ex *Exchange) GetTicker(market Market) (*marketdata.Ticker24h, error) {
+	stats, exists := ex.Stats[market]
 	if !exists {
-		return &GetOrdersResponse{}, nil
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
 	}
 
-	ordersResp := &GetOrdersResponse{
-		Asks: []Order{},
-		Bids: []Order{},
+	return stats.Ticker(), nil
+}
+
+// GetTickers returns the 24 hour statistics of every market, sorted by market
+func (ex *Exchange) GetTickers() []*marketdata.Ticker24h {
+	tickers := make([]*marketdata.Ticker24h, 0, len(ex.Stats))
+	for _, stats := range ex.Stats {
+		tickers = append(tickers, stats.Ticker())
 	}
 
-	for i := 0; i < len(orderbookOrders); i++ {
-		if orderbookOrders[i].Limit == nil {
-			continue
-		}
+	sort.Slice(tickers, func(i, j int) bool { return tickers[i].Market < tickers[j].Market })
 
-		order := Order{
-			ID:        orderbookOrders[i].ID,
-			UserID:    orderbookOrders[i].UserID,
-			Price:     orderbookOrders[i].Limit.Price,
-			Amount:    orderbookOrders[i].Amount,
-			Timestamp: orderbookOrders[i].Timestamp,
-			IsBid:     orderbookOrders[i].Bid,
-		}
+	return tickers
+}
+
+// GetUserOrders gets the open orders of a user
+func (ex *Exchange) GetUserOrders(userID uint64) (*GetOrdersResponse, error) {
+	ordersResp := &GetOrdersResponse{
+		Asks: []*OrderRecord{},
+		Bids: []*OrderRecord{},
+	}
 
+	for _, order := range ex.history.open(userID) {
 		if order.IsBid {
 			ordersResp.Bids = append(ordersResp.Bids, order)
 		} else {
@@ -291,32 +673,171 @@ func (ex *Exchange) GetUserOrders(userID uint64) (*GetOrdersResponse, error) {
 	return ordersResp, nil
 }
 
-// ProcessMatches processes matches by transferring ETH
-func (ex *Exchange) ProcessMatches(matches matchingengine.Matches) error {
-	for _, match := range matches {
-		fromUser, ok := ex.Users[match.Ask.UserID]
-		if !ok {
-			return fmt.Errorf("user not found: %d", match.Ask.UserID)
+// ListOrders returns a page of the order history of a user, newest first
+func (ex *Exchange) ListOrders(filter OrderFilter) (*OrderHistoryResponse, error) {
+	if filter.Market != "" {
+		if _, exists := ex.Orderbooks[filter.Market]; !exists {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, filter.Market)
 		}
+	}
+
+	return ex.history.list(filter)
+}
 
-		toUser, ok := ex.Users[match.Bid.UserID]
-		if !ok {
-			return fmt.Errorf("user not found: %d", match.Bid.UserID)
+// settleMatches settles the matches of the taker order in the ledger, collects their fees and takes what they spent
+// off the reservations of the orders. fees holds the fees of every match. Either every match settles or none does.
+func (ex *Exchange) settleMatches(market Market, taker *matchingengine.Order, matches matchingengine.Matches, fees []MatchFees) error {
+	for i, match := range matches {
+		if err := ex.settleMatch(market, taker, match, fees[i]); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				ex.unsettleMatch(market, taker, matches[j], fees[j])
+			}
+			return err
 		}
+	}
 
-		toAddress := common.HexToAddress(toUser.Address)
-		amount := big.NewInt(int64(match.AmountFilled))
+	for i, match := range matches {
+		notional := match.Price * match.AmountFilled
+		ex.useReservation(match.Ask.ID, match.AmountFilled*(1+ex.Fees.MaxRate()), match.AmountFilled+math.Max(sellerFee(taker, fees[i]), 0))
+		ex.useReservation(match.Bid.ID, notional, notional)
+	}
 
-		err := ex.ETHClient.TransferETH(fromUser.PrivateKey, toAddress, amount)
-		if err != nil {
-			return fmt.Errorf("failed to transfer ETH: %w", err)
+	return nil
+}
+
+// publishSettlements publishes the settlement of the matches and the balances it changed
+func (ex *Exchange) publishSettlements(market Market, matches matchingengine.Matches, fees []MatchFees) {
+	for i, match := range matches {
+		notional := match.Price * match.AmountFilled
+		ex.Events.Publish(events.SettlementCompleted{
+			Market:   string(market),
+			Price:    match.Price,
+			Amount:   match.AmountFilled,
+			BuyerID:  match.Bid.UserID,
+			SellerID: match.Ask.UserID,
+		})
+		ex.publishBalance(match.Ask.UserID, string(market), -match.AmountFilled)
+		ex.publishBalance(match.Bid.UserID, string(market), match.AmountFilled)
+		ex.publishBalance(match.Bid.UserID, QuoteAsset, -notional)
+		ex.publishBalance(match.Ask.UserID, QuoteAsset, notional)
+		if fees[i].TakerFee != 0 {
+			ex.publishBalance(fees[i].TakerUserID, string(market), -fees[i].TakerFee)
+		}
+		if fees[i].MakerFee != 0 {
+			ex.publishBalance(fees[i].MakerUserID, string(market), -fees[i].MakerFee)
 		}
 	}
+}
+
+// maker returns the resting order of a match of the taker
+func maker(match matchingengine.Match, taker *matchingengine.Order) *matchingengine.Order {
+	if taker.Bid {
+		return match.Ask
+	}
+	return match.Bid
+}
+
+// settleMatch moves the amount from the held balance of the seller to the buyer and its price from the held balance
+// of the buyer to the seller and collects the fees
+func (ex *Exchange) settleMatch(market Market, taker *matchingengine.Order, match matchingengine.Match, fees MatchFees) error {
+	base := string(market)
+	seller, buyer := match.Ask, match.Bid
+	notional := match.Price * match.AmountFilled
+
+	if err := ex.Ledger.TransferHeld(seller.UserID, buyer.UserID, base, match.AmountFilled); err != nil {
+		return fmt.Errorf("failed to settle match: %w", err)
+	}
+
+	if err := ex.Ledger.TransferHeld(buyer.UserID, seller.UserID, QuoteAsset, notional); err != nil {
+		ex.returnHeld(buyer.UserID, seller.UserID, base, match.AmountFilled)
+		return fmt.Errorf("failed to settle match: %w", err)
+	}
+
+	takerSells := !taker.Bid
+	if err := ex.collectFees(market, fees, takerSells); err != nil {
+		ex.returnHeld(seller.UserID, buyer.UserID, QuoteAsset, notional)
+		ex.returnHeld(buyer.UserID, seller.UserID, base, match.AmountFilled)
+		return fmt.Errorf("failed to collect fees: %w", err)
+	}
 
 	return nil
 }
 
-// Helper functions
-func cryptoHexToECDSA(hexKey string) (*ecdsa.PrivateKey, error) {
-	return ecdsa.GenerateKey(nil, nil) // This is not safe for production, just a stub for testing
+// unsettleMatch reverses settleMatch
+func (ex *Exchange) unsettleMatch(market Market, taker *matchingengine.Order, match matchingengine.Match, fees MatchFees) {
+	ex.refundFees(market, fees, !taker.Bid)
+	ex.returnHeld(match.Ask.UserID, match.Bid.UserID, QuoteAsset, match.Price*match.AmountFilled)
+	ex.returnHeld(match.Bid.UserID, match.Ask.UserID, string(market), match.AmountFilled)
+}
+
+// sellerFee returns the fee the seller of a match of the taker pays
+func sellerFee(taker *matchingengine.Order, fees MatchFees) float64 {
+	if taker.Bid {
+		return fees.MakerFee
+	}
+	return fees.TakerFee
+}
+
+// returnHeld reverses a TransferHeld from toUserID to fromUserID, the amount is held again
+func (ex *Exchange) returnHeld(fromUserID, toUserID uint64, asset string, amount float64) {
+	_ = ex.Ledger.Transfer(fromUserID, toUserID, asset, amount)
+	_ = ex.Ledger.Hold(toUserID, asset, amount)
+}
+
+// collectFees moves the fees of a match into the fee account. The taker fee is collected first, it always covers a
+// maker rebate. The seller pays out of its reservation.
+func (ex *Exchange) collectFees(market Market, fees MatchFees, takerSells bool) error {
+	if err := ex.chargeFee(fees.TakerUserID, market, fees.TakerFee, takerSells); err != nil {
+		return err
+	}
+
+	if err := ex.chargeFee(fees.MakerUserID, market, fees.MakerFee, !takerSells); err != nil {
+		ex.refundFee(fees.TakerUserID, market, fees.TakerFee, takerSells)
+		return err
+	}
+
+	return nil
+}
+
+// refundFees reverses collectFees
+func (ex *Exchange) refundFees(market Market, fees MatchFees, takerSells bool) {
+	ex.refundFee(fees.MakerUserID, market, fees.MakerFee, !takerSells)
+	ex.refundFee(fees.TakerUserID, market, fees.TakerFee, takerSells)
+}
+
+// chargeFee moves fee from the user to the fee account, out of the held balance of the user if held is set. A
+// negative fee is paid from the fee account to the user.
+func (ex *Exchange) chargeFee(userID uint64, market Market, fee float64, held bool) error {
+	switch {
+	case fee < 0:
+		return ex.Ledger.Transfer(FeeAccountID, userID, string(market), -fee)
+	case held:
+		return ex.Ledger.TransferHeld(userID, FeeAccountID, string(market), fee)
+	default:
+		return ex.Ledger.Transfer(userID, FeeAccountID, string(market), fee)
+	}
+}
+
+// refundFee reverses chargeFee
+func (ex *Exchange) refundFee(userID uint64, market Market, fee float64, held bool) {
+	if fee < 0 {
+		_ = ex.Ledger.Transfer(userID, FeeAccountID, string(market), -fee)
+		return
+	}
+
+	if held {
+		ex.returnHeld(FeeAccountID, userID, string(market), fee)
+		return
+	}
+	_ = ex.Ledger.Transfer(FeeAccountID, userID, string(market), fee)
+}
+
+// publishBalance publishes the current ledger balance of the user after it changed by change
+func (ex *Exchange) publishBalance(userID uint64, asset string, change float64) {
+	ex.Events.Publish(events.BalanceChanged{
+		UserID:  userID,
+		Asset:   asset,
+		Balance: ex.Ledger.Balance(userID, asset),
+		Change:  change,
+	})
 }
@@ -0,0 +1,188 @@
+package exchanges
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/ledger"
+)
+
+func TestCancelOrder_Ownership(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+
+	resp, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 3, Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+	orderID := resp.(*PlaceOrderResponse).OrderID
+
+	// Test case 1: another user cannot cancel the order
+	require.ErrorIs(t, ex.CancelOrder(2, orderID), ErrForbidden)
+	require.Equal(t, 3.0, ex.Orderbooks[MarketETH].AskTotalVolume())
+
+	// Test case 2: the owner can
+	require.NoError(t, ex.CancelOrder(1, orderID))
+	require.Equal(t, 0.0, ex.Orderbooks[MarketETH].AskTotalVolume())
+
+	// Test case 3: cancelled orders are gone
+	require.ErrorIs(t, ex.CancelOrder(1, orderID), ErrOrderNotFound)
+	_, err = ex.GetOrder(orderID)
+	require.ErrorIs(t, err, ErrOrderNotFound)
+}
+
+func TestExchangeEvents(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+
+	var published []events.Envelope
+	ex.Events.Subscribe(func(e events.Envelope) { published = append(published, e) })
+
+	resp, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 3, Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+	orderID := resp.(*PlaceOrderResponse).OrderID
+
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 1, Market: MarketETH})
+	require.NoError(t, err)
+
+	require.NoError(t, ex.CancelOrder(1, orderID))
+
+	var kinds []events.Kind
+	for i, e := range published {
+		require.Equal(t, published[0].Seq+uint64(i), e.Seq)
+		kinds = append(kinds, e.Event.Kind())
+	}
+	require.Equal(t, []events.Kind{
+		events.KindBookLevelChanged,
+		events.KindOrderAccepted,
+		events.KindOrderAccepted,
+		events.KindBookLevelChanged,
+		events.KindTradeExecuted,
+		events.KindSettlementCompleted,
+		events.KindBalanceChanged,
+		events.KindBalanceChanged,
+		events.KindBalanceChanged,
+		events.KindBalanceChanged,
+		events.KindBalanceChanged,
+		events.KindBalanceChanged,
+		events.KindBookLevelChanged,
+		events.KindOrderCancelled,
+	}, kinds)
+
+	trade := published[4].Event.(events.TradeExecuted)
+	require.Equal(t, orderID, trade.MakerOrderID)
+	require.Equal(t, uint64(1), trade.MakerUserID)
+	require.Equal(t, uint64(2), trade.TakerUserID)
+	require.Equal(t, 2.0, trade.MakerRemaining)
+	require.Equal(t, 0.0, trade.TakerRemaining)
+}
+
+func TestMarketOrder_SettlementFails(t *testing.T) {
+	ex := newTestExchange(t, 1, 2, 3)
+
+	_, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 2, Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 3, Type: LimitOrder, IsBid: false, Amount: 2, Price: 110, Market: MarketETH})
+	require.NoError(t, err)
+	// The second seller no longer has what it sells
+	ex.Ledger.Release(3, string(MarketETH), ex.Ledger.Held(3, string(MarketETH)))
+	require.NoError(t, ex.Ledger.Debit(3, string(MarketETH), testFunds))
+
+	var published []events.Envelope
+	ex.Events.Subscribe(func(e events.Envelope) { published = append(published, e) })
+
+	// Test case 1: the order is rejected and the match that settled is undone
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 3, Market: MarketETH})
+	require.ErrorIs(t, err, ledger.ErrInsufficientBalance)
+	require.Len(t, published, 1)
+	require.Equal(t, events.KindOrderRejected, published[0].Event.Kind())
+
+	// Test case 2: the book and the balances are as they were
+	require.Equal(t, 4.0, ex.Orderbooks[MarketETH].AskTotalVolume())
+	require.Equal(t, testFunds, ex.Ledger.Balance(1, string(MarketETH)))
+	require.Equal(t, testFunds, ex.Ledger.Balance(1, QuoteAsset))
+	require.Equal(t, testFunds, ex.Ledger.Balance(2, string(MarketETH)))
+	require.Equal(t, testFunds, ex.Ledger.Balance(2, QuoteAsset))
+	require.Zero(t, ex.Ledger.Held(2, QuoteAsset))
+	require.InDelta(t, 2*(1+ex.Fees.MaxRate()), ex.Ledger.Held(1, string(MarketETH)), 1e-9)
+}
+
+func TestAmendOrder(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+
+	resp, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 3, Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+	orderID := resp.(*PlaceOrderResponse).OrderID
+
+	// Test case 1: another user cannot amend the order
+	_, err = ex.AmendOrder(&AmendOrderRequest{UserID: 2, OrderID: orderID, Price: 101})
+	require.ErrorIs(t, err, ErrForbidden)
+
+	// Test case 2: the order is replaced at the new price, the amount is kept
+	amended, err := ex.AmendOrder(&AmendOrderRequest{UserID: 1, OrderID: orderID, Price: 101})
+	require.NoError(t, err)
+	require.NotEqual(t, orderID, amended.OrderID)
+	_, err = ex.GetOrder(orderID)
+	require.ErrorIs(t, err, ErrOrderNotFound)
+
+	order, err := ex.GetOrder(amended.OrderID)
+	require.NoError(t, err)
+	require.Equal(t, 101.0, order.Limit.Price)
+	require.Equal(t, 3.0, order.Amount)
+	require.False(t, order.Bid)
+
+	// Test case 3: the amount is changed, the price is kept
+	amended, err = ex.AmendOrder(&AmendOrderRequest{UserID: 1, OrderID: amended.OrderID, Amount: 1})
+	require.NoError(t, err)
+	order, err = ex.GetOrder(amended.OrderID)
+	require.NoError(t, err)
+	require.Equal(t, 101.0, order.Limit.Price)
+	require.Equal(t, 1.0, ex.Orderbooks[MarketETH].AskTotalVolume())
+
+	// Test case 4: unknown orders cannot be amended
+	_, err = ex.AmendOrder(&AmendOrderRequest{UserID: 1, OrderID: orderID, Amount: 1})
+	require.ErrorIs(t, err, ErrOrderNotFound)
+
+	// Test case 5: the funds of the original order pay for the replacement
+	bid, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: LimitOrder, IsBid: true, Amount: 1, Price: 90, Market: MarketETH})
+	require.NoError(t, err)
+	require.NoError(t, ex.Ledger.Debit(2, QuoteAsset, testFunds-100))
+	amended, err = ex.AmendOrder(&AmendOrderRequest{UserID: 2, OrderID: bid.(*PlaceOrderResponse).OrderID, Price: 95})
+	require.NoError(t, err)
+	require.Equal(t, 95.0, ex.Ledger.Held(2, QuoteAsset))
+
+	// Test case 6: a rejected replacement leaves the original order resting with its funds held
+	_, err = ex.AmendOrder(&AmendOrderRequest{UserID: 2, OrderID: amended.OrderID, Amount: 2})
+	require.ErrorIs(t, err, ledger.ErrInsufficientBalance)
+	order, err = ex.GetOrder(amended.OrderID)
+	require.NoError(t, err)
+	require.Equal(t, 95.0, order.Limit.Price)
+	require.Equal(t, 1.0, ex.Orderbooks[MarketETH].BidTotalVolume())
+	require.Equal(t, 95.0, ex.Ledger.Held(2, QuoteAsset))
+}
+
+func TestConcurrentOrderEntry(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+
+	// Test case 1: orders placed, read and cancelled from many goroutines all reach the book, run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				resp, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: i%2 == 0, Amount: 1, Price: float64(100 + j), Market: MarketETH})
+				require.NoError(t, err)
+				_, err = ex.GetDepth(MarketETH, 10, 0)
+				require.NoError(t, err)
+				if j%2 == 0 {
+					require.NoError(t, ex.CancelOrder(1, resp.(*PlaceOrderResponse).OrderID))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	book, err := ex.GetOrderbook(MarketETH)
+	require.NoError(t, err)
+	require.Equal(t, 100.0, book.TotalAsksVolume)
+	require.Equal(t, 100.0, book.TotalBidsVolume)
+}
@@ -0,0 +1,303 @@
+package exchanges
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FeeAccountID is the ledger account collected fees are credited to and maker rebates are paid from. User IDs start
+// at 1, so it never belongs to a user.
+const FeeAccountID uint64 = 0
+
+const (
+	// FeeScheduleStandard is the schedule of every user unless another one is assigned
+	FeeScheduleStandard = "STANDARD"
+	// FeeScheduleMarketMaker is the schedule of designated market makers, it pays a rebate on maker fills
+	FeeScheduleMarketMaker = "MARKET_MAKER"
+)
+
+// feeVolumeWindow is how far back the trading volume deciding the fee tier of a user goes
+const feeVolumeWindow = 30 * 24 * time.Hour
+
+// ErrUnknownFeeSchedule is returned for fee schedules the exchange does not have
+var ErrUnknownFeeSchedule = errors.New("unknown fee schedule")
+
+// FeeTier holds the fee rates of users whose 30 day volume is at least MinVolume. Rates are fractions of the filled
+// amount, a negative maker rate is a rebate.
+type FeeTier struct {
+	MinVolume float64 `json:"min_volume"`
+	MakerRate float64 `json:"maker_rate"`
+	TakerRate float64 `json:"taker_rate"`
+}
+
+// FeeSchedule is a named set of fee tiers
+type FeeSchedule struct {
+	Name  string    `json:"name"`
+	Tiers []FeeTier `json:"tiers"`
+}
+
+// MatchFees are the fees of both sides of a match, in the asset of the market. A negative maker fee is a rebate.
+// Buyers pay their fee out of the amount they bought, sellers out of the fee they reserved with their order.
+type MatchFees struct {
+	MakerUserID uint64
+	TakerUserID uint64
+	MakerFee    float64
+	TakerFee    float64
+}
+
+// FeeStatusResponse is the fee schedule of a user with the tier its 30 day volume currently qualifies for
+type FeeStatusResponse struct {
+	UserID    uint64    `json:"user_id"`
+	Schedule  string    `json:"schedule"`
+	Volume30d float64   `json:"volume_30d"`
+	MakerRate float64   `json:"maker_rate"`
+	TakerRate float64   `json:"taker_rate"`
+	Tiers     []FeeTier `json:"tiers"`
+}
+
+// UpdateFeeScheduleRequest represents a request to assign a fee schedule to a user
+type UpdateFeeScheduleRequest struct {
+	UserID   uint64 `param:"id" json:"-"`
+	Schedule string `json:"schedule"`
+}
+
+// Validate checks a schedule is given
+func (r *UpdateFeeScheduleRequest) Validate() error {
+	if r.Schedule == "" {
+		return errors.New("schedule is required")
+	}
+	return nil
+}
+
+// DefaultFeeSchedules returns the schedules the exchange starts with. Volume is notional, price times amount.
+func DefaultFeeSchedules() []FeeSchedule {
+	return []FeeSchedule{
+		{
+			Name: FeeScheduleStandard,
+			Tiers: []FeeTier{
+				{MinVolume: 0, MakerRate: 0.0010, TakerRate: 0.0020},
+				{MinVolume: 100_000, MakerRate: 0.0008, TakerRate: 0.0018},
+				{MinVolume: 1_000_000, MakerRate: 0.0005, TakerRate: 0.0015},
+				{MinVolume: 10_000_000, MakerRate: 0.0002, TakerRate: 0.0010},
+			},
+		},
+		{
+			Name: FeeScheduleMarketMaker,
+			Tiers: []FeeTier{
+				{MinVolume: 0, MakerRate: -0.0001, TakerRate: 0.0010},
+				{MinVolume: 1_000_000, MakerRate: -0.0002, TakerRate: 0.0008},
+			},
+		},
+	}
+}
+
+// FeeEngine computes the maker and taker fees of every fill from the schedule of the user and its rolling 30 day
+// trading volume
+type FeeEngine struct {
+	mu        sync.Mutex
+	schedules map[string]FeeSchedule
+	// assigned holds the users on a schedule other than the standard one
+	assigned map[uint64]string
+	// volume holds the notional volume of every user per day, days are counted from the unix epoch
+	volume map[uint64]map[int64]float64
+	// maxRate is the highest fee rate of any schedule
+	maxRate float64
+	now     func() time.Time
+}
+
+// NewFeeEngine creates a fee engine with the given schedules, one of which must be the standard schedule. Maker
+// rebates may not exceed the lowest taker rate, so the fee account can always pay them out of the taker fee of the
+// same trade.
+func NewFeeEngine(schedules ...FeeSchedule) (*FeeEngine, error) {
+	f := &FeeEngine{
+		schedules: make(map[string]FeeSchedule),
+		assigned:  make(map[uint64]string),
+		volume:    make(map[uint64]map[int64]float64),
+		now:       time.Now,
+	}
+
+	minTakerRate := math.MaxFloat64
+	for _, schedule := range schedules {
+		if len(schedule.Tiers) == 0 {
+			return nil, fmt.Errorf("fee schedule %s has no tiers", schedule.Name)
+		}
+
+		tiers := append([]FeeTier(nil), schedule.Tiers...)
+		sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinVolume < tiers[j].MinVolume })
+		if tiers[0].MinVolume != 0 {
+			return nil, fmt.Errorf("fee schedule %s has no tier for zero volume", schedule.Name)
+		}
+
+		for _, tier := range tiers {
+			f.maxRate = math.Max(f.maxRate, math.Max(tier.MakerRate, tier.TakerRate))
+			if tier.TakerRate < 0 {
+				return nil, fmt.Errorf("fee schedule %s has a negative taker rate", schedule.Name)
+			}
+			if tier.TakerRate < minTakerRate {
+				minTakerRate = tier.TakerRate
+			}
+		}
+
+		schedule.Tiers = tiers
+		f.schedules[schedule.Name] = schedule
+	}
+
+	if _, ok := f.schedules[FeeScheduleStandard]; !ok {
+		return nil, fmt.Errorf("%w: %s is required", ErrUnknownFeeSchedule, FeeScheduleStandard)
+	}
+
+	for _, schedule := range f.schedules {
+		for _, tier := range schedule.Tiers {
+			if -tier.MakerRate > minTakerRate {
+				return nil, fmt.Errorf("fee schedule %s pays a maker rebate above the lowest taker rate", schedule.Name)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// MaxRate returns the highest fee rate of any schedule, no fill costs more than this fraction of its amount
+func (f *FeeEngine) MaxRate() float64 {
+	return f.maxRate
+}
+
+// SetSchedule assigns the named schedule to the user
+func (f *FeeEngine) SetSchedule(userID uint64, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.schedules[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownFeeSchedule, name)
+	}
+
+	if name == FeeScheduleStandard {
+		delete(f.assigned, userID)
+	} else {
+		f.assigned[userID] = name
+	}
+
+	return nil
+}
+
+// Status returns the schedule, 30 day volume and current rates of the user
+func (f *FeeEngine) Status(userID uint64) *FeeStatusResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	schedule := f.schedule(userID)
+	volume := f.rollingVolume(userID)
+	tier := schedule.tier(volume)
+
+	return &FeeStatusResponse{
+		UserID:    userID,
+		Schedule:  schedule.Name,
+		Volume30d: volume,
+		MakerRate: tier.MakerRate,
+		TakerRate: tier.TakerRate,
+		Tiers:     append([]FeeTier(nil), schedule.Tiers...),
+	}
+}
+
+// Quote returns the fees of a fill between the maker and the taker at the tiers the users qualify for, Record adds
+// the fill to their volume once it settled
+func (f *FeeEngine) Quote(makerUserID, takerUserID uint64, amount float64) MatchFees {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return MatchFees{
+		MakerUserID: makerUserID,
+		TakerUserID: takerUserID,
+		MakerFee:    amount * f.schedule(makerUserID).tier(f.rollingVolume(makerUserID)).MakerRate,
+		TakerFee:    amount * f.schedule(takerUserID).tier(f.rollingVolume(takerUserID)).TakerRate,
+	}
+}
+
+// Record adds a fill to the volume of the maker and the taker
+func (f *FeeEngine) Record(makerUserID, takerUserID uint64, price, amount float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	f.addVolume(makerUserID, price*amount, now)
+	f.addVolume(takerUserID, price*amount, now)
+}
+
+// AddVolume adds notional volume traded at the given time to the user, such as the fills persisted before a restart
+func (f *FeeEngine) AddVolume(userID uint64, notional float64, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.addVolume(userID, notional, at)
+}
+
+func (f *FeeEngine) addVolume(userID uint64, notional float64, at time.Time) {
+	if f.volume[userID] == nil {
+		f.volume[userID] = make(map[int64]float64)
+	}
+	f.volume[userID][at.Unix()/86400] += notional
+}
+
+func (f *FeeEngine) schedule(userID uint64) FeeSchedule {
+	if name, ok := f.assigned[userID]; ok {
+		return f.schedules[name]
+	}
+	return f.schedules[FeeScheduleStandard]
+}
+
+// rollingVolume returns the volume of the user over the last 30 days, dropping the days before them
+func (f *FeeEngine) rollingVolume(userID uint64) float64 {
+	oldest := f.now().Add(-feeVolumeWindow).Unix() / 86400
+
+	volume := 0.0
+	for day, v := range f.volume[userID] {
+		if day < oldest {
+			delete(f.volume[userID], day)
+			continue
+		}
+		volume += v
+	}
+
+	return volume
+}
+
+// tier returns the highest tier the volume qualifies for, the tiers are sorted by volume
+func (s FeeSchedule) tier(volume float64) FeeTier {
+	tier := s.Tiers[0]
+	for _, t := range s.Tiers[1:] {
+		if volume < t.MinVolume {
+			break
+		}
+		tier = t
+	}
+	return tier
+}
+
+// FeeStatus returns the fee schedule and current rates of the user
+func (ex *Exchange) FeeStatus(userID uint64) (*FeeStatusResponse, error) {
+	if _, err := ex.GetUser(userID); err != nil {
+		return nil, err
+	}
+
+	return ex.Fees.Status(userID), nil
+}
+
+// SetFeeSchedule assigns the named fee schedule to the user, FeeScheduleMarketMaker designates a market maker
+func (ex *Exchange) SetFeeSchedule(userID uint64, schedule string) (*FeeStatusResponse, error) {
+	if _, err := ex.GetUser(userID); err != nil {
+		return nil, err
+	}
+
+	if err := ex.Fees.SetSchedule(userID, schedule); err != nil {
+		return nil, err
+	}
+	if err := ex.saveUser(userID); err != nil {
+		return nil, err
+	}
+
+	return ex.Fees.Status(userID), nil
+}
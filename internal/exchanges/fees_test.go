@@ -0,0 +1,107 @@
+package exchanges
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeEngine(t *testing.T) {
+	f, err := NewFeeEngine(DefaultFeeSchedules()...)
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	// Test case 1: new users pay the lowest tier of the standard schedule
+	fees := f.Quote(1, 2, 10)
+	require.InDelta(t, 0.01, fees.MakerFee, 1e-12)
+	require.InDelta(t, 0.02, fees.TakerFee, 1e-12)
+	f.Record(1, 2, 100, 10)
+
+	// Test case 2: volume moves users up a tier once it is reached
+	f.Record(1, 3, 100, 999)
+	status := f.Status(1)
+	require.Equal(t, 100_900.0, status.Volume30d)
+	require.Equal(t, 0.0008, status.MakerRate)
+
+	fees = f.Quote(1, 2, 10)
+	require.InDelta(t, 0.008, fees.MakerFee, 1e-12)
+
+	// Test case 3: volume older than 30 days no longer counts
+	now = now.Add(31 * 24 * time.Hour)
+	require.Zero(t, f.Status(1).Volume30d)
+	require.Equal(t, 0.001, f.Status(1).MakerRate)
+
+	// Test case 4: market makers get a rebate on maker fills
+	require.NoError(t, f.SetSchedule(1, FeeScheduleMarketMaker))
+	fees = f.Quote(1, 2, 10)
+	require.InDelta(t, -0.001, fees.MakerFee, 1e-12)
+	require.Equal(t, FeeScheduleMarketMaker, f.Status(1).Schedule)
+
+	require.ErrorIs(t, f.SetSchedule(1, "VIP"), ErrUnknownFeeSchedule)
+	require.NoError(t, f.SetSchedule(1, FeeScheduleStandard))
+	require.Equal(t, FeeScheduleStandard, f.Status(1).Schedule)
+}
+
+func TestNewFeeEngine_Validation(t *testing.T) {
+	standard := FeeSchedule{Name: FeeScheduleStandard, Tiers: []FeeTier{{MakerRate: 0.001, TakerRate: 0.002}}}
+
+	// Test case 1: the standard schedule is required
+	_, err := NewFeeEngine(FeeSchedule{Name: "OTHER", Tiers: standard.Tiers})
+	require.ErrorIs(t, err, ErrUnknownFeeSchedule)
+
+	// Test case 2: every schedule needs a tier starting at zero volume
+	_, err = NewFeeEngine(FeeSchedule{Name: FeeScheduleStandard, Tiers: []FeeTier{{MinVolume: 10, TakerRate: 0.002}}})
+	require.Error(t, err)
+
+	// Test case 3: rebates above the lowest taker rate cannot be funded by the taker fee
+	_, err = NewFeeEngine(standard, FeeSchedule{Name: FeeScheduleMarketMaker, Tiers: []FeeTier{{MakerRate: -0.003, TakerRate: 0.002}}})
+	require.Error(t, err)
+}
+
+func TestFeesOnFills(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+	_, err := ex.SetFeeSchedule(1, FeeScheduleMarketMaker)
+	require.NoError(t, err)
+
+	_, err = ex.SetFeeSchedule(7, FeeScheduleMarketMaker)
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	maker := &recordingSink{}
+	ex.SubscribeUser(1, maker)
+
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 10, Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+
+	// Test case 1: fills, execution reports and matched orders carry the fees
+	resp, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 10, Market: MarketETH})
+	require.NoError(t, err)
+	matched := resp.([]*MatchedOrder)
+	require.InDelta(t, 0.02, matched[0].Fee, 1e-12)
+
+	fills, err := ex.ListFills(FillFilter{UserID: 1})
+	require.NoError(t, err)
+	require.InDelta(t, -0.001, fills.Fills[0].Fee, 1e-12)
+
+	report := maker.reports()[len(maker.reports())-1]
+	require.Equal(t, ExecFilled, report.Status)
+	require.InDelta(t, -0.001, report.Fee, 1e-12)
+
+	// Test case 2: fees go to the fee account, which pays the rebate out of the taker fee
+	require.InDelta(t, 0.019, ex.Ledger.Balance(FeeAccountID, string(MarketETH)), 1e-12)
+	require.InDelta(t, testFunds-9.999, ex.Ledger.Balance(1, string(MarketETH)), 1e-9)
+	require.InDelta(t, testFunds+9.98, ex.Ledger.Balance(2, string(MarketETH)), 1e-9)
+
+	// Test case 3: refunding restores every balance
+	fees := MatchFees{MakerUserID: 1, TakerUserID: 2, MakerFee: -0.001, TakerFee: 0.02}
+	ex.refundFees(MarketETH, fees, false)
+	require.InDelta(t, 0, ex.Ledger.Balance(FeeAccountID, string(MarketETH)), 1e-12)
+	require.InDelta(t, testFunds-10, ex.Ledger.Balance(1, string(MarketETH)), 1e-9)
+	require.InDelta(t, testFunds+10, ex.Ledger.Balance(2, string(MarketETH)), 1e-9)
+
+	// Test case 4: a taker that cannot pay its fee leaves the balances untouched
+	require.Error(t, ex.collectFees(MarketETH, MatchFees{MakerUserID: 1, TakerUserID: 3, MakerFee: -0.001, TakerFee: 0.02}, false))
+	require.InDelta(t, testFunds-10, ex.Ledger.Balance(1, string(MarketETH)), 1e-9)
+}
@@ -0,0 +1,180 @@
+package exchanges
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+)
+
+// Liquidity tells whether the order of a fill was resting in the book or took liquidity from it
+type Liquidity string
+
+const (
+	LiquidityMaker Liquidity = "MAKER"
+	LiquidityTaker Liquidity = "TAKER"
+)
+
+// Fill is one side of a trade. Every trade has two fills sharing the TradeID, one for the taker and one for the
+// maker. Fee is charged in the asset of the market and negative for maker rebates, Timestamp is in unix nanoseconds.
+type Fill struct {
+	ID                  uint64    `json:"id"`
+	TradeID             uint64    `json:"trade_id"`
+	Market              Market    `json:"market"`
+	OrderID             uint64    `json:"order_id"`
+	UserID              uint64    `json:"user_id"`
+	CounterpartyOrderID uint64    `json:"counterparty_order_id"`
+	Liquidity           Liquidity `json:"liquidity"`
+	IsBid               bool      `json:"is_bid"`
+	Price               float64   `json:"price"`
+	Amount              float64   `json:"amount"`
+	Fee                 float64   `json:"fee"`
+	Timestamp           int64     `json:"timestamp"`
+}
+
+// FillsResponse is a page of the fills of a user, NextCursor is empty on the last page
+type FillsResponse struct {
+	Fills      []*Fill `json:"fills"`
+	NextCursor string  `json:"next_cursor"`
+}
+
+// FillFilter selects fills of a user. Empty fields match everything, StartTime and EndTime bound the fill time in
+// unix nanoseconds and Cursor continues a previous page.
+type FillFilter struct {
+	UserID    uint64
+	Market    Market
+	OrderID   uint64
+	StartTime int64
+	EndTime   int64
+	Cursor    string
+	Limit     int
+}
+
+// fillHistory keeps both fills of every trade, it is built from the exchange events
+type fillHistory struct {
+	mu sync.RWMutex
+	// byUser holds the fills of every user in the order they happened
+	byUser map[uint64][]*Fill
+	seq    uint64
+	// persist is called with both fills of every trade when the exchange has a repository
+	persist func(fills ...Fill)
+}
+
+func newFillHistory() *fillHistory {
+	return &fillHistory{byUser: make(map[uint64][]*Fill)}
+}
+
+// handle records the fills of every trade, it is an events.Handler
+func (h *fillHistory) handle(e events.Envelope) {
+	trade, ok := e.Event.(events.TradeExecuted)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	taker := &Fill{
+		TradeID:             trade.TradeID,
+		Market:              Market(trade.Market),
+		OrderID:             trade.TakerOrderID,
+		UserID:              trade.TakerUserID,
+		CounterpartyOrderID: trade.MakerOrderID,
+		Liquidity:           LiquidityTaker,
+		IsBid:               trade.TakerIsBid,
+		Price:               trade.Price,
+		Amount:              trade.Amount,
+		Fee:                 trade.TakerFee,
+		Timestamp:           e.Timestamp,
+	}
+	maker := &Fill{
+		TradeID:             trade.TradeID,
+		Market:              Market(trade.Market),
+		OrderID:             trade.MakerOrderID,
+		UserID:              trade.MakerUserID,
+		CounterpartyOrderID: trade.TakerOrderID,
+		Liquidity:           LiquidityMaker,
+		IsBid:               !trade.TakerIsBid,
+		Price:               trade.Price,
+		Amount:              trade.Amount,
+		Fee:                 trade.MakerFee,
+		Timestamp:           e.Timestamp,
+	}
+
+	h.add(taker)
+	h.add(maker)
+	if h.persist != nil {
+		h.persist(*taker, *maker)
+	}
+}
+
+// restore adds the stored fills, ordered by ID
+func (h *fillHistory) restore(fills []*Fill) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, fill := range fills {
+		h.byUser[fill.UserID] = append(h.byUser[fill.UserID], fill)
+		if fill.ID > h.seq {
+			h.seq = fill.ID
+		}
+	}
+}
+
+func (h *fillHistory) add(fill *Fill) {
+	h.seq++
+	fill.ID = h.seq
+	h.byUser[fill.UserID] = append(h.byUser[fill.UserID], fill)
+}
+
+// list returns the page of the user's fills matching the filter, newest first
+func (h *fillHistory) list(filter FillFilter) (*FillsResponse, error) {
+	limit := pageLimit(filter.Limit)
+	before, err := parseCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	resp := &FillsResponse{Fills: []*Fill{}}
+	fills := h.byUser[filter.UserID]
+	for i := len(fills) - 1; i >= 0; i-- {
+		f := fills[i]
+
+		switch {
+		case before != 0 && f.ID >= before:
+			continue
+		case filter.Market != "" && f.Market != filter.Market:
+			continue
+		case filter.OrderID != 0 && f.OrderID != filter.OrderID:
+			continue
+		case filter.StartTime != 0 && f.Timestamp < filter.StartTime:
+			continue
+		case filter.EndTime != 0 && f.Timestamp > filter.EndTime:
+			continue
+		}
+
+		if len(resp.Fills) == limit {
+			resp.NextCursor = formatCursor(resp.Fills[len(resp.Fills)-1].ID)
+			break
+		}
+
+		fill := *f
+		resp.Fills = append(resp.Fills, &fill)
+	}
+
+	return resp, nil
+}
+
+// ListFills returns a page of the fills of a user, newest first
+func (ex *Exchange) ListFills(filter FillFilter) (*FillsResponse, error) {
+	if filter.Market != "" {
+		if _, exists := ex.Orderbooks[filter.Market]; !exists {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, filter.Market)
+		}
+	}
+
+	return ex.fills.list(filter)
+}
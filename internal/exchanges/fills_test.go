@@ -0,0 +1,77 @@
+package exchanges
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFills(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+
+	place := func(req *PlaceOrderRequest) uint64 {
+		resp, err := ex.PlaceOrder(req)
+		require.NoError(t, err)
+		return resp.(*PlaceOrderResponse).OrderID
+	}
+
+	firstAsk := place(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 2, Price: 100, Market: MarketETH})
+	secondAsk := place(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 2, Price: 110, Market: MarketETH})
+
+	// Test case 1: matched orders name the maker
+	resp, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 3, Market: MarketETH})
+	require.NoError(t, err)
+	matched := resp.([]*MatchedOrder)
+	require.Len(t, matched, 2)
+	require.Equal(t, uint64(1), matched[0].UserID)
+	require.Equal(t, firstAsk, matched[0].ID)
+
+	// Test case 2: both sides of every trade get a fill with the same trade ID
+	taker, err := ex.ListFills(FillFilter{UserID: 2})
+	require.NoError(t, err)
+	require.Len(t, taker.Fills, 2)
+
+	maker, err := ex.ListFills(FillFilter{UserID: 1})
+	require.NoError(t, err)
+	require.Len(t, maker.Fills, 2)
+
+	require.Equal(t, uint64(2), taker.Fills[0].TradeID)
+	require.Equal(t, uint64(1), taker.Fills[1].TradeID)
+	for i := range taker.Fills {
+		require.Equal(t, LiquidityTaker, taker.Fills[i].Liquidity)
+		require.True(t, taker.Fills[i].IsBid)
+		require.Equal(t, LiquidityMaker, maker.Fills[i].Liquidity)
+		require.False(t, maker.Fills[i].IsBid)
+		require.Equal(t, taker.Fills[i].TradeID, maker.Fills[i].TradeID)
+		require.Equal(t, taker.Fills[i].OrderID, maker.Fills[i].CounterpartyOrderID)
+		require.Equal(t, maker.Fills[i].OrderID, taker.Fills[i].CounterpartyOrderID)
+	}
+	require.Equal(t, secondAsk, maker.Fills[0].OrderID)
+	require.Equal(t, 110.0, maker.Fills[0].Price)
+	require.Equal(t, 1.0, maker.Fills[0].Amount)
+
+	// Test case 3: filters by order and pages by cursor
+	byOrder, err := ex.ListFills(FillFilter{UserID: 1, OrderID: firstAsk})
+	require.NoError(t, err)
+	require.Len(t, byOrder.Fills, 1)
+	require.Equal(t, 2.0, byOrder.Fills[0].Amount)
+
+	page, err := ex.ListFills(FillFilter{UserID: 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Fills, 1)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = ex.ListFills(FillFilter{UserID: 1, Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Equal(t, firstAsk, page.Fills[0].OrderID)
+	require.Empty(t, page.NextCursor)
+
+	// Test case 4: trade IDs are sequential per market
+	place(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 1, Price: 20000, Market: MarketBTC})
+	_, _ = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 1, Market: MarketBTC})
+
+	btc, err := ex.ListFills(FillFilter{UserID: 2, Market: MarketBTC})
+	require.NoError(t, err)
+	require.Len(t, btc.Fills, 1)
+	require.Equal(t, uint64(1), btc.Fills[0].TradeID)
+}
@@ -0,0 +1,265 @@
+package exchanges
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+)
+
+const (
+	// DefaultPageLimit is the page size of the order and fill history when none is requested
+	DefaultPageLimit = 100
+	// MaxPageLimit is the largest page size of the order and fill history
+	MaxPageLimit = 500
+)
+
+// ErrInvalidCursor is returned for pagination cursors the exchange did not hand out
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// OrderFilter selects orders of a user's history. Empty fields match everything, StartTime and EndTime bound the
+// creation time in unix nanoseconds and Cursor continues a previous page.
+type OrderFilter struct {
+	UserID    uint64
+	Statuses  []OrderStatus
+	Market    Market
+	StartTime int64
+	EndTime   int64
+	Cursor    string
+	Limit     int
+}
+
+// orderHistory keeps the record of every order ever placed, it is built from the exchange events
+type orderHistory struct {
+	mu      sync.RWMutex
+	records map[uint64]*historyEntry
+	// byUser holds the entries of every user in the order they were created
+	byUser map[uint64][]*historyEntry
+	seq    uint64
+	// persist is called with every new and changed record when the exchange has a repository
+	persist func(OrderRecord)
+}
+
+// historyEntry is an order record with its position in the history, the position is the pagination cursor
+type historyEntry struct {
+	seq    uint64
+	record OrderRecord
+}
+
+func newOrderHistory() *orderHistory {
+	return &orderHistory{
+		records: make(map[uint64]*historyEntry),
+		byUser:  make(map[uint64][]*historyEntry),
+	}
+}
+
+// handle applies the order events to the records, it is an events.Handler
+func (h *orderHistory) handle(e events.Envelope) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch ev := e.Event.(type) {
+	case events.OrderAccepted:
+		h.add(OrderRecord{
+			ID:             ev.OrderID,
+			ClientOrderID:  ev.ClientOrderID,
+			UserID:         ev.UserID,
+			Market:         Market(ev.Market),
+			Type:           OrderType(ev.Type),
+			IsBid:          ev.IsBid,
+			Price:          ev.Price,
+			Status:         OrderOpen,
+			OriginalAmount: ev.Amount,
+			Amount:         ev.Amount,
+			CreatedAt:      e.Timestamp,
+			UpdatedAt:      e.Timestamp,
+		})
+
+	case events.OrderRejected:
+		h.add(OrderRecord{
+			ID:             ev.OrderID,
+			ClientOrderID:  ev.ClientOrderID,
+			UserID:         ev.UserID,
+			Market:         Market(ev.Market),
+			Type:           OrderType(ev.Type),
+			IsBid:          ev.IsBid,
+			Price:          ev.Price,
+			Status:         OrderRejected,
+			OriginalAmount: ev.Amount,
+			Reason:         ev.Reason,
+			CreatedAt:      e.Timestamp,
+			UpdatedAt:      e.Timestamp,
+			ClosedAt:       e.Timestamp,
+		})
+
+	case events.TradeExecuted:
+		h.fill(ev.TakerOrderID, ev.Price, ev.Amount, ev.TakerRemaining, e.Timestamp)
+		h.fill(ev.MakerOrderID, ev.Price, ev.Amount, ev.MakerRemaining, e.Timestamp)
+
+	case events.OrderCancelled:
+		if entry, ok := h.records[ev.OrderID]; ok {
+			entry.record.Status = OrderCancelled
+			entry.record.Amount = ev.Remaining
+			entry.record.UpdatedAt = e.Timestamp
+			entry.record.ClosedAt = e.Timestamp
+			h.save(entry)
+		}
+	}
+}
+
+// restore adds the stored records, oldest first
+func (h *orderHistory) restore(records []OrderRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, record := range records {
+		h.seq++
+		entry := &historyEntry{seq: h.seq, record: record}
+		h.records[record.ID] = entry
+		h.byUser[record.UserID] = append(h.byUser[record.UserID], entry)
+	}
+}
+
+func (h *orderHistory) save(entry *historyEntry) {
+	if h.persist != nil {
+		h.persist(entry.record)
+	}
+}
+
+func (h *orderHistory) add(record OrderRecord) {
+	h.seq++
+	entry := &historyEntry{seq: h.seq, record: record}
+	h.records[record.ID] = entry
+	h.byUser[record.UserID] = append(h.byUser[record.UserID], entry)
+	h.save(entry)
+}
+
+func (h *orderHistory) fill(orderID uint64, price, amount, remaining float64, timestamp int64) {
+	entry, ok := h.records[orderID]
+	if !ok {
+		return
+	}
+
+	r := &entry.record
+	r.AvgFillPrice = (r.AvgFillPrice*r.FilledAmount + price*amount) / (r.FilledAmount + amount)
+	r.FilledAmount += amount
+	r.Amount = remaining
+	r.UpdatedAt = timestamp
+
+	r.Status = OrderPartiallyFilled
+	if remaining <= 0 {
+		r.Status = OrderFilled
+		r.ClosedAt = timestamp
+	}
+	h.save(entry)
+}
+
+// get returns a copy of the record of the order
+func (h *orderHistory) get(orderID uint64) (*OrderRecord, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	entry, ok := h.records[orderID]
+	if !ok {
+		return nil, false
+	}
+
+	record := entry.record
+	return &record, true
+}
+
+// open returns copies of the open orders of the user, oldest first
+func (h *orderHistory) open(userID uint64) []*OrderRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var records []*OrderRecord
+	for _, entry := range h.byUser[userID] {
+		if entry.record.ClosedAt == 0 {
+			record := entry.record
+			records = append(records, &record)
+		}
+	}
+
+	return records
+}
+
+// list returns the page of the user's orders matching the filter, newest first
+func (h *orderHistory) list(filter OrderFilter) (*OrderHistoryResponse, error) {
+	limit := pageLimit(filter.Limit)
+	before, err := parseCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[OrderStatus]bool, len(filter.Statuses))
+	for _, status := range filter.Statuses {
+		statuses[status] = true
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	resp := &OrderHistoryResponse{Orders: []*OrderRecord{}}
+	lastSeq := uint64(0)
+	entries := h.byUser[filter.UserID]
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		r := entry.record
+
+		switch {
+		case before != 0 && entry.seq >= before:
+			continue
+		case len(statuses) > 0 && !statuses[r.Status]:
+			continue
+		case filter.Market != "" && r.Market != filter.Market:
+			continue
+		case filter.StartTime != 0 && r.CreatedAt < filter.StartTime:
+			continue
+		case filter.EndTime != 0 && r.CreatedAt > filter.EndTime:
+			continue
+		}
+
+		if len(resp.Orders) == limit {
+			resp.NextCursor = formatCursor(lastSeq)
+			break
+		}
+
+		resp.Orders = append(resp.Orders, &r)
+		lastSeq = entry.seq
+	}
+
+	return resp, nil
+}
+
+// pageLimit returns the page size for the requested limit
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return limit
+}
+
+// parseCursor returns the history position a cursor continues from, zero for the first page. The cursor is the
+// position of the last record of the previous page.
+func parseCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	seq, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil || seq == 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCursor, cursor)
+	}
+
+	return seq, nil
+}
+
+func formatCursor(seq uint64) string {
+	return strconv.FormatUint(seq, 10)
+}
@@ -0,0 +1,109 @@
+package exchanges
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderHistory(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+
+	place := func(req *PlaceOrderRequest) uint64 {
+		resp, err := ex.PlaceOrder(req)
+		require.NoError(t, err)
+		return resp.(*PlaceOrderResponse).OrderID
+	}
+
+	askID := place(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 5, Price: 100, Market: MarketETH})
+	bidID := place(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: true, Amount: 2, Price: 90, Market: MarketETH})
+	place(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 1, Price: 20000, Market: MarketBTC})
+
+	// Test case 1: new orders are open with their original amount
+	open, err := ex.GetUserOrders(1)
+	require.NoError(t, err)
+	require.Len(t, open.Asks, 2)
+	require.Len(t, open.Bids, 1)
+	require.Equal(t, OrderOpen, open.Bids[0].Status)
+	require.Equal(t, 2.0, open.Bids[0].OriginalAmount)
+	require.NotZero(t, open.Bids[0].CreatedAt)
+
+	// Test case 2: fills update the filled amount and average price, settlement fails without ledger balance
+	_, _ = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 3, Market: MarketETH})
+
+	ask, ok := ex.history.get(askID)
+	require.True(t, ok)
+	require.Equal(t, OrderPartiallyFilled, ask.Status)
+	require.Equal(t, 3.0, ask.FilledAmount)
+	require.Equal(t, 2.0, ask.Amount)
+	require.Equal(t, 100.0, ask.AvgFillPrice)
+	require.Zero(t, ask.ClosedAt)
+
+	taker, err := ex.ListOrders(OrderFilter{UserID: 2})
+	require.NoError(t, err)
+	require.Len(t, taker.Orders, 1)
+	require.Equal(t, OrderFilled, taker.Orders[0].Status)
+	require.Equal(t, MarketOrder, taker.Orders[0].Type)
+	require.NotZero(t, taker.Orders[0].ClosedAt)
+
+	// Test case 3: cancelled and rejected orders stay in the history once closed
+	require.NoError(t, ex.CancelOrder(1, bidID))
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: MarketOrder, IsBid: true, Amount: 10, Market: MarketETH})
+	require.ErrorIs(t, err, ErrInsufficientLiquidity)
+
+	open, err = ex.GetUserOrders(1)
+	require.NoError(t, err)
+	require.Empty(t, open.Bids)
+	require.Len(t, ex.Orders[1], 2)
+
+	closed, err := ex.ListOrders(OrderFilter{UserID: 1, Statuses: []OrderStatus{OrderCancelled, OrderRejected}})
+	require.NoError(t, err)
+	require.Len(t, closed.Orders, 2)
+	require.Equal(t, OrderRejected, closed.Orders[0].Status)
+	require.NotEmpty(t, closed.Orders[0].Reason)
+	require.Equal(t, bidID, closed.Orders[1].ID)
+	require.Equal(t, OrderCancelled, closed.Orders[1].Status)
+	require.Equal(t, 2.0, closed.Orders[1].Amount)
+
+	// Test case 4: filters by market and time range
+	btc, err := ex.ListOrders(OrderFilter{UserID: 1, Market: MarketBTC})
+	require.NoError(t, err)
+	require.Len(t, btc.Orders, 1)
+
+	ranged, err := ex.ListOrders(OrderFilter{UserID: 1, StartTime: ask.CreatedAt, EndTime: ask.CreatedAt})
+	require.NoError(t, err)
+	require.Len(t, ranged.Orders, 1)
+	require.Equal(t, askID, ranged.Orders[0].ID)
+
+	_, err = ex.ListOrders(OrderFilter{UserID: 1, Market: "DOGE"})
+	require.Error(t, err)
+}
+
+func TestOrderHistoryPagination(t *testing.T) {
+	ex := newTestExchange(t, 1)
+
+	var ids []uint64
+	for i := 0; i < 5; i++ {
+		resp, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: true, Amount: 1, Price: float64(90 + i), Market: MarketETH})
+		require.NoError(t, err)
+		ids = append(ids, resp.(*PlaceOrderResponse).OrderID)
+	}
+
+	// Test case 1: pages are newest first and chained by the cursor
+	var got []uint64
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		resp, err := ex.ListOrders(OrderFilter{UserID: 1, Limit: 2, Cursor: cursor})
+		require.NoError(t, err)
+		for _, order := range resp.Orders {
+			got = append(got, order.ID)
+		}
+		cursor = resp.NextCursor
+	}
+	require.Empty(t, cursor)
+	require.Equal(t, []uint64{ids[4], ids[3], ids[2], ids[1], ids[0]}, got)
+
+	// Test case 2: cursors the exchange did not hand out are rejected
+	_, err := ex.ListOrders(OrderFilter{UserID: 1, Cursor: "abc"})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
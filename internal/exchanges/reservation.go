@@ -0,0 +1,81 @@
+package exchanges
+
+import (
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/matchingengine"
+)
+
+// reservation is what an order holds of the ledger balance of its user until it is filled or cancelled
+type reservation struct {
+	userID uint64
+	asset  string
+	amount float64
+}
+
+// reserve holds the funds the order needs to settle, the caller holds bookMu. Sellers hold the amount they sell and
+// its fee at the highest rate of any schedule, buyers the quote asset to pay for what they buy. A market buy pays
+// the prices of the resting orders it fills.
+func (ex *Exchange) reserve(market Market, orderType OrderType, order *matchingengine.Order, price float64) error {
+	r := &reservation{userID: order.UserID, asset: string(market), amount: order.Amount * (1 + ex.Fees.MaxRate())}
+	if order.Bid {
+		r.asset, r.amount = QuoteAsset, order.Amount*price
+	}
+
+	if order.Bid && orderType == MarketOrder {
+		matches, err := ex.Orderbooks[market].PreviewMarketOrder(order)
+		if err != nil {
+			return err
+		}
+
+		r.amount = 0
+		for _, match := range matches {
+			r.amount += match.Price * match.AmountFilled
+		}
+	}
+
+	if err := ex.Ledger.Hold(r.userID, r.asset, r.amount); err != nil {
+		return err
+	}
+	ex.reservations[order.ID] = r
+
+	return nil
+}
+
+// useReservation takes reserved off the reservation of the order once spent of the hold paid for a fill, the rest of
+// reserved becomes available again
+func (ex *Exchange) useReservation(orderID uint64, reserved, spent float64) {
+	r, ok := ex.reservations[orderID]
+	if !ok {
+		return
+	}
+
+	r.amount -= reserved
+	if reserved > spent {
+		ex.Ledger.Release(r.userID, r.asset, reserved-spent)
+	}
+}
+
+// releaseReservation makes what the order still holds available again, for orders that are filled or cancelled
+func (ex *Exchange) releaseReservation(orderID uint64) {
+	r, ok := ex.reservations[orderID]
+	if !ok {
+		return
+	}
+
+	delete(ex.reservations, orderID)
+	if r.amount > 0 {
+		ex.Ledger.Release(r.userID, r.asset, r.amount)
+	}
+}
+
+// restoreReservation holds a reservation taken off the order with releaseReservation again, failing if the user spent
+// the funds in the meantime
+func (ex *Exchange) restoreReservation(orderID uint64, r *reservation) error {
+	if r.amount > 0 {
+		if err := ex.Ledger.Hold(r.userID, r.asset, r.amount); err != nil {
+			return err
+		}
+	}
+	ex.reservations[orderID] = r
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package exchanges
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/ledger"
+)
+
+func TestReservations(t *testing.T) {
+	ex := newTestExchange(t, 1, 2, 3)
+	for _, id := range []uint64{1, 2, 3} {
+		for _, asset := range []string{string(MarketETH), string(MarketBTC), QuoteAsset} {
+			require.NoError(t, ex.Ledger.Debit(id, asset, testFunds))
+		}
+	}
+	margin := 1 + ex.Fees.MaxRate()
+
+	// Test case 1: orders the user cannot pay for are rejected
+	_, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 2, Price: 100, Market: MarketETH})
+	require.ErrorIs(t, err, ledger.ErrInsufficientBalance)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: LimitOrder, IsBid: true, Amount: 2, Price: 100, Market: MarketBTC})
+	require.ErrorIs(t, err, ledger.ErrInsufficientBalance)
+
+	// Test case 2: sellers hold the amount and its fee, buyers the price
+	ex.Ledger.Credit(1, string(MarketETH), 10)
+	ex.Ledger.Credit(2, QuoteAsset, 1000)
+	ask, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 2, Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 2, Price: 110, Market: MarketETH})
+	require.NoError(t, err)
+	bid, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: LimitOrder, IsBid: true, Amount: 5, Price: 90, Market: MarketETH})
+	require.NoError(t, err)
+	require.InDelta(t, 4*margin, ex.Ledger.Held(1, string(MarketETH)), 1e-9)
+	require.Equal(t, 450.0, ex.Ledger.Held(2, QuoteAsset))
+
+	// Test case 3: held funds cannot be spent twice
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: LimitOrder, IsBid: true, Amount: 6, Price: 100, Market: MarketETH})
+	require.ErrorIs(t, err, ledger.ErrInsufficientBalance)
+
+	// Test case 4: cancelling releases the hold
+	require.NoError(t, ex.CancelOrder(2, bid.(*PlaceOrderResponse).OrderID))
+	require.Zero(t, ex.Ledger.Held(2, QuoteAsset))
+
+	// Test case 5: a market buy pays the prices of the orders it fills, both legs settle
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 3, Market: MarketETH})
+	require.NoError(t, err)
+	require.Equal(t, 690.0, ex.Ledger.Balance(2, QuoteAsset))
+	require.InDelta(t, 3*(1-0.002), ex.Ledger.Balance(2, string(MarketETH)), 1e-9)
+	require.Equal(t, 310.0, ex.Ledger.Balance(1, QuoteAsset))
+	require.InDelta(t, 10-3*1.001, ex.Ledger.Balance(1, string(MarketETH)), 1e-9)
+	require.Zero(t, ex.Ledger.Held(2, QuoteAsset))
+
+	// Test case 6: the filled ask released its hold, the partially filled one holds what is left
+	_, err = ex.GetOrder(ask.(*PlaceOrderResponse).OrderID)
+	require.ErrorIs(t, err, ErrOrderNotFound)
+	require.InDelta(t, margin, ex.Ledger.Held(1, string(MarketETH)), 1e-9)
+
+	// Test case 7: a market buy costing more than the available balance is rejected
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: true, Amount: 1, Price: 1, Market: MarketETH})
+	require.NoError(t, err)
+	ex.Ledger.Credit(3, QuoteAsset, 100)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 3, Type: MarketOrder, IsBid: true, Amount: 1, Market: MarketETH})
+	require.ErrorIs(t, err, ledger.ErrInsufficientBalance)
+	require.InDelta(t, margin, ex.Ledger.Held(1, string(MarketETH)), 1e-9)
+}
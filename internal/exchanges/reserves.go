@@ -0,0 +1,113 @@
+package exchanges
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/reserves"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/ethclient"
+)
+
+// PublishReserves builds a Merkle sum tree of all user balances per asset from the ledger, compares the total
+// liabilities with the on-chain balances of the exchange addresses and publishes the result. The quote asset gets a
+// tree too, its balances are liabilities like any other, but nothing on-chain backs it.
+func (ex *Exchange) PublishReserves() ([]*ReservesReport, error) {
+	var reports []*ReservesReport
+
+	for _, market := range ex.reserveAssets() {
+		tree := reserves.BuildTree(string(market), ex.Ledger.Balances(string(market)))
+		root := tree.Root()
+
+		report := &ReservesReport{
+			Market:      market,
+			Root:        root.Hash,
+			Liabilities: root.Sum,
+			Timestamp:   time.Now().UnixNano(),
+		}
+
+		// Only ETH is held on-chain, there is nothing to compare other assets against
+		if market == MarketETH {
+			onChain, err := ex.onChainReserves()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get on-chain reserves: %w", err)
+			}
+
+			report.OnChainVerified = true
+			report.OnChainReserves = onChain
+			report.Solvent = onChain >= root.Sum
+		}
+
+		ex.mu.Lock()
+		ex.reserveTrees[market] = tree
+		ex.reserveReports[market] = report
+		ex.mu.Unlock()
+
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// GetReserves returns the latest published reserves reports
+func (ex *Exchange) GetReserves() []*ReservesReport {
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+
+	reports := make([]*ReservesReport, 0, len(ex.reserveReports))
+	for _, report := range ex.reserveReports {
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+// GetReservesProof returns the user's inclusion proof in the latest published tree of the market's asset, market may
+// also be the quote asset
+func (ex *Exchange) GetReservesProof(userID uint64, market Market) (*reserves.Proof, error) {
+	if _, ok := ex.Orderbooks[market]; !ok && market != QuoteAsset {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
+	}
+
+	ex.mu.RLock()
+	tree, ok := ex.reserveTrees[market]
+	ex.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no reserves published for market %s", market)
+	}
+
+	return tree.Proof(userID)
+}
+
+// reserveAssets returns the assets reserves are published for, the asset of every market and the quote asset
+func (ex *Exchange) reserveAssets() []Market {
+	assets := make([]Market, 0, len(ex.Orderbooks)+1)
+	for market := range ex.Orderbooks {
+		assets = append(assets, market)
+	}
+
+	return append(assets, QuoteAsset)
+}
+
+// onChainReserves sums the ETH held by the hot wallet, every user deposit address and the extra reserve addresses
+func (ex *Exchange) onChainReserves() (float64, error) {
+	addresses := append([]string{ex.Signer.Address().Hex()}, ex.ReserveAddresses...)
+
+	ex.mu.RLock()
+	for _, user := range ex.Users {
+		addresses = append(addresses, user.Signer.Address().Hex())
+	}
+	ex.mu.RUnlock()
+
+	total := big.NewInt(0)
+	for _, address := range addresses {
+		balance, err := ex.ETHClient.GetBalance(address)
+		if err != nil {
+			return 0, err
+		}
+		total.Add(total, balance)
+	}
+
+	return ethclient.WeiToEther(total), nil
+}
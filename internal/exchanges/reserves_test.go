@@ -0,0 +1,57 @@
+package exchanges
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/ethclient"
+)
+
+func TestPublishReserves(t *testing.T) {
+	// Every exchange address holds 1 ETH
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "0xde0b6b3a7640000"})
+	}))
+	defer node.Close()
+	client, err := ethclient.New(node.URL)
+	require.NoError(t, err)
+
+	ex := newTestExchange(t, 1, 2)
+	ex.ETHClient = client
+
+	reports, err := ex.PublishReserves()
+	require.NoError(t, err)
+	published := make(map[Market]*ReservesReport)
+	for _, report := range reports {
+		published[report.Market] = report
+	}
+
+	// Test case 1: ETH liabilities are compared with the on-chain balances of the hot wallet and the users
+	require.True(t, published[MarketETH].OnChainVerified)
+	require.Equal(t, 3.0, published[MarketETH].OnChainReserves)
+	require.Equal(t, 2*testFunds, published[MarketETH].Liabilities)
+
+	// Test case 2: the quote asset is published too, nothing on-chain backs it
+	require.Len(t, reports, len(ex.Orderbooks)+1)
+	usd := published[QuoteAsset]
+	require.NotNil(t, usd)
+	require.False(t, usd.OnChainVerified)
+	require.False(t, usd.Solvent)
+	require.Equal(t, 2*testFunds, usd.Liabilities)
+
+	proof, err := ex.GetReservesProof(1, QuoteAsset)
+	require.NoError(t, err)
+	require.Equal(t, testFunds, proof.Balance)
+	require.Equal(t, usd.Root, proof.Root.Hash)
+
+	// Test case 3: assets the exchange does not hold have no proofs
+	_, err = ex.GetReservesProof(1, "DOGE")
+	require.ErrorIs(t, err, ErrUnknownMarket)
+}
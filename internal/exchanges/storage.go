@@ -0,0 +1,569 @@
+package exchanges
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/matchingengine"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage"
+)
+
+// UseRepository restores the users, API keys, ledger balances, orders and fills from the repository and writes every
+// later change to it. Open limit orders go back into the orderbooks and reserve their funds again. It must be called
+// before users are added, keys are issued and orders are taken.
+func (ex *Exchange) UseRepository(repo storage.Repository) error {
+	if err := ex.restoreUsers(repo); err != nil {
+		return err
+	}
+
+	if err := ex.restoreAPIKeys(repo); err != nil {
+		return err
+	}
+
+	balances, err := repo.ListBalances()
+	if err != nil {
+		return fmt.Errorf("failed to load balances: %w", err)
+	}
+	for _, b := range balances {
+		ex.Ledger.Credit(b.UserID, b.Asset, b.Balance)
+	}
+
+	if err := ex.restoreOrders(repo); err != nil {
+		return err
+	}
+
+	fills, err := repo.ListFills()
+	if err != nil {
+		return fmt.Errorf("failed to load fills: %w", err)
+	}
+	restored := make([]*Fill, len(fills))
+	for i, f := range fills {
+		restored[i] = fillFromStorage(f)
+		ex.Fees.AddVolume(f.UserID, f.Price*f.Amount, time.Unix(0, f.Timestamp))
+	}
+	ex.fills.restore(restored)
+
+	ex.persist = newPersister(repo, balances)
+	ex.Keys.Persist(ex.persist.saveAPIKey, repo.DeleteAPIKey)
+	ex.history.persist = ex.persist.saveOrder
+	ex.fills.persist = ex.persist.saveFills
+	ex.Ledger.OnChange(ex.persist.saveBalance)
+	ex.Events.Subscribe(ex.recordSettlement)
+
+	return nil
+}
+
+// restoreUsers adds the stored users, their keys are derived from the exchange HD wallet again
+func (ex *Exchange) restoreUsers(repo storage.Repository) error {
+	users, err := repo.ListUsers()
+	if err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+
+	for _, u := range users {
+		if u.DerivationIndex == 0 || ex.Wallet == nil {
+			return fmt.Errorf("cannot restore the keys of user %d, they are not derived from the exchange wallet", u.ID)
+		}
+
+		s, err := ex.Wallet.Signer(depositAccounts[MarketETH], u.DerivationIndex)
+		if err != nil {
+			return fmt.Errorf("failed to derive keys for user %d: %w", u.ID, err)
+		}
+
+		ex.mu.Lock()
+		ex.Users[u.ID] = &models.User{
+			ID:              u.ID,
+			Signer:          s,
+			Address:         u.Address,
+			Status:          models.UserStatus(u.Status),
+			DerivationIndex: u.DerivationIndex,
+		}
+		if u.ID >= ex.nextUserID {
+			ex.nextUserID = u.ID + 1
+		}
+		ex.mu.Unlock()
+
+		if u.FeeSchedule != "" {
+			if err := ex.Fees.SetSchedule(u.ID, u.FeeSchedule); err != nil {
+				return fmt.Errorf("failed to restore the fee schedule of user %d: %w", u.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// restoreAPIKeys adds the stored API keys to the key store, they keep the hash of their secret
+func (ex *Exchange) restoreAPIKeys(repo storage.Repository) error {
+	apiKeys, err := repo.ListAPIKeys()
+	if err != nil {
+		return fmt.Errorf("failed to load API keys: %w", err)
+	}
+
+	for _, k := range apiKeys {
+		permissions := make(auth.Permissions, len(k.Permissions))
+		for i, name := range k.Permissions {
+			if permissions[i], err = auth.ParsePermission(name); err != nil {
+				return fmt.Errorf("failed to restore API key of user %d: %w", k.UserID, err)
+			}
+		}
+
+		ex.Keys.Add(&auth.APIKey{
+			Key:         k.Key,
+			SecretHash:  k.SecretHash,
+			UserID:      k.UserID,
+			Permissions: permissions,
+			CreatedAt:   k.CreatedAt,
+		})
+	}
+
+	return nil
+}
+
+// restoreOrders rebuilds the order history and places the open limit orders back into the orderbooks in the order
+// they were created, so they keep their time priority
+func (ex *Exchange) restoreOrders(repo storage.Repository) error {
+	orders, err := repo.ListOrders()
+	if err != nil {
+		return fmt.Errorf("failed to load orders: %w", err)
+	}
+
+	records := make([]OrderRecord, len(orders))
+	for i, o := range orders {
+		records[i] = orderFromStorage(o)
+	}
+	ex.history.restore(records)
+
+	for _, r := range records {
+		if r.ID > ex.lastOrderID {
+			ex.lastOrderID = r.ID
+		}
+		if r.ClosedAt != 0 || r.Type != LimitOrder {
+			continue
+		}
+
+		ob, exists := ex.Orderbooks[r.Market]
+		if !exists {
+			return fmt.Errorf("order %d is in market %s which does not exist", r.ID, r.Market)
+		}
+
+		order := &matchingengine.Order{
+			ID:        r.ID,
+			UserID:    r.UserID,
+			Amount:    r.Amount,
+			Bid:       r.IsBid,
+			Timestamp: r.CreatedAt,
+		}
+		ex.bookMu.Lock()
+		err := ex.reserve(r.Market, LimitOrder, order, r.Price)
+		if err == nil {
+			ob.PlaceLimitOrder(r.Price, order)
+		}
+		ex.bookMu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to reserve the funds of order %d: %w", r.ID, err)
+		}
+
+		ex.mu.Lock()
+		ex.Orders[r.UserID] = append(ex.Orders[r.UserID], order)
+		ex.mu.Unlock()
+	}
+
+	return nil
+}
+
+// saveUser writes the user to the repository, if the exchange has one
+func (ex *Exchange) saveUser(userID uint64) error {
+	if ex.persist == nil {
+		return nil
+	}
+
+	user, err := ex.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	schedule := ex.Fees.Status(userID).Schedule
+	if schedule == FeeScheduleStandard {
+		schedule = ""
+	}
+
+	err = ex.persist.repo.SaveUser(&storage.User{
+		ID:              user.ID,
+		Address:         user.Address,
+		Status:          string(user.Status),
+		DerivationIndex: user.DerivationIndex,
+		FeeSchedule:     schedule,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save user %d: %w", userID, err)
+	}
+
+	return nil
+}
+
+// recordSettlement writes every settlement to the repository, it is an events.Handler
+func (ex *Exchange) recordSettlement(e events.Envelope) {
+	settlement, ok := e.Event.(events.SettlementCompleted)
+	if !ok {
+		return
+	}
+
+	ex.persist.saveSettlement(&storage.Settlement{
+		Market:    settlement.Market,
+		Price:     settlement.Price,
+		Amount:    settlement.Amount,
+		BuyerID:   settlement.BuyerID,
+		SellerID:  settlement.SellerID,
+		Timestamp: e.Timestamp,
+	})
+}
+
+// beginBatch collects the changes until commitBatch into one batch, if the exchange has a repository. The caller
+// holds bookMu, the batch takes the balances of the matches it settles.
+func (ex *Exchange) beginBatch(market Market, matches matchingengine.Matches) {
+	if ex.persist == nil {
+		return
+	}
+
+	keys := []balanceKey{{FeeAccountID, string(market)}}
+	for _, match := range matches {
+		for _, userID := range []uint64{match.Bid.UserID, match.Ask.UserID} {
+			keys = append(keys, balanceKey{userID, string(market)}, balanceKey{userID, QuoteAsset})
+		}
+	}
+	ex.persist.begin(keys)
+}
+
+// commitBatch writes the changes collected since beginBatch in one transaction. If they cannot be written the
+// exchange stops taking orders, see checkPersisted.
+func (ex *Exchange) commitBatch() error {
+	if ex.persist == nil {
+		return nil
+	}
+
+	return ex.persist.commit()
+}
+
+// checkPersisted returns ErrTradingHalted once the account state could not be written, the orders taken before are
+// not all stored and more orders would build on them
+func (ex *Exchange) checkPersisted() error {
+	if ex.persist == nil {
+		return nil
+	}
+
+	return ex.persist.failure()
+}
+
+// balanceKey identifies the balance of a user in an asset
+type balanceKey struct {
+	userID uint64
+	asset  string
+}
+
+// maxBatchAttempts is how often a batch or balance is written when another writer changed the balances, the versions
+// are reloaded before every attempt
+const maxBatchAttempts = 3
+
+// persister writes the account state to the repository. Between begin and commit the changes of an order are
+// collected and written as one batch, so the ledger postings, fills and settlements of a match batch are stored
+// together or not at all.
+type persister struct {
+	repo storage.Repository
+
+	mu sync.Mutex
+	// versions holds the stored version of every balance, a balance is only written over the version it was read at
+	versions map[balanceKey]uint64
+	// batch collects the changes of the order being executed, nil outside of one
+	batch *batch
+	// err is why a batch could not be written, no more orders are taken after that
+	err error
+}
+
+// batch is the changes of one order. Only order entry opens batches and orders, fills and settlements only change
+// during order entry, so those are the order's own. Balances also change on other goroutines, only the ones the
+// order settles join the batch. orders and balances index the batch so every record is written with its latest
+// state.
+type batch struct {
+	storage.Batch
+	keys     map[balanceKey]bool
+	orders   map[uint64]*storage.Order
+	balances map[balanceKey]*storage.Balance
+}
+
+func newPersister(repo storage.Repository, balances []*storage.Balance) *persister {
+	p := &persister{repo: repo, versions: make(map[balanceKey]uint64)}
+	for _, b := range balances {
+		p.versions[balanceKey{b.UserID, b.Asset}] = b.Version
+	}
+	return p
+}
+
+func (p *persister) begin(keys []balanceKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.batch = &batch{
+		keys:     make(map[balanceKey]bool, len(keys)),
+		orders:   make(map[uint64]*storage.Order),
+		balances: make(map[balanceKey]*storage.Balance),
+	}
+	for _, key := range keys {
+		p.batch.keys[key] = true
+	}
+}
+
+// commit writes the open batch, reloading the balance versions when another writer changed them. The balances of
+// the batch are the latest ones, changes made meanwhile wait for the lock and are written after it.
+func (p *persister) commit() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := p.batch
+	p.batch = nil
+	if b == nil || b.Empty() {
+		return nil
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxBatchAttempts; attempt++ {
+		if err = p.repo.SaveBatch(&b.Batch); !errors.Is(err, storage.ErrConflict) {
+			break
+		}
+		if err = p.reloadVersions(b.Balances); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		p.fail(fmt.Errorf("failed to save the batch of %d fills: %w", len(b.Fills), err))
+		return p.err
+	}
+
+	for _, balance := range b.Balances {
+		p.versions[balanceKey{balance.UserID, balance.Asset}] = balance.Version
+	}
+	return nil
+}
+
+// reloadVersions reads the stored versions of the balances
+func (p *persister) reloadVersions(balances []*storage.Balance) error {
+	stored, err := p.repo.ListBalances()
+	if err != nil {
+		return fmt.Errorf("failed to reload balances: %w", err)
+	}
+
+	for _, s := range stored {
+		p.versions[balanceKey{s.UserID, s.Asset}] = s.Version
+	}
+	for _, b := range balances {
+		b.Version = p.versions[balanceKey{b.UserID, b.Asset}]
+	}
+	return nil
+}
+
+func (p *persister) failure() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// The save methods are called from event handlers and the ledger, which cannot fail. A change that cannot be stored
+// halts trading instead, the requests check for it with checkPersisted.
+
+func (p *persister) saveOrder(record OrderRecord) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	order := orderToStorage(record)
+	if p.batch != nil {
+		if pending, ok := p.batch.orders[order.ID]; ok {
+			*pending = *order
+			return
+		}
+		p.batch.orders[order.ID] = order
+		p.batch.Orders = append(p.batch.Orders, order)
+		return
+	}
+
+	if err := p.repo.SaveOrder(order); err != nil {
+		p.fail(fmt.Errorf("failed to save order %d: %w", record.ID, err))
+	}
+}
+
+// saveAPIKey writes an issued or granted key, the key store returns the error to the caller so it does not halt
+// trading
+func (p *persister) saveAPIKey(apiKey *auth.APIKey) error {
+	permissions := make([]string, len(apiKey.Permissions))
+	for i, permission := range apiKey.Permissions {
+		permissions[i] = string(permission)
+	}
+
+	err := p.repo.SaveAPIKey(&storage.APIKey{
+		Key:         apiKey.Key,
+		SecretHash:  apiKey.SecretHash,
+		UserID:      apiKey.UserID,
+		Permissions: permissions,
+		CreatedAt:   apiKey.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save API key of user %d: %w", apiKey.UserID, err)
+	}
+
+	return nil
+}
+
+func (p *persister) saveFills(fills ...Fill) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stored := make([]*storage.Fill, len(fills))
+	for i, f := range fills {
+		stored[i] = fillToStorage(f)
+	}
+	if p.batch != nil {
+		p.batch.Fills = append(p.batch.Fills, stored...)
+		return
+	}
+
+	if err := p.repo.SaveFills(stored...); err != nil {
+		p.fail(fmt.Errorf("failed to save fills of trade %d: %w", fills[0].TradeID, err))
+	}
+}
+
+func (p *persister) saveBalance(userID uint64, asset string, balance float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := balanceKey{userID, asset}
+	if p.batch != nil && p.batch.keys[key] {
+		if pending, ok := p.batch.balances[key]; ok {
+			pending.Balance = balance
+			return
+		}
+		b := &storage.Balance{UserID: userID, Asset: asset, Balance: balance, Version: p.versions[key]}
+		p.batch.balances[key] = b
+		p.batch.Balances = append(p.batch.Balances, b)
+		return
+	}
+
+	b := &storage.Balance{UserID: userID, Asset: asset, Balance: balance, Version: p.versions[key]}
+	var err error
+	for attempt := 1; attempt <= maxBatchAttempts; attempt++ {
+		if err = p.repo.SaveBalance(b); !errors.Is(err, storage.ErrConflict) {
+			break
+		}
+		if err = p.reloadVersions([]*storage.Balance{b}); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		p.fail(fmt.Errorf("failed to save %s balance of user %d: %w", asset, userID, err))
+		return
+	}
+	p.versions[key] = b.Version
+}
+
+func (p *persister) saveSettlement(settlement *storage.Settlement) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.batch != nil {
+		p.batch.Settlements = append(p.batch.Settlements, settlement)
+		return
+	}
+
+	if err := p.repo.SaveSettlement(settlement); err != nil {
+		p.fail(fmt.Errorf("failed to save settlement of %s: %w", settlement.Market, err))
+	}
+}
+
+// fail halts trading because of err, the caller holds mu
+func (p *persister) fail(err error) {
+	log.Printf("%v", err)
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %v", ErrTradingHalted, err)
+	}
+}
+
+func orderToStorage(r OrderRecord) *storage.Order {
+	return &storage.Order{
+		ID:             r.ID,
+		ClientOrderID:  r.ClientOrderID,
+		UserID:         r.UserID,
+		Market:         string(r.Market),
+		Type:           string(r.Type),
+		IsBid:          r.IsBid,
+		Price:          r.Price,
+		Status:         string(r.Status),
+		OriginalAmount: r.OriginalAmount,
+		Amount:         r.Amount,
+		FilledAmount:   r.FilledAmount,
+		AvgFillPrice:   r.AvgFillPrice,
+		Reason:         r.Reason,
+		CreatedAt:      r.CreatedAt,
+		UpdatedAt:      r.UpdatedAt,
+		ClosedAt:       r.ClosedAt,
+	}
+}
+
+func orderFromStorage(o *storage.Order) OrderRecord {
+	return OrderRecord{
+		ID:             o.ID,
+		ClientOrderID:  o.ClientOrderID,
+		UserID:         o.UserID,
+		Market:         Market(o.Market),
+		Type:           OrderType(o.Type),
+		IsBid:          o.IsBid,
+		Price:          o.Price,
+		Status:         OrderStatus(o.Status),
+		OriginalAmount: o.OriginalAmount,
+		Amount:         o.Amount,
+		FilledAmount:   o.FilledAmount,
+		AvgFillPrice:   o.AvgFillPrice,
+		Reason:         o.Reason,
+		CreatedAt:      o.CreatedAt,
+		UpdatedAt:      o.UpdatedAt,
+		ClosedAt:       o.ClosedAt,
+	}
+}
+
+func fillToStorage(f Fill) *storage.Fill {
+	return &storage.Fill{
+		ID:                  f.ID,
+		TradeID:             f.TradeID,
+		Market:              string(f.Market),
+		OrderID:             f.OrderID,
+		UserID:              f.UserID,
+		CounterpartyOrderID: f.CounterpartyOrderID,
+		Liquidity:           string(f.Liquidity),
+		IsBid:               f.IsBid,
+		Price:               f.Price,
+		Amount:              f.Amount,
+		Fee:                 f.Fee,
+		Timestamp:           f.Timestamp,
+	}
+}
+
+func fillFromStorage(f *storage.Fill) *Fill {
+	return &Fill{
+		ID:                  f.ID,
+		TradeID:             f.TradeID,
+		Market:              Market(f.Market),
+		OrderID:             f.OrderID,
+		UserID:              f.UserID,
+		CounterpartyOrderID: f.CounterpartyOrderID,
+		Liquidity:           Liquidity(f.Liquidity),
+		IsBid:               f.IsBid,
+		Price:               f.Price,
+		Amount:              f.Amount,
+		Fee:                 f.Fee,
+		Timestamp:           f.Timestamp,
+	}
+}
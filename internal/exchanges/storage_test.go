@@ -0,0 +1,234 @@
+package exchanges
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/auth"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage/boltdb"
+)
+
+func TestUseRepository(t *testing.T) {
+	wallet := newTestWallet(t)
+
+	dbPath := filepath.Join(t.TempDir(), "exchange.db")
+	start := func() (*Exchange, *boltdb.DB) {
+		db, err := boltdb.Open(dbPath)
+		require.NoError(t, err)
+
+		ex, err := New(fakeSigner{address: common.HexToAddress("0xff")}, wallet, nil)
+		require.NoError(t, err)
+		require.NoError(t, ex.UseRepository(db))
+		return ex, db
+	}
+
+	ex, db := start()
+	maker, err := ex.RegisterUser("")
+	require.NoError(t, err)
+	taker, err := ex.RegisterUser("0x00000000000000000000000000000000000000aa")
+	require.NoError(t, err)
+	_, err = ex.SetFeeSchedule(maker.ID, FeeScheduleMarketMaker)
+	require.NoError(t, err)
+	ex.Ledger.Credit(maker.ID, string(MarketETH), 10)
+	ex.Ledger.Credit(taker.ID, string(MarketETH), 10)
+	ex.Ledger.Credit(taker.ID, QuoteAsset, 1000)
+
+	for _, price := range []float64{100, 110} {
+		_, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: maker.ID, Type: LimitOrder, IsBid: false, Amount: 2, Price: price, Market: MarketETH})
+		require.NoError(t, err)
+	}
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: taker.ID, Type: MarketOrder, IsBid: true, Amount: 1, Market: MarketETH})
+	require.NoError(t, err)
+	_, err = ex.SetUserStatus(taker.ID, models.UserFrozen)
+	require.NoError(t, err)
+	apiKey, err := ex.Keys.Issue(maker.ID, auth.PermissionRead)
+	require.NoError(t, err)
+	_, err = ex.Keys.Grant(apiKey.Key, auth.PermissionWithdraw)
+	require.NoError(t, err)
+	revoked, err := ex.Keys.Issue(taker.ID, auth.PermissionRead)
+	require.NoError(t, err)
+	require.NoError(t, ex.Keys.Revoke(revoked.Key))
+	require.NoError(t, db.Close())
+
+	// Test case 1: users come back with their keys, address, status and fee schedule
+	ex, db = start()
+	defer db.Close()
+
+	restored, err := ex.GetUser(taker.ID)
+	require.NoError(t, err)
+	require.Equal(t, taker.Address, restored.Address)
+	require.Equal(t, models.UserFrozen, restored.Status)
+	require.Equal(t, taker.Signer.Address(), restored.Signer.Address())
+	require.Equal(t, FeeScheduleMarketMaker, ex.Fees.Status(maker.ID).Schedule)
+
+	// Test case 2: ledger balances are restored, open orders hold their funds again
+	require.InDelta(t, 10.998, ex.Ledger.Balance(taker.ID, string(MarketETH)), 1e-9)
+	require.Equal(t, 900.0, ex.Ledger.Balance(taker.ID, QuoteAsset))
+	require.Equal(t, 100.0, ex.Ledger.Balance(maker.ID, QuoteAsset))
+	require.InDelta(t, 3*(1+ex.Fees.MaxRate()), ex.Ledger.Held(maker.ID, string(MarketETH)), 1e-9)
+
+	// Test case 3: open orders are back in the book with their remaining amount, the history keeps the closed ones
+	require.Equal(t, 3.0, ex.Orderbooks[MarketETH].AskTotalVolume())
+	open, err := ex.GetUserOrders(maker.ID)
+	require.NoError(t, err)
+	require.Len(t, open.Asks, 2)
+	require.Equal(t, OrderPartiallyFilled, open.Asks[0].Status)
+	require.Equal(t, 1.0, open.Asks[0].Amount)
+
+	history, err := ex.ListOrders(OrderFilter{UserID: taker.ID})
+	require.NoError(t, err)
+	require.Len(t, history.Orders, 1)
+	require.Equal(t, OrderFilled, history.Orders[0].Status)
+
+	// Test case 4: fills are restored and new fills continue their IDs
+	fills, err := ex.ListFills(FillFilter{UserID: maker.ID})
+	require.NoError(t, err)
+	require.Len(t, fills.Fills, 1)
+	require.Equal(t, LiquidityMaker, fills.Fills[0].Liquidity)
+
+	// Test case 5: new users get the next free ID, their keys are derived at it
+	user, err := ex.RegisterUser("")
+	require.NoError(t, err)
+	require.Equal(t, taker.ID+1, user.ID)
+	require.Equal(t, uint32(user.ID), user.DerivationIndex)
+
+	// Test case 6: new orders continue the order IDs
+	placed, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: maker.ID, Type: LimitOrder, IsBid: false, Amount: 1, Price: 120, Market: MarketETH})
+	require.NoError(t, err)
+	require.Greater(t, placed.(*PlaceOrderResponse).OrderID, history.Orders[0].ID)
+
+	// Test case 7: a balance another writer changed meanwhile is written over its reloaded version
+	balances, err := db.ListBalances()
+	require.NoError(t, err)
+	var stored *storage.Balance
+	for _, b := range balances {
+		if b.UserID == taker.ID && b.Asset == string(MarketETH) {
+			stored = b
+		}
+	}
+	require.NotNil(t, stored)
+	stored.Balance = 42
+	require.NoError(t, db.SaveBalance(stored))
+
+	ex.Ledger.Credit(taker.ID, string(MarketETH), 1)
+	require.NoError(t, ex.checkPersisted())
+	balances, err = db.ListBalances()
+	require.NoError(t, err)
+	for _, b := range balances {
+		if b.UserID == taker.ID && b.Asset == string(MarketETH) {
+			require.Equal(t, ex.Ledger.Balance(taker.ID, string(MarketETH)), b.Balance)
+			require.Equal(t, stored.Version+1, b.Version)
+		}
+	}
+
+	// Test case 8: API keys come back with their permissions and still verify the secret they were issued with,
+	// only the hash of the secret was stored
+	restoredKey, err := ex.Keys.Lookup(apiKey.Key)
+	require.NoError(t, err)
+	require.Equal(t, maker.ID, restoredKey.UserID)
+	require.Equal(t, auth.Permissions{auth.PermissionRead, auth.PermissionWithdraw}, restoredKey.Permissions)
+	signature := auth.Sign(apiKey.Secret, 1700000000000, "GET", "/v1/fills", nil)
+	require.True(t, auth.VerifySignature(restoredKey.SecretHash, signature, 1700000000000, "GET", "/v1/fills", nil))
+	_, err = ex.Keys.Lookup(revoked.Key)
+	require.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+
+	apiKeys, err := db.ListAPIKeys()
+	require.NoError(t, err)
+	require.Len(t, apiKeys, 1)
+	require.Equal(t, auth.HashSecret(apiKey.Secret), apiKeys[0].SecretHash)
+}
+
+// failingBatches is a repository whose batches cannot be written
+type failingBatches struct {
+	storage.Repository
+}
+
+func (failingBatches) SaveBatch(*storage.Batch) error {
+	return errors.New("disk full")
+}
+
+// failingWrites is a repository nothing can be written to
+type failingWrites struct {
+	failingBatches
+}
+
+func (failingWrites) SaveUser(*storage.User) error   { return errors.New("disk full") }
+func (failingWrites) SaveOrder(*storage.Order) error { return errors.New("disk full") }
+
+func TestPersistBatch(t *testing.T) {
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "exchange.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ex := newTestExchange(t, 1, 2)
+	require.NoError(t, ex.UseRepository(db))
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 2, Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+	storedBalance := func(userID uint64, asset string) *storage.Balance {
+		balances, err := db.ListBalances()
+		require.NoError(t, err)
+		for _, b := range balances {
+			if b.UserID == userID && b.Asset == asset {
+				return b
+			}
+		}
+		return nil
+	}
+
+	// Test case 1: a batch is written over the balances another writer changed meanwhile
+	ex.Ledger.Credit(2, QuoteAsset, 1)
+	stale := storedBalance(2, QuoteAsset)
+	require.NotNil(t, stale)
+	stale.Balance = 42
+	require.NoError(t, db.SaveBalance(stale))
+
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 1, Market: MarketETH})
+	require.NoError(t, err)
+	require.Equal(t, ex.Ledger.Balance(2, QuoteAsset), storedBalance(2, QuoteAsset).Balance)
+	require.Equal(t, ex.Ledger.Balance(2, string(MarketETH)), storedBalance(2, string(MarketETH)).Balance)
+
+	// Test case 2: balances the order does not settle are written on their own while its batch is open
+	ex.persist.begin([]balanceKey{{1, string(MarketETH)}})
+	ex.Ledger.Credit(2, string(MarketBTC), 5)
+	ex.Ledger.Credit(1, string(MarketETH), 5)
+	require.Equal(t, ex.Ledger.Balance(2, string(MarketBTC)), storedBalance(2, string(MarketBTC)).Balance)
+	require.NotEqual(t, ex.Ledger.Balance(1, string(MarketETH)), storedBalance(1, string(MarketETH)).Balance)
+	require.NoError(t, ex.commitBatch())
+	require.Equal(t, ex.Ledger.Balance(1, string(MarketETH)), storedBalance(1, string(MarketETH)).Balance)
+
+	// Test case 3: an order whose batch cannot be written halts trading
+	ex.persist.repo = failingBatches{db}
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 0.5, Market: MarketETH})
+	require.ErrorIs(t, err, ErrTradingHalted)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 1, Price: 100, Market: MarketETH})
+	require.ErrorIs(t, err, ErrTradingHalted)
+	require.ErrorIs(t, ex.CancelOrder(1, 1), ErrTradingHalted)
+}
+
+func TestPersistFailure(t *testing.T) {
+	db, err := boltdb.Open(filepath.Join(t.TempDir(), "exchange.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ex := newTestExchange(t, 1)
+	require.NoError(t, ex.UseRepository(db))
+	ex.persist.repo = failingWrites{failingBatches{db}}
+
+	// Test case 1: a user that cannot be stored is not added
+	user, err := models.NewUser(fakeSigner{address: common.HexToAddress("0x02")}, 2)
+	require.NoError(t, err)
+	require.Error(t, ex.AddUser(user))
+	_, err = ex.GetUser(2)
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	// Test case 2: an order that cannot be stored fails and halts trading
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: true, Amount: 1, Price: 100, Market: MarketETH})
+	require.ErrorIs(t, err, ErrTradingHalted)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: true, Amount: 1, Price: 100, Market: MarketETH})
+	require.ErrorIs(t, err, ErrTradingHalted)
+}
@@ -0,0 +1,191 @@
+package exchanges
+
+import (
+	"sync"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/marketdata"
+)
+
+// ExecStatus is the state of an order reported in an execution report
+type ExecStatus string
+
+const (
+	ExecAccepted        ExecStatus = "ACCEPTED"
+	ExecPartiallyFilled ExecStatus = "PARTIALLY_FILLED"
+	ExecFilled          ExecStatus = "FILLED"
+	ExecCancelled       ExecStatus = "CANCELLED"
+	ExecRejected        ExecStatus = "REJECTED"
+	// ExecExpired is reported for orders removed by their time in force, orders do not expire yet
+	ExecExpired ExecStatus = "EXPIRED"
+)
+
+const (
+	// ChannelExecutions is the private stream channel of execution reports
+	ChannelExecutions = "executions"
+	// ChannelBalances is the private stream channel of balance changes
+	ChannelBalances = "balances"
+)
+
+// ExecutionReport tells a user about a change of one of its orders. TradeID, LastPrice and LastAmount describe the
+// fill that caused the report and Fee is what the user paid for it, negative for maker rebates. Remaining is the
+// amount of the order still open.
+type ExecutionReport struct {
+	Channel       string     `json:"channel"`
+	OrderID       uint64     `json:"order_id"`
+	ClientOrderID string     `json:"client_order_id,omitempty"`
+	TradeID       uint64     `json:"trade_id"`
+	UserID        uint64     `json:"user_id"`
+	Market        Market     `json:"market"`
+	Type          OrderType  `json:"type"`
+	IsBid         bool       `json:"is_bid"`
+	Status        ExecStatus `json:"status"`
+	Price         float64    `json:"price"`
+	LastPrice     float64    `json:"last_price"`
+	LastAmount    float64    `json:"last_amount"`
+	Fee           float64    `json:"fee"`
+	Remaining     float64    `json:"remaining"`
+	Reason        string     `json:"reason"`
+	Timestamp     int64      `json:"timestamp"`
+}
+
+// BalanceUpdate tells a user about a change of its ledger balance of an asset
+type BalanceUpdate struct {
+	Channel   string  `json:"channel"`
+	UserID    uint64  `json:"user_id"`
+	Asset     string  `json:"asset"`
+	Balance   float64 `json:"balance"`
+	Change    float64 `json:"change"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// userStreams fans the private events of every user out to the user's subscribers
+type userStreams struct {
+	mu    sync.Mutex
+	sinks map[uint64]map[marketdata.Sink]struct{}
+}
+
+// SubscribeUser registers the sink for the execution reports and balance updates of the user
+func (ex *Exchange) SubscribeUser(userID uint64, sink marketdata.Sink) {
+	ex.streams.mu.Lock()
+	defer ex.streams.mu.Unlock()
+
+	if ex.streams.sinks == nil {
+		ex.streams.sinks = make(map[uint64]map[marketdata.Sink]struct{})
+	}
+	if ex.streams.sinks[userID] == nil {
+		ex.streams.sinks[userID] = make(map[marketdata.Sink]struct{})
+	}
+	ex.streams.sinks[userID][sink] = struct{}{}
+}
+
+// UnsubscribeUser removes the sink from the private events of the user
+func (ex *Exchange) UnsubscribeUser(userID uint64, sink marketdata.Sink) {
+	ex.streams.mu.Lock()
+	defer ex.streams.mu.Unlock()
+
+	delete(ex.streams.sinks[userID], sink)
+	if len(ex.streams.sinks[userID]) == 0 {
+		delete(ex.streams.sinks, userID)
+	}
+}
+
+// handle turns the events concerning users into execution reports and balance updates, it is an events.Handler
+func (s *userStreams) handle(e events.Envelope) {
+	switch ev := e.Event.(type) {
+	case events.OrderAccepted:
+		s.publish(ev.UserID, &ExecutionReport{
+			Channel:       ChannelExecutions,
+			OrderID:       ev.OrderID,
+			ClientOrderID: ev.ClientOrderID,
+			UserID:        ev.UserID,
+			Market:        Market(ev.Market),
+			Type:          OrderType(ev.Type),
+			IsBid:         ev.IsBid,
+			Status:        ExecAccepted,
+			Price:         ev.Price,
+			Remaining:     ev.Amount,
+			Timestamp:     e.Timestamp,
+		})
+
+	case events.OrderRejected:
+		s.publish(ev.UserID, &ExecutionReport{
+			Channel:       ChannelExecutions,
+			OrderID:       ev.OrderID,
+			ClientOrderID: ev.ClientOrderID,
+			UserID:        ev.UserID,
+			Market:        Market(ev.Market),
+			Type:          OrderType(ev.Type),
+			IsBid:         ev.IsBid,
+			Status:        ExecRejected,
+			Price:         ev.Price,
+			Remaining:     ev.Amount,
+			Reason:        ev.Reason,
+			Timestamp:     e.Timestamp,
+		})
+
+	case events.OrderCancelled:
+		s.publish(ev.UserID, &ExecutionReport{
+			Channel:   ChannelExecutions,
+			OrderID:   ev.OrderID,
+			UserID:    ev.UserID,
+			Market:    Market(ev.Market),
+			Type:      LimitOrder,
+			IsBid:     ev.IsBid,
+			Status:    ExecCancelled,
+			Price:     ev.Price,
+			Remaining: ev.Remaining,
+			Timestamp: e.Timestamp,
+		})
+
+	case events.TradeExecuted:
+		s.publish(ev.TakerUserID, fillReport(e, ev, ev.TakerOrderID, ev.TakerUserID, OrderType(ev.TakerType), ev.TakerIsBid, 0, ev.TakerFee, ev.TakerRemaining))
+		s.publish(ev.MakerUserID, fillReport(e, ev, ev.MakerOrderID, ev.MakerUserID, LimitOrder, !ev.TakerIsBid, ev.Price, ev.MakerFee, ev.MakerRemaining))
+
+	case events.BalanceChanged:
+		s.publish(ev.UserID, &BalanceUpdate{
+			Channel:   ChannelBalances,
+			UserID:    ev.UserID,
+			Asset:     ev.Asset,
+			Balance:   ev.Balance,
+			Change:    ev.Change,
+			Timestamp: e.Timestamp,
+		})
+	}
+}
+
+// publish sends the message to every subscriber of the user, dropping those that cannot keep up
+func (s *userStreams) publish(userID uint64, msg interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sink := range s.sinks[userID] {
+		if !sink.Send(msg) {
+			delete(s.sinks[userID], sink)
+		}
+	}
+}
+
+func fillReport(e events.Envelope, trade events.TradeExecuted, orderID, userID uint64, orderType OrderType, isBid bool, price, fee, remaining float64) *ExecutionReport {
+	status := ExecPartiallyFilled
+	if remaining <= 0 {
+		status = ExecFilled
+	}
+
+	return &ExecutionReport{
+		Channel:    ChannelExecutions,
+		OrderID:    orderID,
+		TradeID:    trade.TradeID,
+		UserID:     userID,
+		Market:     Market(trade.Market),
+		Type:       orderType,
+		IsBid:      isBid,
+		Status:     status,
+		Price:      price,
+		LastPrice:  trade.Price,
+		LastAmount: trade.Amount,
+		Fee:        fee,
+		Remaining:  remaining,
+		Timestamp:  e.Timestamp,
+	}
+}
@@ -0,0 +1,82 @@
+package exchanges
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	messages []interface{}
+}
+
+func (s *recordingSink) Send(msg interface{}) bool {
+	s.messages = append(s.messages, msg)
+	return true
+}
+
+func (s *recordingSink) reports() []*ExecutionReport {
+	var reports []*ExecutionReport
+	for _, msg := range s.messages {
+		if report, ok := msg.(*ExecutionReport); ok {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+func TestExecutionReports(t *testing.T) {
+	ex := newTestExchange(t, 1, 2)
+
+	maker := &recordingSink{}
+	taker := &recordingSink{}
+	ex.SubscribeUser(1, maker)
+	ex.SubscribeUser(2, taker)
+
+	// Test case 1: resting orders are accepted
+	resp, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 5, Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+	orderID := resp.(*PlaceOrderResponse).OrderID
+
+	require.Len(t, maker.reports(), 1)
+	require.Equal(t, ExecAccepted, maker.reports()[0].Status)
+	require.Equal(t, orderID, maker.reports()[0].OrderID)
+	require.Equal(t, 5.0, maker.reports()[0].Remaining)
+
+	// Test case 2: both sides of a match get a fill report
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 2, Market: MarketETH})
+	require.NoError(t, err)
+
+	fill := maker.reports()[1]
+	require.Equal(t, ExecPartiallyFilled, fill.Status)
+	require.Equal(t, 100.0, fill.LastPrice)
+	require.Equal(t, 2.0, fill.LastAmount)
+	require.Equal(t, 3.0, fill.Remaining)
+
+	require.Equal(t, ExecAccepted, taker.reports()[0].Status)
+	takerFill := taker.reports()[1]
+	require.Equal(t, ExecFilled, takerFill.Status)
+	require.Equal(t, MarketOrder, takerFill.Type)
+	require.Equal(t, 0.0, takerFill.Remaining)
+
+	// Test case 3: market orders larger than the book are rejected
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 10, Market: MarketETH})
+	require.ErrorIs(t, err, ErrInsufficientLiquidity)
+
+	rejected := taker.reports()[len(taker.reports())-1]
+	require.Equal(t, ExecRejected, rejected.Status)
+	require.NotEmpty(t, rejected.Reason)
+
+	// Test case 4: cancelling reports the amount that was still open
+	require.NoError(t, ex.CancelOrder(1, orderID))
+	cancelled := maker.reports()[len(maker.reports())-1]
+	require.Equal(t, ExecCancelled, cancelled.Status)
+	require.Equal(t, 3.0, cancelled.Remaining)
+
+	// Test case 5: unsubscribed sinks get nothing
+	ex.UnsubscribeUser(1, maker)
+	count := len(maker.messages)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: true, Amount: 1, Price: 90, Market: MarketETH})
+	require.NoError(t, err)
+	require.Len(t, maker.messages, count)
+}
@@ -0,0 +1,112 @@
+package exchanges
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/marketdata"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+// recordTrade adds every trade to the trade history, it is an events.Handler
+func (ex *Exchange) recordTrade(e events.Envelope) {
+	trade, ok := e.Event.(events.TradeExecuted)
+	if !ok {
+		return
+	}
+
+	side := trades.SideSell
+	if trade.TakerIsBid {
+		side = trades.SideBuy
+	}
+
+	err := ex.Trades.Add(&trades.Trade{
+		ID:        trade.TradeID,
+		Market:    trade.Market,
+		Price:     trade.Price,
+		Size:      trade.Amount,
+		TakerSide: side,
+		Timestamp: e.Timestamp,
+	})
+	if err != nil {
+		log.Printf("failed to record trade %d of %s: %v", trade.TradeID, trade.Market, err)
+	}
+}
+
+// UseTradeStore persists the trades in the store, keeping ringSize trades per market in memory. Trade IDs continue
+// from the stored trades and the candles and 24 hour statistics are rebuilt from them. It must be called before the
+// exchange takes orders.
+func (ex *Exchange) UseTradeStore(store trades.Store, ringSize int) error {
+	history := trades.NewHistory(store, ringSize)
+
+	since := time.Now().Add(-rebuildWindow()).UnixNano()
+	for market := range ex.Orderbooks {
+		lastID, err := store.LastID(string(market))
+		if err != nil {
+			return fmt.Errorf("failed to read the last trade of %s: %w", market, err)
+		}
+		*ex.lastTradeIDs[market] = lastID
+
+		if err := history.Warm(string(market)); err != nil {
+			return fmt.Errorf("failed to load the trades of %s: %w", market, err)
+		}
+
+		candles, stats := ex.Candles[market], ex.Stats[market]
+		candles.Reset()
+		stats.Reset()
+		err = scanStoredTrades(store, trades.Query{Market: string(market), StartTime: since}, func(page []*trades.Trade) {
+			candles.Restore(page)
+			stats.Restore(page)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to load the trades of %s: %w", market, err)
+		}
+	}
+
+	ex.Trades = history
+	return nil
+}
+
+// rebuildWindow is how far back the candles are rebuilt from the stored trades, the longest reach of the stored
+// candles of any interval
+func rebuildWindow() time.Duration {
+	var window time.Duration
+	for _, interval := range marketdata.Intervals {
+		if w := interval.Window(); w > window {
+			window = w
+		}
+	}
+
+	return window
+}
+
+// scanStoredTrades passes every trade matching the query from the store to fn, page by page, so the trades are never
+// all held at once
+func scanStoredTrades(store trades.Store, q trades.Query, fn func(page []*trades.Trade)) error {
+	q.Limit = trades.MaxLimit
+
+	for {
+		page, err := store.Query(q)
+		if err != nil {
+			return err
+		}
+		fn(page)
+		if len(page) < q.Limit {
+			return nil
+		}
+
+		q.FromID, q.StartTime = page[len(page)-1].ID+1, 0
+	}
+}
+
+// GetTrades returns the trades of the market matching the query, oldest first
+func (ex *Exchange) GetTrades(market Market, q trades.Query) ([]*trades.Trade, error) {
+	if _, exists := ex.Orderbooks[market]; !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, market)
+	}
+
+	q.Market = string(market)
+	return ex.Trades.Query(q)
+}
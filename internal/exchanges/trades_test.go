@@ -0,0 +1,75 @@
+package exchanges
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+func TestTrades(t *testing.T) {
+	dir := t.TempDir()
+	store, err := trades.NewFileStore(dir)
+	require.NoError(t, err)
+
+	ex := newTestExchange(t, 1, 2)
+	require.NoError(t, ex.UseTradeStore(store, 10))
+
+	for _, price := range []float64{100, 110} {
+		_, err := ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 2, Price: price, Market: MarketETH})
+		require.NoError(t, err)
+	}
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: true, Amount: 1, Price: 90, Market: MarketETH})
+	require.NoError(t, err)
+
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 3, Market: MarketETH})
+	require.NoError(t, err)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: false, Amount: 1, Market: MarketETH})
+	require.NoError(t, err)
+
+	// Test case 1: trades get sequential IDs per market and report the taker side
+	got, err := ex.GetTrades(MarketETH, trades.Query{})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	for i, trade := range got {
+		require.Equal(t, uint64(i+1), trade.ID)
+		require.Equal(t, string(MarketETH), trade.Market)
+	}
+	require.Equal(t, trades.SideBuy, got[0].TakerSide)
+	require.Equal(t, 100.0, got[0].Price)
+	require.Equal(t, 2.0, got[0].Size)
+	require.Equal(t, trades.SideSell, got[2].TakerSide)
+	require.Equal(t, 90.0, got[2].Price)
+
+	got, err = ex.GetTrades(MarketETH, trades.Query{FromID: 2, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, uint64(2), got[0].ID)
+
+	_, err = ex.GetTrades("DOGE", trades.Query{})
+	require.Error(t, err)
+
+	// Test case 2: a restarted exchange continues the trade IDs and rebuilds its market data from the store
+	require.NoError(t, store.Close())
+	store, err = trades.NewFileStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	ex = newTestExchange(t, 1, 2)
+	require.NoError(t, ex.UseTradeStore(store, 10))
+
+	ticker, err := ex.GetTicker(MarketETH)
+	require.NoError(t, err)
+	require.Equal(t, 3, ticker.Trades)
+	require.Equal(t, 90.0, ticker.LastPrice)
+
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: 1, Price: 120, Market: MarketETH})
+	require.NoError(t, err)
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 2, Type: MarketOrder, IsBid: true, Amount: 1, Market: MarketETH})
+	require.NoError(t, err)
+
+	got, err = ex.GetTrades(MarketETH, trades.Query{})
+	require.NoError(t, err)
+	require.Len(t, got, 4)
+	require.Equal(t, uint64(4), got[3].ID)
+}
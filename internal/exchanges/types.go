@@ -1,5 +1,14 @@
 package exchanges
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+)
+
 // Market represents a trading market
 type Market string
 
@@ -12,60 +21,578 @@ const (
 	// MarketBTC represents the BTC market
 	MarketBTC Market = "BTC"
 
+	// QuoteAsset is the ledger asset prices are in, buyers pay it and sellers receive it
+	QuoteAsset = "USD"
+
 	// MarketOrder represents a market order type
 	MarketOrder OrderType = "MARKET"
 	// LimitOrder represents a limit order type
 	LimitOrder OrderType = "LIMIT"
+
+	// MaxClientOrderIDLength is the longest client order ID the exchange accepts
+	MaxClientOrderIDLength = 64
 )
 
-// PlaceOrderRequest is a data structure for placing orders via API
+// PlaceOrderRequest is a data structure for placing orders via API. UserID is never read from the request body,
+// it is set from the authenticated caller. ClientOrderID is an optional ID of the client's choice, it is echoed in
+// the execution reports and the order history.
 type PlaceOrderRequest struct {
-	UserID uint64
-	Type   OrderType
-	IsBid  bool
-	Amount float64
-	Price  float64
-	Market Market
+	UserID        uint64    `json:"-"`
+	ClientOrderID string    `json:"client_order_id"`
+	Type          OrderType `json:"type"`
+	IsBid         bool      `json:"is_bid"`
+	Amount        float64   `json:"amount"`
+	Price         float64   `json:"price"`
+	Market        Market    `json:"market"`
+}
+
+// Validate checks the order is well formed, whether it can enter its market is up to the exchange
+func (r *PlaceOrderRequest) Validate() error {
+	r.Type = OrderType(strings.ToUpper(string(r.Type)))
+	if r.Type != MarketOrder && r.Type != LimitOrder {
+		return fmt.Errorf("%w: %q", ErrUnknownOrderType, r.Type)
+	}
+	if r.Market == "" {
+		return errors.New("market is required")
+	}
+	if !positive(r.Amount) {
+		return errors.New("amount must be positive")
+	}
+	if r.Type == LimitOrder && !positive(r.Price) {
+		return errors.New("price of a limit order must be positive")
+	}
+	if len(r.ClientOrderID) > MaxClientOrderIDLength {
+		return fmt.Errorf("client order id must be at most %d characters", MaxClientOrderIDLength)
+	}
+
+	return nil
+}
+
+// AmendOrderRequest is a data structure for changing the price or amount of a resting limit order. A zero Price or
+// Amount keeps the current one, Amount is the new open amount. ClientOrderID is the client order ID of the
+// replacement.
+type AmendOrderRequest struct {
+	UserID        uint64  `json:"-"`
+	OrderID       uint64  `json:"order_id"`
+	ClientOrderID string  `json:"client_order_id"`
+	Price         float64 `json:"price"`
+	Amount        float64 `json:"amount"`
+}
+
+// Validate checks something is amended and the new values are positive
+func (r *AmendOrderRequest) Validate() error {
+	if r.Price == 0 && r.Amount == 0 {
+		return errors.New("price or amount is required")
+	}
+	if r.Price != 0 && !positive(r.Price) {
+		return errors.New("price must be positive")
+	}
+	if r.Amount != 0 && !positive(r.Amount) {
+		return errors.New("amount must be positive")
+	}
+	if len(r.ClientOrderID) > MaxClientOrderIDLength {
+		return fmt.Errorf("client order id must be at most %d characters", MaxClientOrderIDLength)
+	}
+
+	return nil
+}
+
+// WithdrawRequest is a data structure for withdrawing a user's balance to an external wallet via API
+type WithdrawRequest struct {
+	UserID  uint64  `json:"-"`
+	Market  Market  `json:"market"`
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+}
+
+// Validate checks the withdrawal is well formed, whether the user can withdraw it is up to the exchange
+func (r *WithdrawRequest) Validate() error {
+	if r.Market == "" {
+		return errors.New("market is required")
+	}
+	if r.Address == "" {
+		return errors.New("address is required")
+	}
+	if !positive(r.Amount) {
+		return errors.New("amount must be positive")
+	}
+
+	return nil
+}
+
+// WithdrawalResponse is a response for a withdrawal sent from the hot wallet
+type WithdrawalResponse struct {
+	Market  Market  `json:"market"`
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
 }
 
 // PlaceOrderResponse is a response for a successful order placement
 type PlaceOrderResponse struct {
-	OrderID uint64
+	OrderID uint64 `json:"order_id"`
+}
+
+// CancelOrderResponse is a response for a successful order cancellation
+type CancelOrderResponse struct {
+	Message string `json:"message"`
 }
 
-// Order represents a simplified order for API responses
+// Order represents a simplified order for API responses, UserID is left out of the public order book
 type Order struct {
-	UserID    uint64
-	ID        uint64
-	Amount    float64
-	IsBid     bool
-	Price     float64
-	Timestamp int64
+	UserID    uint64  `json:"user_id,omitempty"`
+	ID        uint64  `json:"id"`
+	Amount    float64 `json:"amount"`
+	IsBid     bool    `json:"is_bid"`
+	Price     float64 `json:"price"`
+	Timestamp int64   `json:"timestamp"`
 }
 
-// OrderbookResponse represents an orderbook for API responses
+// OrderbookResponse represents the L3 orderbook, every resting order, for API responses
 type OrderbookResponse struct {
-	TotalAsksVolume float64
-	TotalBidsVolume float64
-	Asks            []*Order
-	Bids            []*Order
+	TotalAsksVolume float64  `json:"total_asks_volume"`
+	TotalBidsVolume float64  `json:"total_bids_volume"`
+	Asks            []*Order `json:"asks"`
+	Bids            []*Order `json:"bids"`
+}
+
+// DepthLevel is the total size and number of orders resting at a price
+type DepthLevel struct {
+	Price  float64 `json:"price"`
+	Size   float64 `json:"size"`
+	Orders int     `json:"orders"`
+}
+
+// DepthResponse represents the aggregated L2 orderbook for API responses, levels are best price first
+type DepthResponse struct {
+	Market          Market       `json:"market"`
+	TotalAsksVolume float64      `json:"total_asks_volume"`
+	TotalBidsVolume float64      `json:"total_bids_volume"`
+	Asks            []DepthLevel `json:"asks"`
+	Bids            []DepthLevel `json:"bids"`
+}
+
+// OrderStatus is the lifecycle state of an order
+type OrderStatus string
+
+const (
+	OrderOpen            OrderStatus = "OPEN"
+	OrderPartiallyFilled OrderStatus = "PARTIALLY_FILLED"
+	OrderFilled          OrderStatus = "FILLED"
+	OrderCancelled       OrderStatus = "CANCELLED"
+	OrderRejected        OrderStatus = "REJECTED"
+	// OrderExpired is the status of orders removed by their time in force, orders do not expire yet
+	OrderExpired OrderStatus = "EXPIRED"
+)
+
+// OrderRecord is the full history of an order for API responses. Amount is what is still open, timestamps are unix
+// nanoseconds and ClosedAt is zero while the order is open.
+type OrderRecord struct {
+	ID             uint64      `json:"id"`
+	ClientOrderID  string      `json:"client_order_id,omitempty"`
+	UserID         uint64      `json:"user_id"`
+	Market         Market      `json:"market"`
+	Type           OrderType   `json:"type"`
+	IsBid          bool        `json:"is_bid"`
+	Price          float64     `json:"price"`
+	Status         OrderStatus `json:"status"`
+	OriginalAmount float64     `json:"original_amount"`
+	Amount         float64     `json:"amount"`
+	FilledAmount   float64     `json:"filled_amount"`
+	AvgFillPrice   float64     `json:"avg_fill_price"`
+	Reason         string      `json:"reason"`
+	CreatedAt      int64       `json:"created_at"`
+	UpdatedAt      int64       `json:"updated_at"`
+	ClosedAt       int64       `json:"closed_at"`
 }
 
-// GetOrdersResponse represents a response to get orders API call
+// GetOrdersResponse represents a response to get orders API call, it holds the open orders of a user
 type GetOrdersResponse struct {
-	Asks []Order
-	Bids []Order
+	Asks []*OrderRecord `json:"asks"`
+	Bids []*OrderRecord `json:"bids"`
+}
+
+// OrderHistoryResponse is a page of the order history of a user, NextCursor is empty on the last page
+type OrderHistoryResponse struct {
+	Orders     []*OrderRecord `json:"orders"`
+	NextCursor string         `json:"next_cursor"`
 }
 
 // PriceResponse represents a response with a price
 type PriceResponse struct {
-	Price float64
+	Price float64 `json:"price"`
 }
 
-// MatchedOrder represents a matched order for API responses
+// MatchedOrder represents a resting order matched by a market order for API responses, Fee is what the market order
+// paid for the fill
 type MatchedOrder struct {
-	UserID       uint64
-	Price        float64
-	AmountFilled float64
-	ID           uint64
+	UserID       uint64  `json:"user_id"`
+	Price        float64 `json:"price"`
+	AmountFilled float64 `json:"amount_filled"`
+	ID           uint64  `json:"id"`
+	Fee          float64 `json:"fee"`
+}
+
+// DepositAddressResponse represents a user's deposit address for a market's asset
+type DepositAddressResponse struct {
+	Market  Market `json:"market"`
+	Address string `json:"address"`
+}
+
+// ReservesReport represents a published proof-of-reserves snapshot of one asset
+type ReservesReport struct {
+	Market          Market  `json:"market"`
+	Root            string  `json:"root"`
+	Liabilities     float64 `json:"liabilities"`
+	OnChainReserves float64 `json:"on_chain_reserves"`
+	OnChainVerified bool    `json:"on_chain_verified"`
+	Solvent         bool    `json:"solvent"`
+	Timestamp       int64   `json:"timestamp"`
+}
+
+// UserResponse represents a user's account for API responses
+type UserResponse struct {
+	ID      uint64            `json:"id"`
+	Address string            `json:"address"`
+	Status  models.UserStatus `json:"status"`
+}
+
+// UpdateUserStatusRequest is a data structure for changing a user's account status via API
+type UpdateUserStatusRequest struct {
+	UserID uint64            `param:"id" json:"-"`
+	Status models.UserStatus `json:"status"`
+}
+
+// Validate checks a status is given
+func (r *UpdateUserStatusRequest) Validate() error {
+	if r.Status == "" {
+		return errors.New("status is required")
+	}
+	return nil
+}
+
+// GrantPermissionsRequest is a data structure for granting permissions to a user's API key via API
+type GrantPermissionsRequest struct {
+	Key         string   `param:"key" json:"-"`
+	Permissions []string `json:"permissions"`
+}
+
+// Validate checks permissions are given
+func (r *GrantPermissionsRequest) Validate() error {
+	if len(r.Permissions) == 0 {
+		return errors.New("permissions are required")
+	}
+	return nil
+}
+
+// APIKeyPermissionsResponse represents the permissions of an API key, without its secret
+type APIKeyPermissionsResponse struct {
+	APIKey      string   `json:"api_key"`
+	UserID      uint64   `json:"user_id"`
+	Permissions []string `json:"permissions"`
+}
+
+// CreditBalanceRequest is a data structure for crediting a user's balance via API
+type CreditBalanceRequest struct {
+	UserID uint64  `param:"id" json:"-"`
+	Asset  string  `json:"asset"`
+	Amount float64 `json:"amount"`
+}
+
+// Validate checks an asset and a positive amount are given
+func (r *CreditBalanceRequest) Validate() error {
+	if r.Asset == "" {
+		return errors.New("asset is required")
+	}
+	if !positive(r.Amount) {
+		return errors.New("amount must be positive")
+	}
+
+	return nil
+}
+
+// BalanceResponse represents a user's ledger balance of an asset, available is the part not held by open orders
+type BalanceResponse struct {
+	UserID    uint64  `json:"user_id"`
+	Asset     string  `json:"asset"`
+	Balance   float64 `json:"balance"`
+	Available float64 `json:"available"`
+}
+
+// RegisterUserResponse represents a newly registered user together with its first API key
+type RegisterUserResponse struct {
+	UserResponse
+	APIKeyResponse
+}
+
+// APIKeyResponse represents a newly issued API key. The secret is only ever returned here.
+type APIKeyResponse struct {
+	APIKey      string   `json:"api_key"`
+	APISecret   string   `json:"api_secret"`
+	Permissions []string `json:"permissions"`
+}
+
+// CreateAPIKeyRequest is a data structure for issuing an API key via API. Permissions default to the caller's.
+type CreateAPIKeyRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+// RegisterUserRequest is a data structure for registering users via API. Address is the user's own wallet, it is
+// optional and needed only to log in to the web UI. Nonce and Signature are a login challenge of the address signed
+// by its wallet, proving the user owns it.
+type RegisterUserRequest struct {
+	Address   string `json:"address"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// Validate checks a signed challenge is given with the address
+func (r *RegisterUserRequest) Validate() error {
+	if r.Address != "" && (r.Nonce == "" || r.Signature == "") {
+		return errors.New("nonce and signature are required with an address")
+	}
+	return nil
+}
+
+// AuthNonceRequest is a data structure for requesting a wallet login challenge via API
+type AuthNonceRequest struct {
+	Address string `json:"address"`
+}
+
+// Validate checks an address is given
+func (r *AuthNonceRequest) Validate() error {
+	if r.Address == "" {
+		return errors.New("address is required")
+	}
+	return nil
+}
+
+// AuthLoginRequest is a data structure for logging in with a signed challenge via API
+type AuthLoginRequest struct {
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+}
+
+// Validate checks the nonce and signature are given
+func (r *AuthLoginRequest) Validate() error {
+	if r.Nonce == "" || r.Signature == "" {
+		return errors.New("nonce and signature are required")
+	}
+	return nil
+}
+
+// AuthRefreshRequest is a data structure for renewing or ending a session via API
+type AuthRefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Validate checks a refresh token is given
+func (r *AuthRefreshRequest) Validate() error {
+	if r.RefreshToken == "" {
+		return errors.New("refresh_token is required")
+	}
+	return nil
+}
+
+// MarketRequest addresses a market in the path
+type MarketRequest struct {
+	Market Market `param:"market"`
+}
+
+// UserRequest addresses a user in the path
+type UserRequest struct {
+	UserID uint64 `param:"id"`
+}
+
+// UserQuery is a request about the caller, admins may pass another user_id
+type UserQuery struct {
+	UserID uint64 `query:"user_id"`
+}
+
+// CancelOrderRequest is a data structure for cancelling an order via API
+type CancelOrderRequest struct {
+	OrderID uint64 `param:"id"`
+}
+
+// UserOrdersRequest is a data structure for reading the open orders of a user via API
+type UserOrdersRequest struct {
+	UserID uint64 `param:"user_id"`
+}
+
+// DepositAddressRequest is a data structure for reading a user's deposit address via API
+type DepositAddressRequest struct {
+	UserID uint64 `param:"user_id"`
+	Market Market `param:"market"`
+}
+
+// ReservesProofRequest is a data structure for reading a proof of reserves via API, Market is a market or the quote
+// asset and defaults to ETH
+type ReservesProofRequest struct {
+	UserID uint64 `query:"user_id"`
+	Market Market `query:"market"`
+}
+
+// GetDepthRequest is a data structure for reading the L2 orderbook via API, Depth and Grouping are optional
+type GetDepthRequest struct {
+	Market   Market  `param:"market"`
+	Depth    int     `query:"depth"`
+	Grouping float64 `query:"grouping"`
+}
+
+// Validate checks depth and grouping are not negative
+func (r *GetDepthRequest) Validate() error {
+	if r.Depth < 0 {
+		return errors.New("depth must not be negative")
+	}
+	if r.Grouping < 0 || math.IsNaN(r.Grouping) || math.IsInf(r.Grouping, 0) {
+		return errors.New("grouping must not be negative")
+	}
+	return nil
+}
+
+// GetCandlesRequest is a data structure for reading candles via API, From and To bound the open time in unix
+// nanoseconds
+type GetCandlesRequest struct {
+	Market   Market `param:"market"`
+	Interval string `query:"interval"`
+	From     int64  `query:"from"`
+	To       int64  `query:"to"`
+}
+
+// Validate checks the time range
+func (r *GetCandlesRequest) Validate() error {
+	return validateRange(r.From, r.To)
+}
+
+// GetTradesRequest is a data structure for reading the trade history via API, times are unix nanoseconds
+type GetTradesRequest struct {
+	Market    Market `param:"market"`
+	FromID    uint64 `query:"from_id"`
+	StartTime int64  `query:"start_time"`
+	EndTime   int64  `query:"end_time"`
+	Limit     int    `query:"limit"`
+}
+
+// Validate checks the time range and limit
+func (r *GetTradesRequest) Validate() error {
+	if err := validateRange(r.StartTime, r.EndTime); err != nil {
+		return err
+	}
+	return validateLimit(r.Limit)
+}
+
+// ListOrdersRequest is a data structure for listing the order history via API. Status is a comma separated list,
+// times are unix nanoseconds.
+type ListOrdersRequest struct {
+	UserID    uint64 `query:"user_id"`
+	Status    string `query:"status"`
+	Market    Market `query:"market"`
+	StartTime int64  `query:"start_time"`
+	EndTime   int64  `query:"end_time"`
+	Cursor    string `query:"cursor"`
+	Limit     int    `query:"limit"`
+}
+
+// Validate checks the time range and limit
+func (r *ListOrdersRequest) Validate() error {
+	if err := validateRange(r.StartTime, r.EndTime); err != nil {
+		return err
+	}
+	return validateLimit(r.Limit)
+}
+
+// Filter returns the history filter of the request for the user
+func (r *ListOrdersRequest) Filter(userID uint64) OrderFilter {
+	filter := OrderFilter{
+		UserID:    userID,
+		Market:    r.Market,
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+		Cursor:    r.Cursor,
+		Limit:     r.Limit,
+	}
+	if r.Status != "" {
+		for _, status := range strings.Split(r.Status, ",") {
+			filter.Statuses = append(filter.Statuses, OrderStatus(strings.ToUpper(strings.TrimSpace(status))))
+		}
+	}
+	return filter
+}
+
+// ListFillsRequest is a data structure for listing fills via API, times are unix nanoseconds
+type ListFillsRequest struct {
+	UserID    uint64 `query:"user_id"`
+	Market    Market `query:"market"`
+	OrderID   uint64 `query:"order_id"`
+	StartTime int64  `query:"start_time"`
+	EndTime   int64  `query:"end_time"`
+	Cursor    string `query:"cursor"`
+	Limit     int    `query:"limit"`
+}
+
+// Validate checks the time range and limit
+func (r *ListFillsRequest) Validate() error {
+	if err := validateRange(r.StartTime, r.EndTime); err != nil {
+		return err
+	}
+	return validateLimit(r.Limit)
+}
+
+// Filter returns the fill filter of the request for the user
+func (r *ListFillsRequest) Filter(userID uint64) FillFilter {
+	return FillFilter{
+		UserID:    userID,
+		Market:    r.Market,
+		OrderID:   r.OrderID,
+		StartTime: r.StartTime,
+		EndTime:   r.EndTime,
+		Cursor:    r.Cursor,
+		Limit:     r.Limit,
+	}
+}
+
+func positive(amount float64) bool {
+	return amount > 0 && !math.IsInf(amount, 1)
+}
+
+// validateRange checks a time range in unix nanoseconds, zero leaves a bound open
+func validateRange(start, end int64) error {
+	if start < 0 || end < 0 {
+		return errors.New("times must not be negative")
+	}
+	if end != 0 && start > end {
+		return errors.New("start must not be after end")
+	}
+	return nil
+}
+
+func validateLimit(limit int) error {
+	if limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	return nil
+}
+
+// StreamOp is an operation a WebSocket client sends
+type StreamOp string
+
+const (
+	// StreamSubscribe subscribes to a channel of a market
+	StreamSubscribe StreamOp = "subscribe"
+	// StreamUnsubscribe ends a subscription
+	StreamUnsubscribe StreamOp = "unsubscribe"
+)
+
+// StreamRequest is a message a WebSocket client sends to manage its subscriptions
+type StreamRequest struct {
+	Op      StreamOp `json:"op"`
+	Channel string   `json:"channel"`
+	Market  Market   `json:"market"`
+}
+
+// StreamResponse acknowledges a StreamRequest, Type is "subscribed", "unsubscribed" or "error"
+type StreamResponse struct {
+	Type    string `json:"type"`
+	Channel string `json:"channel"`
+	Market  Market `json:"market"`
+	Error   string `json:"error"`
 }
@@ -0,0 +1,233 @@
+package exchanges
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+)
+
+var (
+	// ErrUserNotFound is returned for operations on users that are not registered
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserNotActive is returned when a frozen or closed user tries to trade
+	ErrUserNotActive = errors.New("user is not active")
+	// ErrInvalidStatus is returned for unknown statuses and transitions out of a closed account
+	ErrInvalidStatus = errors.New("invalid user status")
+	// ErrOrderNotFound is returned for operations on orders that are not resting in any orderbook
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrForbidden is returned when a user acts on an order owned by another user
+	ErrForbidden = errors.New("forbidden")
+	// ErrInvalidAddress is returned for malformed Ethereum addresses
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrAddressTaken is returned when registering a wallet address that already identifies a user
+	ErrAddressTaken = errors.New("address is already registered")
+	// ErrInsufficientLiquidity is returned for market orders larger than the opposite side of the orderbook
+	ErrInsufficientLiquidity = errors.New("not enough volume in the orderbook")
+	// ErrUnknownMarket is returned for markets the exchange does not list
+	ErrUnknownMarket = errors.New("unknown market")
+	// ErrUnknownOrderType is returned for order types other than MARKET and LIMIT
+	ErrUnknownOrderType = errors.New("unknown order type")
+	// ErrEmptyBook is returned for the best price of an orderbook side without orders
+	ErrEmptyBook = errors.New("no orders on this side of the orderbook")
+	// ErrDepositUnavailable is returned for deposit addresses of assets the exchange cannot take deposits of
+	ErrDepositUnavailable = errors.New("deposits are not available")
+	// ErrWithdrawalUnavailable is returned for withdrawals of assets the exchange cannot pay out
+	ErrWithdrawalUnavailable = errors.New("withdrawals are not available")
+	// ErrTradingHalted is returned for orders once the account state could not be written to the repository
+	ErrTradingHalted = errors.New("trading is halted")
+)
+
+// AddUser adds a new user to the exchange, the user is not added if it cannot be stored
+func (ex *Exchange) AddUser(user *models.User) error {
+	ex.mu.Lock()
+	ex.Users[user.ID] = user
+	if user.ID >= ex.nextUserID {
+		ex.nextUserID = user.ID + 1
+	}
+	ex.mu.Unlock()
+
+	if err := ex.saveUser(user.ID); err != nil {
+		ex.mu.Lock()
+		delete(ex.Users, user.ID)
+		ex.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// RegisterUser creates a new active user with the next free ID and keys derived from the exchange HD wallet.
+// The optional wallet address is the user's own wallet, which they can log in with.
+func (ex *Exchange) RegisterUser(walletAddress string) (*models.User, error) {
+	ex.registerMu.Lock()
+	defer ex.registerMu.Unlock()
+
+	if walletAddress != "" {
+		if !common.IsHexAddress(walletAddress) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, walletAddress)
+		}
+		walletAddress = common.HexToAddress(walletAddress).Hex()
+
+		if _, err := ex.GetUserByAddress(walletAddress); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrAddressTaken, walletAddress)
+		}
+	}
+
+	ex.mu.Lock()
+	userID := ex.nextUserID
+	ex.nextUserID++
+	ex.mu.Unlock()
+
+	user, err := ex.OnboardUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if walletAddress != "" {
+		ex.mu.Lock()
+		ex.Users[user.ID].Address = walletAddress
+		ex.mu.Unlock()
+
+		if err := ex.saveUser(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return ex.GetUser(user.ID)
+}
+
+// GetUserByAddress returns a copy of the user identified by the given address
+func (ex *Exchange) GetUserByAddress(address string) (*models.User, error) {
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+
+	for _, user := range ex.Users {
+		if strings.EqualFold(user.Address, address) {
+			u := *user
+			return &u, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUserNotFound, address)
+}
+
+// GetUser returns a copy of the user with the given ID
+func (ex *Exchange) GetUser(userID uint64) (*models.User, error) {
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+
+	user, ok := ex.Users[userID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+
+	u := *user
+	return &u, nil
+}
+
+// SetUserStatus changes the status of a user's account. Freezing or closing an account cancels its resting orders
+// so they can no longer be matched.
+func (ex *Exchange) SetUserStatus(userID uint64, status models.UserStatus) (*models.User, error) {
+	switch status {
+	case models.UserActive, models.UserFrozen, models.UserClosed:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidStatus, status)
+	}
+
+	ex.mu.Lock()
+	user, ok := ex.Users[userID]
+	if !ok {
+		ex.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+
+	if user.Status == models.UserClosed && status != models.UserClosed {
+		ex.mu.Unlock()
+		return nil, fmt.Errorf("%w: account %d is closed", ErrInvalidStatus, userID)
+	}
+
+	user.Status = status
+	ex.mu.Unlock()
+
+	if err := ex.saveUser(userID); err != nil {
+		return nil, err
+	}
+
+	if status != models.UserActive {
+		if err := ex.cancelUserOrders(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	return ex.GetUser(userID)
+}
+
+// CreditBalance credits an asset to a user's ledger balance without an on-chain deposit, it is how the operator funds
+// accounts such as the market maker's. Credits are liabilities like any other balance and show in the reserves.
+func (ex *Exchange) CreditBalance(req *CreditBalanceRequest) (*BalanceResponse, error) {
+	if _, ok := ex.Orderbooks[Market(req.Asset)]; !ok && req.Asset != QuoteAsset {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, req.Asset)
+	}
+	if _, err := ex.GetUser(req.UserID); err != nil {
+		return nil, err
+	}
+
+	ex.Ledger.Credit(req.UserID, req.Asset, req.Amount)
+	if err := ex.checkPersisted(); err != nil {
+		_ = ex.Ledger.Debit(req.UserID, req.Asset, req.Amount)
+		return nil, err
+	}
+	ex.publishBalance(req.UserID, req.Asset, req.Amount)
+	log.Printf("Credit => user [%d] | asset [%s] | amount [%.8f]", req.UserID, req.Asset, req.Amount)
+
+	return &BalanceResponse{
+		UserID:    req.UserID,
+		Asset:     req.Asset,
+		Balance:   ex.Ledger.Balance(req.UserID, req.Asset),
+		Available: ex.Ledger.Available(req.UserID, req.Asset),
+	}, nil
+}
+
+// checkUserActive returns an error unless the user exists and is active
+func (ex *Exchange) checkUserActive(userID uint64) error {
+	ex.mu.RLock()
+	defer ex.mu.RUnlock()
+
+	user, ok := ex.Users[userID]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+
+	if !user.IsActive() {
+		return fmt.Errorf("%w: %d is %s", ErrUserNotActive, userID, user.Status)
+	}
+
+	return nil
+}
+
+// cancelUserOrders removes all resting orders of the user from the orderbooks, failing if the cancellations cannot
+// be stored
+func (ex *Exchange) cancelUserOrders(userID uint64) error {
+	ex.bookMu.Lock()
+	defer ex.bookMu.Unlock()
+
+	ex.mu.RLock()
+	orders := make([]uint64, 0, len(ex.Orders[userID]))
+	for _, order := range ex.Orders[userID] {
+		if order.Limit != nil {
+			orders = append(orders, order.ID)
+		}
+	}
+	ex.mu.RUnlock()
+
+	for _, orderID := range orders {
+		_ = ex.cancelOrder(userID, orderID)
+	}
+
+	return ex.checkPersisted()
+}
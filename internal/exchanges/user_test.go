@@ -0,0 +1,90 @@
+package exchanges
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/models"
+)
+
+type fakeSigner struct{ address common.Address }
+
+func (s fakeSigner) Address() common.Address { return s.address }
+func (s fakeSigner) SignTx(tx *types.Transaction, _ *big.Int) (*types.Transaction, error) {
+	return tx, nil
+}
+
+// testFunds is what the users of newTestExchange have of every asset
+const testFunds = 1_000_000.0
+
+// newTestExchange creates an exchange with the given users, each holding testFunds of every asset
+func newTestExchange(t *testing.T, userIDs ...uint64) *Exchange {
+	ex, err := New(fakeSigner{address: common.HexToAddress("0xff")}, nil, nil)
+	require.NoError(t, err)
+
+	for _, id := range userIDs {
+		user, err := models.NewUser(fakeSigner{address: common.BigToAddress(new(big.Int).SetUint64(id))}, id)
+		require.NoError(t, err)
+		require.NoError(t, ex.AddUser(user))
+		for _, asset := range []string{string(MarketETH), string(MarketBTC), QuoteAsset} {
+			ex.Ledger.Credit(id, asset, testFunds)
+		}
+	}
+
+	return ex
+}
+
+func TestPlaceOrder_UserStatus(t *testing.T) {
+	ex := newTestExchange(t, 1)
+	limit := func(userID uint64) *PlaceOrderRequest {
+		return &PlaceOrderRequest{UserID: userID, Type: LimitOrder, IsBid: true, Amount: 1, Price: 100, Market: MarketETH}
+	}
+
+	// Test case 1: unknown users cannot trade
+	_, err := ex.PlaceOrder(limit(7))
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	// Test case 2: active users can trade
+	_, err = ex.PlaceOrder(limit(1))
+	require.NoError(t, err)
+	require.Equal(t, 1.0, ex.Orderbooks[MarketETH].BidTotalVolume())
+
+	// Test case 3: freezing cancels resting orders and blocks new ones
+	user, err := ex.SetUserStatus(1, models.UserFrozen)
+	require.NoError(t, err)
+	require.Equal(t, models.UserFrozen, user.Status)
+	require.Equal(t, 0.0, ex.Orderbooks[MarketETH].BidTotalVolume())
+
+	_, err = ex.PlaceOrder(limit(1))
+	require.ErrorIs(t, err, ErrUserNotActive)
+
+	// Test case 4: closed accounts cannot be reopened
+	_, err = ex.SetUserStatus(1, models.UserClosed)
+	require.NoError(t, err)
+	_, err = ex.SetUserStatus(1, models.UserActive)
+	require.ErrorIs(t, err, ErrInvalidStatus)
+}
+
+func TestAddUser_NextUserID(t *testing.T) {
+	ex := newTestExchange(t, 1, 5)
+	require.Equal(t, uint64(6), ex.nextUserID)
+}
+
+func TestCreditBalance(t *testing.T) {
+	ex := newTestExchange(t, 1)
+
+	// Test case 1: credits add to the balance of the asset
+	balance, err := ex.CreditBalance(&CreditBalanceRequest{UserID: 1, Asset: QuoteAsset, Amount: 500})
+	require.NoError(t, err)
+	require.Equal(t, testFunds+500, balance.Balance)
+	require.Equal(t, testFunds+500, ex.Ledger.Available(1, QuoteAsset))
+
+	// Test case 2: unknown assets and users are rejected
+	_, err = ex.CreditBalance(&CreditBalanceRequest{UserID: 1, Asset: "DOGE", Amount: 1})
+	require.ErrorIs(t, err, ErrUnknownMarket)
+	_, err = ex.CreditBalance(&CreditBalanceRequest{UserID: 7, Asset: string(MarketETH), Amount: 1})
+	require.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,50 @@
+package exchanges
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/treasury"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/ethclient"
+)
+
+// Withdraw debits the amount from the user's ledger balance and pays it from the exchange hot wallet to the address.
+// Only ETH can be withdrawn, and only what the hot wallet covers, the balance is credited back if it cannot be sent.
+func (ex *Exchange) Withdraw(req *WithdrawRequest) (*WithdrawalResponse, error) {
+	if _, ok := ex.Orderbooks[req.Market]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMarket, req.Market)
+	}
+	if req.Market != MarketETH || ex.Treasury == nil {
+		return nil, fmt.Errorf("%w: %s", ErrWithdrawalUnavailable, req.Market)
+	}
+	if !common.IsHexAddress(req.Address) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, req.Address)
+	}
+	if err := ex.checkUserActive(req.UserID); err != nil {
+		return nil, err
+	}
+
+	// The balance held by open orders cannot be withdrawn
+	if err := ex.Ledger.Debit(req.UserID, string(req.Market), req.Amount); err != nil {
+		return nil, err
+	}
+	if err := ex.checkPersisted(); err != nil {
+		ex.Ledger.Credit(req.UserID, string(req.Market), req.Amount)
+		return nil, err
+	}
+
+	to := common.HexToAddress(req.Address)
+	if err := ex.Treasury.Withdraw(to, ethclient.EtherToWei(req.Amount)); err != nil {
+		ex.Ledger.Credit(req.UserID, string(req.Market), req.Amount)
+		if errors.Is(err, treasury.ErrInsufficientHotWallet) {
+			err = fmt.Errorf("%w: %v", ErrWithdrawalUnavailable, err)
+		}
+		return nil, err
+	}
+	ex.publishBalance(req.UserID, string(req.Market), -req.Amount)
+	log.Printf("Withdrawal => user [%d] | address [%s] | amount [%.8f]", req.UserID, to.Hex(), req.Amount)
+
+	return &WithdrawalResponse{Market: req.Market, Address: to.Hex(), Amount: req.Amount}, nil
+}
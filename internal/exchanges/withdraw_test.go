@@ -0,0 +1,77 @@
+package exchanges
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/ledger"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/treasury"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/signer"
+)
+
+// fakeChain keeps the balances of a chain in memory
+type fakeChain struct {
+	balances map[common.Address]*big.Int
+}
+
+func (c *fakeChain) balance(address common.Address) *big.Int {
+	if c.balances[address] == nil {
+		c.balances[address] = big.NewInt(0)
+	}
+	return c.balances[address]
+}
+
+func (c *fakeChain) GetBalance(address string) (*big.Int, error) {
+	return new(big.Int).Set(c.balance(common.HexToAddress(address))), nil
+}
+
+func (c *fakeChain) TransferETH(s signer.Signer, to common.Address, amount *big.Int) error {
+	c.balance(s.Address()).Sub(c.balance(s.Address()), amount)
+	c.balance(to).Add(c.balance(to), amount)
+	return nil
+}
+
+func (c *fakeChain) TransferFee() (*big.Int, error) {
+	return big.NewInt(0), nil
+}
+
+func TestWithdraw(t *testing.T) {
+	ex := newTestExchange(t, 1)
+	to := common.HexToAddress("0xabc").Hex()
+	withdraw := func(market Market, amount float64) error {
+		_, err := ex.Withdraw(&WithdrawRequest{UserID: 1, Market: market, Address: to, Amount: amount})
+		return err
+	}
+
+	// Test case 1: without a treasury nothing can be withdrawn
+	require.ErrorIs(t, withdraw(MarketETH, 1), ErrWithdrawalUnavailable)
+
+	chain := &fakeChain{balances: map[common.Address]*big.Int{ex.Signer.Address(): big.NewInt(5 * params.Ether)}}
+	tr, err := treasury.New(chain, ex.Signer, treasury.Config{LowWatermark: big.NewInt(0), HighWatermark: big.NewInt(9 * params.Ether)})
+	require.NoError(t, err)
+	ex.Treasury = tr
+
+	// Test case 2: the hot wallet pays the withdrawal in wei and the ledger is debited
+	require.NoError(t, withdraw(MarketETH, 1.5))
+	require.Equal(t, big.NewInt(15e17), chain.balances[common.HexToAddress(to)])
+	require.Equal(t, big.NewInt(35e17), chain.balances[ex.Signer.Address()])
+	require.Equal(t, testFunds-1.5, ex.Ledger.Balance(1, string(MarketETH)))
+
+	// Test case 3: a withdrawal the hot wallet cannot cover is refused and the balance stays
+	require.ErrorIs(t, withdraw(MarketETH, 4), ErrWithdrawalUnavailable)
+	require.Equal(t, treasury.AlertInsufficientForWithdrawals, (<-tr.Alerts()).Kind)
+	require.Equal(t, testFunds-1.5, ex.Ledger.Balance(1, string(MarketETH)))
+
+	// Test case 4: funds held by open orders cannot be withdrawn
+	_, err = ex.PlaceOrder(&PlaceOrderRequest{UserID: 1, Type: LimitOrder, IsBid: false, Amount: (testFunds - 2) / (1 + ex.Fees.MaxRate()), Price: 100, Market: MarketETH})
+	require.NoError(t, err)
+	require.ErrorIs(t, withdraw(MarketETH, 1), ledger.ErrInsufficientBalance)
+
+	// Test case 5: BTC cannot be withdrawn, addresses must be Ethereum addresses
+	require.ErrorIs(t, withdraw(MarketBTC, 1), ErrWithdrawalUnavailable)
+	_, err = ex.Withdraw(&WithdrawRequest{UserID: 1, Market: MarketETH, Address: "nope", Amount: 1})
+	require.ErrorIs(t, err, ErrInvalidAddress)
+}
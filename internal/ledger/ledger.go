@@ -0,0 +1,192 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrInsufficientBalance is returned when a debit or transfer exceeds the balance
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// Ledger keeps the internal balance of every user per asset. Amounts are in asset units, the same units orders are
+// placed in. Part of a balance may be held, such as for open orders, debits and transfers only spend what is
+// available and held funds are spent with TransferHeld.
+type Ledger struct {
+	mu       sync.RWMutex
+	balances map[uint64]map[string]float64
+	holds    map[uint64]map[string]float64
+
+	// listeners are called for every balance change, see OnChange
+	listeners []func(userID uint64, asset string, balance float64)
+}
+
+// New is constructor of Ledger struct.
+func New() *Ledger {
+	return &Ledger{
+		balances: make(map[uint64]map[string]float64),
+		holds:    make(map[uint64]map[string]float64),
+	}
+}
+
+// Credit adds amount to the user's balance of asset
+func (l *Ledger) Credit(userID uint64, asset string, amount float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.credit(userID, asset, amount)
+}
+
+// Debit removes amount from the user's balance of asset, failing if the balance is insufficient
+func (l *Ledger) Debit(userID uint64, asset string, amount float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.debit(userID, asset, amount)
+}
+
+// Transfer moves amount of asset from one user to another
+func (l *Ledger) Transfer(fromUserID, toUserID uint64, asset string, amount float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.debit(fromUserID, asset, amount); err != nil {
+		return err
+	}
+	l.credit(toUserID, asset, amount)
+
+	return nil
+}
+
+// Hold sets amount of the user's available balance of asset aside, failing if the available balance is insufficient
+func (l *Ledger) Hold(userID uint64, asset string, amount float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.available(userID, asset) < amount {
+		return fmt.Errorf("%w: %s of user %d", ErrInsufficientBalance, asset, userID)
+	}
+	if l.holds[userID] == nil {
+		l.holds[userID] = make(map[string]float64)
+	}
+	l.holds[userID][asset] += amount
+
+	return nil
+}
+
+// Release makes up to amount of the user's held balance of asset available again
+func (l *Ledger) Release(userID uint64, asset string, amount float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.release(userID, asset, amount)
+}
+
+// TransferHeld moves amount of asset from one user to another out of the held balance of the sender. Whatever the
+// hold does not cover, such as rounding, is spent from the available balance.
+func (l *Ledger) TransferHeld(fromUserID, toUserID uint64, asset string, amount float64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	held := l.holds[fromUserID][asset]
+	if held > amount {
+		held = amount
+	}
+	if l.available(fromUserID, asset) < amount-held {
+		return fmt.Errorf("%w: %s of user %d", ErrInsufficientBalance, asset, fromUserID)
+	}
+
+	l.release(fromUserID, asset, held)
+	if err := l.debit(fromUserID, asset, amount); err != nil {
+		l.holds[fromUserID][asset] += held
+		return err
+	}
+	l.credit(toUserID, asset, amount)
+
+	return nil
+}
+
+// OnChange registers fn to be called with the new balance after every change. It is called while the ledger is
+// locked, so it must not call back into the ledger.
+func (l *Ledger) OnChange(fn func(userID uint64, asset string, balance float64)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.listeners = append(l.listeners, fn)
+}
+
+// Balance returns the user's balance of asset
+func (l *Ledger) Balance(userID uint64, asset string) float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.balances[userID][asset]
+}
+
+// Available returns the user's balance of asset that is not held
+func (l *Ledger) Available(userID uint64, asset string) float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.available(userID, asset)
+}
+
+// Held returns the user's held balance of asset
+func (l *Ledger) Held(userID uint64, asset string) float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.holds[userID][asset]
+}
+
+// Balances returns a snapshot of every user's balance of asset
+func (l *Ledger) Balances(asset string) map[uint64]float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	balances := make(map[uint64]float64)
+	for userID, assets := range l.balances {
+		if balance, ok := assets[asset]; ok {
+			balances[userID] = balance
+		}
+	}
+
+	return balances
+}
+
+func (l *Ledger) credit(userID uint64, asset string, amount float64) {
+	if l.balances[userID] == nil {
+		l.balances[userID] = make(map[string]float64)
+	}
+	l.balances[userID][asset] += amount
+	l.changed(userID, asset)
+}
+
+func (l *Ledger) debit(userID uint64, asset string, amount float64) error {
+	if l.available(userID, asset) < amount {
+		return fmt.Errorf("%w: %s of user %d", ErrInsufficientBalance, asset, userID)
+	}
+	l.balances[userID][asset] -= amount
+	l.changed(userID, asset)
+
+	return nil
+}
+
+func (l *Ledger) available(userID uint64, asset string) float64 {
+	return l.balances[userID][asset] - l.holds[userID][asset]
+}
+
+func (l *Ledger) release(userID uint64, asset string, amount float64) {
+	held := l.holds[userID][asset]
+	if amount >= held {
+		delete(l.holds[userID], asset)
+		return
+	}
+	l.holds[userID][asset] = held - amount
+}
+
+func (l *Ledger) changed(userID uint64, asset string) {
+	for _, fn := range l.listeners {
+		fn(userID, asset, l.balances[userID][asset])
+	}
+}
@@ -0,0 +1,76 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransfer(t *testing.T) {
+	l := New()
+	l.Credit(1, "ETH", 10)
+
+	// Test case 1: transfer within the balance
+	require.NoError(t, l.Transfer(1, 2, "ETH", 4))
+	require.Equal(t, 6.0, l.Balance(1, "ETH"))
+	require.Equal(t, 4.0, l.Balance(2, "ETH"))
+
+	// Test case 2: transfer above the balance leaves both balances untouched
+	require.Error(t, l.Transfer(2, 1, "ETH", 5))
+	require.Equal(t, 6.0, l.Balance(1, "ETH"))
+	require.Equal(t, 4.0, l.Balance(2, "ETH"))
+
+	// Test case 3: balances are kept per asset
+	require.Equal(t, 0.0, l.Balance(1, "BTC"))
+	require.Equal(t, map[uint64]float64{1: 6, 2: 4}, l.Balances("ETH"))
+}
+
+func TestOnChange(t *testing.T) {
+	l := New()
+	var changes []float64
+	l.OnChange(func(userID uint64, asset string, balance float64) {
+		require.Equal(t, "ETH", asset)
+		changes = append(changes, float64(userID)*100+balance)
+	})
+
+	// Test case 1: every change reports the new balance
+	l.Credit(1, "ETH", 10)
+	require.NoError(t, l.Transfer(1, 2, "ETH", 4))
+	require.Equal(t, []float64{110, 106, 204}, changes)
+
+	// Test case 2: failed debits change nothing
+	require.Error(t, l.Debit(2, "ETH", 5))
+	require.Len(t, changes, 3)
+}
+
+func TestHold(t *testing.T) {
+	l := New()
+	l.Credit(1, "ETH", 10)
+
+	// Test case 1: held funds cannot be debited or transferred
+	require.NoError(t, l.Hold(1, "ETH", 6))
+	require.Equal(t, 4.0, l.Available(1, "ETH"))
+	require.Equal(t, 10.0, l.Balance(1, "ETH"))
+	require.ErrorIs(t, l.Debit(1, "ETH", 5), ErrInsufficientBalance)
+	require.ErrorIs(t, l.Hold(1, "ETH", 5), ErrInsufficientBalance)
+
+	// Test case 2: held funds are spent with TransferHeld
+	require.NoError(t, l.TransferHeld(1, 2, "ETH", 4))
+	require.Equal(t, 2.0, l.Held(1, "ETH"))
+	require.Equal(t, 6.0, l.Balance(1, "ETH"))
+	require.Equal(t, 4.0, l.Balance(2, "ETH"))
+
+	// Test case 3: what the hold does not cover is spent from the available balance, if there is enough
+	require.NoError(t, l.TransferHeld(1, 2, "ETH", 3))
+	require.Zero(t, l.Held(1, "ETH"))
+	require.Equal(t, 3.0, l.Balance(1, "ETH"))
+	require.ErrorIs(t, l.TransferHeld(1, 2, "ETH", 4), ErrInsufficientBalance)
+	require.Equal(t, 3.0, l.Balance(1, "ETH"))
+
+	// Test case 4: released funds are available again
+	require.NoError(t, l.Hold(1, "ETH", 3))
+	l.Release(1, "ETH", 1)
+	require.Equal(t, 1.0, l.Available(1, "ETH"))
+	l.Release(1, "ETH", 5)
+	require.Equal(t, 3.0, l.Available(1, "ETH"))
+}
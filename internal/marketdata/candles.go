@@ -0,0 +1,198 @@
+package marketdata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+// ErrUnknownInterval is returned for candle intervals that are not aggregated
+var ErrUnknownInterval = errors.New("unknown interval")
+
+// Interval is the length of a candle
+type Interval string
+
+const (
+	Interval1m  Interval = "1m"
+	Interval5m  Interval = "5m"
+	Interval15m Interval = "15m"
+	Interval1h  Interval = "1h"
+	Interval1d  Interval = "1d"
+)
+
+// Intervals are the candle intervals every market is aggregated at
+var Intervals = []Interval{Interval1m, Interval5m, Interval15m, Interval1h, Interval1d}
+
+const (
+	// MaxCandles is the most candles a query returns
+	MaxCandles = 1000
+	// storedCandles is how many candles of every interval are kept, a week of one minute candles
+	storedCandles = 10080
+)
+
+// Duration returns the length of the interval, zero for unknown intervals
+func (i Interval) Duration() time.Duration {
+	switch i {
+	case Interval1m:
+		return time.Minute
+	case Interval5m:
+		return 5 * time.Minute
+	case Interval15m:
+		return 15 * time.Minute
+	case Interval1h:
+		return time.Hour
+	case Interval1d:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// Window is how far back the stored candles of the interval reach
+func (i Interval) Window() time.Duration {
+	return storedCandles * i.Duration()
+}
+
+// Candle is the OHLCV bar of an interval. OpenTime is the unix nanosecond the interval starts at, QuoteVolume is the
+// sum of price times size of the trades.
+type Candle struct {
+	OpenTime    int64   `json:"open_time"`
+	Open        float64 `json:"open"`
+	High        float64 `json:"high"`
+	Low         float64 `json:"low"`
+	Close       float64 `json:"close"`
+	Volume      float64 `json:"volume"`
+	QuoteVolume float64 `json:"quote_volume"`
+	Trades      int     `json:"trades"`
+}
+
+// Candles aggregates the trades of a market into candles of every interval
+type Candles struct {
+	market string
+
+	mu sync.RWMutex
+	// bars holds the candles of every interval sorted by open time
+	bars map[Interval][]*Candle
+	now  func() time.Time
+}
+
+// NewCandles creates the candle aggregator of the market, it is fed by Apply
+func NewCandles(market string) *Candles {
+	c := &Candles{
+		market: market,
+		bars:   make(map[Interval][]*Candle),
+		now:    time.Now,
+	}
+	for _, interval := range Intervals {
+		c.bars[interval] = []*Candle{}
+	}
+	return c
+}
+
+// Apply adds the trades of the market to the candles, it is an events.Handler
+func (c *Candles) Apply(e events.Envelope) {
+	trade, ok := e.Event.(events.TradeExecuted)
+	if !ok || trade.Market != c.market {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.add(trade.Price, trade.Amount, e.Timestamp)
+}
+
+// Reset drops all candles, such as before Restore rebuilds them
+func (c *Candles) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, interval := range Intervals {
+		c.bars[interval] = []*Candle{}
+	}
+}
+
+// Restore adds trades to the candles, such as the trades persisted before a restart, which can be restored page by
+// page. A trade only counts towards the intervals whose stored candles reach back to it, see Interval.Window.
+func (c *Candles) Restore(history []*trades.Trade) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for _, trade := range history {
+		for _, interval := range Intervals {
+			if trade.Timestamp >= now.Add(-interval.Window()).UnixNano() {
+				c.addTo(interval, trade.Price, trade.Size, trade.Timestamp)
+			}
+		}
+	}
+}
+
+// Query returns the candles of the interval opening between from and to, in unix nanoseconds and oldest first. A
+// zero to means now, a zero from returns the latest MaxCandles candles.
+func (c *Candles) Query(interval Interval, from, to int64) ([]*Candle, error) {
+	if interval.Duration() == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownInterval, interval)
+	}
+	if to == 0 {
+		to = c.now().UnixNano()
+	}
+	if from > to {
+		return nil, fmt.Errorf("from %d is after to %d", from, to)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	bars := c.bars[interval]
+	start := sort.Search(len(bars), func(i int) bool { return bars[i].OpenTime >= from })
+	end := sort.Search(len(bars), func(i int) bool { return bars[i].OpenTime > to })
+	if end-start > MaxCandles {
+		start = end - MaxCandles
+	}
+
+	candles := make([]*Candle, 0, end-start)
+	for _, bar := range bars[start:end] {
+		candle := *bar
+		candles = append(candles, &candle)
+	}
+
+	return candles, nil
+}
+
+func (c *Candles) add(price, size float64, timestamp int64) {
+	for _, interval := range Intervals {
+		c.addTo(interval, price, size, timestamp)
+	}
+}
+
+func (c *Candles) addTo(interval Interval, price, size float64, timestamp int64) {
+	d := int64(interval.Duration())
+	openTime := timestamp - timestamp%d
+
+	bars := c.bars[interval]
+	i := sort.Search(len(bars), func(i int) bool { return bars[i].OpenTime >= openTime })
+	if i == len(bars) || bars[i].OpenTime != openTime {
+		bars = append(bars, nil)
+		copy(bars[i+1:], bars[i:])
+		bars[i] = &Candle{OpenTime: openTime, Open: price, High: price, Low: price}
+	}
+
+	bar := bars[i]
+	bar.High = math.Max(bar.High, price)
+	bar.Low = math.Min(bar.Low, price)
+	bar.Close = price
+	bar.Volume += size
+	bar.QuoteVolume += price * size
+	bar.Trades++
+
+	if len(bars) > storedCandles {
+		bars = bars[len(bars)-storedCandles:]
+	}
+	c.bars[interval] = bars
+}
@@ -0,0 +1,106 @@
+package marketdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+func TestCandles(t *testing.T) {
+	candles := NewCandles("ETH")
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	trade := func(offset time.Duration, price, size float64) events.Envelope {
+		return events.Envelope{
+			Timestamp: start.Add(offset).UnixNano(),
+			Event:     events.TradeExecuted{Market: "ETH", Price: price, Amount: size},
+		}
+	}
+
+	candles.Apply(trade(10*time.Second, 100, 1))
+	candles.Apply(trade(20*time.Second, 110, 2))
+	candles.Apply(trade(30*time.Second, 95, 1))
+	candles.Apply(trade(70*time.Second, 105, 3))
+	candles.Apply(events.Envelope{Timestamp: start.UnixNano(), Event: events.TradeExecuted{Market: "BTC", Price: 1, Amount: 1}})
+
+	// Test case 1: trades are aggregated into one minute candles
+	minutes, err := candles.Query(Interval1m, start.UnixNano(), start.Add(time.Hour).UnixNano())
+	require.NoError(t, err)
+	require.Len(t, minutes, 2)
+	require.Equal(t, &Candle{
+		OpenTime:    start.UnixNano(),
+		Open:        100,
+		High:        110,
+		Low:         95,
+		Close:       95,
+		Volume:      4,
+		QuoteVolume: 100 + 220 + 95,
+		Trades:      3,
+	}, minutes[0])
+	require.Equal(t, start.Add(time.Minute).UnixNano(), minutes[1].OpenTime)
+
+	// Test case 2: longer intervals cover all trades
+	hours, err := candles.Query(Interval1h, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, hours, 1)
+	require.Equal(t, 105.0, hours[0].Close)
+	require.Equal(t, 7.0, hours[0].Volume)
+	require.Equal(t, 4, hours[0].Trades)
+
+	// Test case 3: the range bounds the open time
+	minutes, err = candles.Query(Interval1m, start.Add(time.Minute).UnixNano(), start.Add(time.Hour).UnixNano())
+	require.NoError(t, err)
+	require.Len(t, minutes, 1)
+
+	// Test case 4: unknown intervals and inverted ranges are rejected
+	_, err = candles.Query("2m", 0, 0)
+	require.ErrorIs(t, err, ErrUnknownInterval)
+	_, err = candles.Query(Interval1m, 2, 1)
+	require.Error(t, err)
+}
+
+func TestCandlesRebuild(t *testing.T) {
+	candles := NewCandles("ETH")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles.Apply(events.Envelope{Timestamp: start.UnixNano(), Event: events.TradeExecuted{Market: "ETH", Price: 1, Amount: 1}})
+
+	candles.now = func() time.Time { return start.Add(time.Hour) }
+
+	// Test case 1: restoring after a reset replaces the candles, trades out of order land in their own candle
+	candles.Reset()
+	candles.Restore([]*trades.Trade{
+		{Price: 100, Size: 1, Timestamp: start.Add(5 * time.Minute).UnixNano()},
+		{Price: 90, Size: 1, Timestamp: start.Add(time.Minute).UnixNano()},
+		{Price: 120, Size: 2, Timestamp: start.Add(6 * time.Minute).UnixNano()},
+	})
+
+	minutes, err := candles.Query(Interval1m, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, minutes, 3)
+	require.Equal(t, 90.0, minutes[0].Open)
+	require.Equal(t, start.Add(time.Minute).UnixNano(), minutes[0].OpenTime)
+
+	fives, err := candles.Query(Interval5m, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, fives, 2)
+	require.Equal(t, 3.0, fives[1].Volume)
+	require.Equal(t, 120.0, fives[1].High)
+
+	// Test case 2: trades only restore the intervals whose stored candles reach back to them
+	candles.now = func() time.Time { return start.Add(30 * 24 * time.Hour) }
+	candles.Reset()
+	candles.Restore([]*trades.Trade{{Price: 100, Size: 1, Timestamp: start.UnixNano()}})
+
+	minutes, err = candles.Query(Interval1m, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, minutes)
+	fives, err = candles.Query(Interval5m, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, fives, 1)
+	days, err := candles.Query(Interval1d, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, days, 1)
+}
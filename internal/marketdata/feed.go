@@ -0,0 +1,301 @@
+// Package marketdata turns exchange events into the public market data: L2 book deltas, trades and the best bid
+// and offer of every market as streams, OHLCV candles and rolling 24 hour statistics.
+package marketdata
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/matchingengine"
+)
+
+// ErrUnknownChannel is returned when subscribing to a channel the feed does not publish
+var ErrUnknownChannel = errors.New("unknown channel")
+
+// Channel is a stream of a market a client can subscribe to
+type Channel string
+
+const (
+	// ChannelBook streams the aggregated L2 book, a snapshot followed by sequenced deltas
+	ChannelBook Channel = "book"
+	// ChannelTrades streams every trade
+	ChannelTrades Channel = "trades"
+	// ChannelTicker streams the best bid and offer whenever it changes
+	ChannelTicker Channel = "ticker"
+)
+
+// Valid reports whether the feed publishes the channel
+func (c Channel) Valid() bool {
+	return c == ChannelBook || c == ChannelTrades || c == ChannelTicker
+}
+
+// MessageType tells whether a book message replaces the book or changes it
+type MessageType string
+
+const (
+	MessageSnapshot MessageType = "snapshot"
+	MessageDelta    MessageType = "delta"
+	MessageUpdate   MessageType = "update"
+)
+
+// Level is the total size resting at a price, a zero size in a delta removes the level
+type Level struct {
+	Price float64 `json:"price"`
+	Size  float64 `json:"size"`
+}
+
+// BookMessage is a snapshot or delta of the L2 book. Every delta carries the next sequence number of the market, a
+// client that sees a sequence number other than the last one plus one has missed an update and must resubscribe.
+type BookMessage struct {
+	Channel Channel     `json:"channel"`
+	Market  string      `json:"market"`
+	Type    MessageType `json:"type"`
+	Seq     uint64      `json:"seq"`
+	Bids    []Level     `json:"bids"`
+	Asks    []Level     `json:"asks"`
+}
+
+// TradeMessage is a single trade, Bid is the taker side
+type TradeMessage struct {
+	Channel   Channel     `json:"channel"`
+	Market    string      `json:"market"`
+	Type      MessageType `json:"type"`
+	Price     float64     `json:"price"`
+	Size      float64     `json:"size"`
+	Bid       bool        `json:"bid"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// TickerMessage is the best bid and offer, Seq is the book sequence number it was taken at
+type TickerMessage struct {
+	Channel     Channel     `json:"channel"`
+	Market      string      `json:"market"`
+	Type        MessageType `json:"type"`
+	Seq         uint64      `json:"seq"`
+	BestBid     float64     `json:"best_bid"`
+	BestBidSize float64     `json:"best_bid_size"`
+	BestAsk     float64     `json:"best_ask"`
+	BestAskSize float64     `json:"best_ask_size"`
+}
+
+// Sink receives the messages of a subscription. Send must not block, it returns false when the sink cannot keep up;
+// the subscription is dropped then and the client has to resubscribe.
+type Sink interface {
+	Send(msg interface{}) bool
+}
+
+// Feed keeps the L2 book of a market built from the exchange events and fans the market data out to subscribers
+type Feed struct {
+	market string
+
+	mu          sync.Mutex
+	seq         uint64
+	bids        map[float64]float64
+	asks        map[float64]float64
+	ticker      TickerMessage
+	subscribers map[Channel]map[Sink]struct{}
+}
+
+// NewFeed is constructor of Feed struct. The feed starts from the current state of the orderbook, Apply must receive
+// every event published after that.
+func NewFeed(market string, ob *matchingengine.Orderbook) *Feed {
+	f := &Feed{
+		market: market,
+		bids:   make(map[float64]float64),
+		asks:   make(map[float64]float64),
+		ticker: TickerMessage{Channel: ChannelTicker, Market: market, Type: MessageUpdate},
+		subscribers: map[Channel]map[Sink]struct{}{
+			ChannelBook:   {},
+			ChannelTrades: {},
+			ChannelTicker: {},
+		},
+	}
+
+	for _, limit := range ob.Bids() {
+		f.bids[limit.Price] = limit.TotalVolume
+	}
+	for _, limit := range ob.Asks() {
+		f.asks[limit.Price] = limit.TotalVolume
+	}
+	f.updateTicker()
+
+	return f
+}
+
+// Market returns the market of the feed
+func (f *Feed) Market() string {
+	return f.market
+}
+
+// Subscribe registers the sink for the channel. Book subscribers get a snapshot and ticker subscribers the current
+// best bid and offer first, both consistent with the deltas that follow.
+func (f *Feed) Subscribe(channel Channel, sink Sink) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !channel.Valid() {
+		return fmt.Errorf("%w: %s", ErrUnknownChannel, channel)
+	}
+	subscribers := f.subscribers[channel]
+
+	switch channel {
+	case ChannelBook:
+		if !sink.Send(f.snapshot()) {
+			return nil
+		}
+	case ChannelTicker:
+		if !sink.Send(f.ticker) {
+			return nil
+		}
+	}
+
+	subscribers[sink] = struct{}{}
+
+	return nil
+}
+
+// Unsubscribe removes the sink from the channel
+func (f *Feed) Unsubscribe(channel Channel, sink Sink) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.subscribers[channel], sink)
+}
+
+// Snapshot returns the current L2 book
+func (f *Feed) Snapshot() *BookMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.snapshot()
+}
+
+// BestBidOffer returns the current best bid and offer
+func (f *Feed) BestBidOffer() TickerMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.ticker
+}
+
+// Apply updates the feed with an exchange event and publishes the resulting messages, events of other markets are
+// ignored. It is an events.Handler.
+func (f *Feed) Apply(e events.Envelope) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch ev := e.Event.(type) {
+	case events.TradeExecuted:
+		if ev.Market != f.market {
+			return
+		}
+
+		f.publish(ChannelTrades, TradeMessage{
+			Channel:   ChannelTrades,
+			Market:    f.market,
+			Type:      MessageUpdate,
+			Price:     ev.Price,
+			Size:      ev.Amount,
+			Bid:       ev.TakerIsBid,
+			Timestamp: e.Timestamp,
+		})
+
+	case events.BookLevelChanged:
+		if ev.Market != f.market {
+			return
+		}
+
+		levels := f.asks
+		if ev.IsBid {
+			levels = f.bids
+		}
+		if ev.Size > 0 {
+			levels[ev.Price] = ev.Size
+		} else {
+			delete(levels, ev.Price)
+		}
+
+		f.seq++
+		delta := &BookMessage{
+			Channel: ChannelBook,
+			Market:  f.market,
+			Type:    MessageDelta,
+			Seq:     f.seq,
+		}
+		if ev.IsBid {
+			delta.Bids = []Level{{Price: ev.Price, Size: ev.Size}}
+		} else {
+			delta.Asks = []Level{{Price: ev.Price, Size: ev.Size}}
+		}
+		f.publish(ChannelBook, delta)
+
+		previous := f.ticker
+		f.updateTicker()
+		if f.ticker.BestBid != previous.BestBid || f.ticker.BestBidSize != previous.BestBidSize ||
+			f.ticker.BestAsk != previous.BestAsk || f.ticker.BestAskSize != previous.BestAskSize {
+			f.publish(ChannelTicker, f.ticker)
+		}
+	}
+}
+
+// publish sends the message to every subscriber of the channel, dropping those that cannot keep up
+func (f *Feed) publish(channel Channel, msg interface{}) {
+	for sink := range f.subscribers[channel] {
+		if !sink.Send(msg) {
+			delete(f.subscribers[channel], sink)
+		}
+	}
+}
+
+func (f *Feed) snapshot() *BookMessage {
+	return &BookMessage{
+		Channel: ChannelBook,
+		Market:  f.market,
+		Type:    MessageSnapshot,
+		Seq:     f.seq,
+		Bids:    sortedLevels(f.bids, true),
+		Asks:    sortedLevels(f.asks, false),
+	}
+}
+
+func (f *Feed) updateTicker() {
+	f.ticker.Seq = f.seq
+
+	bid := bestLevel(f.bids, true)
+	f.ticker.BestBid, f.ticker.BestBidSize = bid.Price, bid.Size
+
+	ask := bestLevel(f.asks, false)
+	f.ticker.BestAsk, f.ticker.BestAskSize = ask.Price, ask.Size
+}
+
+// bestLevel returns the highest bid or lowest ask level, or a zero level for an empty side
+func bestLevel(levels map[float64]float64, bid bool) Level {
+	var best Level
+	for price, size := range levels {
+		if best.Size == 0 || (bid && price > best.Price) || (!bid && price < best.Price) {
+			best = Level{Price: price, Size: size}
+		}
+	}
+
+	return best
+}
+
+// sortedLevels returns the levels best price first
+func sortedLevels(levels map[float64]float64, bid bool) []Level {
+	sorted := make([]Level, 0, len(levels))
+	for price, size := range levels {
+		sorted = append(sorted, Level{Price: price, Size: size})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if bid {
+			return sorted[i].Price > sorted[j].Price
+		}
+		return sorted[i].Price < sorted[j].Price
+	})
+
+	return sorted
+}
@@ -0,0 +1,122 @@
+package marketdata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/matchingengine"
+)
+
+type recorder struct {
+	messages []interface{}
+	capacity int
+}
+
+func (r *recorder) Send(msg interface{}) bool {
+	if r.capacity > 0 && len(r.messages) >= r.capacity {
+		return false
+	}
+	r.messages = append(r.messages, msg)
+	return true
+}
+
+func newTestFeed(ob *matchingengine.Orderbook) (*Feed, *events.Bus) {
+	bus := events.NewBus()
+	feed := NewFeed("ETH", ob)
+	bus.Subscribe(feed.Apply)
+	return feed, bus
+}
+
+func level(bid bool, price, size float64) events.BookLevelChanged {
+	return events.BookLevelChanged{Market: "ETH", IsBid: bid, Price: price, Size: size}
+}
+
+func TestFeedBook(t *testing.T) {
+	ob := matchingengine.NewOrderbook()
+	ob.PlaceLimitOrder(100, matchingengine.NewOrder(false, 5, 1))
+
+	feed, bus := newTestFeed(ob)
+	bus.Publish(level(true, 90, 2))
+
+	// Test case 1: subscribers start from a snapshot of the current book
+	book := &recorder{}
+	require.NoError(t, feed.Subscribe(ChannelBook, book))
+	require.Equal(t, &BookMessage{
+		Channel: ChannelBook,
+		Market:  "ETH",
+		Type:    MessageSnapshot,
+		Seq:     1,
+		Bids:    []Level{{Price: 90, Size: 2}},
+		Asks:    []Level{{Price: 100, Size: 5}},
+	}, book.messages[0])
+
+	// Test case 2: deltas continue the sequence of the snapshot, other markets are ignored
+	bus.Publish(level(false, 101, 1))
+	bus.Publish(events.BookLevelChanged{Market: "BTC", Price: 1, Size: 1})
+	bus.Publish(level(false, 100, 0))
+	require.Len(t, book.messages, 3)
+	require.Equal(t, &BookMessage{
+		Channel: ChannelBook,
+		Market:  "ETH",
+		Type:    MessageDelta,
+		Seq:     2,
+		Asks:    []Level{{Price: 101, Size: 1}},
+	}, book.messages[1])
+	require.Equal(t, &BookMessage{
+		Channel: ChannelBook,
+		Market:  "ETH",
+		Type:    MessageDelta,
+		Seq:     3,
+		Asks:    []Level{{Price: 100, Size: 0}},
+	}, book.messages[2])
+
+	require.Equal(t, []Level{{Price: 101, Size: 1}}, feed.Snapshot().Asks)
+
+	// Test case 3: unknown channels are rejected
+	require.ErrorIs(t, feed.Subscribe("candles", book), ErrUnknownChannel)
+}
+
+func TestFeedTradesAndTicker(t *testing.T) {
+	feed, bus := newTestFeed(matchingengine.NewOrderbook())
+
+	trades := &recorder{}
+	ticker := &recorder{}
+	require.NoError(t, feed.Subscribe(ChannelTrades, trades))
+	require.NoError(t, feed.Subscribe(ChannelTicker, ticker))
+
+	bus.Publish(level(false, 100, 5))
+	bus.Publish(level(true, 90, 1))
+	bus.Publish(level(true, 80, 1))
+	bus.Publish(events.TradeExecuted{Market: "ETH", Price: 100, Amount: 2, TakerIsBid: true})
+	bus.Publish(level(false, 100, 3))
+
+	// Test case 1: trades carry the taker side
+	require.Len(t, trades.messages, 1)
+	trade := trades.messages[0].(TradeMessage)
+	require.Equal(t, 100.0, trade.Price)
+	require.Equal(t, 2.0, trade.Size)
+	require.True(t, trade.Bid)
+
+	// Test case 2: the ticker is published only when the best bid or offer changes
+	require.Len(t, ticker.messages, 4)
+	require.Equal(t, TickerMessage{Channel: ChannelTicker, Market: "ETH", Type: MessageUpdate}, ticker.messages[0])
+	last := ticker.messages[3].(TickerMessage)
+	require.Equal(t, uint64(4), last.Seq)
+	require.Equal(t, 90.0, last.BestBid)
+	require.Equal(t, 3.0, last.BestAskSize)
+}
+
+func TestFeedDropsSlowSubscribers(t *testing.T) {
+	feed, bus := newTestFeed(matchingengine.NewOrderbook())
+
+	slow := &recorder{capacity: 2}
+	require.NoError(t, feed.Subscribe(ChannelBook, slow))
+
+	for i := 1; i <= 3; i++ {
+		bus.Publish(level(false, 100, float64(i)))
+	}
+
+	require.Len(t, slow.messages, 2)
+	require.Empty(t, feed.subscribers[ChannelBook])
+}
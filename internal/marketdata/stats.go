@@ -0,0 +1,193 @@
+package marketdata
+
+import (
+	"sync"
+	"time"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+// statsWindow is the period the market statistics cover
+const statsWindow = 24 * time.Hour
+
+// Ticker24h summarizes the trading of a market over the last 24 hours. LastPrice is the price of the latest trade
+// even when it is older than the window, QuoteVolume is the sum of price times size and times are unix nanoseconds.
+type Ticker24h struct {
+	Market             string  `json:"market"`
+	LastPrice          float64 `json:"last_price"`
+	Open               float64 `json:"open"`
+	High               float64 `json:"high"`
+	Low                float64 `json:"low"`
+	Volume             float64 `json:"volume"`
+	QuoteVolume        float64 `json:"quote_volume"`
+	PriceChange        float64 `json:"price_change"`
+	PriceChangePercent float64 `json:"price_change_percent"`
+	BestBid            float64 `json:"best_bid"`
+	BestBidSize        float64 `json:"best_bid_size"`
+	BestAsk            float64 `json:"best_ask"`
+	BestAskSize        float64 `json:"best_ask_size"`
+	Trades             int     `json:"trades"`
+	OpenTime           int64   `json:"open_time"`
+	CloseTime          int64   `json:"close_time"`
+}
+
+// statsTrade is a trade in the window, seq orders the trades so the high and low queues can tell them apart
+type statsTrade struct {
+	seq       uint64
+	price     float64
+	size      float64
+	timestamp int64
+}
+
+// Stats keeps the rolling 24 hour statistics of a market, updated with every trade. The best bid and offer come from
+// the feed of the market.
+type Stats struct {
+	market string
+	feed   *Feed
+
+	mu   sync.Mutex
+	seq  uint64
+	last float64
+	// trades holds the trades of the window from head on, the evicted ones before head are dropped in batches
+	trades []statsTrade
+	head   int
+	// highs and lows hold the trades that can still become the high or low once older trades leave the window,
+	// highs in decreasing and lows in increasing price order
+	highs       []statsTrade
+	lows        []statsTrade
+	volume      float64
+	quoteVolume float64
+	now         func() time.Time
+}
+
+// NewStats creates the 24 hour statistics of the market, it is fed by Apply
+func NewStats(feed *Feed) *Stats {
+	return &Stats{
+		market: feed.Market(),
+		feed:   feed,
+		now:    time.Now,
+	}
+}
+
+// Apply adds the trades of the market to the statistics, it is an events.Handler
+func (s *Stats) Apply(e events.Envelope) {
+	trade, ok := e.Event.(events.TradeExecuted)
+	if !ok || trade.Market != s.market {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.add(trade.Price, trade.Amount, e.Timestamp)
+}
+
+// Reset drops the statistics, such as before Restore rebuilds them
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trades, s.head, s.highs, s.lows = nil, 0, nil, nil
+	s.volume, s.quoteVolume = 0, 0
+}
+
+// Restore adds trades to the statistics, oldest first, such as the trades persisted before a restart, which can be
+// restored page by page. Trades older than the window are dropped after every page.
+func (s *Stats) Restore(history []*trades.Trade) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, trade := range history {
+		s.add(trade.Price, trade.Size, trade.Timestamp)
+	}
+	s.evict(s.now().UnixNano())
+}
+
+// add adds a trade to the window
+func (s *Stats) add(price, size float64, timestamp int64) {
+	s.seq++
+	t := statsTrade{seq: s.seq, price: price, size: size, timestamp: timestamp}
+
+	s.last = t.price
+	s.trades = append(s.trades, t)
+	s.volume += t.size
+	s.quoteVolume += t.price * t.size
+
+	for len(s.highs) > 0 && s.highs[len(s.highs)-1].price <= t.price {
+		s.highs = s.highs[:len(s.highs)-1]
+	}
+	s.highs = append(s.highs, t)
+
+	for len(s.lows) > 0 && s.lows[len(s.lows)-1].price >= t.price {
+		s.lows = s.lows[:len(s.lows)-1]
+	}
+	s.lows = append(s.lows, t)
+
+	s.evict(timestamp)
+}
+
+// Ticker returns the statistics of the last 24 hours
+func (s *Stats) Ticker() *Ticker24h {
+	bbo := s.feed.BestBidOffer()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UnixNano()
+	s.evict(now)
+
+	ticker := &Ticker24h{
+		Market:      s.market,
+		LastPrice:   s.last,
+		Open:        s.last,
+		High:        s.last,
+		Low:         s.last,
+		Volume:      s.volume,
+		QuoteVolume: s.quoteVolume,
+		BestBid:     bbo.BestBid,
+		BestBidSize: bbo.BestBidSize,
+		BestAsk:     bbo.BestAsk,
+		BestAskSize: bbo.BestAskSize,
+		Trades:      len(s.trades) - s.head,
+		OpenTime:    now - int64(statsWindow),
+		CloseTime:   now,
+	}
+
+	if s.head < len(s.trades) {
+		ticker.Open = s.trades[s.head].price
+		ticker.High = s.highs[0].price
+		ticker.Low = s.lows[0].price
+		ticker.PriceChange = ticker.LastPrice - ticker.Open
+		ticker.PriceChangePercent = ticker.PriceChange / ticker.Open * 100
+	}
+
+	return ticker
+}
+
+// evict drops the trades that left the window ending at now
+func (s *Stats) evict(now int64) {
+	oldest := now - int64(statsWindow)
+
+	for s.head < len(s.trades) && s.trades[s.head].timestamp <= oldest {
+		t := s.trades[s.head]
+		s.volume -= t.size
+		s.quoteVolume -= t.price * t.size
+
+		if len(s.highs) > 0 && s.highs[0].seq == t.seq {
+			s.highs = s.highs[1:]
+		}
+		if len(s.lows) > 0 && s.lows[0].seq == t.seq {
+			s.lows = s.lows[1:]
+		}
+		s.head++
+	}
+
+	if s.head == len(s.trades) {
+		// Start over so the subtractions do not leave rounding errors behind
+		s.trades, s.head = s.trades[:0], 0
+		s.volume, s.quoteVolume = 0, 0
+	} else if s.head > len(s.trades)/2 {
+		s.trades, s.head = append(s.trades[:0], s.trades[s.head:]...), 0
+	}
+}
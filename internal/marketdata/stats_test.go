@@ -0,0 +1,72 @@
+package marketdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/events"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/matchingengine"
+)
+
+func TestStats(t *testing.T) {
+	feed, bus := newTestFeed(matchingengine.NewOrderbook())
+	stats := NewStats(feed)
+	bus.Subscribe(stats.Apply)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	stats.now = func() time.Time { return now }
+
+	trade := func(offset time.Duration, price, size float64) {
+		stats.Apply(events.Envelope{
+			Timestamp: start.Add(offset).UnixNano(),
+			Event:     events.TradeExecuted{Market: "ETH", Price: price, Amount: size},
+		})
+	}
+
+	// Test case 1: a market without trades has an empty ticker with the best bid and offer
+	bus.Publish(level(true, 90, 2))
+	bus.Publish(level(false, 110, 3))
+	ticker := stats.Ticker()
+	require.Zero(t, ticker.Trades)
+	require.Equal(t, 90.0, ticker.BestBid)
+	require.Equal(t, 3.0, ticker.BestAskSize)
+
+	// Test case 2: trades update the statistics
+	trade(time.Hour, 100, 1)
+	trade(2*time.Hour, 120, 2)
+	trade(3*time.Hour, 80, 1)
+	trade(4*time.Hour, 90, 1)
+
+	now = start.Add(5 * time.Hour)
+	ticker = stats.Ticker()
+	require.Equal(t, 4, ticker.Trades)
+	require.Equal(t, 100.0, ticker.Open)
+	require.Equal(t, 120.0, ticker.High)
+	require.Equal(t, 80.0, ticker.Low)
+	require.Equal(t, 90.0, ticker.LastPrice)
+	require.Equal(t, 5.0, ticker.Volume)
+	require.Equal(t, 100.0+240+80+90, ticker.QuoteVolume)
+	require.Equal(t, -10.0, ticker.PriceChange)
+	require.Equal(t, -10.0, ticker.PriceChangePercent)
+	require.Equal(t, now.UnixNano(), ticker.CloseTime)
+
+	// Test case 3: trades older than 24 hours leave the window, the high and low follow
+	now = start.Add(26*time.Hour + time.Minute)
+	ticker = stats.Ticker()
+	require.Equal(t, 2, ticker.Trades)
+	require.Equal(t, 80.0, ticker.Open)
+	require.Equal(t, 90.0, ticker.High)
+	require.Equal(t, 80.0, ticker.Low)
+	require.Equal(t, 2.0, ticker.Volume)
+
+	// Test case 4: without trades in the window the last price remains
+	now = start.Add(48 * time.Hour)
+	ticker = stats.Ticker()
+	require.Zero(t, ticker.Trades)
+	require.Zero(t, ticker.Volume)
+	require.Equal(t, 90.0, ticker.LastPrice)
+	require.Equal(t, 90.0, ticker.High)
+	require.Zero(t, ticker.PriceChange)
+}
@@ -0,0 +1,44 @@
+package matchingengine
+
+// EventKind tells what changed in the orderbook
+type EventKind string
+
+const (
+	// EventLevelChanged is emitted when the total volume at a price level changes, a zero size removes the level
+	EventLevelChanged EventKind = "LEVEL_CHANGED"
+	// EventTrade is emitted for every match
+	EventTrade EventKind = "TRADE"
+)
+
+// Event is a change of the orderbook reported to its listeners
+type Event struct {
+	Kind EventKind
+	// Bid is the side of the level, or the taker side of a trade
+	Bid   bool
+	Price float64
+	// Size is the new total volume at the level, or the size of the trade
+	Size      float64
+	Timestamp int64
+}
+
+// OnEvent registers a listener that is called synchronously for every change of the orderbook, in the order the
+// changes happen. Listeners must not block or modify the orderbook.
+func (ob *Orderbook) OnEvent(listener func(Event)) {
+	ob.listeners = append(ob.listeners, listener)
+}
+
+func (ob *Orderbook) emit(e Event) {
+	for _, listener := range ob.listeners {
+		listener(e)
+	}
+}
+
+func (ob *Orderbook) emitLevel(bid bool, l *Limit, timestamp int64) {
+	ob.emit(Event{
+		Kind:      EventLevelChanged,
+		Bid:       bid,
+		Price:     l.Price,
+		Size:      l.TotalVolume,
+		Timestamp: timestamp,
+	})
+}
@@ -2,17 +2,21 @@ package matchingengine
 
 import (
 	"fmt"
-	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
+// lastOrderID is the ID of the last order made by NewOrder
+var lastOrderID uint64
+
 type Order struct {
-	ID        uint64  // The ID concept is only for external APIs
-	UserID    uint64  // UserID to identify who puts the order
-	Amount    float64 // Amount of our crypto
-	Bid       bool    // Is this a sell or buy Order
-	Limit     *Limit  // To keep track of what limit this order is set in
-	Timestamp int64   // Use in64 because we will use Unix nano for Timestamp
+	ID            uint64  // The ID concept is only for external APIs
+	ClientOrderID string  // Optional ID the client assigned to the order
+	UserID        uint64  // UserID to identify who puts the order
+	Amount        float64 // Amount of our crypto
+	Bid           bool    // Is this a sell or buy Order
+	Limit         *Limit  // To keep track of what limit this order is set in
+	Timestamp     int64   // Use in64 because we will use Unix nano for Timestamp
 }
 
 type Orders []*Order
@@ -21,10 +25,11 @@ func (o Orders) Len() int           { return len(o) }
 func (o Orders) Less(i, j int) bool { return o[i].Timestamp < o[j].Timestamp }
 func (o Orders) Swap(i, j int)      { o[i], o[j] = o[j], o[i] }
 
-// NewOrder is constructor of Order struct.
+// NewOrder is constructor of Order struct, order IDs are sequential within the process. Callers that persist orders
+// assign their own IDs.
 func NewOrder(isBid bool, amount float64, userID uint64) *Order {
 	return &Order{
-		ID:        uint64(rand.Intn(1000000)),
+		ID:        atomic.AddUint64(&lastOrderID, 1),
 		UserID:    userID,
 		Amount:    amount,
 		Bid:       isBid,
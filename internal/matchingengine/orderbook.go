@@ -1,11 +1,15 @@
 package matchingengine
 
 import (
+	"errors"
 	"sort"
 	"sync"
 	"time"
 )
 
+// ErrInsufficientVolume is returned for market orders larger than the volume of the other side of the orderbook
+var ErrInsufficientVolume = errors.New("there is not enough volume in the orderbook")
+
 // Orderbook contains our asks and bids; orderbook would need to be persisted in a db somehow and shared between clients
 // In real world exchanges we can use distributed event stream like Apache Kafka. By doing this, we could always replay
 // the Orders deterministically if the exchange crashes and restore the order books to their original state.
@@ -24,19 +28,12 @@ type Orderbook struct {
 	// To keep track Orders for operations like canceling through APIs
 	Orders map[uint64]*Order
 
-	Trades []*Trade
+	// listeners are called for every change, see OnEvent
+	listeners []func(Event)
 
 	mu *sync.Mutex
 }
 
-// Trade is each order filled match
-type Trade struct {
-	Price     float64
-	Size      float64
-	Bid       bool
-	Timestamp int64
-}
-
 // NewOrderbook is constructor of Orderbook struct.
 func NewOrderbook() *Orderbook {
 	return &Orderbook{
@@ -46,25 +43,26 @@ func NewOrderbook() *Orderbook {
 		AskLimits: make(map[float64]*Limit),
 		BidLimits: make(map[float64]*Limit),
 
-		Trades: []*Trade{},
 		Orders: make(map[uint64]*Order),
 	}
 }
 
 // PlaceMarketOrder will fill the order with orderbook asks or bids, and also checks the volume for specific order request.
-func (ob *Orderbook) PlaceMarketOrder(o *Order) Matches {
+// An order larger than the volume fails with ErrInsufficientVolume and leaves the orderbook as it was.
+func (ob *Orderbook) PlaceMarketOrder(o *Order) (Matches, error) {
 	var matches Matches
 
 	if o.Bid {
 		// Check if the amount of the order is greater than the total volume of the ask Orders
 		if o.Amount > ob.AskTotalVolume() {
-			panic("there is not enough volume in the orderbook")
+			return nil, ErrInsufficientVolume
 		}
 		//Iterate through all the ask Orders
 		for _, ask := range ob.Asks() {
 			//Fill the ask order with the market order.
 			asksMatches := ask.Fill(o)
 			matches = append(matches, asksMatches...)
+			ob.recordTrades(o.Bid, false, ask, asksMatches)
 
 			//Check if there are no more Orders in the limit. we can keep limits without any Orders but we will
 			//remove it because of memory efficiency.
@@ -74,11 +72,12 @@ func (ob *Orderbook) PlaceMarketOrder(o *Order) Matches {
 		}
 	} else {
 		if o.Amount > ob.BidTotalVolume() {
-			panic("there is not enough volume in the orderbook")
+			return nil, ErrInsufficientVolume
 		}
 		for _, bid := range ob.Bids() {
 			bidsMatches := bid.Fill(o)
 			matches = append(matches, bidsMatches...)
+			ob.recordTrades(o.Bid, true, bid, bidsMatches)
 
 			if len(bid.Orders) == 0 {
 				ob.clearLimit(true, bid)
@@ -86,17 +85,64 @@ func (ob *Orderbook) PlaceMarketOrder(o *Order) Matches {
 		}
 	}
 
+	return matches, nil
+}
+
+// PreviewMarketOrder returns the matches PlaceMarketOrder would make for the order without changing the orderbook or
+// the orders. The matches point to the order and the resting orders it would fill.
+func (ob *Orderbook) PreviewMarketOrder(o *Order) (Matches, error) {
+	limits, available := ob.Asks(), ob.AskTotalVolume()
+	if !o.Bid {
+		limits, available = ob.Bids(), ob.BidTotalVolume()
+	}
+	if o.Amount > available {
+		return nil, ErrInsufficientVolume
+	}
+
+	var matches Matches
+	remaining := o.Amount
+	for _, limit := range limits {
+		// Resting orders are filled in the order Limit.Fill goes through them
+		for _, resting := range limit.Orders {
+			if remaining == 0.0 {
+				return matches, nil
+			}
+
+			filled := resting.Amount
+			if remaining <= resting.Amount {
+				filled = remaining
+			}
+			remaining -= filled
+
+			match := Match{Ask: resting, Bid: o, AmountFilled: filled, Price: limit.Price}
+			if !o.Bid {
+				match.Ask, match.Bid = o, resting
+			}
+			matches = append(matches, match)
+		}
+	}
+
+	return matches, nil
+}
+
+// recordTrades reports a trade for every match against the limit together with the new limit volume
+func (ob *Orderbook) recordTrades(takerBid, isLimitBid bool, l *Limit, matches Matches) {
+	if len(matches) == 0 {
+		return
+	}
+
+	timestamp := time.Now().UnixNano()
 	for _, match := range matches {
-		trade := &Trade{
+		ob.emit(Event{
+			Kind:      EventTrade,
+			Bid:       takerBid,
 			Price:     match.Price,
 			Size:      match.AmountFilled,
-			Timestamp: time.Now().UnixNano(),
-			Bid:       o.Bid,
-		}
-		ob.Trades = append(ob.Trades, trade)
+			Timestamp: timestamp,
+		})
 	}
 
-	return matches
+	ob.emitLevel(isLimitBid, l, timestamp)
 }
 
 func (ob *Orderbook) PlaceLimitOrder(price float64, o *Order) {
@@ -123,6 +169,8 @@ func (ob *Orderbook) PlaceLimitOrder(price float64, o *Order) {
 
 	ob.Orders[o.ID] = o
 	limit.AddOrder(o)
+
+	ob.emitLevel(o.Bid, limit, o.Timestamp)
 }
 
 func (ob *Orderbook) clearLimit(isLimitBid bool, l *Limit) {
@@ -154,6 +202,8 @@ func (ob *Orderbook) CancelOrder(o *Order) {
 	if len(limit.Orders) == 0 {
 		ob.clearLimit(o.Bid, limit)
 	}
+
+	ob.emitLevel(o.Bid, limit, time.Now().UnixNano())
 }
 
 // BidTotalVolume returns total volume of the asks in the market.
@@ -21,7 +21,8 @@ func TestPlaceMarketOrder(t *testing.T) {
 
 	// Test case 1: Place a market buy order with amount 30
 	buyMarketOrder := NewOrder(true, 10.0, 0)
-	matches := ob.PlaceMarketOrder(buyMarketOrder)
+	matches, err := ob.PlaceMarketOrder(buyMarketOrder)
+	require.NoError(t, err)
 
 	// check if the order is filled
 	if !buyMarketOrder.IsFilled() {
@@ -37,7 +38,8 @@ func TestPlaceMarketOrder(t *testing.T) {
 
 	// Test case 2: Place a market sell order with amount 50
 	sellOrder3 := NewOrder(false, 3, 0)
-	matches = ob.PlaceMarketOrder(sellOrder3)
+	matches, err = ob.PlaceMarketOrder(sellOrder3)
+	require.NoError(t, err)
 
 	// check if the order is filled
 	if !sellOrder3.IsFilled() {
@@ -54,12 +56,11 @@ func TestPlaceMarketOrder(t *testing.T) {
 
 	// Test case 3: Place a sell market order with amount greater than the total volume of bid Orders
 	sellOrder4 := NewOrder(false, 100, 0)
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("The code did not panic")
-		}
-	}()
-	ob.PlaceMarketOrder(sellOrder4)
+	bidVolume := ob.BidTotalVolume()
+	_, err = ob.PlaceMarketOrder(sellOrder4)
+	require.ErrorIs(t, err, ErrInsufficientVolume)
+	require.Equal(t, bidVolume, ob.BidTotalVolume())
+	require.Equal(t, 100.0, sellOrder4.Amount)
 
 	// Test case 4: Place a buy market order with amount less than the total volume of ask Orders
 	// and check if the matches returned are correct
@@ -70,7 +71,8 @@ func TestPlaceMarketOrder(t *testing.T) {
 	ask3 := NewOrder(false, 20, 0)
 	ob.PlaceLimitOrder(120, ask3)
 	buyOrder = NewOrder(true, 50, 0)
-	matches2 := ob.PlaceMarketOrder(buyOrder)
+	matches2, err := ob.PlaceMarketOrder(buyOrder)
+	require.NoError(t, err)
 	if len(matches2) != 1 || matches2[0].AmountFilled != 50 {
 		t.Error("Expected one match with size filled of 50, got: ", matches2)
 	}
@@ -216,3 +218,86 @@ func TestBids(t *testing.T) {
 		t.Errorf("Bids() = %v, expected %v", bids, []*Limit{l2, l1, l3})
 	}
 }
+
+func TestOrderbookEvents(t *testing.T) {
+	ob := NewOrderbook()
+
+	var events []Event
+	ob.OnEvent(func(e Event) {
+		e.Timestamp = 0
+		events = append(events, e)
+	})
+
+	ask1 := NewOrder(false, 5, 0)
+	ask2 := NewOrder(false, 3, 0)
+	ob.PlaceLimitOrder(100, ask1)
+	ob.PlaceLimitOrder(110, ask2)
+
+	// Test case 1: resting orders change their level
+	require.Equal(t, []Event{
+		{Kind: EventLevelChanged, Bid: false, Price: 100, Size: 5},
+		{Kind: EventLevelChanged, Bid: false, Price: 110, Size: 3},
+	}, events)
+
+	// Test case 2: a market order reports its trades followed by the new volume of every level it touched
+	events = nil
+	_, err := ob.PlaceMarketOrder(NewOrder(true, 6, 0))
+	require.NoError(t, err)
+	require.Equal(t, []Event{
+		{Kind: EventTrade, Bid: true, Price: 100, Size: 5},
+		{Kind: EventLevelChanged, Bid: false, Price: 100, Size: 0},
+		{Kind: EventTrade, Bid: true, Price: 110, Size: 1},
+		{Kind: EventLevelChanged, Bid: false, Price: 110, Size: 2},
+	}, events)
+
+	// Test case 3: cancelling removes the volume from the level
+	events = nil
+	ob.CancelOrder(ask2)
+	require.Equal(t, []Event{
+		{Kind: EventLevelChanged, Bid: false, Price: 110, Size: 0},
+	}, events)
+}
+
+func TestPreviewMarketOrder(t *testing.T) {
+	ob := NewOrderbook()
+	ask1 := NewOrder(false, 5, 0)
+	ask2 := NewOrder(false, 3, 0)
+	ask3 := NewOrder(false, 4, 0)
+	ob.PlaceLimitOrder(110, ask1)
+	ob.PlaceLimitOrder(100, ask2)
+	ob.PlaceLimitOrder(110, ask3)
+
+	// Test case 1: the preview has the matches of placing the order and changes nothing
+	buy := NewOrder(true, 10, 0)
+	preview, err := ob.PreviewMarketOrder(buy)
+	require.NoError(t, err)
+	require.Equal(t, Matches{
+		{Ask: ask2, Bid: buy, AmountFilled: 3, Price: 100},
+		{Ask: ask1, Bid: buy, AmountFilled: 5, Price: 110},
+		{Ask: ask3, Bid: buy, AmountFilled: 2, Price: 110},
+	}, preview)
+	require.Equal(t, 10.0, buy.Amount)
+	require.Equal(t, 4.0, ask3.Amount)
+	require.Equal(t, 12.0, ob.AskTotalVolume())
+
+	matches, err := ob.PlaceMarketOrder(buy)
+	require.NoError(t, err)
+	require.Len(t, matches, len(preview))
+	for i, match := range matches {
+		require.Same(t, preview[i].Ask, match.Ask)
+		require.Equal(t, preview[i].AmountFilled, match.AmountFilled)
+		require.Equal(t, preview[i].Price, match.Price)
+	}
+
+	// Test case 2: an order larger than the book cannot be previewed
+	_, err = ob.PreviewMarketOrder(NewOrder(true, 3, 0))
+	require.ErrorIs(t, err, ErrInsufficientVolume)
+
+	// Test case 3: sell orders match the bids
+	bid := NewOrder(true, 2, 0)
+	ob.PlaceLimitOrder(90, bid)
+	sell := NewOrder(false, 1, 0)
+	preview, err = ob.PreviewMarketOrder(sell)
+	require.NoError(t, err)
+	require.Equal(t, Matches{{Ask: sell, Bid: bid, AmountFilled: 1, Price: 90}}, preview)
+}
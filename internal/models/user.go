@@ -1,42 +1,48 @@
 package models
 
 import (
-	"crypto/ecdsa"
 	"errors"
 
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/signer"
+)
+
+// UserStatus represents the state of a user's account
+type UserStatus string
+
+const (
+	// UserActive accounts can trade
+	UserActive UserStatus = "ACTIVE"
+	// UserFrozen accounts cannot place orders until they are unfrozen
+	UserFrozen UserStatus = "FROZEN"
+	// UserClosed accounts are closed for good
+	UserClosed UserStatus = "CLOSED"
 )
 
 // User represents a user of the exchange
 type User struct {
-	ID         uint64
-	PrivateKey *ecdsa.PrivateKey
-	Address    string
+	ID uint64
+	// Signer signs for the user's custodial account, settlements move funds from and to its address
+	Signer signer.Signer
+	// Address identifies the user: the user's own wallet when they registered with one, which they log in with,
+	// otherwise the custodial account address
+	Address string
+	Status  UserStatus
+	// DerivationIndex is the user's index in the exchange HD wallet, deposit addresses are derived from it.
+	// Zero means the user's keys are not derived from the exchange wallet.
+	DerivationIndex uint32
 }
 
-// NewUser creates a new user with the given private key and ID
-func NewUser(privKey string, userID uint64) (*User, error) {
-	if privKey == "" {
-		return nil, errors.New("private key cannot be empty")
-	}
-
-	pk, err := crypto.HexToECDSA(privKey)
-	if err != nil {
-		return nil, err
+// NewUser creates a new user with the given signer and ID
+func NewUser(s signer.Signer, userID uint64) (*User, error) {
+	if s == nil {
+		return nil, errors.New("signer cannot be nil")
 	}
 
-	publicKey := pk.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return nil, errors.New("failed to convert public key to ECDSA")
-	}
-
-	address := crypto.PubkeyToAddress(*publicKeyECDSA).Hex()
-
 	return &User{
-		ID:         userID,
-		PrivateKey: pk,
-		Address:    address,
+		ID:      userID,
+		Signer:  s,
+		Address: s.Address().Hex(),
+		Status:  UserActive,
 	}, nil
 }
 
@@ -44,3 +50,8 @@ func NewUser(privKey string, userID uint64) (*User, error) {
 func (u *User) GetAddress() string {
 	return u.Address
 }
+
+// IsActive reports whether the user is allowed to trade
+func (u *User) IsActive() bool {
+	return u.Status == UserActive
+}
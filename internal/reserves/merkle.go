@@ -0,0 +1,176 @@
+package reserves
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Node is a node of the Merkle sum tree: a hash committing to the node's children and the sum of their balances.
+type Node struct {
+	Hash string  `json:"hash"`
+	Sum  float64 `json:"sum"`
+}
+
+// ProofStep is a sibling on the path from a leaf to the root. Left tells if the sibling is the left child.
+type ProofStep struct {
+	Hash string  `json:"hash"`
+	Sum  float64 `json:"sum"`
+	Left bool    `json:"left"`
+}
+
+// Proof proves that a user's balance is included in the tree with the given root
+type Proof struct {
+	Asset   string      `json:"asset"`
+	UserID  uint64      `json:"user_id"`
+	Balance float64     `json:"balance"`
+	Path    []ProofStep `json:"path"`
+	Root    Node        `json:"root"`
+}
+
+// Tree is a Merkle sum tree of user balances of a single asset. Every parent commits to the hashes and sums of both
+// children, so a user who verifies their proof knows their balance is counted in the published total.
+type Tree struct {
+	asset  string
+	levels [][]Node // levels[0] are the leaves, the last level is the root
+	leaves map[uint64]int
+}
+
+// BuildTree builds the tree from the balances of every user, leaves are ordered by user ID
+func BuildTree(asset string, balances map[uint64]float64) *Tree {
+	userIDs := make([]uint64, 0, len(balances))
+	for userID := range balances {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	t := &Tree{
+		asset:  asset,
+		leaves: make(map[uint64]int),
+	}
+
+	level := make([]Node, len(userIDs))
+	for i, userID := range userIDs {
+		level[i] = Node{Hash: leafHash(asset, userID, balances[userID]), Sum: balances[userID]}
+		t.leaves[userID] = i
+	}
+	if len(level) == 0 {
+		level = append(level, emptyNode())
+	}
+	t.levels = append(t.levels, level)
+
+	for len(level) > 1 {
+		var next []Node
+		for i := 0; i < len(level); i += 2 {
+			right := emptyNode()
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, parent(level[i], right))
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+
+	return t
+}
+
+// Asset returns the asset the tree was built for
+func (t *Tree) Asset() string {
+	return t.asset
+}
+
+// Root returns the root of the tree, its sum is the total liabilities for the asset
+func (t *Tree) Root() Node {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Proof returns the inclusion proof for the user's balance
+func (t *Tree) Proof(userID uint64) (*Proof, error) {
+	index, ok := t.leaves[userID]
+	if !ok {
+		return nil, errors.New("user is not included in the tree")
+	}
+
+	proof := &Proof{
+		Asset:   t.asset,
+		UserID:  userID,
+		Balance: t.levels[0][index].Sum,
+		Root:    t.Root(),
+	}
+
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := emptyNode()
+		isRight := index%2 == 1
+		if isRight {
+			sibling = level[index-1]
+		} else if index+1 < len(level) {
+			sibling = level[index+1]
+		}
+
+		proof.Path = append(proof.Path, ProofStep{Hash: sibling.Hash, Sum: sibling.Sum, Left: isRight})
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// Verify recomputes the root from the leaf and the path and checks it against the proof root
+func (p *Proof) Verify() bool {
+	node := Node{Hash: leafHash(p.Asset, p.UserID, p.Balance), Sum: p.Balance}
+
+	for _, step := range p.Path {
+		if step.Sum < 0 {
+			return false
+		}
+
+		sibling := Node{Hash: step.Hash, Sum: step.Sum}
+		if step.Left {
+			node = parent(sibling, node)
+		} else {
+			node = parent(node, sibling)
+		}
+	}
+
+	return node == p.Root
+}
+
+func leafHash(asset string, userID uint64, balance float64) string {
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, userID)
+
+	h := sha256.New()
+	h.Write([]byte("leaf:" + asset + ":"))
+	h.Write(id)
+	h.Write([]byte(strconv.FormatFloat(balance, 'f', -1, 64)))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func parent(left, right Node) Node {
+	h := sha256.New()
+	h.Write([]byte("node:"))
+	h.Write([]byte(left.Hash))
+	h.Write(sumBytes(left.Sum))
+	h.Write([]byte(right.Hash))
+	h.Write(sumBytes(right.Sum))
+
+	return Node{
+		Hash: hex.EncodeToString(h.Sum(nil)),
+		Sum:  left.Sum + right.Sum,
+	}
+}
+
+func emptyNode() Node {
+	return Node{Hash: hex.EncodeToString(make([]byte, sha256.Size)), Sum: 0}
+}
+
+func sumBytes(sum float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(sum))
+	return b
+}
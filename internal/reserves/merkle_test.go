@@ -0,0 +1,49 @@
+package reserves
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTree(t *testing.T) {
+	balances := map[uint64]float64{1: 10, 2: 5.5, 3: 0.25, 7: 100, 8: 1}
+	tree := BuildTree("ETH", balances)
+
+	// Test case 1: root sum is the total liabilities
+	require.Equal(t, 116.75, tree.Root().Sum)
+
+	// Test case 2: every user's proof verifies against the root
+	for userID, balance := range balances {
+		proof, err := tree.Proof(userID)
+		require.NoError(t, err)
+		require.Equal(t, balance, proof.Balance)
+		require.True(t, proof.Verify())
+	}
+
+	// Test case 3: a tampered balance or sibling sum does not verify
+	proof, err := tree.Proof(2)
+	require.NoError(t, err)
+	proof.Balance = 6
+	require.False(t, proof.Verify())
+
+	proof, err = tree.Proof(2)
+	require.NoError(t, err)
+	proof.Path[0].Sum = 0
+	require.False(t, proof.Verify())
+
+	// Test case 4: unknown users have no proof
+	_, err = tree.Proof(666)
+	require.Error(t, err)
+}
+
+func TestBuildTree_Empty(t *testing.T) {
+	tree := BuildTree("ETH", map[uint64]float64{})
+	require.Equal(t, 0.0, tree.Root().Sum)
+
+	tree = BuildTree("ETH", map[uint64]float64{1: 3})
+	proof, err := tree.Proof(1)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(proof.Path))
+	require.True(t, proof.Verify())
+}
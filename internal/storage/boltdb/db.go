@@ -0,0 +1,153 @@
+// Package boltdb implements the storage repositories and the trade store on an embedded bbolt database file
+package boltdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	bucketMeta        = []byte("meta")
+	bucketUsers       = []byte("users")
+	bucketOrders      = []byte("orders")
+	bucketFills       = []byte("fills")
+	bucketBalances    = []byte("balances")
+	bucketSettlements = []byte("settlements")
+	bucketTrades      = []byte("trades")
+	bucketAPIKeys     = []byte("api_keys")
+
+	keySchemaVersion = []byte("schema_version")
+)
+
+// migration changes the schema of the database, migrations run in order and each one only once
+type migration struct {
+	name  string
+	apply func(tx *bbolt.Tx) error
+}
+
+// migrations is the schema history, new migrations are appended and existing ones never change
+var migrations = []migration{
+	{
+		name: "create buckets",
+		apply: func(tx *bbolt.Tx) error {
+			for _, name := range [][]byte{bucketUsers, bucketOrders, bucketFills, bucketBalances, bucketSettlements, bucketTrades} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name: "create api keys bucket",
+		apply: func(tx *bbolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(bucketAPIKeys)
+			return err
+		},
+	},
+}
+
+// DB is a bbolt database holding the exchange state, it implements storage.Repository and trades.Store
+type DB struct {
+	db *bbolt.DB
+}
+
+// Open opens the database file at path, creating it if needed, and migrates it to the latest schema
+func Open(path string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{db: db}, nil
+}
+
+// migrate applies the migrations the database has not seen yet in a single transaction
+func migrate(db *bbolt.DB) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+
+		var version uint64
+		if v := meta.Get(keySchemaVersion); v != nil {
+			version = binary.BigEndian.Uint64(v)
+		}
+		if version > uint64(len(migrations)) {
+			return fmt.Errorf("database schema version %d is newer than this binary supports", version)
+		}
+
+		for i := version; i < uint64(len(migrations)); i++ {
+			if err := migrations[i].apply(tx); err != nil {
+				return fmt.Errorf("failed to apply migration %d (%s): %w", i+1, migrations[i].name, err)
+			}
+		}
+
+		return meta.Put(keySchemaVersion, itob(uint64(len(migrations))))
+	})
+}
+
+// SchemaVersion returns the number of migrations applied to the database
+func (d *DB) SchemaVersion() (uint64, error) {
+	var version uint64
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		if v := tx.Bucket(bucketMeta).Get(keySchemaVersion); v != nil {
+			version = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return version, err
+}
+
+// Close closes the database file
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// put stores value as JSON under key in the bucket
+func put(tx *bbolt.Tx, bucket, key []byte, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put(key, data)
+}
+
+// get reads the JSON value under key in the bucket into value, reporting whether it exists
+func get(tx *bbolt.Tx, bucket, key []byte, value interface{}) (bool, error) {
+	data := tx.Bucket(bucket).Get(key)
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, value)
+}
+
+// each decodes every value of the bucket in key order with decode
+func each(tx *bbolt.Tx, bucket []byte, decode func(data []byte) error) error {
+	return tx.Bucket(bucket).ForEach(func(_, data []byte) error {
+		return decode(data)
+	})
+}
+
+// itob encodes an ID as a key that sorts in numeric order
+func itob(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
@@ -0,0 +1,221 @@
+package boltdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+func openTestDB(t *testing.T) (*DB, string) {
+	path := filepath.Join(t.TempDir(), "data", "exchange.db")
+	db, err := Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, path
+}
+
+func TestMigrations(t *testing.T) {
+	db, path := openTestDB(t)
+
+	// Test case 1: a new database is migrated to the latest schema
+	version, err := db.SchemaVersion()
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(migrations)), version)
+
+	// Test case 2: reopening a migrated database keeps its data
+	require.NoError(t, db.SaveUser(&storage.User{ID: 1, Status: "ACTIVE"}))
+	require.NoError(t, db.Close())
+	db, err = Open(path)
+	require.NoError(t, err)
+	_, err = db.GetUser(1)
+	require.NoError(t, err)
+
+	// Test case 3: a schema newer than the binary is refused
+	require.NoError(t, db.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, itob(uint64(len(migrations)+1)))
+	}))
+	require.NoError(t, db.Close())
+	_, err = Open(path)
+	require.Error(t, err)
+}
+
+func TestRepository(t *testing.T) {
+	db, _ := openTestDB(t)
+
+	// Test case 1: users are created, replaced and listed by ID
+	require.NoError(t, db.SaveUser(&storage.User{ID: 2, Status: "ACTIVE", DerivationIndex: 2}))
+	require.NoError(t, db.SaveUser(&storage.User{ID: 1, Status: "ACTIVE", DerivationIndex: 1}))
+	require.NoError(t, db.SaveUser(&storage.User{ID: 1, Status: "FROZEN", DerivationIndex: 1, FeeSchedule: "MARKET_MAKER"}))
+
+	users, err := db.ListUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, &storage.User{ID: 1, Status: "FROZEN", DerivationIndex: 1, FeeSchedule: "MARKET_MAKER"}, users[0])
+
+	_, err = db.GetUser(7)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Test case 2: orders keep their latest state and are listed oldest first
+	require.NoError(t, db.SaveOrder(&storage.Order{ID: 900, UserID: 1, Status: "OPEN", Amount: 2, CreatedAt: 20}))
+	require.NoError(t, db.SaveOrder(&storage.Order{ID: 5, UserID: 1, Status: "OPEN", Amount: 1, CreatedAt: 30}))
+	require.NoError(t, db.SaveOrder(&storage.Order{ID: 900, UserID: 1, Status: "FILLED", Amount: 0, FilledAmount: 2, CreatedAt: 20, ClosedAt: 40}))
+
+	orders, err := db.ListOrders()
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, uint64(900), orders[0].ID)
+	require.Equal(t, "FILLED", orders[0].Status)
+
+	order, err := db.GetOrder(5)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, order.Amount)
+	_, err = db.GetOrder(6)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Test case 3: fills are stored together and listed by ID
+	require.NoError(t, db.SaveFills(&storage.Fill{ID: 1, TradeID: 1, UserID: 2}, &storage.Fill{ID: 2, TradeID: 1, UserID: 1}))
+	fills, err := db.ListFills()
+	require.NoError(t, err)
+	require.Len(t, fills, 2)
+	require.Equal(t, uint64(2), fills[1].ID)
+
+	// Test case 4: balances are kept per user and asset, every save increments the version
+	eth := &storage.Balance{UserID: 1, Asset: "ETH", Balance: 10}
+	require.NoError(t, db.SaveBalance(eth))
+	require.NoError(t, db.SaveBalance(&storage.Balance{UserID: 1, Asset: "BTC", Balance: 1}))
+	eth.Balance = 7
+	require.NoError(t, db.SaveBalance(eth))
+	require.Equal(t, uint64(2), eth.Version)
+
+	balances, err := db.ListBalances()
+	require.NoError(t, err)
+	require.Equal(t, []*storage.Balance{{UserID: 1, Asset: "BTC", Balance: 1, Version: 1}, {UserID: 1, Asset: "ETH", Balance: 7, Version: 2}}, balances)
+
+	// Test case 5: a stale version is a conflict
+	err = db.SaveBalance(&storage.Balance{UserID: 1, Asset: "ETH", Balance: 3, Version: 1})
+	require.ErrorIs(t, err, storage.ErrConflict)
+
+	// Test case 6: settlements get sequential IDs and are paged by ID
+	for i := 0; i < 3; i++ {
+		settlement := &storage.Settlement{Market: "ETH", Amount: float64(i + 1)}
+		require.NoError(t, db.SaveSettlement(settlement))
+		require.Equal(t, uint64(i+1), settlement.ID)
+	}
+
+	settlements, err := db.ListSettlements(2, 5)
+	require.NoError(t, err)
+	require.Len(t, settlements, 2)
+	require.Equal(t, 2.0, settlements[0].Amount)
+
+	// Test case 7: API keys are created, replaced, deleted and listed by creation time
+	require.NoError(t, db.SaveAPIKey(&storage.APIKey{Key: "b", SecretHash: "hb", UserID: 1, Permissions: []string{"read"}, CreatedAt: 2}))
+	require.NoError(t, db.SaveAPIKey(&storage.APIKey{Key: "c", SecretHash: "hc", UserID: 2, Permissions: []string{"trade"}, CreatedAt: 3}))
+	require.NoError(t, db.SaveAPIKey(&storage.APIKey{Key: "a", SecretHash: "ha", UserID: 1, Permissions: []string{"trade"}, CreatedAt: 1}))
+	require.NoError(t, db.SaveAPIKey(&storage.APIKey{Key: "a", SecretHash: "ha", UserID: 1, Permissions: []string{"trade", "withdraw"}, CreatedAt: 1}))
+	require.NoError(t, db.DeleteAPIKey("c"))
+	require.NoError(t, db.DeleteAPIKey("missing"))
+
+	apiKeys, err := db.ListAPIKeys()
+	require.NoError(t, err)
+	require.Equal(t, []*storage.APIKey{
+		{Key: "a", SecretHash: "ha", UserID: 1, Permissions: []string{"trade", "withdraw"}, CreatedAt: 1},
+		{Key: "b", SecretHash: "hb", UserID: 1, Permissions: []string{"read"}, CreatedAt: 2},
+	}, apiKeys)
+}
+
+func TestSaveBatch(t *testing.T) {
+	db, _ := openTestDB(t)
+
+	seller := &storage.Balance{UserID: 1, Asset: "ETH", Balance: 10}
+	require.NoError(t, db.SaveBalance(seller))
+
+	// Test case 1: the changes of a match batch are written together
+	seller.Balance = 8
+	buyer := &storage.Balance{UserID: 2, Asset: "ETH", Balance: 2}
+	settlement := &storage.Settlement{Market: "ETH", Amount: 2, BuyerID: 2, SellerID: 1}
+	require.NoError(t, db.SaveBatch(&storage.Batch{
+		Orders:      []*storage.Order{{ID: 7, UserID: 1, Status: "FILLED"}},
+		Fills:       []*storage.Fill{{ID: 1, UserID: 2}, {ID: 2, UserID: 1}},
+		Balances:    []*storage.Balance{seller, buyer},
+		Settlements: []*storage.Settlement{settlement},
+	}))
+	require.Equal(t, uint64(2), seller.Version)
+	require.Equal(t, uint64(1), buyer.Version)
+	require.Equal(t, uint64(1), settlement.ID)
+
+	// Test case 2: a stale balance rolls the whole batch back
+	stale := &storage.Balance{UserID: 1, Asset: "ETH", Balance: 5, Version: 1}
+	err := db.SaveBatch(&storage.Batch{
+		Orders:      []*storage.Order{{ID: 8, UserID: 1}},
+		Fills:       []*storage.Fill{{ID: 3, UserID: 1}},
+		Balances:    []*storage.Balance{{UserID: 3, Asset: "ETH", Balance: 1}, stale},
+		Settlements: []*storage.Settlement{{Market: "ETH"}},
+	})
+	require.ErrorIs(t, err, storage.ErrConflict)
+	require.Equal(t, uint64(1), stale.Version)
+
+	_, err = db.GetOrder(8)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	fills, err := db.ListFills()
+	require.NoError(t, err)
+	require.Len(t, fills, 2)
+	balances, err := db.ListBalances()
+	require.NoError(t, err)
+	require.Len(t, balances, 2)
+	require.Equal(t, 8.0, balances[0].Balance)
+	settlements, err := db.ListSettlements(0, 10)
+	require.NoError(t, err)
+	require.Len(t, settlements, 1)
+}
+
+func TestTradeStore(t *testing.T) {
+	db, _ := openTestDB(t)
+
+	for id := uint64(1); id <= 5; id++ {
+		require.NoError(t, db.Append(&trades.Trade{ID: id, Market: "ETH", Price: 100, Size: 1, TakerSide: trades.SideBuy, Timestamp: int64(id * 10)}))
+	}
+
+	// Test case 1: trades must be appended in ID order
+	require.Error(t, db.Append(&trades.Trade{ID: 3, Market: "ETH"}))
+
+	lastID, err := db.LastID("ETH")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), lastID)
+	lastID, err = db.LastID("BTC")
+	require.NoError(t, err)
+	require.Zero(t, lastID)
+
+	// Test case 2: the latest trades, trades from an ID and trades in a time range, oldest first
+	got, err := db.Query(trades.Query{Market: "ETH", Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{4, 5}, tradeIDs(got))
+
+	got, err = db.Query(trades.Query{Market: "ETH", FromID: 2, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3}, tradeIDs(got))
+
+	got, err = db.Query(trades.Query{Market: "ETH", StartTime: 25, EndTime: 40})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 4}, tradeIDs(got))
+
+	got, err = db.Query(trades.Query{Market: "ETH", EndTime: 20})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2}, tradeIDs(got))
+
+	// Test case 3: contradicting bounds are rejected
+	_, err = db.Query(trades.Query{Market: "ETH", StartTime: 40, EndTime: 20})
+	require.ErrorIs(t, err, trades.ErrInvalidQuery)
+}
+
+func tradeIDs(trades []*trades.Trade) []uint64 {
+	ids := make([]uint64, len(trades))
+	for i, t := range trades {
+		ids[i] = t.ID
+	}
+	return ids
+}
@@ -0,0 +1,285 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage"
+)
+
+var _ storage.Repository = (*DB)(nil)
+
+// SaveUser creates or replaces the user
+func (d *DB) SaveUser(user *storage.User) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, bucketUsers, itob(user.ID), user)
+	})
+}
+
+// GetUser returns the user
+func (d *DB) GetUser(userID uint64) (*storage.User, error) {
+	var user storage.User
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		found, err := get(tx, bucketUsers, itob(userID), &user)
+		if err == nil && !found {
+			err = fmt.Errorf("%w: user %d", storage.ErrNotFound, userID)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns every user ordered by ID
+func (d *DB) ListUsers() ([]*storage.User, error) {
+	users := []*storage.User{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		return each(tx, bucketUsers, func(data []byte) error {
+			var user storage.User
+			if err := json.Unmarshal(data, &user); err != nil {
+				return err
+			}
+			users = append(users, &user)
+			return nil
+		})
+	})
+	return users, err
+}
+
+// SaveAPIKey creates or replaces the API key
+func (d *DB) SaveAPIKey(apiKey *storage.APIKey) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, bucketAPIKeys, []byte(apiKey.Key), apiKey)
+	})
+}
+
+// DeleteAPIKey removes the API key
+func (d *DB) DeleteAPIKey(key string) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketAPIKeys).Delete([]byte(key))
+	})
+}
+
+// ListAPIKeys returns every API key ordered by creation time
+func (d *DB) ListAPIKeys() ([]*storage.APIKey, error) {
+	apiKeys := []*storage.APIKey{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		return each(tx, bucketAPIKeys, func(data []byte) error {
+			var apiKey storage.APIKey
+			if err := json.Unmarshal(data, &apiKey); err != nil {
+				return err
+			}
+			apiKeys = append(apiKeys, &apiKey)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are stored by key, which is random
+	sort.SliceStable(apiKeys, func(i, j int) bool { return apiKeys[i].CreatedAt < apiKeys[j].CreatedAt })
+	return apiKeys, nil
+}
+
+// SaveOrder creates or replaces the order
+func (d *DB) SaveOrder(order *storage.Order) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, bucketOrders, itob(order.ID), order)
+	})
+}
+
+// GetOrder returns the order
+func (d *DB) GetOrder(orderID uint64) (*storage.Order, error) {
+	var order storage.Order
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		found, err := get(tx, bucketOrders, itob(orderID), &order)
+		if err == nil && !found {
+			err = fmt.Errorf("%w: order %d", storage.ErrNotFound, orderID)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ListOrders returns every order, oldest first
+func (d *DB) ListOrders() ([]*storage.Order, error) {
+	orders := []*storage.Order{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		return each(tx, bucketOrders, func(data []byte) error {
+			var order storage.Order
+			if err := json.Unmarshal(data, &order); err != nil {
+				return err
+			}
+			orders = append(orders, &order)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Orders are listed oldest first, like in the other repositories
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].CreatedAt < orders[j].CreatedAt })
+	return orders, nil
+}
+
+// SaveFills stores the fills in one transaction
+func (d *DB) SaveFills(fills ...*storage.Fill) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		for _, fill := range fills {
+			if err := put(tx, bucketFills, itob(fill.ID), fill); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListFills returns every fill ordered by ID
+func (d *DB) ListFills() ([]*storage.Fill, error) {
+	fills := []*storage.Fill{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		return each(tx, bucketFills, func(data []byte) error {
+			var fill storage.Fill
+			if err := json.Unmarshal(data, &fill); err != nil {
+				return err
+			}
+			fills = append(fills, &fill)
+			return nil
+		})
+	})
+	return fills, err
+}
+
+// SaveBalance creates or replaces the balance of the user in the asset if its version is current
+func (d *DB) SaveBalance(balance *storage.Balance) error {
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		return saveBalance(tx, balance)
+	})
+	if err != nil {
+		return err
+	}
+
+	balance.Version++
+	return nil
+}
+
+// saveBalance writes the balance with the next version, failing with storage.ErrConflict when the stored version
+// differs from the balance's
+func saveBalance(tx *bbolt.Tx, balance *storage.Balance) error {
+	key := balanceKey(balance.UserID, balance.Asset)
+
+	var stored storage.Balance
+	if _, err := get(tx, bucketBalances, key, &stored); err != nil {
+		return err
+	}
+	if stored.Version != balance.Version {
+		return fmt.Errorf("%w: %s balance of user %d is at version %d, not %d", storage.ErrConflict, balance.Asset,
+			balance.UserID, stored.Version, balance.Version)
+	}
+
+	next := *balance
+	next.Version++
+	return put(tx, bucketBalances, key, &next)
+}
+
+// ListBalances returns every balance ordered by user and asset
+func (d *DB) ListBalances() ([]*storage.Balance, error) {
+	balances := []*storage.Balance{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		return each(tx, bucketBalances, func(data []byte) error {
+			var balance storage.Balance
+			if err := json.Unmarshal(data, &balance); err != nil {
+				return err
+			}
+			balances = append(balances, &balance)
+			return nil
+		})
+	})
+	return balances, err
+}
+
+// SaveSettlement stores the settlement with the next settlement ID
+func (d *DB) SaveSettlement(settlement *storage.Settlement) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return saveSettlement(tx, settlement)
+	})
+}
+
+func saveSettlement(tx *bbolt.Tx, settlement *storage.Settlement) error {
+	id, err := tx.Bucket(bucketSettlements).NextSequence()
+	if err != nil {
+		return err
+	}
+	settlement.ID = id
+	return put(tx, bucketSettlements, itob(id), settlement)
+}
+
+// ListSettlements returns up to limit settlements from the ID on, ordered by ID
+func (d *DB) ListSettlements(fromID uint64, limit int) ([]*storage.Settlement, error) {
+	settlements := []*storage.Settlement{}
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucketSettlements).Cursor()
+		for k, data := c.Seek(itob(fromID)); k != nil && len(settlements) < limit; k, data = c.Next() {
+			var settlement storage.Settlement
+			if err := json.Unmarshal(data, &settlement); err != nil {
+				return err
+			}
+			settlements = append(settlements, &settlement)
+		}
+		return nil
+	})
+	return settlements, err
+}
+
+// SaveBatch writes the batch in one transaction
+func (d *DB) SaveBatch(batch *storage.Batch) error {
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		for _, order := range batch.Orders {
+			if err := put(tx, bucketOrders, itob(order.ID), order); err != nil {
+				return err
+			}
+		}
+		for _, fill := range batch.Fills {
+			if err := put(tx, bucketFills, itob(fill.ID), fill); err != nil {
+				return err
+			}
+		}
+		for _, balance := range batch.Balances {
+			if err := saveBalance(tx, balance); err != nil {
+				return err
+			}
+		}
+		for _, settlement := range batch.Settlements {
+			if err := saveSettlement(tx, settlement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, settlement := range batch.Settlements {
+			settlement.ID = 0
+		}
+		return err
+	}
+
+	for _, balance := range batch.Balances {
+		balance.Version++
+	}
+	return nil
+}
+
+// balanceKey sorts balances by user, then asset
+func balanceKey(userID uint64, asset string) []byte {
+	return append(itob(userID), asset...)
+}
@@ -0,0 +1,99 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+var _ trades.Store = (*DB)(nil)
+
+// Append stores the trade in the bucket of its market
+func (d *DB) Append(trade *trades.Trade) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		market, err := tx.Bucket(bucketTrades).CreateBucketIfNotExists([]byte(trade.Market))
+		if err != nil {
+			return err
+		}
+
+		if last, _ := market.Cursor().Last(); last != nil && binary.BigEndian.Uint64(last) >= trade.ID {
+			return fmt.Errorf("trade %d of %s is not after the last stored trade %d", trade.ID, trade.Market, binary.BigEndian.Uint64(last))
+		}
+
+		data, err := json.Marshal(trade)
+		if err != nil {
+			return err
+		}
+		return market.Put(itob(trade.ID), data)
+	})
+}
+
+// Query returns the trades matching the query, oldest first
+func (d *DB) Query(q trades.Query) ([]*trades.Trade, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*trades.Trade{}
+	err = d.db.View(func(tx *bbolt.Tx) error {
+		market := tx.Bucket(bucketTrades).Bucket([]byte(q.Market))
+		if market == nil {
+			return nil
+		}
+		c := market.Cursor()
+
+		// Without a starting point the latest trades are collected backwards
+		if q.FromID == 0 && q.StartTime == 0 {
+			for k, data := c.Last(); k != nil && len(result) < q.Limit; k, data = c.Prev() {
+				var t trades.Trade
+				if err := json.Unmarshal(data, &t); err != nil {
+					return err
+				}
+				if q.EndTime == 0 || t.Timestamp <= q.EndTime {
+					result = append(result, &t)
+				}
+			}
+			for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+				result[i], result[j] = result[j], result[i]
+			}
+			return nil
+		}
+
+		for k, data := c.Seek(itob(q.FromID)); k != nil && len(result) < q.Limit; k, data = c.Next() {
+			var t trades.Trade
+			if err := json.Unmarshal(data, &t); err != nil {
+				return err
+			}
+			if q.EndTime != 0 && t.Timestamp > q.EndTime {
+				break
+			}
+			if t.Timestamp >= q.StartTime {
+				result = append(result, &t)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// LastID returns the ID of the latest stored trade of the market
+func (d *DB) LastID(market string) (uint64, error) {
+	var id uint64
+	err := d.db.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket(bucketTrades).Bucket([]byte(market)); b != nil {
+			if last, _ := b.Cursor().Last(); last != nil {
+				id = binary.BigEndian.Uint64(last)
+			}
+		}
+		return nil
+	})
+	return id, err
+}
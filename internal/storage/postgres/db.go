@@ -0,0 +1,142 @@
+// Package postgres implements the storage repositories and the trade store on a PostgreSQL database
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+
+	_ "github.com/lib/pq"
+)
+
+// migrationFiles is the schema history, one SQL file per migration applied in file name order. New migrations are
+// added as new files and existing ones never change.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLock is the advisory lock key that serializes exchange binaries migrating the same database
+const migrationLock = 7316001
+
+// DB is a PostgreSQL database holding the exchange state, it implements storage.Repository and trades.Store
+type DB struct {
+	db *sql.DB
+}
+
+// Open connects to the database at dsn and migrates it to the latest schema. The dsn is a postgres:// URL or a
+// key=value connection string.
+func Open(dsn string) (*DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{db: db}, nil
+}
+
+// migrations returns the names of the migration files in the order they are applied
+func migrations() ([]string, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// migrate applies the migrations the database has not seen yet in a single transaction. The applied migrations are
+// recorded in schema_migrations, an advisory lock keeps concurrently starting binaries from migrating twice.
+func migrate(db *sql.DB) error {
+	names, err := migrations()
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	return inTx(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+		if err != nil {
+			return err
+		}
+
+		var version int
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+			return err
+		}
+		if version > len(names) {
+			return fmt.Errorf("database schema version %d is newer than this binary supports", version)
+		}
+
+		for i := version; i < len(names); i++ {
+			script, err := migrationFiles.ReadFile("migrations/" + names[i])
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(string(script)); err != nil {
+				return fmt.Errorf("failed to apply migration %d (%s): %w", i+1, names[i], err)
+			}
+			if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, i+1, names[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// SchemaVersion returns the number of migrations applied to the database
+func (d *DB) SchemaVersion() (uint64, error) {
+	var version uint64
+	err := d.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// Close closes the connections to the database
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// execer runs statements on the database or in a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction, committing it when fn succeeds and rolling it back otherwise
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
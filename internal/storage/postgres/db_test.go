@@ -0,0 +1,324 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+// dsnEnv names a server the tests create their databases on, the role needs CREATEDB. Without it the tests start a
+// throwaway cluster with the initdb and pg_ctl found on PATH, and are skipped when there are none.
+const dsnEnv = "EXCHANGE_TEST_POSTGRES_DSN"
+
+var (
+	// serverDSN connects to the test server, empty when there is none
+	serverDSN string
+	// skipReason tells why there is no test server
+	skipReason string
+	databases  uint64
+)
+
+func TestMain(m *testing.M) {
+	stop := startServer()
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+// startServer finds or starts the test server and returns the function that stops it
+func startServer() func() {
+	if dsn := os.Getenv(dsnEnv); dsn != "" {
+		if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+			converted, err := pq.ParseURL(dsn)
+			if err != nil {
+				skipReason = fmt.Sprintf("invalid %s: %v", dsnEnv, err)
+				return func() {}
+			}
+			dsn = converted
+		}
+		serverDSN = dsn
+		return func() {}
+	}
+
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		skipReason = fmt.Sprintf("no PostgreSQL server, set %s or put initdb and pg_ctl on PATH", dsnEnv)
+		return func() {}
+	}
+	if os.Geteuid() == 0 {
+		skipReason = "initdb refuses to run as root, set " + dsnEnv
+		return func() {}
+	}
+	pgCtl := filepath.Join(filepath.Dir(initdb), "pg_ctl")
+
+	// The server only listens on a unix socket in its data directory, so it never clashes with another server
+	dir, err := os.MkdirTemp("", "pg")
+	if err != nil {
+		skipReason = err.Error()
+		return func() {}
+	}
+	data := filepath.Join(dir, "data")
+	if out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "--auth=trust").CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		skipReason = fmt.Sprintf("initdb failed: %v\n%s", err, out)
+		return func() {}
+	}
+	options := fmt.Sprintf("-c listen_addresses='' -k %s", dir)
+	start := exec.Command(pgCtl, "-D", data, "-o", options, "-l", filepath.Join(dir, "log"), "-w", "start")
+	if out, err := start.CombinedOutput(); err != nil {
+		os.RemoveAll(dir)
+		skipReason = fmt.Sprintf("pg_ctl start failed: %v\n%s", err, out)
+		return func() {}
+	}
+
+	serverDSN = fmt.Sprintf("host=%s user=postgres dbname=postgres sslmode=disable", dir)
+	return func() {
+		exec.Command(pgCtl, "-D", data, "-m", "fast", "-w", "stop").Run()
+		os.RemoveAll(dir)
+	}
+}
+
+// openTestDB opens a new empty database on the test server and returns it with its DSN
+func openTestDB(t *testing.T) (*DB, string) {
+	if serverDSN == "" {
+		t.Skip(skipReason)
+	}
+
+	server, err := sql.Open("postgres", serverDSN)
+	require.NoError(t, err)
+	defer server.Close()
+
+	name := fmt.Sprintf("exchange_test_%d_%d", os.Getpid(), atomic.AddUint64(&databases, 1))
+	_, err = server.Exec("CREATE DATABASE " + name)
+	require.NoError(t, err)
+
+	// Later keys of a connection string override earlier ones
+	dsn := serverDSN + " dbname=" + name
+	db, err := Open(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+		if server, err := sql.Open("postgres", serverDSN); err == nil {
+			server.Exec("DROP DATABASE IF EXISTS " + name)
+			server.Close()
+		}
+	})
+	return db, dsn
+}
+
+func TestMigrations(t *testing.T) {
+	db, dsn := openTestDB(t)
+	names, err := migrations()
+	require.NoError(t, err)
+
+	// Test case 1: a new database is migrated to the latest schema
+	version, err := db.SchemaVersion()
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(names)), version)
+
+	// Test case 2: reopening a migrated database keeps its data
+	require.NoError(t, db.SaveUser(&storage.User{ID: 1, Status: "ACTIVE"}))
+	reopened, err := Open(dsn)
+	require.NoError(t, err)
+	_, err = reopened.GetUser(1)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Close())
+
+	// Test case 3: a schema newer than the binary is refused
+	_, err = db.db.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, 'future')`, len(names)+1)
+	require.NoError(t, err)
+	_, err = Open(dsn)
+	require.Error(t, err)
+}
+
+func TestRepository(t *testing.T) {
+	db, _ := openTestDB(t)
+
+	// Test case 1: users are created, replaced and listed by ID
+	require.NoError(t, db.SaveUser(&storage.User{ID: 2, Status: "ACTIVE", DerivationIndex: 2}))
+	require.NoError(t, db.SaveUser(&storage.User{ID: 1, Status: "ACTIVE", DerivationIndex: 1}))
+	require.NoError(t, db.SaveUser(&storage.User{ID: 1, Status: "FROZEN", DerivationIndex: 1, FeeSchedule: "MARKET_MAKER"}))
+
+	users, err := db.ListUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, &storage.User{ID: 1, Status: "FROZEN", DerivationIndex: 1, FeeSchedule: "MARKET_MAKER"}, users[0])
+
+	_, err = db.GetUser(7)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Test case 2: orders keep their latest state and are listed oldest first
+	require.NoError(t, db.SaveOrder(&storage.Order{ID: 900, UserID: 1, Status: "OPEN", Amount: 2, CreatedAt: 20}))
+	require.NoError(t, db.SaveOrder(&storage.Order{ID: 5, UserID: 1, Status: "OPEN", Amount: 1, CreatedAt: 30}))
+	require.NoError(t, db.SaveOrder(&storage.Order{ID: 900, UserID: 1, Status: "FILLED", Amount: 0, FilledAmount: 2, CreatedAt: 20, ClosedAt: 40}))
+
+	orders, err := db.ListOrders()
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	require.Equal(t, uint64(900), orders[0].ID)
+	require.Equal(t, "FILLED", orders[0].Status)
+
+	order, err := db.GetOrder(5)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, order.Amount)
+	_, err = db.GetOrder(6)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Test case 3: fills are stored together and listed by ID
+	require.NoError(t, db.SaveFills(&storage.Fill{ID: 1, TradeID: 1, UserID: 2}, &storage.Fill{ID: 2, TradeID: 1, UserID: 1}))
+	fills, err := db.ListFills()
+	require.NoError(t, err)
+	require.Len(t, fills, 2)
+	require.Equal(t, uint64(2), fills[1].ID)
+
+	// Test case 4: balances are kept per user and asset, every save increments the version
+	eth := &storage.Balance{UserID: 1, Asset: "ETH", Balance: 10}
+	require.NoError(t, db.SaveBalance(eth))
+	require.NoError(t, db.SaveBalance(&storage.Balance{UserID: 1, Asset: "BTC", Balance: 1}))
+	eth.Balance = 7
+	require.NoError(t, db.SaveBalance(eth))
+	require.Equal(t, uint64(2), eth.Version)
+
+	balances, err := db.ListBalances()
+	require.NoError(t, err)
+	require.Equal(t, []*storage.Balance{{UserID: 1, Asset: "BTC", Balance: 1, Version: 1}, {UserID: 1, Asset: "ETH", Balance: 7, Version: 2}}, balances)
+
+	// Test case 5: a stale version is a conflict, so is creating a balance twice
+	err = db.SaveBalance(&storage.Balance{UserID: 1, Asset: "ETH", Balance: 3, Version: 1})
+	require.ErrorIs(t, err, storage.ErrConflict)
+	err = db.SaveBalance(&storage.Balance{UserID: 1, Asset: "ETH", Balance: 3})
+	require.ErrorIs(t, err, storage.ErrConflict)
+
+	// Test case 6: settlements get sequential IDs and are paged by ID
+	for i := 0; i < 3; i++ {
+		settlement := &storage.Settlement{Market: "ETH", Amount: float64(i + 1)}
+		require.NoError(t, db.SaveSettlement(settlement))
+		require.Equal(t, uint64(i+1), settlement.ID)
+	}
+
+	settlements, err := db.ListSettlements(2, 5)
+	require.NoError(t, err)
+	require.Len(t, settlements, 2)
+	require.Equal(t, 2.0, settlements[0].Amount)
+
+	// Test case 7: API keys are created, replaced, deleted and listed by creation time
+	require.NoError(t, db.SaveAPIKey(&storage.APIKey{Key: "b", SecretHash: "hb", UserID: 1, Permissions: []string{"read"}, CreatedAt: 2}))
+	require.NoError(t, db.SaveAPIKey(&storage.APIKey{Key: "c", SecretHash: "hc", UserID: 2, Permissions: []string{"trade"}, CreatedAt: 3}))
+	require.NoError(t, db.SaveAPIKey(&storage.APIKey{Key: "a", SecretHash: "ha", UserID: 1, Permissions: []string{"trade"}, CreatedAt: 1}))
+	require.NoError(t, db.SaveAPIKey(&storage.APIKey{Key: "a", SecretHash: "ha", UserID: 1, Permissions: []string{"trade", "withdraw"}, CreatedAt: 1}))
+	require.NoError(t, db.DeleteAPIKey("c"))
+	require.NoError(t, db.DeleteAPIKey("missing"))
+
+	apiKeys, err := db.ListAPIKeys()
+	require.NoError(t, err)
+	require.Equal(t, []*storage.APIKey{
+		{Key: "a", SecretHash: "ha", UserID: 1, Permissions: []string{"trade", "withdraw"}, CreatedAt: 1},
+		{Key: "b", SecretHash: "hb", UserID: 1, Permissions: []string{"read"}, CreatedAt: 2},
+	}, apiKeys)
+}
+
+func TestSaveBatch(t *testing.T) {
+	db, _ := openTestDB(t)
+
+	seller := &storage.Balance{UserID: 1, Asset: "ETH", Balance: 10}
+	require.NoError(t, db.SaveBalance(seller))
+
+	// Test case 1: the changes of a match batch are written together
+	seller.Balance = 8
+	buyer := &storage.Balance{UserID: 2, Asset: "ETH", Balance: 2}
+	settlement := &storage.Settlement{Market: "ETH", Amount: 2, BuyerID: 2, SellerID: 1}
+	require.NoError(t, db.SaveBatch(&storage.Batch{
+		Orders:      []*storage.Order{{ID: 7, UserID: 1, Status: "FILLED"}},
+		Fills:       []*storage.Fill{{ID: 1, UserID: 2}, {ID: 2, UserID: 1}},
+		Balances:    []*storage.Balance{seller, buyer},
+		Settlements: []*storage.Settlement{settlement},
+	}))
+	require.Equal(t, uint64(2), seller.Version)
+	require.Equal(t, uint64(1), buyer.Version)
+	require.Equal(t, uint64(1), settlement.ID)
+
+	// Test case 2: a stale balance rolls the whole batch back
+	stale := &storage.Balance{UserID: 1, Asset: "ETH", Balance: 5, Version: 1}
+	unsettled := &storage.Settlement{Market: "ETH"}
+	err := db.SaveBatch(&storage.Batch{
+		Orders:      []*storage.Order{{ID: 8, UserID: 1}},
+		Fills:       []*storage.Fill{{ID: 3, UserID: 1}},
+		Balances:    []*storage.Balance{{UserID: 3, Asset: "ETH", Balance: 1}, stale},
+		Settlements: []*storage.Settlement{unsettled},
+	})
+	require.ErrorIs(t, err, storage.ErrConflict)
+	require.Equal(t, uint64(1), stale.Version)
+	require.Zero(t, unsettled.ID)
+
+	_, err = db.GetOrder(8)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	fills, err := db.ListFills()
+	require.NoError(t, err)
+	require.Len(t, fills, 2)
+	balances, err := db.ListBalances()
+	require.NoError(t, err)
+	require.Len(t, balances, 2)
+	require.Equal(t, 8.0, balances[0].Balance)
+	settlements, err := db.ListSettlements(0, 10)
+	require.NoError(t, err)
+	require.Len(t, settlements, 1)
+}
+
+func TestTradeStore(t *testing.T) {
+	db, _ := openTestDB(t)
+
+	for id := uint64(1); id <= 5; id++ {
+		require.NoError(t, db.Append(&trades.Trade{ID: id, Market: "ETH", Price: 100, Size: 1, TakerSide: trades.SideBuy, Timestamp: int64(id * 10)}))
+	}
+
+	// Test case 1: trades must be appended in ID order
+	require.Error(t, db.Append(&trades.Trade{ID: 3, Market: "ETH"}))
+
+	lastID, err := db.LastID("ETH")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), lastID)
+	lastID, err = db.LastID("BTC")
+	require.NoError(t, err)
+	require.Zero(t, lastID)
+
+	// Test case 2: the latest trades, trades from an ID and trades in a time range, oldest first
+	got, err := db.Query(trades.Query{Market: "ETH", Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{4, 5}, tradeIDs(got))
+	require.Equal(t, trades.SideBuy, got[0].TakerSide)
+
+	got, err = db.Query(trades.Query{Market: "ETH", FromID: 2, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3}, tradeIDs(got))
+
+	got, err = db.Query(trades.Query{Market: "ETH", StartTime: 25, EndTime: 40})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 4}, tradeIDs(got))
+
+	got, err = db.Query(trades.Query{Market: "ETH", EndTime: 20})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2}, tradeIDs(got))
+
+	// Test case 3: contradicting bounds are rejected
+	_, err = db.Query(trades.Query{Market: "ETH", StartTime: 40, EndTime: 20})
+	require.ErrorIs(t, err, trades.ErrInvalidQuery)
+}
+
+func tradeIDs(trades []*trades.Trade) []uint64 {
+	ids := make([]uint64, len(trades))
+	for i, t := range trades {
+		ids[i] = t.ID
+	}
+	return ids
+}
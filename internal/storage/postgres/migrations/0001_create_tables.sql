@@ -0,0 +1,71 @@
+CREATE TABLE users (
+	id               BIGINT PRIMARY KEY,
+	address          TEXT NOT NULL,
+	status           TEXT NOT NULL,
+	derivation_index BIGINT NOT NULL,
+	fee_schedule     TEXT NOT NULL
+);
+
+CREATE TABLE orders (
+	id              BIGINT PRIMARY KEY,
+	user_id         BIGINT NOT NULL,
+	market          TEXT NOT NULL,
+	type            TEXT NOT NULL,
+	is_bid          BOOLEAN NOT NULL,
+	price           DOUBLE PRECISION NOT NULL,
+	status          TEXT NOT NULL,
+	original_amount DOUBLE PRECISION NOT NULL,
+	amount          DOUBLE PRECISION NOT NULL,
+	filled_amount   DOUBLE PRECISION NOT NULL,
+	avg_fill_price  DOUBLE PRECISION NOT NULL,
+	reason          TEXT NOT NULL,
+	created_at      BIGINT NOT NULL,
+	updated_at      BIGINT NOT NULL,
+	closed_at       BIGINT NOT NULL
+);
+
+CREATE INDEX orders_created_at ON orders (created_at);
+
+CREATE TABLE fills (
+	id                    BIGINT PRIMARY KEY,
+	trade_id              BIGINT NOT NULL,
+	market                TEXT NOT NULL,
+	order_id              BIGINT NOT NULL,
+	user_id               BIGINT NOT NULL,
+	counterparty_order_id BIGINT NOT NULL,
+	liquidity             TEXT NOT NULL,
+	is_bid                BOOLEAN NOT NULL,
+	price                 DOUBLE PRECISION NOT NULL,
+	amount                DOUBLE PRECISION NOT NULL,
+	fee                   DOUBLE PRECISION NOT NULL,
+	timestamp             BIGINT NOT NULL
+);
+
+-- version is the optimistic lock of a balance, see storage.Balance
+CREATE TABLE balances (
+	user_id BIGINT NOT NULL,
+	asset   TEXT NOT NULL,
+	balance DOUBLE PRECISION NOT NULL,
+	version BIGINT NOT NULL,
+	PRIMARY KEY (user_id, asset)
+);
+
+CREATE TABLE settlements (
+	id        BIGSERIAL PRIMARY KEY,
+	market    TEXT NOT NULL,
+	price     DOUBLE PRECISION NOT NULL,
+	amount    DOUBLE PRECISION NOT NULL,
+	buyer_id  BIGINT NOT NULL,
+	seller_id BIGINT NOT NULL,
+	timestamp BIGINT NOT NULL
+);
+
+CREATE TABLE trades (
+	market     TEXT NOT NULL,
+	id         BIGINT NOT NULL,
+	price      DOUBLE PRECISION NOT NULL,
+	size       DOUBLE PRECISION NOT NULL,
+	taker_side TEXT NOT NULL,
+	timestamp  BIGINT NOT NULL,
+	PRIMARY KEY (market, id)
+);
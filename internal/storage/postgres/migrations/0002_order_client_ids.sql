@@ -0,0 +1,2 @@
+-- client_order_id is the optional ID a client assigned to its order
+ALTER TABLE orders ADD COLUMN client_order_id TEXT NOT NULL DEFAULT '';
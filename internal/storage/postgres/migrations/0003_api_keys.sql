@@ -0,0 +1,9 @@
+-- api_keys holds the API keys issued to users, secret_hash is the SHA-256 of the secret the requests are signed with
+-- and permissions is a comma separated list
+CREATE TABLE api_keys (
+	key         TEXT PRIMARY KEY,
+	secret_hash TEXT NOT NULL,
+	user_id     BIGINT NOT NULL,
+	permissions TEXT NOT NULL,
+	created_at  BIGINT NOT NULL
+);
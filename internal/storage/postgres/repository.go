@@ -0,0 +1,336 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/storage"
+)
+
+var _ storage.Repository = (*DB)(nil)
+
+// SaveUser creates or replaces the user
+func (d *DB) SaveUser(user *storage.User) error {
+	_, err := d.db.Exec(`INSERT INTO users (id, address, status, derivation_index, fee_schedule)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET address = EXCLUDED.address, status = EXCLUDED.status,
+			derivation_index = EXCLUDED.derivation_index, fee_schedule = EXCLUDED.fee_schedule`,
+		user.ID, user.Address, user.Status, user.DerivationIndex, user.FeeSchedule)
+	return err
+}
+
+const selectUsers = `SELECT id, address, status, derivation_index, fee_schedule FROM users`
+
+// GetUser returns the user
+func (d *DB) GetUser(userID uint64) (*storage.User, error) {
+	var user storage.User
+	err := d.db.QueryRow(selectUsers+` WHERE id = $1`, userID).
+		Scan(&user.ID, &user.Address, &user.Status, &user.DerivationIndex, &user.FeeSchedule)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: user %d", storage.ErrNotFound, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns every user ordered by ID
+func (d *DB) ListUsers() ([]*storage.User, error) {
+	users := []*storage.User{}
+	err := d.query(selectUsers+` ORDER BY id`, nil, func(rows *sql.Rows) error {
+		var user storage.User
+		if err := rows.Scan(&user.ID, &user.Address, &user.Status, &user.DerivationIndex, &user.FeeSchedule); err != nil {
+			return err
+		}
+		users = append(users, &user)
+		return nil
+	})
+	return users, err
+}
+
+// SaveAPIKey creates or replaces the API key
+func (d *DB) SaveAPIKey(apiKey *storage.APIKey) error {
+	_, err := d.db.Exec(`INSERT INTO api_keys (key, secret_hash, user_id, permissions, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (key) DO UPDATE SET secret_hash = EXCLUDED.secret_hash, user_id = EXCLUDED.user_id,
+			permissions = EXCLUDED.permissions, created_at = EXCLUDED.created_at`,
+		apiKey.Key, apiKey.SecretHash, apiKey.UserID, strings.Join(apiKey.Permissions, ","), apiKey.CreatedAt)
+	return err
+}
+
+// DeleteAPIKey removes the API key
+func (d *DB) DeleteAPIKey(key string) error {
+	_, err := d.db.Exec(`DELETE FROM api_keys WHERE key = $1`, key)
+	return err
+}
+
+// ListAPIKeys returns every API key ordered by creation time
+func (d *DB) ListAPIKeys() ([]*storage.APIKey, error) {
+	apiKeys := []*storage.APIKey{}
+	err := d.query(`SELECT key, secret_hash, user_id, permissions, created_at FROM api_keys ORDER BY created_at, key`,
+		nil, func(rows *sql.Rows) error {
+			var apiKey storage.APIKey
+			var permissions string
+			if err := rows.Scan(&apiKey.Key, &apiKey.SecretHash, &apiKey.UserID, &permissions, &apiKey.CreatedAt); err != nil {
+				return err
+			}
+			if permissions != "" {
+				apiKey.Permissions = strings.Split(permissions, ",")
+			}
+			apiKeys = append(apiKeys, &apiKey)
+			return nil
+		})
+	return apiKeys, err
+}
+
+// SaveOrder creates or replaces the order
+func (d *DB) SaveOrder(order *storage.Order) error {
+	return saveOrder(d.db, order)
+}
+
+func saveOrder(db execer, order *storage.Order) error {
+	_, err := db.Exec(`INSERT INTO orders (id, user_id, market, type, is_bid, price, status, original_amount, amount,
+			filled_amount, avg_fill_price, reason, created_at, updated_at, closed_at, client_order_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (id) DO UPDATE SET user_id = EXCLUDED.user_id, market = EXCLUDED.market, type = EXCLUDED.type,
+			is_bid = EXCLUDED.is_bid, price = EXCLUDED.price, status = EXCLUDED.status,
+			original_amount = EXCLUDED.original_amount, amount = EXCLUDED.amount,
+			filled_amount = EXCLUDED.filled_amount, avg_fill_price = EXCLUDED.avg_fill_price,
+			reason = EXCLUDED.reason, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at,
+			closed_at = EXCLUDED.closed_at, client_order_id = EXCLUDED.client_order_id`,
+		order.ID, order.UserID, order.Market, order.Type, order.IsBid, order.Price, order.Status,
+		order.OriginalAmount, order.Amount, order.FilledAmount, order.AvgFillPrice, order.Reason,
+		order.CreatedAt, order.UpdatedAt, order.ClosedAt, order.ClientOrderID)
+	return err
+}
+
+const selectOrders = `SELECT id, user_id, market, type, is_bid, price, status, original_amount, amount, filled_amount,
+	avg_fill_price, reason, created_at, updated_at, closed_at, client_order_id FROM orders`
+
+func scanOrder(row interface{ Scan(...interface{}) error }, order *storage.Order) error {
+	return row.Scan(&order.ID, &order.UserID, &order.Market, &order.Type, &order.IsBid, &order.Price, &order.Status,
+		&order.OriginalAmount, &order.Amount, &order.FilledAmount, &order.AvgFillPrice, &order.Reason,
+		&order.CreatedAt, &order.UpdatedAt, &order.ClosedAt, &order.ClientOrderID)
+}
+
+// GetOrder returns the order
+func (d *DB) GetOrder(orderID uint64) (*storage.Order, error) {
+	var order storage.Order
+	err := scanOrder(d.db.QueryRow(selectOrders+` WHERE id = $1`, orderID), &order)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: order %d", storage.ErrNotFound, orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ListOrders returns every order, oldest first
+func (d *DB) ListOrders() ([]*storage.Order, error) {
+	orders := []*storage.Order{}
+	err := d.query(selectOrders+` ORDER BY created_at, id`, nil, func(rows *sql.Rows) error {
+		var order storage.Order
+		if err := scanOrder(rows, &order); err != nil {
+			return err
+		}
+		orders = append(orders, &order)
+		return nil
+	})
+	return orders, err
+}
+
+// SaveFills stores the fills in one transaction
+func (d *DB) SaveFills(fills ...*storage.Fill) error {
+	return inTx(d.db, func(tx *sql.Tx) error {
+		for _, fill := range fills {
+			if err := saveFill(tx, fill); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func saveFill(db execer, fill *storage.Fill) error {
+	_, err := db.Exec(`INSERT INTO fills (id, trade_id, market, order_id, user_id, counterparty_order_id, liquidity,
+			is_bid, price, amount, fee, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET trade_id = EXCLUDED.trade_id, market = EXCLUDED.market,
+			order_id = EXCLUDED.order_id, user_id = EXCLUDED.user_id,
+			counterparty_order_id = EXCLUDED.counterparty_order_id, liquidity = EXCLUDED.liquidity,
+			is_bid = EXCLUDED.is_bid, price = EXCLUDED.price, amount = EXCLUDED.amount, fee = EXCLUDED.fee,
+			timestamp = EXCLUDED.timestamp`,
+		fill.ID, fill.TradeID, fill.Market, fill.OrderID, fill.UserID, fill.CounterpartyOrderID, fill.Liquidity,
+		fill.IsBid, fill.Price, fill.Amount, fill.Fee, fill.Timestamp)
+	return err
+}
+
+// ListFills returns every fill ordered by ID
+func (d *DB) ListFills() ([]*storage.Fill, error) {
+	fills := []*storage.Fill{}
+	err := d.query(`SELECT id, trade_id, market, order_id, user_id, counterparty_order_id, liquidity, is_bid, price,
+		amount, fee, timestamp FROM fills ORDER BY id`, nil, func(rows *sql.Rows) error {
+		var fill storage.Fill
+		err := rows.Scan(&fill.ID, &fill.TradeID, &fill.Market, &fill.OrderID, &fill.UserID, &fill.CounterpartyOrderID,
+			&fill.Liquidity, &fill.IsBid, &fill.Price, &fill.Amount, &fill.Fee, &fill.Timestamp)
+		if err != nil {
+			return err
+		}
+		fills = append(fills, &fill)
+		return nil
+	})
+	return fills, err
+}
+
+// SaveBalance creates or replaces the balance of the user in the asset if its version is current
+func (d *DB) SaveBalance(balance *storage.Balance) error {
+	if err := saveBalance(d.db, balance); err != nil {
+		return err
+	}
+
+	balance.Version++
+	return nil
+}
+
+// saveBalance writes the balance with the next version, failing with storage.ErrConflict when the stored version
+// differs from the balance's. A balance at version zero must not exist yet.
+func saveBalance(db execer, balance *storage.Balance) error {
+	var (
+		result sql.Result
+		err    error
+	)
+	if balance.Version == 0 {
+		result, err = db.Exec(`INSERT INTO balances (user_id, asset, balance, version) VALUES ($1, $2, $3, 1)
+			ON CONFLICT (user_id, asset) DO NOTHING`,
+			balance.UserID, balance.Asset, balance.Balance)
+	} else {
+		result, err = db.Exec(`UPDATE balances SET balance = $3, version = version + 1
+			WHERE user_id = $1 AND asset = $2 AND version = $4`,
+			balance.UserID, balance.Asset, balance.Balance, balance.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%w: %s balance of user %d is not at version %d", storage.ErrConflict, balance.Asset,
+			balance.UserID, balance.Version)
+	}
+	return nil
+}
+
+// ListBalances returns every balance ordered by user and asset
+func (d *DB) ListBalances() ([]*storage.Balance, error) {
+	balances := []*storage.Balance{}
+	err := d.query(`SELECT user_id, asset, balance, version FROM balances ORDER BY user_id, asset`, nil,
+		func(rows *sql.Rows) error {
+			var balance storage.Balance
+			if err := rows.Scan(&balance.UserID, &balance.Asset, &balance.Balance, &balance.Version); err != nil {
+				return err
+			}
+			balances = append(balances, &balance)
+			return nil
+		})
+	return balances, err
+}
+
+// SaveSettlement stores the settlement with the next settlement ID
+func (d *DB) SaveSettlement(settlement *storage.Settlement) error {
+	id, err := saveSettlement(d.db, settlement)
+	if err != nil {
+		return err
+	}
+
+	settlement.ID = id
+	return nil
+}
+
+// saveSettlement inserts the settlement and returns the ID the database assigned
+func saveSettlement(db execer, settlement *storage.Settlement) (uint64, error) {
+	var id uint64
+	err := db.QueryRow(`INSERT INTO settlements (market, price, amount, buyer_id, seller_id, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		settlement.Market, settlement.Price, settlement.Amount, settlement.BuyerID, settlement.SellerID,
+		settlement.Timestamp).Scan(&id)
+	return id, err
+}
+
+// ListSettlements returns up to limit settlements from the ID on, ordered by ID
+func (d *DB) ListSettlements(fromID uint64, limit int) ([]*storage.Settlement, error) {
+	settlements := []*storage.Settlement{}
+	err := d.query(`SELECT id, market, price, amount, buyer_id, seller_id, timestamp FROM settlements
+		WHERE id >= $1 ORDER BY id LIMIT $2`, []interface{}{fromID, limit}, func(rows *sql.Rows) error {
+		var s storage.Settlement
+		if err := rows.Scan(&s.ID, &s.Market, &s.Price, &s.Amount, &s.BuyerID, &s.SellerID, &s.Timestamp); err != nil {
+			return err
+		}
+		settlements = append(settlements, &s)
+		return nil
+	})
+	return settlements, err
+}
+
+// SaveBatch writes the batch in one transaction
+func (d *DB) SaveBatch(batch *storage.Batch) error {
+	ids := make([]uint64, len(batch.Settlements))
+	err := inTx(d.db, func(tx *sql.Tx) error {
+		for _, order := range batch.Orders {
+			if err := saveOrder(tx, order); err != nil {
+				return err
+			}
+		}
+		for _, fill := range batch.Fills {
+			if err := saveFill(tx, fill); err != nil {
+				return err
+			}
+		}
+		for _, balance := range batch.Balances {
+			if err := saveBalance(tx, balance); err != nil {
+				return err
+			}
+		}
+		for i, settlement := range batch.Settlements {
+			id, err := saveSettlement(tx, settlement)
+			if err != nil {
+				return err
+			}
+			ids[i] = id
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, balance := range batch.Balances {
+		balance.Version++
+	}
+	for i, settlement := range batch.Settlements {
+		settlement.ID = ids[i]
+	}
+	return nil
+}
+
+// query runs the query and calls scan for every row
+func (d *DB) query(query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
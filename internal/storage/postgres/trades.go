@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/taha-ahmadi/cryptocurrency-exchange/internal/trades"
+)
+
+var _ trades.Store = (*DB)(nil)
+
+// Append stores the trade, it must come after the latest stored trade of its market
+func (d *DB) Append(trade *trades.Trade) error {
+	result, err := d.db.Exec(`INSERT INTO trades (market, id, price, size, taker_side, timestamp)
+		SELECT $1, $2, $3, $4, $5, $6
+		WHERE NOT EXISTS (SELECT 1 FROM trades WHERE market = $1 AND id >= $2)`,
+		trade.Market, trade.ID, trade.Price, trade.Size, string(trade.TakerSide), trade.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("trade %d of %s is not after the last stored trade", trade.ID, trade.Market)
+	}
+	return nil
+}
+
+const selectTrades = `SELECT id, market, price, size, taker_side, timestamp FROM trades`
+
+// Query returns the trades matching the query, oldest first
+func (d *DB) Query(q trades.Query) ([]*trades.Trade, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a starting point the latest trades are collected backwards
+	latest := q.FromID == 0 && q.StartTime == 0
+	query, args := selectTrades+` WHERE market = $1 AND id >= $2 AND timestamp >= $3
+		AND ($4::BIGINT = 0 OR timestamp <= $4) ORDER BY id LIMIT $5`,
+		[]interface{}{q.Market, q.FromID, q.StartTime, q.EndTime, q.Limit}
+	if latest {
+		query, args = selectTrades+` WHERE market = $1 AND ($2::BIGINT = 0 OR timestamp <= $2) ORDER BY id DESC LIMIT $3`,
+			[]interface{}{q.Market, q.EndTime, q.Limit}
+	}
+
+	result := []*trades.Trade{}
+	err = d.query(query, args, func(rows *sql.Rows) error {
+		var (
+			t    trades.Trade
+			side string
+		)
+		if err := rows.Scan(&t.ID, &t.Market, &t.Price, &t.Size, &side, &t.Timestamp); err != nil {
+			return err
+		}
+		t.TakerSide = trades.Side(side)
+		result = append(result, &t)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if latest {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	return result, nil
+}
+
+// LastID returns the ID of the latest stored trade of the market
+func (d *DB) LastID(market string) (uint64, error) {
+	var id uint64
+	err := d.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM trades WHERE market = $1`, market).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,175 @@
+// Package storage defines the repositories the exchange persists its account state in: users, API keys, orders,
+// fills, ledger balances and settlements. Implementations live in the sub packages, the exchange restores its state
+// from a Repository on start and writes every change through it.
+package storage
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when a record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a balance was changed by another writer since it was read, see Balance.Version
+	ErrConflict = errors.New("balance was modified concurrently")
+)
+
+// User is a registered user. The user's keys are not stored, they are derived from the exchange HD wallet with
+// DerivationIndex.
+type User struct {
+	ID              uint64
+	Address         string
+	Status          string
+	DerivationIndex uint32
+	FeeSchedule     string
+}
+
+// Order is the latest state of an order, closed orders are kept as the order history. Times are unix nanoseconds,
+// ClosedAt is zero while the order is open.
+type Order struct {
+	ID             uint64
+	ClientOrderID  string
+	UserID         uint64
+	Market         string
+	Type           string
+	IsBid          bool
+	Price          float64
+	Status         string
+	OriginalAmount float64
+	Amount         float64
+	FilledAmount   float64
+	AvgFillPrice   float64
+	Reason         string
+	CreatedAt      int64
+	UpdatedAt      int64
+	ClosedAt       int64
+}
+
+// Fill is one side of a trade
+type Fill struct {
+	ID                  uint64
+	TradeID             uint64
+	Market              string
+	OrderID             uint64
+	UserID              uint64
+	CounterpartyOrderID uint64
+	Liquidity           string
+	IsBid               bool
+	Price               float64
+	Amount              float64
+	Fee                 float64
+	Timestamp           int64
+}
+
+// Balance is the ledger balance of a user in an asset. Version is the number of times the balance was saved, a save
+// only succeeds when it matches the stored version and increments it on success. A new balance has version zero.
+type Balance struct {
+	UserID  uint64
+	Asset   string
+	Balance float64
+	Version uint64
+}
+
+// Settlement is a trade settled in the ledger and on-chain, IDs are assigned by the repository
+type Settlement struct {
+	ID        uint64
+	Market    string
+	Price     float64
+	Amount    float64
+	BuyerID   uint64
+	SellerID  uint64
+	Timestamp int64
+}
+
+// APIKey is an API key issued to a user. The secret is not stored, only its SHA-256, which is the key requests are
+// signed with. CreatedAt is unix nanoseconds.
+type APIKey struct {
+	Key         string
+	SecretHash  string
+	UserID      uint64
+	Permissions []string
+	CreatedAt   int64
+}
+
+// UserRepository stores users
+type UserRepository interface {
+	// SaveUser creates or replaces the user
+	SaveUser(user *User) error
+	// GetUser returns the user, ErrNotFound when it does not exist
+	GetUser(userID uint64) (*User, error)
+	// ListUsers returns every user ordered by ID
+	ListUsers() ([]*User, error)
+}
+
+// OrderRepository stores orders
+type OrderRepository interface {
+	// SaveOrder creates or replaces the order
+	SaveOrder(order *Order) error
+	// GetOrder returns the order, ErrNotFound when it does not exist
+	GetOrder(orderID uint64) (*Order, error)
+	// ListOrders returns every order, oldest first
+	ListOrders() ([]*Order, error)
+}
+
+// FillRepository stores fills
+type FillRepository interface {
+	// SaveFills stores the fills, usually both sides of a trade
+	SaveFills(fills ...*Fill) error
+	// ListFills returns every fill ordered by ID
+	ListFills() ([]*Fill, error)
+}
+
+// BalanceRepository stores ledger balances
+type BalanceRepository interface {
+	// SaveBalance creates or replaces the balance of the user in the asset, ErrConflict when its version is stale
+	SaveBalance(balance *Balance) error
+	// ListBalances returns every balance ordered by user and asset
+	ListBalances() ([]*Balance, error)
+}
+
+// SettlementRepository stores settlements
+type SettlementRepository interface {
+	// SaveSettlement stores the settlement and assigns its ID
+	SaveSettlement(settlement *Settlement) error
+	// ListSettlements returns the settlements from the ID on, up to limit of them, ordered by ID
+	ListSettlements(fromID uint64, limit int) ([]*Settlement, error)
+}
+
+// APIKeyRepository stores API keys
+type APIKeyRepository interface {
+	// SaveAPIKey creates or replaces the API key
+	SaveAPIKey(apiKey *APIKey) error
+	// DeleteAPIKey removes the API key, deleting a key that does not exist is not an error
+	DeleteAPIKey(key string) error
+	// ListAPIKeys returns every API key ordered by creation time
+	ListAPIKeys() ([]*APIKey, error)
+}
+
+// Batch holds everything a match batch changed, the order states, fills, ledger balances and settlements of one
+// taker order. It is written in a single transaction.
+type Batch struct {
+	Orders      []*Order
+	Fills       []*Fill
+	Balances    []*Balance
+	Settlements []*Settlement
+}
+
+// Empty reports whether the batch holds no changes
+func (b *Batch) Empty() bool {
+	return len(b.Orders) == 0 && len(b.Fills) == 0 && len(b.Balances) == 0 && len(b.Settlements) == 0
+}
+
+// Repository stores the account state of the exchange
+type Repository interface {
+	UserRepository
+	OrderRepository
+	FillRepository
+	BalanceRepository
+	SettlementRepository
+	APIKeyRepository
+
+	// SaveBatch writes the batch atomically. Nothing is written when a balance is stale, the error is then
+	// ErrConflict. On success the balance versions are incremented and the settlements get their IDs.
+	SaveBatch(batch *Batch) error
+
+	// Close releases the underlying database
+	Close() error
+}
@@ -0,0 +1,161 @@
+package trades
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileStore persists the trades of every market as JSON lines in a file per market. Queries read the file, the
+// ring in front of it serves the recent trades.
+type FileStore struct {
+	dir string
+
+	mu      sync.Mutex
+	files   map[string]*os.File
+	lastIDs map[string]uint64
+}
+
+// NewFileStore opens the trade files in dir, creating the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create trade store directory: %w", err)
+	}
+
+	s := &FileStore{
+		dir:     dir,
+		files:   make(map[string]*os.File),
+		lastIDs: make(map[string]uint64),
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		market := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		err := s.scan(market, func(t *Trade) bool {
+			s.lastIDs[market] = t.ID
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Append writes the trade to the file of its market
+func (s *FileStore) Append(trade *Trade) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if trade.ID <= s.lastIDs[trade.Market] {
+		return fmt.Errorf("trade %d of %s is not after the last stored trade %d", trade.ID, trade.Market, s.lastIDs[trade.Market])
+	}
+
+	f, ok := s.files[trade.Market]
+	if !ok {
+		var err error
+		f, err = os.OpenFile(s.path(trade.Market), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open trade file: %w", err)
+		}
+		s.files[trade.Market] = f
+	}
+
+	line, err := json.Marshal(trade)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write trade: %w", err)
+	}
+
+	s.lastIDs[trade.Market] = trade.ID
+	return nil
+}
+
+// Query reads the trades matching the query from the file of the market
+func (s *FileStore) Query(q Query) ([]*Trade, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	var trades []*Trade
+	err = s.scan(q.Market, func(t *Trade) bool {
+		if !q.matches(t) {
+			// Trades are stored in ID and time order, nothing after the end time can match
+			return q.EndTime == 0 || t.Timestamp <= q.EndTime
+		}
+
+		trades = append(trades, t)
+		if q.latest() && len(trades) > q.Limit {
+			trades = trades[1:]
+		}
+		return q.latest() || len(trades) < q.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return collect(trades, q), nil
+}
+
+// LastID returns the ID of the latest stored trade of the market
+func (s *FileStore) LastID(market string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastIDs[market], nil
+}
+
+// Close closes the trade files
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for market, f := range s.files {
+		if err := f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.files, market)
+	}
+	return firstErr
+}
+
+// scan calls fn with every stored trade of the market in order until it returns false
+func (s *FileStore) scan(market string, fn func(*Trade) bool) error {
+	f, err := os.Open(s.path(market))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open trade file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var t Trade
+		if err := json.Unmarshal(scanner.Bytes(), &t); err != nil {
+			return fmt.Errorf("failed to read trade file of %s: %w", market, err)
+		}
+		if !fn(&t) {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *FileStore) path(market string) string {
+	return filepath.Join(s.dir, market+".jsonl")
+}
@@ -0,0 +1,60 @@
+package trades
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	for id := uint64(1); id <= 5; id++ {
+		require.NoError(t, store.Append(&Trade{ID: id, Market: "ETH", Price: float64(100 + id), Size: 1, TakerSide: SideBuy, Timestamp: int64(id * 10)}))
+	}
+	require.NoError(t, store.Append(&Trade{ID: 1, Market: "BTC", Price: 1, Size: 1, TakerSide: SideSell, Timestamp: 10}))
+
+	// Test case 1: trades must be appended in ID order
+	require.Error(t, store.Append(&Trade{ID: 5, Market: "ETH"}))
+
+	// Test case 2: the latest trades are returned oldest first
+	trades, err := store.Query(Query{Market: "ETH", Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{4, 5}, ids(trades))
+
+	// Test case 3: trades from an ID or a time on, bounded by the end time
+	trades, err = store.Query(Query{Market: "ETH", FromID: 2, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3}, ids(trades))
+
+	trades, err = store.Query(Query{Market: "ETH", StartTime: 25, EndTime: 40})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3, 4}, ids(trades))
+
+	trades, err = store.Query(Query{Market: "ETH", EndTime: 20})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2}, ids(trades))
+
+	// Test case 4: contradicting bounds are rejected
+	_, err = store.Query(Query{Market: "ETH", StartTime: 40, EndTime: 20})
+	require.ErrorIs(t, err, ErrInvalidQuery)
+
+	// Test case 5: reopening the store keeps the trades and the last IDs
+	require.NoError(t, store.Close())
+	store, err = NewFileStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	lastID, err := store.LastID("ETH")
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), lastID)
+	lastID, err = store.LastID("SOL")
+	require.NoError(t, err)
+	require.Zero(t, lastID)
+
+	trades, err = store.Query(Query{Market: "BTC"})
+	require.NoError(t, err)
+	require.Equal(t, []*Trade{{ID: 1, Market: "BTC", Price: 1, Size: 1, TakerSide: SideSell, Timestamp: 10}}, trades)
+}
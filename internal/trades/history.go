@@ -0,0 +1,132 @@
+package trades
+
+import "sync"
+
+// History serves the trades of every market from a ring of the latest trades, falling back to the store for older
+// ones. Without a store only the trades in the rings are kept.
+type History struct {
+	store    Store
+	ringSize int
+
+	mu    sync.RWMutex
+	rings map[string]*Ring
+}
+
+// NewHistory creates a history keeping ringSize trades per market in memory in front of store, which may be nil
+func NewHistory(store Store, ringSize int) *History {
+	if ringSize <= 0 {
+		ringSize = DefaultRingSize
+	}
+	return &History{store: store, ringSize: ringSize, rings: make(map[string]*Ring)}
+}
+
+// Add records the trade, persisting it when the history has a store
+func (h *History) Add(trade *Trade) error {
+	if h.store != nil {
+		if err := h.store.Append(trade); err != nil {
+			return err
+		}
+	}
+
+	h.ring(trade.Market).Add(trade)
+	return nil
+}
+
+// Warm loads the latest stored trades of the market into its ring, such as after a restart
+func (h *History) Warm(market string) error {
+	if h.store == nil {
+		return nil
+	}
+
+	lastID, err := h.store.LastID(market)
+	if err != nil {
+		return err
+	}
+
+	ring := h.ring(market)
+	fromID := uint64(1)
+	if lastID > uint64(h.ringSize) {
+		fromID = lastID - uint64(h.ringSize) + 1
+	}
+	for fromID <= lastID {
+		page, err := h.store.Query(Query{Market: market, FromID: fromID, Limit: MaxLimit})
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		for _, t := range page {
+			ring.Add(t)
+		}
+		fromID = page[len(page)-1].ID + 1
+	}
+	return nil
+}
+
+// LastID returns the ID of the latest trade of the market, zero when it has none
+func (h *History) LastID(market string) (uint64, error) {
+	if h.store != nil {
+		return h.store.LastID(market)
+	}
+
+	trades := h.ring(market).Trades()
+	if len(trades) == 0 {
+		return 0, nil
+	}
+	return trades[len(trades)-1].ID, nil
+}
+
+// Query returns the trades matching the query, oldest first
+func (h *History) Query(q Query) ([]*Trade, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	ring := h.ring(q.Market)
+	if h.store == nil || h.covers(ring, q) {
+		return collect(ring.Trades(), q), nil
+	}
+	return h.store.Query(q)
+}
+
+// covers reports whether the ring holds every trade the query could return
+func (h *History) covers(ring *Ring, q Query) bool {
+	oldest := ring.Oldest()
+	if oldest == nil {
+		return false
+	}
+	// A ring that never dropped a trade holds the whole history of the market
+	if oldest.ID == 1 {
+		return true
+	}
+
+	switch {
+	case q.FromID != 0:
+		return q.FromID >= oldest.ID
+	case q.StartTime != 0:
+		return q.StartTime > oldest.Timestamp
+	default:
+		// The latest trades are in the ring unless the end time or the limit reach past its oldest trade
+		trades := collect(ring.Trades(), q)
+		return len(trades) == q.Limit && trades[0].ID > oldest.ID
+	}
+}
+
+func (h *History) ring(market string) *Ring {
+	h.mu.RLock()
+	ring, ok := h.rings[market]
+	h.mu.RUnlock()
+	if ok {
+		return ring
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ring, ok = h.rings[market]; !ok {
+		ring = NewRing(h.ringSize)
+		h.rings[market] = ring
+	}
+	return ring
+}
@@ -0,0 +1,56 @@
+package trades
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	defer store.Close()
+
+	history := NewHistory(store, 3)
+	for id := uint64(1); id <= 6; id++ {
+		require.NoError(t, history.Add(&Trade{ID: id, Market: "ETH", Price: 100, Size: 1, Timestamp: int64(id * 10)}))
+	}
+
+	// Test case 1: the latest trades come from the ring
+	trades, err := history.Query(Query{Market: "ETH", Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5, 6}, ids(trades))
+
+	// Test case 2: trades older than the ring come from the store
+	trades, err = history.Query(Query{Market: "ETH"})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3, 4, 5, 6}, ids(trades))
+
+	trades, err = history.Query(Query{Market: "ETH", FromID: 2, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3}, ids(trades))
+
+	trades, err = history.Query(Query{Market: "ETH", StartTime: 45})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5, 6}, ids(trades))
+
+	// Test case 3: a new history resumes from the store and loads the ring
+	history = NewHistory(store, 3)
+	require.NoError(t, history.Warm("ETH"))
+	lastID, err := history.LastID("ETH")
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), lastID)
+	require.Equal(t, []uint64{4, 5, 6}, ids(history.ring("ETH").Trades()))
+
+	// Test case 4: without a store only the ring is kept
+	history = NewHistory(nil, 2)
+	for id := uint64(1); id <= 3; id++ {
+		require.NoError(t, history.Add(&Trade{ID: id, Market: "BTC"}))
+	}
+	trades, err = history.Query(Query{Market: "BTC"})
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3}, ids(trades))
+	trades, err = history.Query(Query{Market: "SOL"})
+	require.NoError(t, err)
+	require.Empty(t, trades)
+}
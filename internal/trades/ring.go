@@ -0,0 +1,55 @@
+package trades
+
+import "sync"
+
+// DefaultRingSize is how many of the latest trades of every market are kept in memory
+const DefaultRingSize = 10000
+
+// Ring keeps the latest trades of a market in memory, the oldest trade is dropped once it is full
+type Ring struct {
+	mu     sync.RWMutex
+	trades []*Trade
+	// start is the index of the oldest trade once the ring is full
+	start int
+	size  int
+}
+
+// NewRing creates a ring holding up to size trades
+func NewRing(size int) *Ring {
+	return &Ring{trades: make([]*Trade, 0, size), size: size}
+}
+
+// Add adds the trade as the latest one
+func (r *Ring) Add(trade *Trade) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.trades) < r.size {
+		r.trades = append(r.trades, trade)
+		return
+	}
+
+	r.trades[r.start] = trade
+	r.start = (r.start + 1) % r.size
+}
+
+// Oldest returns the oldest trade in the ring, nil when it is empty
+func (r *Ring) Oldest() *Trade {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.trades) == 0 {
+		return nil
+	}
+	return r.trades[r.start]
+}
+
+// Trades returns the trades in the ring, oldest first
+func (r *Ring) Trades() []*Trade {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	trades := make([]*Trade, 0, len(r.trades))
+	trades = append(trades, r.trades[r.start:]...)
+	return append(trades, r.trades[:r.start]...)
+}
@@ -0,0 +1,35 @@
+package trades
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(3)
+
+	// Test case 1: an empty ring has no trades
+	require.Nil(t, ring.Oldest())
+	require.Empty(t, ring.Trades())
+
+	// Test case 2: trades are kept oldest first until the ring is full
+	for id := uint64(1); id <= 3; id++ {
+		ring.Add(&Trade{ID: id})
+	}
+	require.Equal(t, []uint64{1, 2, 3}, ids(ring.Trades()))
+
+	// Test case 3: a full ring drops its oldest trade
+	ring.Add(&Trade{ID: 4})
+	ring.Add(&Trade{ID: 5})
+	require.Equal(t, []uint64{3, 4, 5}, ids(ring.Trades()))
+	require.Equal(t, uint64(3), ring.Oldest().ID)
+}
+
+func ids(trades []*Trade) []uint64 {
+	result := make([]uint64, len(trades))
+	for i, t := range trades {
+		result[i] = t.ID
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+// Package trades keeps the trade history of every market: a bounded ring of the latest trades in memory in front of
+// a persistent store.
+package trades
+
+import (
+	"errors"
+)
+
+// Side is the side of the taker of a trade
+type Side string
+
+const (
+	SideBuy  Side = "BUY"
+	SideSell Side = "SELL"
+)
+
+const (
+	// DefaultLimit is the number of trades a query returns when none is requested
+	DefaultLimit = 500
+	// MaxLimit is the most trades a query returns
+	MaxLimit = 1000
+)
+
+// ErrInvalidQuery is returned for queries with contradicting bounds
+var ErrInvalidQuery = errors.New("invalid trade query")
+
+// Trade is a match between a taker and a maker order. IDs are sequential per market starting at 1, Timestamp is in
+// unix nanoseconds.
+type Trade struct {
+	ID        uint64  `json:"id"`
+	Market    string  `json:"market"`
+	Price     float64 `json:"price"`
+	Size      float64 `json:"size"`
+	TakerSide Side    `json:"taker_side"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// Query selects trades of a market. With FromID the trades from that ID on are returned, with StartTime the trades
+// from that time on, otherwise the latest trades up to EndTime. Results are oldest first.
+type Query struct {
+	Market    string
+	FromID    uint64
+	StartTime int64
+	EndTime   int64
+	Limit     int
+}
+
+// Store persists trades
+type Store interface {
+	// Append stores the trade, trades of a market are appended in ID order
+	Append(trade *Trade) error
+	// Query returns the trades matching the query
+	Query(q Query) ([]*Trade, error)
+	// LastID returns the ID of the latest stored trade of the market, zero when it has none
+	LastID(market string) (uint64, error)
+}
+
+// latest reports whether the query asks for the latest trades rather than the ones from a starting point
+func (q Query) latest() bool {
+	return q.FromID == 0 && q.StartTime == 0
+}
+
+// matches reports whether the trade is within the bounds of the query
+func (q Query) matches(t *Trade) bool {
+	return t.ID >= q.FromID &&
+		(q.StartTime == 0 || t.Timestamp >= q.StartTime) &&
+		(q.EndTime == 0 || t.Timestamp <= q.EndTime)
+}
+
+// Normalize validates the query and applies the default and maximum limit
+func (q Query) Normalize() (Query, error) {
+	if q.Limit < 0 || (q.StartTime != 0 && q.EndTime != 0 && q.StartTime > q.EndTime) {
+		return q, ErrInvalidQuery
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	return q, nil
+}
+
+// collect returns the trades of the query from trades sorted by ID
+func collect(trades []*Trade, q Query) []*Trade {
+	result := []*Trade{}
+	if q.latest() {
+		for i := len(trades) - 1; i >= 0 && len(result) < q.Limit; i-- {
+			if q.matches(trades[i]) {
+				result = append(result, trades[i])
+			}
+		}
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+		return result
+	}
+
+	for _, t := range trades {
+		if len(result) == q.Limit {
+			break
+		}
+		if q.matches(t) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
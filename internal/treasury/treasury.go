@@ -0,0 +1,251 @@
+package treasury
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/signer"
+)
+
+// Chain is the part of the Ethereum client the treasury needs to check balances and move funds
+type Chain interface {
+	GetBalance(address string) (*big.Int, error)
+	TransferETH(s signer.Signer, to common.Address, amount *big.Int) error
+	// TransferFee is the gas a transfer costs at the current gas price, paid by the sender on top of the amount
+	TransferFee() (*big.Int, error)
+}
+
+// AlertKind identifies why the treasury raised an alert
+type AlertKind string
+
+const (
+	// AlertHotWalletLow is raised when the hot wallet drops below the low watermark and needs a top-up from cold
+	// storage, which is a manual step since the cold wallet is offline
+	AlertHotWalletLow AlertKind = "HOT_WALLET_LOW"
+	// AlertInsufficientForWithdrawals is raised when the hot wallet cannot cover the pending withdrawals
+	AlertInsufficientForWithdrawals AlertKind = "INSUFFICIENT_FOR_WITHDRAWALS"
+)
+
+// Alert is an event that needs operator attention
+type Alert struct {
+	Kind       AlertKind
+	HotBalance *big.Int
+	Required   *big.Int
+	Timestamp  int64
+}
+
+// MovementKind identifies a type of treasury fund movement
+type MovementKind string
+
+const (
+	// MovementDepositSweep is a sweep of user deposit addresses into the hot wallet
+	MovementDepositSweep MovementKind = "DEPOSIT_SWEEP"
+	// MovementColdSweep is a transfer of excess hot wallet funds into cold storage
+	MovementColdSweep MovementKind = "COLD_SWEEP"
+	// MovementWithdrawal is a payout of the hot wallet to a user's wallet
+	MovementWithdrawal MovementKind = "WITHDRAWAL"
+)
+
+// ErrInsufficientHotWallet is returned for withdrawals the hot wallet cannot cover until it is topped up
+var ErrInsufficientHotWallet = errors.New("hot wallet cannot cover the withdrawal")
+
+// Movement is an audit record of funds moved by the treasury
+type Movement struct {
+	Kind      MovementKind
+	From      string
+	To        string
+	Amount    *big.Int
+	Timestamp int64
+}
+
+// Config holds the treasury wallet addresses and watermarks, amounts are in wei
+type Config struct {
+	ColdAddress   common.Address
+	LowWatermark  *big.Int
+	HighWatermark *big.Int
+
+	// DepositSweeper optionally sweeps user deposit addresses into the hot wallet before every rebalance
+	DepositSweeper func() (*big.Int, error)
+}
+
+// Treasury keeps the hot wallet balance between the configured watermarks
+type Treasury struct {
+	chain  Chain
+	hot    signer.Signer
+	config Config
+	alerts chan Alert
+
+	// mu also serializes the transactions of the hot wallet
+	mu        sync.Mutex
+	movements []Movement
+}
+
+// New creates a treasury for the given hot wallet
+func New(chain Chain, hot signer.Signer, config Config) (*Treasury, error) {
+	if config.LowWatermark == nil || config.HighWatermark == nil {
+		return nil, errors.New("treasury watermarks must be set")
+	}
+
+	if config.LowWatermark.Cmp(config.HighWatermark) > 0 {
+		return nil, errors.New("low watermark cannot be above high watermark")
+	}
+
+	return &Treasury{
+		chain:  chain,
+		hot:    hot,
+		config: config,
+		alerts: make(chan Alert, 64),
+	}, nil
+}
+
+// Alerts returns the channel alerts are delivered on
+func (t *Treasury) Alerts() <-chan Alert {
+	return t.alerts
+}
+
+// Withdraw sends amount wei from the hot wallet to the address. A withdrawal the hot wallet cannot cover together with
+// the gas of the transfer fails with ErrInsufficientHotWallet and raises an alert, so cold storage can top it up.
+func (t *Treasury) Withdraw(to common.Address, amount *big.Int) error {
+	if amount.Sign() <= 0 {
+		return errors.New("withdrawal amount must be positive")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	balance, err := t.chain.GetBalance(t.hot.Address().Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get hot wallet balance: %w", err)
+	}
+	fee, err := t.chain.TransferFee()
+	if err != nil {
+		return fmt.Errorf("failed to get transfer fee: %w", err)
+	}
+	required := new(big.Int).Add(amount, fee)
+	if balance.Cmp(required) < 0 {
+		t.alert(AlertInsufficientForWithdrawals, balance, required)
+		return ErrInsufficientHotWallet
+	}
+
+	if err := t.chain.TransferETH(t.hot, to, amount); err != nil {
+		return fmt.Errorf("failed to send withdrawal: %w", err)
+	}
+	t.recordLocked(MovementWithdrawal, t.hot.Address().Hex(), to.Hex(), amount)
+
+	return nil
+}
+
+// Movements returns the audit trail of every movement made by the treasury
+func (t *Treasury) Movements() []Movement {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	movements := make([]Movement, len(t.movements))
+	copy(movements, t.movements)
+
+	return movements
+}
+
+// Rebalance sweeps deposits into the hot wallet, moves any balance above the high watermark into cold storage and
+// raises alerts when the hot wallet runs low.
+func (t *Treasury) Rebalance() error {
+	hotAddress := t.hot.Address()
+
+	if t.config.DepositSweeper != nil {
+		swept, err := t.config.DepositSweeper()
+		if swept != nil && swept.Sign() > 0 {
+			t.record(MovementDepositSweep, "deposits", hotAddress.Hex(), swept)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to sweep deposits: %w", err)
+		}
+	}
+
+	// Withdrawals are not sent while the hot wallet is rebalanced
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	balance, err := t.chain.GetBalance(hotAddress.Hex())
+	if err != nil {
+		return fmt.Errorf("failed to get hot wallet balance: %w", err)
+	}
+
+	// Sweep down to the middle of the band so the next few deposits don't trigger another sweep straight away
+	if balance.Cmp(t.config.HighWatermark) > 0 {
+		target := new(big.Int).Add(t.config.LowWatermark, t.config.HighWatermark)
+		target.Div(target, big.NewInt(2))
+		amount := new(big.Int).Sub(balance, target)
+
+		if err := t.chain.TransferETH(t.hot, t.config.ColdAddress, amount); err != nil {
+			return fmt.Errorf("failed to sweep hot wallet into cold storage: %w", err)
+		}
+		t.recordLocked(MovementColdSweep, hotAddress.Hex(), t.config.ColdAddress.Hex(), amount)
+
+		balance = target
+	}
+
+	if balance.Cmp(t.config.LowWatermark) < 0 {
+		t.alert(AlertHotWalletLow, balance, t.config.LowWatermark)
+	}
+
+	return nil
+}
+
+// Run rebalances on every interval until the context is cancelled
+func (t *Treasury) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.Rebalance(); err != nil {
+			log.Printf("Treasury rebalance failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *Treasury) record(kind MovementKind, from, to string, amount *big.Int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recordLocked(kind, from, to, amount)
+}
+
+// recordLocked is record for callers holding mu
+func (t *Treasury) recordLocked(kind MovementKind, from, to string, amount *big.Int) {
+	t.movements = append(t.movements, Movement{
+		Kind:      kind,
+		From:      from,
+		To:        to,
+		Amount:    new(big.Int).Set(amount),
+		Timestamp: time.Now().UnixNano(),
+	})
+
+	log.Printf("Treasury movement => kind [%s] | from [%s] | to [%s] | amount [%s]", kind, from, to, amount)
+}
+
+func (t *Treasury) alert(kind AlertKind, balance, required *big.Int) {
+	alert := Alert{
+		Kind:       kind,
+		HotBalance: new(big.Int).Set(balance),
+		Required:   new(big.Int).Set(required),
+		Timestamp:  time.Now().UnixNano(),
+	}
+
+	select {
+	case t.alerts <- alert:
+	default:
+		log.Printf("Treasury alert dropped, nobody is reading alerts => kind [%s]", kind)
+	}
+}
@@ -0,0 +1,102 @@
+package treasury
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/signer"
+)
+
+type fakeSigner struct{ address common.Address }
+
+func (s fakeSigner) Address() common.Address { return s.address }
+func (s fakeSigner) SignTx(tx *types.Transaction, _ *big.Int) (*types.Transaction, error) {
+	return tx, nil
+}
+
+type fakeChain struct {
+	balances map[string]*big.Int
+	fee      int64
+}
+
+func (c *fakeChain) GetBalance(address string) (*big.Int, error) {
+	if b, ok := c.balances[address]; ok {
+		return new(big.Int).Set(b), nil
+	}
+	return big.NewInt(0), nil
+}
+
+func (c *fakeChain) TransferETH(s signer.Signer, to common.Address, amount *big.Int) error {
+	from := s.Address().Hex()
+	c.balances[from] = new(big.Int).Sub(c.balances[from], amount)
+	if c.balances[to.Hex()] == nil {
+		c.balances[to.Hex()] = big.NewInt(0)
+	}
+	c.balances[to.Hex()].Add(c.balances[to.Hex()], amount)
+	return nil
+}
+
+func (c *fakeChain) TransferFee() (*big.Int, error) {
+	return big.NewInt(c.fee), nil
+}
+
+func TestRebalance(t *testing.T) {
+	hot := fakeSigner{address: common.HexToAddress("0x01")}
+	cold := common.HexToAddress("0x02")
+	chain := &fakeChain{balances: map[string]*big.Int{hot.address.Hex(): big.NewInt(500)}}
+
+	tr, err := New(chain, hot, Config{
+		ColdAddress:   cold,
+		LowWatermark:  big.NewInt(100),
+		HighWatermark: big.NewInt(300),
+	})
+	require.NoError(t, err)
+
+	// Test case 1: excess above the high watermark is swept to the middle of the band
+	require.NoError(t, tr.Rebalance())
+	require.Equal(t, big.NewInt(200), chain.balances[hot.address.Hex()])
+	require.Equal(t, big.NewInt(300), chain.balances[cold.Hex()])
+	require.Equal(t, 1, len(tr.Movements()))
+	require.Equal(t, MovementColdSweep, tr.Movements()[0].Kind)
+	require.Equal(t, 0, len(tr.Alerts()))
+
+	// Test case 2: withdrawals are paid from the hot wallet
+	user := common.HexToAddress("0x03")
+	require.NoError(t, tr.Withdraw(user, big.NewInt(50)))
+	require.Equal(t, big.NewInt(150), chain.balances[hot.address.Hex()])
+	require.Equal(t, big.NewInt(50), chain.balances[user.Hex()])
+	require.Equal(t, MovementWithdrawal, tr.Movements()[1].Kind)
+
+	// Test case 3: a withdrawal above the hot balance is refused and raises an alert
+	require.ErrorIs(t, tr.Withdraw(user, big.NewInt(250)), ErrInsufficientHotWallet)
+	alert := <-tr.Alerts()
+	require.Equal(t, AlertInsufficientForWithdrawals, alert.Kind)
+	require.Equal(t, big.NewInt(250), alert.Required)
+	require.Equal(t, big.NewInt(150), alert.HotBalance)
+	require.Equal(t, big.NewInt(50), chain.balances[user.Hex()])
+
+	// Test case 4: the hot wallet must cover the gas of the withdrawal too
+	chain.fee = 10
+	require.ErrorIs(t, tr.Withdraw(user, big.NewInt(145)), ErrInsufficientHotWallet)
+	alert = <-tr.Alerts()
+	require.Equal(t, AlertInsufficientForWithdrawals, alert.Kind)
+	require.Equal(t, big.NewInt(155), alert.Required)
+	require.NoError(t, tr.Withdraw(user, big.NewInt(140)))
+	require.Equal(t, big.NewInt(190), chain.balances[user.Hex()])
+	chain.fee = 0
+
+	// Test case 5: balance below the low watermark raises an alert and moves nothing
+	chain.balances[hot.address.Hex()] = big.NewInt(50)
+	require.NoError(t, tr.Rebalance())
+	alert = <-tr.Alerts()
+	require.Equal(t, AlertHotWalletLow, alert.Kind)
+	require.Equal(t, 3, len(tr.Movements()))
+}
+
+func TestNew_InvalidWatermarks(t *testing.T) {
+	_, err := New(&fakeChain{}, fakeSigner{}, Config{LowWatermark: big.NewInt(2), HighWatermark: big.NewInt(1)})
+	require.Error(t, err)
+}
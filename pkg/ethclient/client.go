@@ -2,16 +2,18 @@ package ethclient
 
 import (
 	"context"
-	"crypto/ecdsa"
-	"errors"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/signer"
 )
 
+// transferGasLimit is the gas used by a plain ETH transfer
+const transferGasLimit = uint64(21000)
+
 // Client wraps an Ethereum client with additional functionality
 type Client struct {
 	*ethclient.Client
@@ -39,30 +41,74 @@ func (c *Client) GetBalance(address string) (*big.Int, error) {
 	return c.BalanceAt(context.Background(), common.HexToAddress(address), nil)
 }
 
-// TransferETH transfers ETH from one account to another
-func (c *Client) TransferETH(priKey *ecdsa.PrivateKey, to common.Address, amount *big.Int) error {
-	publicKey := priKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return errors.New("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
-	}
+// WeiToEther converts an amount in wei to ether
+func WeiToEther(wei *big.Int) float64 {
+	ether, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Float64()
+	return ether
+}
+
+// EtherToWei converts an amount in ether to wei, rounded down
+func EtherToWei(ether float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(ether), big.NewFloat(params.Ether)).Int(nil)
+	return wei
+}
 
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	nonce, err := c.PendingNonceAt(context.Background(), fromAddress)
+// TransferETH transfers ETH from the signer's account to another account
+func (c *Client) TransferETH(s signer.Signer, to common.Address, amount *big.Int) error {
+	gasPrice, err := c.SuggestGasPrice(context.Background())
 	if err != nil {
 		return err
 	}
 
-	gasLimit := uint64(21000) // in units
+	return c.sendETH(s, to, amount, gasPrice)
+}
+
+// TransferFee returns the fee of a plain ETH transfer at the suggested gas price
+func (c *Client) TransferFee() (*big.Int, error) {
+	gasPrice, err := c.SuggestGasPrice(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(transferGasLimit)), nil
+}
+
+// SweepETH transfers the whole balance of the signer's account, minus the transaction fee, to another account.
+// It returns the amount swept, which is zero when the balance does not cover the fee.
+func (c *Client) SweepETH(s signer.Signer, to common.Address) (*big.Int, error) {
+	balance, err := c.BalanceAt(context.Background(), s.Address(), nil)
+	if err != nil {
+		return nil, err
+	}
+
 	gasPrice, err := c.SuggestGasPrice(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	fee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(transferGasLimit))
+	amount := new(big.Int).Sub(balance, fee)
+	if amount.Sign() <= 0 {
+		return big.NewInt(0), nil
+	}
+
+	if err := c.sendETH(s, to, amount, gasPrice); err != nil {
+		return nil, err
+	}
+
+	return amount, nil
+}
+
+func (c *Client) sendETH(s signer.Signer, to common.Address, amount, gasPrice *big.Int) error {
+	nonce, err := c.PendingNonceAt(context.Background(), s.Address())
 	if err != nil {
 		return err
 	}
 
 	var data []byte
-	tx := types.NewTransaction(nonce, to, amount, gasLimit, gasPrice, data)
+	tx := types.NewTransaction(nonce, to, amount, transferGasLimit, gasPrice, data)
 
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(c.ChainID), priKey)
+	signedTx, err := s.SignTx(tx, c.ChainID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,235 @@
+package fix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// AcceptorConfig configures an acceptor
+type AcceptorConfig struct {
+	// BeginString is the FIX version spoken, FIX.4.4 when empty
+	BeginString string
+	// CompID is the CompID of the acceptor, counterparties send it as their TargetCompID
+	CompID string
+	// Store keeps the sessions, they are kept in memory when nil
+	Store Store
+	// LogonTimeout is how long a connection may take to log on, DefaultLogonTimeout when zero
+	LogonTimeout time.Duration
+}
+
+// Acceptor accepts FIX sessions from counterparties, a counterparty is identified by its SenderCompID and may have
+// one session at a time
+type Acceptor struct {
+	config AcceptorConfig
+	app    Application
+
+	mu       sync.Mutex
+	listener net.Listener
+	sessions map[SessionID]*Session
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewAcceptor creates an acceptor delivering the sessions to app
+func NewAcceptor(config AcceptorConfig, app Application) *Acceptor {
+	if config.BeginString == "" {
+		config.BeginString = BeginString44
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.LogonTimeout == 0 {
+		config.LogonTimeout = DefaultLogonTimeout
+	}
+
+	return &Acceptor{
+		config:   config,
+		app:      app,
+		sessions: make(map[SessionID]*Session),
+	}
+}
+
+// Serve accepts connections on the listener until the acceptor is closed, it then returns nil
+func (a *Acceptor) Serve(l net.Listener) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.listener = l
+	a.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			a.mu.Lock()
+			closed := a.closed
+			a.mu.Unlock()
+			if closed {
+				return nil
+			}
+
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			if err := a.serveConn(conn); err != nil {
+				log.Printf("fix: connection from %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Close stops accepting connections and logs every session out
+func (a *Acceptor) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	var err error
+	if a.listener != nil {
+		err = a.listener.Close()
+	}
+	sessions := make([]*Session, 0, len(a.sessions))
+	for _, s := range a.sessions {
+		sessions = append(sessions, s)
+	}
+	a.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range sessions {
+		wg.Add(1)
+		go func(s *Session) {
+			defer wg.Done()
+			s.Logout("acceptor shutting down")
+		}(s)
+	}
+	wg.Wait()
+	a.wg.Wait()
+
+	return err
+}
+
+// serveConn logs the connection on and runs its session
+func (a *Acceptor) serveConn(conn net.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(a.config.LogonTimeout))
+
+	s, logon, err := a.readLogon(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if !a.register(s) {
+		conn.Close()
+		return fmt.Errorf("session %s is already logged on", s.id)
+	}
+
+	if err := a.logon(s, logon); err != nil {
+		s.close(err)
+		return fmt.Errorf("logon of %s failed: %w", s.id, err)
+	}
+
+	conn.SetReadDeadline(time.Time{})
+	s.run()
+	return nil
+}
+
+// readLogon reads the first message of a connection, which must be a Logon to this acceptor, and creates its session
+func (a *Acceptor) readLogon(conn net.Conn) (*Session, *Message, error) {
+	reader := bufio.NewReader(conn)
+	data, err := ReadMessage(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	logon, beginString, err := Parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case logon.Type() != MsgTypeLogon:
+		return nil, nil, fmt.Errorf("expected Logon, received MsgType %s", logon.Type())
+	case beginString != a.config.BeginString:
+		return nil, nil, fmt.Errorf("unsupported BeginString %s", beginString)
+	case logon.Get(TagTargetCompID) != a.config.CompID:
+		return nil, nil, fmt.Errorf("unknown TargetCompID %s", logon.Get(TagTargetCompID))
+	case logon.Get(TagSenderCompID) == "":
+		return nil, nil, errors.New("SenderCompID is required")
+	}
+
+	heartBtInt, err := logon.Int(TagHeartBtInt)
+	if err != nil || heartBtInt < 0 {
+		return nil, nil, errors.New("HeartBtInt is required")
+	}
+
+	id := SessionID{
+		BeginString:  beginString,
+		SenderCompID: a.config.CompID,
+		TargetCompID: logon.Get(TagSenderCompID),
+	}
+	s, err := newSession(id, conn, reader, a.config.Store, a.app)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.heartBtInt = time.Duration(heartBtInt) * time.Second
+
+	return s, logon, nil
+}
+
+// logon answers the Logon of the counterparty with the Logon of the acceptor
+func (a *Acceptor) logon(s *Session, logon *Message) error {
+	reply := NewMessage(MsgTypeLogon).
+		SetInt(TagEncryptMethod, 0).
+		SetInt(TagHeartBtInt, int64(s.heartBtInt/time.Second))
+
+	return s.logon(logon, reply)
+}
+
+// register adds the session unless its counterparty is logged on already
+func (a *Acceptor) register(s *Session) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return false
+	}
+	if _, exists := a.sessions[s.id]; exists {
+		return false
+	}
+	a.sessions[s.id] = s
+	s.onClose = a.unregister
+
+	return true
+}
+
+func (a *Acceptor) unregister(s *Session) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.sessions[s.id] == s {
+		delete(a.sessions, s.id)
+	}
+}
+
+// Sessions returns the sessions that are currently connected
+func (a *Acceptor) Sessions() []*Session {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	sessions := make([]*Session, 0, len(a.sessions))
+	for _, s := range a.sessions {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
@@ -0,0 +1,147 @@
+package fix
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	bucketSessions = []byte("sessions")
+	bucketMessages = []byte("messages")
+
+	keySeqNums = []byte("seqnums")
+)
+
+// BoltStore keeps the sessions in a bbolt database file, each session is a bucket holding its sequence numbers and a
+// bucket of its sent messages keyed by sequence number
+type BoltStore struct {
+	db *bbolt.DB
+}
+
+// OpenBoltStore opens the store file at path, creating it if needed
+func OpenBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketSessions)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Close closes the store file
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// session returns the bucket of the session, creating it when create is set. It is nil for a new session otherwise.
+func session(tx *bbolt.Tx, id SessionID, create bool) (*bbolt.Bucket, error) {
+	sessions := tx.Bucket(bucketSessions)
+	if !create {
+		return sessions.Bucket([]byte(id.String())), nil
+	}
+
+	bucket, err := sessions.CreateBucketIfNotExists([]byte(id.String()))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := bucket.CreateBucketIfNotExists(bucketMessages); err != nil {
+		return nil, err
+	}
+	return bucket, nil
+}
+
+// SeqNums returns the next sequence numbers of the session
+func (s *BoltStore) SeqNums(id SessionID) (uint64, uint64, error) {
+	sender, target := uint64(1), uint64(1)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket, err := session(tx, id, false)
+		if err != nil || bucket == nil {
+			return err
+		}
+		if v := bucket.Get(keySeqNums); len(v) == 16 {
+			sender, target = binary.BigEndian.Uint64(v[:8]), binary.BigEndian.Uint64(v[8:])
+		}
+		return nil
+	})
+	return sender, target, err
+}
+
+// SetSeqNums stores the next sequence numbers of the session
+func (s *BoltStore) SetSeqNums(id SessionID, sender, target uint64) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := session(tx, id, true)
+		if err != nil {
+			return err
+		}
+
+		v := make([]byte, 16)
+		binary.BigEndian.PutUint64(v[:8], sender)
+		binary.BigEndian.PutUint64(v[8:], target)
+		return bucket.Put(keySeqNums, v)
+	})
+}
+
+// SaveMessage stores a sent message
+func (s *BoltStore) SaveMessage(id SessionID, seqNum uint64, msg []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := session(tx, id, true)
+		if err != nil {
+			return err
+		}
+		return bucket.Bucket(bucketMessages).Put(itob(seqNum), msg)
+	})
+}
+
+// Messages returns the stored messages in the range
+func (s *BoltStore) Messages(id SessionID, begin, end uint64) (map[uint64][]byte, error) {
+	messages := make(map[uint64][]byte)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket, err := session(tx, id, false)
+		if err != nil || bucket == nil {
+			return err
+		}
+
+		c := bucket.Bucket(bucketMessages).Cursor()
+		for k, v := c.Seek(itob(begin)); k != nil && binary.BigEndian.Uint64(k) <= end; k, v = c.Next() {
+			messages[binary.BigEndian.Uint64(k)] = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return messages, err
+}
+
+// Reset starts the session over
+func (s *BoltStore) Reset(id SessionID) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(bucketSessions).DeleteBucket([]byte(id.String()))
+		if err == bbolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// itob encodes a sequence number as a key that sorts in sequence
+func itob(seqNum uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seqNum)
+	return key
+}
@@ -0,0 +1,113 @@
+package fix
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"time"
+)
+
+// InitiatorConfig configures a session an initiator opens
+type InitiatorConfig struct {
+	// BeginString is the FIX version spoken, FIX.4.4 when empty
+	BeginString string
+	// SenderCompID and TargetCompID are the CompIDs of the initiator and of the acceptor
+	SenderCompID string
+	TargetCompID string
+	// HeartBtInt is the heartbeat interval proposed to the acceptor, in whole seconds. Zero disables heartbeats.
+	HeartBtInt time.Duration
+	// Store keeps the session, it is kept in memory when nil
+	Store Store
+	// ResetSeqNum starts the session over with sequence numbers 1
+	ResetSeqNum bool
+	// Logon adds fields such as credentials to the Logon, its SendingTime is already set
+	Logon func(logon *Message)
+	// LogonTimeout is how long the acceptor may take to answer the Logon, DefaultLogonTimeout when zero
+	LogonTimeout time.Duration
+}
+
+// Dial connects to an acceptor and logs a session on, the session runs until it is logged out or the connection is
+// lost. A refused logon returns ErrLogonRefused with the Text of the acceptor's Logout.
+func Dial(addr string, config InitiatorConfig, app Application) (*Session, error) {
+	if config.BeginString == "" {
+		config.BeginString = BeginString44
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.LogonTimeout == 0 {
+		config.LogonTimeout = DefaultLogonTimeout
+	}
+
+	id := SessionID{
+		BeginString:  config.BeginString,
+		SenderCompID: config.SenderCompID,
+		TargetCompID: config.TargetCompID,
+	}
+	if config.ResetSeqNum {
+		if err := config.Store.Reset(id); err != nil {
+			return nil, fmt.Errorf("failed to reset sequence numbers: %w", err)
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, config.LogonTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newSession(id, conn, bufio.NewReader(conn), config.Store, app)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.heartBtInt = config.HeartBtInt.Truncate(time.Second)
+
+	if err := s.initiate(config); err != nil {
+		s.close(err)
+		return nil, err
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// initiate sends the Logon and waits for the acceptor's
+func (s *Session) initiate(config InitiatorConfig) error {
+	logon := NewMessage(MsgTypeLogon).
+		SetTime(TagSendingTime, time.Now()).
+		SetInt(TagEncryptMethod, 0).
+		SetInt(TagHeartBtInt, int64(s.heartBtInt/time.Second))
+	if config.ResetSeqNum {
+		logon.SetBool(TagResetSeqNumFlag, true)
+	}
+	if config.Logon != nil {
+		config.Logon(logon)
+	}
+
+	s.mu.Lock()
+	err := s.send(logon)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.conn.SetReadDeadline(time.Now().Add(config.LogonTimeout))
+	defer s.conn.SetReadDeadline(time.Time{})
+
+	data, err := ReadMessage(s.reader)
+	if err != nil {
+		return err
+	}
+	reply, _, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	switch reply.Type() {
+	case MsgTypeLogon:
+		return s.logon(reply, nil)
+	case MsgTypeLogout:
+		return fmt.Errorf("%w: %s", ErrLogonRefused, reply.Get(TagText))
+	default:
+		return fmt.Errorf("expected Logon, received MsgType %s", reply.Type())
+	}
+}
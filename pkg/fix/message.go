@@ -0,0 +1,361 @@
+// Package fix implements the session layer of the FIX protocol over TCP: message encoding, logon, heartbeats,
+// persisted sequence numbers, resend requests and gap fills. Applications get the messages of a logged on session in
+// sequence and send theirs with Session.Send. Repeating groups and binary data fields are not supported.
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	// BeginString44 is the BeginString of FIX 4.4
+	BeginString44 = "FIX.4.4"
+
+	// SOH separates the fields of a message
+	SOH = '\x01'
+
+	// MaxMessageSize is the largest body length a reader accepts
+	MaxMessageSize = 64 << 10
+
+	// TimestampFormat is the layout of UTCTimestamp fields
+	TimestampFormat = "20060102-15:04:05.000"
+)
+
+var (
+	// ErrMalformed is returned for data that is not a FIX message
+	ErrMalformed = errors.New("malformed message")
+	// ErrFieldNotFound is returned when reading a field a message does not have
+	ErrFieldNotFound = errors.New("field not found")
+)
+
+// Tag is the number of a field
+type Tag int
+
+// Field is a tag and its value
+type Field struct {
+	Tag   Tag
+	Value string
+}
+
+// Message is a FIX message without its framing fields BeginString, BodyLength and CheckSum, which are added when it
+// is encoded. Fields keep the order they were set in, the standard header fields are encoded first.
+type Message struct {
+	Fields []Field
+}
+
+// NewMessage creates a message of the type
+func NewMessage(msgType string) *Message {
+	return &Message{Fields: []Field{{Tag: TagMsgType, Value: msgType}}}
+}
+
+// Type returns the MsgType of the message
+func (m *Message) Type() string {
+	return m.Get(TagMsgType)
+}
+
+// Has tells whether the message has the field
+func (m *Message) Has(tag Tag) bool {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the value of the field, it is empty when the message does not have it
+func (m *Message) Get(tag Tag) string {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Set sets the value of the field, replacing the current one
+func (m *Message) Set(tag Tag, value string) *Message {
+	for i := range m.Fields {
+		if m.Fields[i].Tag == tag {
+			m.Fields[i].Value = value
+			return m
+		}
+	}
+	m.Fields = append(m.Fields, Field{Tag: tag, Value: value})
+	return m
+}
+
+// SetInt sets an integer field
+func (m *Message) SetInt(tag Tag, value int64) *Message {
+	return m.Set(tag, strconv.FormatInt(value, 10))
+}
+
+// SetUint sets an unsigned integer field
+func (m *Message) SetUint(tag Tag, value uint64) *Message {
+	return m.Set(tag, strconv.FormatUint(value, 10))
+}
+
+// SetFloat sets a decimal field with as many digits as needed
+func (m *Message) SetFloat(tag Tag, value float64) *Message {
+	return m.Set(tag, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// SetBool sets a Boolean field to Y or N
+func (m *Message) SetBool(tag Tag, value bool) *Message {
+	if value {
+		return m.Set(tag, "Y")
+	}
+	return m.Set(tag, "N")
+}
+
+// SetTime sets a UTCTimestamp field with millisecond precision
+func (m *Message) SetTime(tag Tag, value time.Time) *Message {
+	return m.Set(tag, value.UTC().Format(TimestampFormat))
+}
+
+// Remove removes the field
+func (m *Message) Remove(tag Tag) {
+	fields := m.Fields[:0]
+	for _, f := range m.Fields {
+		if f.Tag != tag {
+			fields = append(fields, f)
+		}
+	}
+	m.Fields = fields
+}
+
+// Int returns the value of an integer field
+func (m *Message) Int(tag Tag) (int64, error) {
+	value, err := m.value(tag)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: tag %d is not an integer", ErrMalformed, tag)
+	}
+	return i, nil
+}
+
+// Uint returns the value of an unsigned integer field
+func (m *Message) Uint(tag Tag) (uint64, error) {
+	value, err := m.value(tag)
+	if err != nil {
+		return 0, err
+	}
+	u, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: tag %d is not an unsigned integer", ErrMalformed, tag)
+	}
+	return u, nil
+}
+
+// Float returns the value of a decimal field
+func (m *Message) Float(tag Tag) (float64, error) {
+	value, err := m.value(tag)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: tag %d is not a number", ErrMalformed, tag)
+	}
+	return f, nil
+}
+
+// Bool tells whether a Boolean field is Y, a missing field is false
+func (m *Message) Bool(tag Tag) bool {
+	return m.Get(tag) == "Y"
+}
+
+// Time returns the value of a UTCTimestamp field, with or without milliseconds
+func (m *Message) Time(tag Tag) (time.Time, error) {
+	value, err := m.value(tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range []string{TimestampFormat, "20060102-15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: tag %d is not a timestamp", ErrMalformed, tag)
+}
+
+func (m *Message) value(tag Tag) (string, error) {
+	for _, f := range m.Fields {
+		if f.Tag == tag {
+			return f.Value, nil
+		}
+	}
+	return "", fmt.Errorf("%w: tag %d", ErrFieldNotFound, tag)
+}
+
+// Copy returns a copy of the message
+func (m *Message) Copy() *Message {
+	return &Message{Fields: append([]Field(nil), m.Fields...)}
+}
+
+// headerTags are the standard header fields in the order they are encoded in, after BeginString and BodyLength
+var headerTags = []Tag{TagMsgType, TagSenderCompID, TagTargetCompID, TagMsgSeqNum, TagPossDupFlag, TagSendingTime, TagOrigSendingTime}
+
+func isHeaderTag(tag Tag) bool {
+	for _, t := range headerTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Bytes encodes the message with the BeginString, its BodyLength and CheckSum
+func (m *Message) Bytes(beginString string) []byte {
+	var body bytes.Buffer
+	for _, tag := range headerTags {
+		for _, f := range m.Fields {
+			if f.Tag == tag {
+				writeField(&body, f)
+				break
+			}
+		}
+	}
+	for _, f := range m.Fields {
+		if !isHeaderTag(f.Tag) && f.Tag != TagBeginString && f.Tag != TagBodyLength && f.Tag != TagCheckSum {
+			writeField(&body, f)
+		}
+	}
+
+	var msg bytes.Buffer
+	writeField(&msg, Field{Tag: TagBeginString, Value: beginString})
+	writeField(&msg, Field{Tag: TagBodyLength, Value: strconv.Itoa(body.Len())})
+	msg.Write(body.Bytes())
+	writeField(&msg, Field{Tag: TagCheckSum, Value: fmt.Sprintf("%03d", checksum(msg.Bytes()))})
+
+	return msg.Bytes()
+}
+
+// String returns the message with its fields separated by |, for logging
+func (m *Message) String() string {
+	var b bytes.Buffer
+	for i, f := range m.Fields {
+		if i > 0 {
+			b.WriteByte('|')
+		}
+		fmt.Fprintf(&b, "%d=%s", f.Tag, f.Value)
+	}
+	return b.String()
+}
+
+func writeField(b *bytes.Buffer, f Field) {
+	b.WriteString(strconv.Itoa(int(f.Tag)))
+	b.WriteByte('=')
+	b.WriteString(f.Value)
+	b.WriteByte(SOH)
+}
+
+func checksum(data []byte) int {
+	var sum int
+	for _, c := range data {
+		sum += int(c)
+	}
+	return sum % 256
+}
+
+// Parse decodes a message, checking its framing, BodyLength and CheckSum. It returns the message and its BeginString.
+func Parse(data []byte) (*Message, string, error) {
+	fields, err := splitFields(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(fields) < 4 || fields[0].Tag != TagBeginString || fields[1].Tag != TagBodyLength || fields[2].Tag != TagMsgType {
+		return nil, "", fmt.Errorf("%w: a message starts with BeginString, BodyLength and MsgType", ErrMalformed)
+	}
+	last := fields[len(fields)-1]
+	if last.Tag != TagCheckSum {
+		return nil, "", fmt.Errorf("%w: a message ends with CheckSum", ErrMalformed)
+	}
+
+	bodyStart := len(fields[0].Value) + len(fields[1].Value) + 6
+	bodyEnd := len(data) - len(last.Value) - 4
+	length, err := strconv.Atoi(fields[1].Value)
+	if err != nil || length != bodyEnd-bodyStart {
+		return nil, "", fmt.Errorf("%w: wrong BodyLength", ErrMalformed)
+	}
+	sum, err := strconv.Atoi(last.Value)
+	if err != nil || len(last.Value) != 3 || sum != checksum(data[:bodyEnd]) {
+		return nil, "", fmt.Errorf("%w: wrong CheckSum", ErrMalformed)
+	}
+
+	return &Message{Fields: fields[2 : len(fields)-1]}, fields[0].Value, nil
+}
+
+func splitFields(data []byte) ([]Field, error) {
+	if len(data) == 0 || data[len(data)-1] != SOH {
+		return nil, fmt.Errorf("%w: a message ends with SOH", ErrMalformed)
+	}
+
+	var fields []Field
+	for _, raw := range bytes.Split(data[:len(data)-1], []byte{SOH}) {
+		i := bytes.IndexByte(raw, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("%w: field %q", ErrMalformed, raw)
+		}
+		tag, err := strconv.Atoi(string(raw[:i]))
+		if err != nil || tag <= 0 {
+			return nil, fmt.Errorf("%w: tag %q", ErrMalformed, raw[:i])
+		}
+		fields = append(fields, Field{Tag: Tag(tag), Value: string(raw[i+1:])})
+	}
+	return fields, nil
+}
+
+// ReadMessage reads the next message from r, framed by its BodyLength and CheckSum. The message is returned
+// undecoded, Parse checks it.
+func ReadMessage(r *bufio.Reader) ([]byte, error) {
+	begin, err := r.ReadSlice(SOH)
+	if err != nil {
+		return nil, readError(err)
+	}
+	if !bytes.HasPrefix(begin, []byte("8=")) {
+		return nil, fmt.Errorf("%w: expected BeginString", ErrMalformed)
+	}
+	msg := append([]byte(nil), begin...)
+
+	bodyLength, err := r.ReadSlice(SOH)
+	if err != nil {
+		return nil, readError(err)
+	}
+	if !bytes.HasPrefix(bodyLength, []byte("9=")) {
+		return nil, fmt.Errorf("%w: expected BodyLength", ErrMalformed)
+	}
+	length, err := strconv.Atoi(string(bodyLength[2 : len(bodyLength)-1]))
+	if err != nil || length <= 0 || length > MaxMessageSize {
+		return nil, fmt.Errorf("%w: invalid BodyLength", ErrMalformed)
+	}
+	msg = append(msg, bodyLength...)
+
+	// The body is followed by the CheckSum field, which is always 10=nnn and SOH
+	rest := make([]byte, length+7)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, readError(err)
+	}
+	if !bytes.HasPrefix(rest[length:], []byte("10=")) || rest[len(rest)-1] != SOH {
+		return nil, fmt.Errorf("%w: expected CheckSum", ErrMalformed)
+	}
+
+	return append(msg, rest...), nil
+}
+
+// readError reports fields longer than the read buffer as malformed
+func readError(err error) error {
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return fmt.Errorf("%w: field too long", ErrMalformed)
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package fix
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage(t *testing.T) {
+	sendingTime := time.Date(2023, 5, 1, 12, 30, 15, 250e6, time.UTC)
+
+	// Test case 1: the header fields are encoded first, with the BodyLength and CheckSum of the message
+	msg := NewMessage(MsgTypeNewOrderSingle).
+		Set(TagClOrdID, "order-1").
+		SetFloat(TagPrice, 1800.5).
+		Set(TagSenderCompID, "CLIENT").
+		Set(TagTargetCompID, "EXCHANGE").
+		SetUint(TagMsgSeqNum, 7).
+		SetTime(TagSendingTime, sendingTime)
+	data := msg.Bytes(BeginString44)
+	require.Equal(t, "8=FIX.4.4|9=78|35=D|49=CLIENT|56=EXCHANGE|34=7|52=20230501-12:30:15.250|11=order-1|44=1800.5|10=211|",
+		strings.ReplaceAll(string(data), "\x01", "|"))
+
+	parsed, beginString, err := Parse(data)
+	require.NoError(t, err)
+	require.Equal(t, BeginString44, beginString)
+	require.Equal(t, MsgTypeNewOrderSingle, parsed.Type())
+	require.Equal(t, "order-1", parsed.Get(TagClOrdID))
+	price, err := parsed.Float(TagPrice)
+	require.NoError(t, err)
+	require.Equal(t, 1800.5, price)
+	seqNum, err := parsed.Uint(TagMsgSeqNum)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), seqNum)
+	parsedTime, err := parsed.Time(TagSendingTime)
+	require.NoError(t, err)
+	require.Equal(t, sendingTime, parsedTime)
+
+	// Test case 2: missing and malformed fields are reported
+	_, err = parsed.Int(TagOrderQty)
+	require.ErrorIs(t, err, ErrFieldNotFound)
+	_, err = parsed.Float(TagClOrdID)
+	require.ErrorIs(t, err, ErrMalformed)
+	require.False(t, parsed.Bool(TagPossDupFlag))
+
+	// Test case 3: messages with a wrong BodyLength or CheckSum are rejected
+	_, _, err = Parse(bytes.Replace(data, []byte("9=78"), []byte("9=77"), 1))
+	require.ErrorIs(t, err, ErrMalformed)
+	_, _, err = Parse(bytes.Replace(data, []byte("10=211"), []byte("10=212"), 1))
+	require.ErrorIs(t, err, ErrMalformed)
+	_, _, err = Parse(bytes.Replace(data, []byte("order-1"), []byte("order-2"), 1))
+	require.ErrorIs(t, err, ErrMalformed)
+	_, _, err = Parse([]byte("35=D\x0111=order-1\x01"))
+	require.ErrorIs(t, err, ErrMalformed)
+
+	// Test case 4: a reader splits a stream into messages
+	heartbeat := NewMessage(MsgTypeHeartbeat).SetUint(TagMsgSeqNum, 8).Bytes(BeginString44)
+	reader := bufio.NewReader(bytes.NewReader(append(append([]byte(nil), data...), heartbeat...)))
+
+	read, err := ReadMessage(reader)
+	require.NoError(t, err)
+	require.Equal(t, data, read)
+	read, err = ReadMessage(reader)
+	require.NoError(t, err)
+	require.Equal(t, heartbeat, read)
+	_, err = ReadMessage(reader)
+	require.ErrorIs(t, err, io.EOF)
+
+	_, err = ReadMessage(bufio.NewReader(strings.NewReader("8=FIX.4.4\x019=999999999\x01")))
+	require.ErrorIs(t, err, ErrMalformed)
+}
@@ -0,0 +1,624 @@
+package fix
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultLogonTimeout is how long a connection may take to log on
+	DefaultLogonTimeout = 10 * time.Second
+	// logoutTimeout is how long a session waits for the counterparty to confirm its Logout
+	logoutTimeout = 2 * time.Second
+	// writeTimeout bounds a write to a counterparty that stopped reading
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	// ErrNotLoggedOn is returned when sending on a session that is not logged on
+	ErrNotLoggedOn = errors.New("session is not logged on")
+	// ErrLogonRefused is returned when the counterparty answered a Logon with a Logout
+	ErrLogonRefused = errors.New("logon refused")
+	// errLoggedOut ends a session that was logged out by either side
+	errLoggedOut = errors.New("logged out")
+)
+
+// SessionID identifies a session by the FIX version and the CompIDs, SenderCompID is the local one
+type SessionID struct {
+	BeginString  string
+	SenderCompID string
+	TargetCompID string
+}
+
+func (id SessionID) String() string {
+	return id.BeginString + ":" + id.SenderCompID + "->" + id.TargetCompID
+}
+
+// Application handles the events of sessions. The callbacks of a session are made one at a time from its reader, a
+// slow application delays the session.
+type Application interface {
+	// FromLogon is called with the Logon of the counterparty before the session is logged on, an error refuses the
+	// logon and is sent as the Text of the Logout
+	FromLogon(s *Session, logon *Message) error
+	// OnLogon is called when the session is logged on, before the first message of the counterparty is delivered
+	OnLogon(s *Session)
+	// FromApp is called with the application messages of the counterparty in sequence
+	FromApp(s *Session, msg *Message)
+	// OnLogout is called once when a session ends whose Logon FromLogon accepted, even if it never logged on
+	OnLogout(s *Session)
+}
+
+// Session is a FIX session on a connection. Sequence numbers are kept in the store, so they continue when the
+// counterparty reconnects, and sent application messages are resent from it on request.
+type Session struct {
+	id         SessionID
+	conn       net.Conn
+	reader     *bufio.Reader
+	store      Store
+	app        Application
+	heartBtInt time.Duration
+
+	// mu serializes the writes and guards the sequence numbers and the logon state
+	mu         sync.Mutex
+	nextSender uint64
+	nextTarget uint64
+	loggedOn   bool
+	logoutSent bool
+	// accepted is set once the application accepted the counterparty's Logon
+	accepted bool
+
+	// resendTo is the highest sequence number received ahead of a gap, it is zero when no resend is outstanding.
+	// It is only used by the reader.
+	resendTo uint64
+
+	lastSent     atomic.Int64
+	lastReceived atomic.Int64
+	testReqID    atomic.Value
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+	// onClose is called when the session ended, the acceptor forgets the session with it
+	onClose func(*Session)
+}
+
+func newSession(id SessionID, conn net.Conn, reader *bufio.Reader, store Store, app Application) (*Session, error) {
+	sender, target, err := store.SeqNums(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sequence numbers: %w", err)
+	}
+
+	s := &Session{
+		id:         id,
+		conn:       conn,
+		reader:     reader,
+		store:      store,
+		app:        app,
+		nextSender: sender,
+		nextTarget: target,
+		done:       make(chan struct{}),
+	}
+	s.testReqID.Store("")
+	now := time.Now().UnixNano()
+	s.lastSent.Store(now)
+	s.lastReceived.Store(now)
+
+	return s, nil
+}
+
+// ID returns the ID of the session
+func (s *Session) ID() SessionID {
+	return s.id
+}
+
+// SeqNums returns the next sequence number to send and the next one expected
+func (s *Session) SeqNums() (sender, target uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.nextSender, s.nextTarget
+}
+
+// Done is closed when the session ended
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the session ended, it is nil after a logout
+func (s *Session) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Send sends an application message with the next sequence number. It is safe for concurrent use.
+func (s *Session) Send(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.loggedOn {
+		return ErrNotLoggedOn
+	}
+	return s.send(msg)
+}
+
+// Reject sends a session level Reject of the message
+func (s *Session) Reject(ref *Message, reason int, text string) error {
+	reject := NewMessage(MsgTypeReject).
+		Set(TagRefSeqNum, ref.Get(TagMsgSeqNum)).
+		Set(TagRefMsgType, ref.Type()).
+		SetInt(TagRejectReason, int64(reason))
+	if text != "" {
+		reject.Set(TagText, text)
+	}
+	return s.Send(reject)
+}
+
+// Logout logs the session out and waits until the counterparty confirmed it or a timeout passed
+func (s *Session) Logout(text string) {
+	s.mu.Lock()
+	if !s.loggedOn || s.logoutSent {
+		s.mu.Unlock()
+		s.close(nil)
+		return
+	}
+	s.logoutSent = true
+	err := s.send(logoutMessage(text))
+	s.mu.Unlock()
+
+	if err != nil {
+		s.close(err)
+		return
+	}
+
+	select {
+	case <-s.done:
+	case <-time.After(logoutTimeout):
+		s.close(nil)
+	}
+}
+
+// Close ends the session without logging out
+func (s *Session) Close() {
+	s.close(nil)
+}
+
+func logoutMessage(text string) *Message {
+	logout := NewMessage(MsgTypeLogout)
+	if text != "" {
+		logout.Set(TagText, text)
+	}
+	return logout
+}
+
+// send sends the message with the next sequence number, mu is held. Application messages are stored for resending.
+// The SendingTime is the current time unless the message has one.
+func (s *Session) send(msg *Message) error {
+	msg = msg.Copy()
+	seqNum := s.nextSender
+	msg.SetUint(TagMsgSeqNum, seqNum)
+	if !msg.Has(TagSendingTime) {
+		msg.SetTime(TagSendingTime, time.Now())
+	}
+	data := s.encode(msg)
+
+	if !IsAdmin(msg.Type()) {
+		if err := s.store.SaveMessage(s.id, seqNum, data); err != nil {
+			return fmt.Errorf("failed to store message %d: %w", seqNum, err)
+		}
+	}
+	if err := s.store.SetSeqNums(s.id, seqNum+1, s.nextTarget); err != nil {
+		return fmt.Errorf("failed to store sequence numbers: %w", err)
+	}
+	s.nextSender++
+
+	return s.write(data)
+}
+
+// encode adds the CompIDs to the message and encodes it
+func (s *Session) encode(msg *Message) []byte {
+	msg.Set(TagSenderCompID, s.id.SenderCompID).Set(TagTargetCompID, s.id.TargetCompID)
+	return msg.Bytes(s.id.BeginString)
+}
+
+// write writes an encoded message, mu is held. A failed write closes the connection, which ends the session.
+func (s *Session) write(data []byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := s.conn.Write(data); err != nil {
+		s.conn.Close()
+		return err
+	}
+	s.lastSent.Store(time.Now().UnixNano())
+	return nil
+}
+
+// sendAdmin sends a session message, errors end the session through the closed connection
+func (s *Session) sendAdmin(msg *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.send(msg); err != nil {
+		log.Printf("fix: %s: failed to send %s: %v", s.id, msg.Type(), err)
+		s.conn.Close()
+	}
+}
+
+// logon authenticates the counterparty's Logon with the application, checks its sequence number and logs the session
+// on. reply is the Logon reply of an acceptor, the acceptor starts the sequence numbers over when the counterparty asks
+// to. A Logon ahead of the expected sequence number is accepted and the missing messages are requested.
+func (s *Session) logon(logon *Message, reply *Message) error {
+	seqNum, err := logon.Uint(TagMsgSeqNum)
+	if err != nil {
+		return err
+	}
+
+	// Only an authenticated counterparty may learn or reset the sequence numbers
+	if err := s.app.FromLogon(s, logon); err != nil {
+		s.sendAdmin(logoutMessage(err.Error()))
+		return err
+	}
+	s.mu.Lock()
+	s.accepted = true
+	s.mu.Unlock()
+
+	if reply != nil && logon.Bool(TagResetSeqNumFlag) {
+		if err := s.store.Reset(s.id); err != nil {
+			return fmt.Errorf("failed to reset sequence numbers: %w", err)
+		}
+		s.mu.Lock()
+		s.nextSender, s.nextTarget = 1, 1
+		s.mu.Unlock()
+		reply.SetBool(TagResetSeqNumFlag, true)
+	}
+
+	s.mu.Lock()
+	expected := s.nextTarget
+	s.mu.Unlock()
+	if seqNum < expected {
+		err := fmt.Errorf("MsgSeqNum too low, expecting %d but received %d", expected, seqNum)
+		s.sendAdmin(logoutMessage(err.Error()))
+		return err
+	}
+
+	if err := s.loggedOnAt(seqNum, reply); err != nil {
+		return err
+	}
+	s.app.OnLogon(s)
+	return nil
+}
+
+// loggedOnAt sends the Logon reply, marks the session logged on and accounts for the counterparty's Logon
+func (s *Session) loggedOnAt(seqNum uint64, reply *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reply != nil {
+		if err := s.send(reply); err != nil {
+			return err
+		}
+	}
+	s.loggedOn = true
+
+	if seqNum > s.nextTarget {
+		s.resendTo = seqNum
+		return s.send(resendRequest(s.nextTarget))
+	}
+	s.nextTarget++
+	return s.store.SetSeqNums(s.id, s.nextSender, s.nextTarget)
+}
+
+func resendRequest(begin uint64) *Message {
+	return NewMessage(MsgTypeResendRequest).SetUint(TagBeginSeqNo, begin).SetInt(TagEndSeqNo, 0)
+}
+
+// run reads and handles the messages of a logged on session until it ends
+func (s *Session) run() {
+	if s.heartBtInt > 0 {
+		go s.heartbeat()
+	}
+
+	for {
+		data, err := ReadMessage(s.reader)
+		if err != nil {
+			s.close(err)
+			return
+		}
+
+		msg, beginString, err := Parse(data)
+		if err != nil {
+			// Garbled messages are ignored, the sequence gap they leave is detected by the next message
+			log.Printf("fix: %s: ignoring garbled message: %v", s.id, err)
+			continue
+		}
+		if beginString != s.id.BeginString {
+			s.Logout("incorrect BeginString " + beginString)
+			return
+		}
+
+		if err := s.handle(msg); err != nil {
+			if errors.Is(err, errLoggedOut) {
+				err = nil
+			}
+			s.close(err)
+			return
+		}
+	}
+}
+
+// handle checks the header and sequence number of a received message and processes it, an error ends the session
+func (s *Session) handle(msg *Message) error {
+	s.lastReceived.Store(time.Now().UnixNano())
+	s.testReqID.Store("")
+
+	if msg.Get(TagSenderCompID) != s.id.TargetCompID || msg.Get(TagTargetCompID) != s.id.SenderCompID {
+		s.sendAdmin(logoutMessage("CompID problem"))
+		return fmt.Errorf("unexpected CompIDs %s->%s", msg.Get(TagSenderCompID), msg.Get(TagTargetCompID))
+	}
+	seqNum, err := msg.Uint(TagMsgSeqNum)
+	if err != nil {
+		s.sendAdmin(logoutMessage("MsgSeqNum missing"))
+		return err
+	}
+
+	// A SequenceReset without GapFillFlag moves the expected sequence number regardless of the message's own
+	if msg.Type() == MsgTypeSequenceReset && !msg.Bool(TagGapFillFlag) {
+		return s.resetSeqNum(msg)
+	}
+
+	s.mu.Lock()
+	expected := s.nextTarget
+	s.mu.Unlock()
+
+	switch {
+	case seqNum > expected:
+		// Resend requests are served and logouts honored even out of sequence, so two sessions with gaps on both
+		// sides do not wait for each other
+		switch msg.Type() {
+		case MsgTypeResendRequest:
+			if err := s.resend(msg); err != nil {
+				return err
+			}
+		case MsgTypeLogout:
+			return s.loggedOut()
+		}
+		if s.resendTo == 0 {
+			s.sendAdmin(resendRequest(expected))
+		}
+		if seqNum > s.resendTo {
+			s.resendTo = seqNum
+		}
+		return nil
+
+	case seqNum < expected:
+		if msg.Bool(TagPossDupFlag) {
+			return nil
+		}
+		err := fmt.Errorf("MsgSeqNum too low, expecting %d but received %d", expected, seqNum)
+		s.sendAdmin(logoutMessage(err.Error()))
+		return err
+	}
+
+	if err := s.setNextTarget(seqNum + 1); err != nil {
+		return err
+	}
+	if err := s.dispatch(msg); err != nil {
+		return err
+	}
+
+	if s.resendTo != 0 {
+		s.mu.Lock()
+		if s.nextTarget > s.resendTo {
+			s.resendTo = 0
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// dispatch processes a message received in sequence
+func (s *Session) dispatch(msg *Message) error {
+	switch msg.Type() {
+	case MsgTypeHeartbeat, MsgTypeReject:
+	case MsgTypeTestRequest:
+		s.sendAdmin(NewMessage(MsgTypeHeartbeat).Set(TagTestReqID, msg.Get(TagTestReqID)))
+	case MsgTypeResendRequest:
+		return s.resend(msg)
+	case MsgTypeSequenceReset:
+		newSeqNum, err := msg.Uint(TagNewSeqNo)
+		if err != nil {
+			s.Reject(msg, RejectReasonRequiredTagMissing, "NewSeqNo is required")
+			return nil
+		}
+		s.mu.Lock()
+		next := s.nextTarget
+		s.mu.Unlock()
+		if newSeqNum > next {
+			return s.setNextTarget(newSeqNum)
+		}
+	case MsgTypeLogout:
+		return s.loggedOut()
+	case MsgTypeLogon:
+		s.Reject(msg, RejectReasonInvalidMsgType, "session is already logged on")
+	default:
+		s.app.FromApp(s, msg)
+	}
+	return nil
+}
+
+// resetSeqNum handles a SequenceReset in reset mode, the expected sequence number may only increase
+func (s *Session) resetSeqNum(msg *Message) error {
+	newSeqNum, err := msg.Uint(TagNewSeqNo)
+	if err != nil {
+		s.Reject(msg, RejectReasonRequiredTagMissing, "NewSeqNo is required")
+		return nil
+	}
+
+	s.mu.Lock()
+	next := s.nextTarget
+	s.mu.Unlock()
+	if newSeqNum < next {
+		s.Reject(msg, RejectReasonValueIncorrect, fmt.Sprintf("NewSeqNo %d is lower than the expected %d", newSeqNum, next))
+		return nil
+	}
+	s.resendTo = 0
+	return s.setNextTarget(newSeqNum)
+}
+
+func (s *Session) setNextTarget(next uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextTarget = next
+	if err := s.store.SetSeqNums(s.id, s.nextSender, next); err != nil {
+		return fmt.Errorf("failed to store sequence numbers: %w", err)
+	}
+	return nil
+}
+
+// loggedOut confirms the counterparty's Logout, or accepts its confirmation of ours, and ends the session
+func (s *Session) loggedOut() error {
+	s.mu.Lock()
+	if !s.logoutSent {
+		s.logoutSent = true
+		s.send(logoutMessage(""))
+	}
+	s.mu.Unlock()
+
+	return errLoggedOut
+}
+
+// resend answers a ResendRequest. Application messages are resent from the store with PossDupFlag set, session
+// messages and messages missing from the store are skipped with a SequenceReset in gap fill mode.
+func (s *Session) resend(req *Message) error {
+	begin, err := req.Uint(TagBeginSeqNo)
+	if err != nil || begin == 0 {
+		s.Reject(req, RejectReasonRequiredTagMissing, "BeginSeqNo is required")
+		return nil
+	}
+	end, err := req.Uint(TagEndSeqNo)
+	if err != nil {
+		s.Reject(req, RejectReasonRequiredTagMissing, "EndSeqNo is required")
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last := s.nextSender - 1
+	if end == 0 || end > last {
+		end = last
+	}
+	if begin > end {
+		return nil
+	}
+
+	stored, err := s.store.Messages(s.id, begin, end)
+	if err != nil {
+		return fmt.Errorf("failed to load messages to resend: %w", err)
+	}
+
+	var gapStart uint64
+	for seqNum := begin; seqNum <= end; seqNum++ {
+		var msg *Message
+		if data, ok := stored[seqNum]; ok {
+			msg, _, err = Parse(data)
+			if err != nil {
+				msg = nil
+			}
+		}
+		if msg == nil {
+			if gapStart == 0 {
+				gapStart = seqNum
+			}
+			continue
+		}
+
+		if gapStart != 0 {
+			if err := s.gapFill(gapStart, seqNum); err != nil {
+				return err
+			}
+			gapStart = 0
+		}
+
+		msg.SetBool(TagPossDupFlag, true).
+			Set(TagOrigSendingTime, msg.Get(TagSendingTime)).
+			SetTime(TagSendingTime, time.Now())
+		if err := s.write(s.encode(msg)); err != nil {
+			return err
+		}
+	}
+
+	if gapStart != 0 {
+		return s.gapFill(gapStart, end+1)
+	}
+	return nil
+}
+
+// gapFill sends a SequenceReset in gap fill mode taking the place of the messages from seqNum to newSeqNum, mu is held
+func (s *Session) gapFill(seqNum, newSeqNum uint64) error {
+	msg := NewMessage(MsgTypeSequenceReset).
+		SetUint(TagMsgSeqNum, seqNum).
+		SetBool(TagPossDupFlag, true).
+		SetTime(TagSendingTime, time.Now()).
+		SetBool(TagGapFillFlag, true).
+		SetUint(TagNewSeqNo, newSeqNum)
+	return s.write(s.encode(msg))
+}
+
+// heartbeat sends a Heartbeat when nothing was sent for the heartbeat interval and a TestRequest when nothing was
+// received for a bit longer. The session ends when the test request is not answered either.
+func (s *Session) heartbeat() {
+	ticker := time.NewTicker(s.heartBtInt / 4)
+	defer ticker.Stop()
+
+	grace := s.heartBtInt + s.heartBtInt/5
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			if now.Sub(time.Unix(0, s.lastSent.Load())) >= s.heartBtInt {
+				s.sendAdmin(NewMessage(MsgTypeHeartbeat))
+			}
+
+			silence := now.Sub(time.Unix(0, s.lastReceived.Load()))
+			pending := s.testReqID.Load().(string)
+			switch {
+			case pending == "" && silence >= grace:
+				id := strconv.FormatInt(now.UnixNano(), 10)
+				s.testReqID.Store(id)
+				s.sendAdmin(NewMessage(MsgTypeTestRequest).Set(TagTestReqID, id))
+			case pending != "" && silence >= 2*grace:
+				s.close(errors.New("heartbeat timeout"))
+				return
+			}
+		}
+	}
+}
+
+// close ends the session, the application learns about it when the session was logged on
+func (s *Session) close(err error) {
+	s.closeOnce.Do(func() {
+		s.err = err
+		s.conn.Close()
+
+		s.mu.Lock()
+		accepted := s.accepted
+		s.loggedOn = false
+		s.mu.Unlock()
+
+		close(s.done)
+		if accepted {
+			s.app.OnLogout(s)
+		}
+		if s.onClose != nil {
+			s.onClose(s)
+		}
+	})
+}
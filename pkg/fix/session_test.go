@@ -0,0 +1,268 @@
+package fix
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testApp records the events of its sessions
+type testApp struct {
+	refuse   error
+	logons   chan *Session
+	messages chan *Message
+	logouts  chan *Session
+}
+
+func newTestApp() *testApp {
+	return &testApp{
+		logons:   make(chan *Session, 10),
+		messages: make(chan *Message, 100),
+		logouts:  make(chan *Session, 10),
+	}
+}
+
+func (a *testApp) FromLogon(*Session, *Message) error { return a.refuse }
+func (a *testApp) OnLogon(s *Session)                 { a.logons <- s }
+func (a *testApp) FromApp(_ *Session, msg *Message)   { a.messages <- msg }
+func (a *testApp) OnLogout(s *Session)                { a.logouts <- s }
+
+func (a *testApp) next(t *testing.T) *Message {
+	t.Helper()
+	select {
+	case msg := <-a.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func startAcceptor(t *testing.T, store Store) (*Acceptor, *testApp, string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	app := newTestApp()
+	acceptor := NewAcceptor(AcceptorConfig{CompID: "EXCHANGE", Store: store}, app)
+	go acceptor.Serve(l)
+	t.Cleanup(func() { acceptor.Close() })
+
+	return acceptor, app, l.Addr().String()
+}
+
+func initiatorConfig(store Store) InitiatorConfig {
+	return InitiatorConfig{SenderCompID: "CLIENT", TargetCompID: "EXCHANGE", HeartBtInt: 30 * time.Second, Store: store}
+}
+
+func waitSession(t *testing.T, app *testApp) *Session {
+	t.Helper()
+	select {
+	case s := <-app.logons:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("no logon")
+		return nil
+	}
+}
+
+func TestSessionLogon(t *testing.T) {
+	_, acceptorApp, addr := startAcceptor(t, NewMemoryStore())
+	clientStore := NewMemoryStore()
+
+	// Test case 1: a logged on session exchanges application messages in both directions
+	client, err := Dial(addr, initiatorConfig(clientStore), newTestApp())
+	require.NoError(t, err)
+	server := waitSession(t, acceptorApp)
+	require.Equal(t, SessionID{BeginString: BeginString44, SenderCompID: "EXCHANGE", TargetCompID: "CLIENT"}, server.ID())
+
+	require.NoError(t, client.Send(NewMessage(MsgTypeNewOrderSingle).Set(TagClOrdID, "order-1")))
+	msg := acceptorApp.next(t)
+	require.Equal(t, "order-1", msg.Get(TagClOrdID))
+	require.Equal(t, "2", msg.Get(TagMsgSeqNum))
+
+	// Test case 2: a second session of the same counterparty is turned away
+	_, err = Dial(addr, initiatorConfig(NewMemoryStore()), newTestApp())
+	require.Error(t, err)
+
+	// Test case 3: a logout ends the sessions on both sides, the sequence numbers continue on the next logon
+	client.Logout("")
+	require.NoError(t, client.Err())
+	select {
+	case <-acceptorApp.logouts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("acceptor did not log out")
+	}
+	sender, target := client.SeqNums()
+	require.Equal(t, uint64(4), sender)
+	require.Equal(t, uint64(3), target)
+
+	client, err = Dial(addr, initiatorConfig(clientStore), newTestApp())
+	require.NoError(t, err)
+	server = waitSession(t, acceptorApp)
+	sender, target = client.SeqNums()
+	require.Equal(t, uint64(5), sender)
+	require.Equal(t, uint64(4), target)
+	sender, target = server.SeqNums()
+	require.Equal(t, uint64(4), sender)
+	require.Equal(t, uint64(5), target)
+
+	// Test case 4: a logon with a sequence number lower than expected is refused
+	client.Logout("")
+	require.NoError(t, clientStore.SetSeqNums(client.ID(), 2, 4))
+	<-acceptorApp.logouts
+	_, err = Dial(addr, initiatorConfig(clientStore), newTestApp())
+	require.ErrorIs(t, err, ErrLogonRefused)
+	require.Contains(t, err.Error(), "MsgSeqNum too low, expecting 6 but received 2")
+
+	// Test case 5: a reset starts both sides over
+	config := initiatorConfig(clientStore)
+	config.ResetSeqNum = true
+	client, err = Dial(addr, config, newTestApp())
+	require.NoError(t, err)
+	server = waitSession(t, acceptorApp)
+	sender, target = server.SeqNums()
+	require.Equal(t, uint64(2), sender)
+	require.Equal(t, uint64(2), target)
+	client.Close()
+}
+
+func TestSessionRefused(t *testing.T) {
+	store := NewMemoryStore()
+	acceptor, acceptorApp, addr := startAcceptor(t, store)
+	acceptorApp.refuse = errors.New("invalid credentials")
+
+	// Test case 1: the application's error is sent in the Logout
+	_, err := Dial(addr, initiatorConfig(nil), newTestApp())
+	require.ErrorIs(t, err, ErrLogonRefused)
+	require.Contains(t, err.Error(), "invalid credentials")
+
+	// Test case 2: connections that do not start with a Logon to the acceptor are dropped
+	config := initiatorConfig(nil)
+	config.TargetCompID = "SOMEONE_ELSE"
+	_, err = Dial(addr, config, newTestApp())
+	require.Error(t, err)
+	require.Empty(t, acceptor.Sessions())
+
+	// Test case 3: a refused Logon cannot reset the sequence numbers, only the Logout of the acceptor counts
+	id := SessionID{BeginString: BeginString44, SenderCompID: "EXCHANGE", TargetCompID: "CLIENT"}
+	require.NoError(t, store.SetSeqNums(id, 7, 9))
+	config = initiatorConfig(nil)
+	config.ResetSeqNum = true
+	_, err = Dial(addr, config, newTestApp())
+	require.ErrorIs(t, err, ErrLogonRefused)
+	sender, target, err := store.SeqNums(id)
+	require.NoError(t, err)
+	require.Equal(t, uint64(8), sender)
+	require.Equal(t, uint64(9), target)
+}
+
+func TestSessionResend(t *testing.T) {
+	_, acceptorApp, addr := startAcceptor(t, NewMemoryStore())
+	clientStore := NewMemoryStore()
+	clientApp := newTestApp()
+
+	client, err := Dial(addr, initiatorConfig(clientStore), clientApp)
+	require.NoError(t, err)
+	server := waitSession(t, acceptorApp)
+
+	// The acceptor sends two reports around a session message
+	require.NoError(t, server.Send(NewMessage(MsgTypeExecutionReport).Set(TagExecID, "1")))
+	server.sendAdmin(NewMessage(MsgTypeHeartbeat))
+	require.NoError(t, server.Send(NewMessage(MsgTypeExecutionReport).Set(TagExecID, "2")))
+	require.Equal(t, "1", clientApp.next(t).Get(TagExecID))
+	require.Equal(t, "2", clientApp.next(t).Get(TagExecID))
+
+	client.Logout("")
+	<-acceptorApp.logouts
+
+	// Test case 1: a client that lost the reports asks for them on logon, the session message is gap filled
+	require.NoError(t, clientStore.SetSeqNums(client.ID(), 3, 2))
+	client, err = Dial(addr, initiatorConfig(clientStore), clientApp)
+	require.NoError(t, err)
+	server = waitSession(t, acceptorApp)
+
+	first := clientApp.next(t)
+	require.Equal(t, "1", first.Get(TagExecID))
+	require.True(t, first.Bool(TagPossDupFlag))
+	require.True(t, first.Has(TagOrigSendingTime))
+	require.Equal(t, "2", first.Get(TagMsgSeqNum))
+	second := clientApp.next(t)
+	require.Equal(t, "2", second.Get(TagExecID))
+	require.Equal(t, "4", second.Get(TagMsgSeqNum))
+
+	// Test case 2: the sequence numbers agree again after the resend
+	require.NoError(t, server.Send(NewMessage(MsgTypeExecutionReport).Set(TagExecID, "3")))
+	third := clientApp.next(t)
+	require.Equal(t, "3", third.Get(TagExecID))
+	require.False(t, third.Bool(TagPossDupFlag))
+
+	serverSender, _ := server.SeqNums()
+	require.Eventually(t, func() bool {
+		_, clientTarget := client.SeqNums()
+		return clientTarget == serverSender
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Test case 3: a message ahead of the expected sequence number is dropped until the gap is filled
+	server.mu.Lock()
+	server.nextSender++
+	server.mu.Unlock()
+	require.NoError(t, server.Send(NewMessage(MsgTypeExecutionReport).Set(TagExecID, "5")))
+
+	gap := clientApp.next(t)
+	require.Equal(t, "5", gap.Get(TagExecID))
+	require.True(t, gap.Bool(TagPossDupFlag))
+	client.Close()
+}
+
+func TestSessionHeartbeat(t *testing.T) {
+	_, acceptorApp, addr := startAcceptor(t, nil)
+
+	config := initiatorConfig(nil)
+	config.HeartBtInt = time.Second
+	client, err := Dial(addr, config, newTestApp())
+	require.NoError(t, err)
+	server := waitSession(t, acceptorApp)
+
+	// Test case 1: idle sessions exchange heartbeats
+	require.Eventually(t, func() bool {
+		sender, _ := server.SeqNums()
+		_, target := client.SeqNums()
+		return sender >= 3 && target >= 3
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// Test case 2: a test request is answered with a heartbeat
+	before, _ := server.SeqNums()
+	client.sendAdmin(NewMessage(MsgTypeTestRequest).Set(TagTestReqID, "ping"))
+	require.Eventually(t, func() bool {
+		sender, _ := server.SeqNums()
+		return sender > before
+	}, time.Second, 10*time.Millisecond)
+	client.Close()
+
+	// Test case 3: a counterparty that goes silent is tested and then disconnected
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	logon := NewMessage(MsgTypeLogon).
+		Set(TagSenderCompID, "SILENT").
+		Set(TagTargetCompID, "EXCHANGE").
+		SetUint(TagMsgSeqNum, 1).
+		SetTime(TagSendingTime, time.Now()).
+		SetInt(TagEncryptMethod, 0).
+		SetInt(TagHeartBtInt, 1)
+	_, err = conn.Write(logon.Bytes(BeginString44))
+	require.NoError(t, err)
+
+	silent := waitSession(t, acceptorApp)
+	select {
+	case <-silent.Done():
+		require.EqualError(t, silent.Err(), "heartbeat timeout")
+	case <-time.After(5 * time.Second):
+		t.Fatal("silent session was not disconnected")
+	}
+}
@@ -0,0 +1,96 @@
+package fix
+
+import (
+	"sync"
+)
+
+// Store persists the state of sessions across connections: the next sequence numbers and the sent application
+// messages, which are resent on request. Implementations are safe for concurrent use.
+type Store interface {
+	// SeqNums returns the next sequence number to send and the next one expected, both are 1 for a new session
+	SeqNums(id SessionID) (sender, target uint64, err error)
+	// SetSeqNums stores the next sequence number to send and the next one expected
+	SetSeqNums(id SessionID, sender, target uint64) error
+	// SaveMessage stores a sent message under its sequence number
+	SaveMessage(id SessionID, seqNum uint64, msg []byte) error
+	// Messages returns the stored messages from begin to end inclusive by sequence number
+	Messages(id SessionID, begin, end uint64) (map[uint64][]byte, error)
+	// Reset removes the messages of the session and sets both sequence numbers to 1
+	Reset(id SessionID) error
+}
+
+// MemoryStore keeps the sessions in memory, they survive reconnects but not restarts
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[SessionID]*memorySession
+}
+
+type memorySession struct {
+	sender, target uint64
+	messages       map[uint64][]byte
+}
+
+// NewMemoryStore creates an empty in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[SessionID]*memorySession)}
+}
+
+func (s *MemoryStore) session(id SessionID) *memorySession {
+	session, ok := s.sessions[id]
+	if !ok {
+		session = &memorySession{sender: 1, target: 1, messages: make(map[uint64][]byte)}
+		s.sessions[id] = session
+	}
+	return session
+}
+
+// SeqNums returns the next sequence numbers of the session
+func (s *MemoryStore) SeqNums(id SessionID) (uint64, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.session(id)
+	return session.sender, session.target, nil
+}
+
+// SetSeqNums stores the next sequence numbers of the session
+func (s *MemoryStore) SetSeqNums(id SessionID, sender, target uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session := s.session(id)
+	session.sender, session.target = sender, target
+	return nil
+}
+
+// SaveMessage stores a sent message
+func (s *MemoryStore) SaveMessage(id SessionID, seqNum uint64, msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.session(id).messages[seqNum] = append([]byte(nil), msg...)
+	return nil
+}
+
+// Messages returns the stored messages in the range
+func (s *MemoryStore) Messages(id SessionID, begin, end uint64) (map[uint64][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := make(map[uint64][]byte)
+	for seqNum, msg := range s.session(id).messages {
+		if seqNum >= begin && seqNum <= end {
+			messages[seqNum] = msg
+		}
+	}
+	return messages, nil
+}
+
+// Reset starts the session over
+func (s *MemoryStore) Reset(id SessionID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
@@ -0,0 +1,76 @@
+package fix
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	id := SessionID{BeginString: BeginString44, SenderCompID: "EXCHANGE", TargetCompID: "CLIENT"}
+	other := SessionID{BeginString: BeginString44, SenderCompID: "EXCHANGE", TargetCompID: "OTHER"}
+
+	path := filepath.Join(t.TempDir(), "fix.db")
+	bolt, err := OpenBoltStore(path)
+	require.NoError(t, err)
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "bolt": bolt} {
+		t.Run(name, func(t *testing.T) {
+			// Test case 1: a new session starts with sequence numbers 1
+			sender, target, err := store.SeqNums(id)
+			require.NoError(t, err)
+			require.Equal(t, uint64(1), sender)
+			require.Equal(t, uint64(1), target)
+
+			// Test case 2: sequence numbers and messages are kept per session
+			require.NoError(t, store.SetSeqNums(id, 5, 3))
+			require.NoError(t, store.SaveMessage(id, 2, []byte("two")))
+			require.NoError(t, store.SaveMessage(id, 4, []byte("four")))
+			require.NoError(t, store.SaveMessage(other, 3, []byte("other")))
+
+			sender, target, err = store.SeqNums(id)
+			require.NoError(t, err)
+			require.Equal(t, uint64(5), sender)
+			require.Equal(t, uint64(3), target)
+
+			messages, err := store.Messages(id, 2, 3)
+			require.NoError(t, err)
+			require.Equal(t, map[uint64][]byte{2: []byte("two")}, messages)
+			messages, err = store.Messages(id, 1, 10)
+			require.NoError(t, err)
+			require.Len(t, messages, 2)
+
+			// Test case 3: a reset starts the session over without touching the others
+			require.NoError(t, store.Reset(id))
+			sender, target, err = store.SeqNums(id)
+			require.NoError(t, err)
+			require.Equal(t, uint64(1), sender)
+			require.Equal(t, uint64(1), target)
+			messages, err = store.Messages(id, 1, 10)
+			require.NoError(t, err)
+			require.Empty(t, messages)
+
+			messages, err = store.Messages(other, 1, 10)
+			require.NoError(t, err)
+			require.Len(t, messages, 1)
+			require.NoError(t, store.Reset(id))
+		})
+	}
+
+	// Test case 4: the bolt store keeps the sessions when it is reopened
+	require.NoError(t, bolt.SetSeqNums(id, 9, 7))
+	require.NoError(t, bolt.Close())
+
+	bolt, err = OpenBoltStore(path)
+	require.NoError(t, err)
+	defer bolt.Close()
+
+	sender, target, err := bolt.SeqNums(id)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), sender)
+	require.Equal(t, uint64(7), target)
+	messages, err := bolt.Messages(other, 3, 3)
+	require.NoError(t, err)
+	require.Equal(t, map[uint64][]byte{3: []byte("other")}, messages)
+}
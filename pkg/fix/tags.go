@@ -0,0 +1,105 @@
+package fix
+
+// Tags of the standard header and trailer
+const (
+	TagBeginString     Tag = 8
+	TagBodyLength      Tag = 9
+	TagCheckSum        Tag = 10
+	TagMsgSeqNum       Tag = 34
+	TagMsgType         Tag = 35
+	TagPossDupFlag     Tag = 43
+	TagSenderCompID    Tag = 49
+	TagSendingTime     Tag = 52
+	TagTargetCompID    Tag = 56
+	TagOrigSendingTime Tag = 122
+)
+
+// Tags of the session messages
+const (
+	TagBeginSeqNo           Tag = 7
+	TagEndSeqNo             Tag = 16
+	TagNewSeqNo             Tag = 36
+	TagRefSeqNum            Tag = 45
+	TagText                 Tag = 58
+	TagRawDataLength        Tag = 95
+	TagRawData              Tag = 96
+	TagEncryptMethod        Tag = 98
+	TagHeartBtInt           Tag = 108
+	TagTestReqID            Tag = 112
+	TagGapFillFlag          Tag = 123
+	TagResetSeqNumFlag      Tag = 141
+	TagRefTagID             Tag = 371
+	TagRefMsgType           Tag = 372
+	TagRejectReason         Tag = 373
+	TagBusinessRejectReason Tag = 380
+	TagUsername             Tag = 553
+	TagPassword             Tag = 554
+)
+
+// Tags of the order entry messages
+const (
+	TagAvgPx            Tag = 6
+	TagClOrdID          Tag = 11
+	TagCommission       Tag = 12
+	TagCumQty           Tag = 14
+	TagExecID           Tag = 17
+	TagLastPx           Tag = 31
+	TagLastQty          Tag = 32
+	TagOrderID          Tag = 37
+	TagOrderQty         Tag = 38
+	TagOrdStatus        Tag = 39
+	TagOrdType          Tag = 40
+	TagOrigClOrdID      Tag = 41
+	TagPrice            Tag = 44
+	TagSide             Tag = 54
+	TagSymbol           Tag = 55
+	TagTransactTime     Tag = 60
+	TagCxlRejReason     Tag = 102
+	TagOrdRejReason     Tag = 103
+	TagExecType         Tag = 150
+	TagLeavesQty        Tag = 151
+	TagCxlRejResponseTo Tag = 434
+)
+
+// Message types of the session layer
+const (
+	MsgTypeHeartbeat      = "0"
+	MsgTypeTestRequest    = "1"
+	MsgTypeResendRequest  = "2"
+	MsgTypeReject         = "3"
+	MsgTypeSequenceReset  = "4"
+	MsgTypeLogout         = "5"
+	MsgTypeLogon          = "A"
+	MsgTypeBusinessReject = "j"
+)
+
+// Message types of order entry
+const (
+	MsgTypeExecutionReport           = "8"
+	MsgTypeOrderCancelReject         = "9"
+	MsgTypeNewOrderSingle            = "D"
+	MsgTypeOrderCancelRequest        = "F"
+	MsgTypeOrderCancelReplaceRequest = "G"
+)
+
+// Session reject reasons, the values of SessionRejectReason
+const (
+	RejectReasonRequiredTagMissing  = 1
+	RejectReasonValueIncorrect      = 5
+	RejectReasonIncorrectDataFormat = 6
+	RejectReasonCompIDProblem       = 9
+	RejectReasonSendingTimeAccuracy = 10
+	RejectReasonInvalidMsgType      = 11
+	RejectReasonOther               = 99
+)
+
+// IsAdmin tells whether the message type belongs to the session layer. Session messages are not resent, a gap fill
+// takes their place.
+func IsAdmin(msgType string) bool {
+	switch msgType {
+	case MsgTypeHeartbeat, MsgTypeTestRequest, MsgTypeResendRequest, MsgTypeReject, MsgTypeSequenceReset,
+		MsgTypeLogout, MsgTypeLogon:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package hdwallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// HardenedKeyStart is the index of the first hardened child key (BIP-32)
+const HardenedKeyStart uint32 = 0x80000000
+
+var (
+	errDeriveHardenedFromPublic = errors.New("cannot derive a hardened key from a public key")
+	errInvalidChild             = errors.New("invalid child key, use the next index")
+)
+
+// ExtendedKey is a BIP-32 extended key: a private or compressed public key together with its chain code.
+type ExtendedKey struct {
+	key       []byte // 32 byte private key or 33 byte compressed public key
+	chainCode []byte
+	private   bool
+}
+
+// NewMaster derives the master extended private key from a seed
+func NewMaster(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("seed must be between 128 and 512 bits")
+	}
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	k := new(big.Int).SetBytes(sum[:32])
+	if k.Sign() == 0 || k.Cmp(crypto.S256().Params().N) >= 0 {
+		return nil, errors.New("unusable seed")
+	}
+
+	return &ExtendedKey{
+		key:       sum[:32],
+		chainCode: sum[32:],
+		private:   true,
+	}, nil
+}
+
+// Child derives the child extended key at index i. Indexes from HardenedKeyStart onwards are hardened and can only
+// be derived from private keys.
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	hardened := i >= HardenedKeyStart
+	if hardened && !k.private {
+		return nil, errDeriveHardenedFromPublic
+	}
+
+	var data []byte
+	if hardened {
+		data = append([]byte{0x00}, k.key...)
+	} else {
+		data = append(data, k.publicKey()...)
+	}
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, i)
+	data = append(data, index...)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	curve := crypto.S256()
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(curve.Params().N) >= 0 {
+		return nil, errInvalidChild
+	}
+
+	child := &ExtendedKey{chainCode: sum[32:], private: k.private}
+
+	if k.private {
+		childKey := il.Add(il, new(big.Int).SetBytes(k.key))
+		childKey.Mod(childKey, curve.Params().N)
+		if childKey.Sign() == 0 {
+			return nil, errInvalidChild
+		}
+		child.key = common.LeftPadBytes(childKey.Bytes(), 32)
+		return child, nil
+	}
+
+	// Public derivation: child point = parse(IL)*G + parent point
+	parent, err := crypto.DecompressPubkey(k.key)
+	if err != nil {
+		return nil, err
+	}
+	ilx, ily := curve.ScalarBaseMult(sum[:32])
+	x, y := curve.Add(ilx, ily, parent.X, parent.Y)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, errInvalidChild
+	}
+	child.key = crypto.CompressPubkey(&ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+
+	return child, nil
+}
+
+// Derive walks the given derivation path starting from this key
+func (k *ExtendedKey) Derive(path accounts.DerivationPath) (*ExtendedKey, error) {
+	key := k
+	for _, i := range path {
+		var err error
+		if key, err = key.Child(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// Neuter returns the public version of the extended key, which can derive non-hardened addresses without ever
+// touching a private key.
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	if !k.private {
+		return k
+	}
+
+	return &ExtendedKey{
+		key:       k.publicKey(),
+		chainCode: k.chainCode,
+		private:   false,
+	}
+}
+
+// IsPrivate reports whether the extended key holds a private key
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.private
+}
+
+// ECDSA returns the private key of a private extended key
+func (k *ExtendedKey) ECDSA() (*ecdsa.PrivateKey, error) {
+	if !k.private {
+		return nil, errors.New("extended key is not private")
+	}
+
+	return crypto.ToECDSA(k.key)
+}
+
+// Address returns the Ethereum address of the extended key
+func (k *ExtendedKey) Address() (common.Address, error) {
+	pub, err := crypto.DecompressPubkey(k.publicKey())
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// Zero wipes the key material of the extended key
+func (k *ExtendedKey) Zero() {
+	for i := range k.key {
+		k.key[i] = 0
+	}
+}
+
+func (k *ExtendedKey) publicKey() []byte {
+	if !k.private {
+		return k.key
+	}
+
+	curve := crypto.S256()
+	x, y := curve.ScalarBaseMult(k.key)
+	return crypto.CompressPubkey(&ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+}
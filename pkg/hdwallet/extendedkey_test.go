@@ -0,0 +1,63 @@
+package hdwallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/stretchr/testify/require"
+)
+
+// BIP-32 test vector 1
+func TestExtendedKey_Derive(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMaster(seed)
+	require.NoError(t, err)
+	require.Equal(t, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", hex.EncodeToString(master.key))
+	require.Equal(t, "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508", hex.EncodeToString(master.chainCode))
+
+	tests := []struct {
+		name string
+		path accounts.DerivationPath
+		key  string
+	}{
+		{"m/0'", accounts.DerivationPath{HardenedKeyStart}, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+		{"m/0'/1", accounts.DerivationPath{HardenedKeyStart, 1}, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+		{"m/0'/1/2'", accounts.DerivationPath{HardenedKeyStart, 1, HardenedKeyStart + 2}, "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := master.Derive(test.path)
+			require.NoError(t, err)
+			require.Equal(t, test.key, hex.EncodeToString(key.key))
+		})
+	}
+}
+
+func TestExtendedKey_PublicDerivation(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMaster(seed)
+	require.NoError(t, err)
+
+	account, err := master.Derive(DerivationPath(0, 0)[:3])
+	require.NoError(t, err)
+
+	// Test case 1: non-hardened children of the public key match the private derivation
+	for index := uint32(0); index < 3; index++ {
+		priv, err := account.Derive(accounts.DerivationPath{0, index})
+		require.NoError(t, err)
+		pub, err := account.Neuter().Derive(accounts.DerivationPath{0, index})
+		require.NoError(t, err)
+
+		privAddress, err := priv.Address()
+		require.NoError(t, err)
+		pubAddress, err := pub.Address()
+		require.NoError(t, err)
+		require.Equal(t, privAddress, pubAddress)
+	}
+
+	// Test case 2: hardened children cannot be derived from a public key
+	_, err = account.Neuter().Child(HardenedKeyStart)
+	require.Error(t, err)
+}
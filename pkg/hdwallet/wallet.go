@@ -0,0 +1,192 @@
+package hdwallet
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/taha-ahmadi/cryptocurrency-exchange/pkg/signer"
+)
+
+// coinTypeETH is the BIP-44 coin type of Ethereum
+const coinTypeETH = 60
+
+// Wallet derives deposit addresses from a single master seed kept encrypted in the go-ethereum keystore format.
+// Addresses are derived from cached account-level public keys, so the seed is only decrypted to sign.
+type Wallet struct {
+	seed       keystore.CryptoJSON
+	passphrase string
+
+	mu          sync.Mutex
+	accountKeys map[uint32]*ExtendedKey // neutered m/44'/60'/account' keys
+}
+
+// DerivationPath returns the BIP-44 path m/44'/60'/account'/0/index
+func DerivationPath(account, index uint32) accounts.DerivationPath {
+	return accounts.DerivationPath{
+		HardenedKeyStart + 44,
+		HardenedKeyStart + coinTypeETH,
+		HardenedKeyStart + account,
+		0,
+		index,
+	}
+}
+
+// NewSeed generates a random 256 bit master seed
+func NewSeed() ([]byte, error) {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+
+	return seed, nil
+}
+
+// CreateSeedFile encrypts the seed with the passphrase and writes it to path
+func CreateSeedFile(path, passphrase string, seed []byte, scryptN, scryptP int) error {
+	encrypted, err := keystore.EncryptDataV3(seed, []byte(passphrase), scryptN, scryptP)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(encrypted)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0600)
+}
+
+// LoadWallet reads an encrypted seed file and checks that the passphrase decrypts it
+func LoadWallet(path, passphrase string) (*Wallet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var seed keystore.CryptoJSON
+	if err := json.Unmarshal(content, &seed); err != nil {
+		return nil, fmt.Errorf("invalid seed file: %w", err)
+	}
+
+	w := &Wallet{
+		seed:        seed,
+		passphrase:  passphrase,
+		accountKeys: make(map[uint32]*ExtendedKey),
+	}
+
+	master, err := w.master()
+	if err != nil {
+		return nil, err
+	}
+	master.Zero()
+
+	return w, nil
+}
+
+// Address returns the address at m/44'/60'/account'/0/index
+func (w *Wallet) Address(account, index uint32) (common.Address, error) {
+	accountKey, err := w.accountKey(account)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	key, err := accountKey.Derive(accounts.DerivationPath{0, index})
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return key.Address()
+}
+
+// Signer returns a signer for the address at m/44'/60'/account'/0/index
+func (w *Wallet) Signer(account, index uint32) (signer.Signer, error) {
+	address, err := w.Address(account, index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &derivedSigner{
+		wallet:  w,
+		path:    DerivationPath(account, index),
+		address: address,
+	}, nil
+}
+
+func (w *Wallet) master() (*ExtendedKey, error) {
+	seed, err := keystore.DecryptDataV3(w.seed, w.passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt seed: %w", err)
+	}
+	defer func() {
+		for i := range seed {
+			seed[i] = 0
+		}
+	}()
+
+	return NewMaster(seed)
+}
+
+func (w *Wallet) accountKey(account uint32) (*ExtendedKey, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if key, ok := w.accountKeys[account]; ok {
+		return key, nil
+	}
+
+	master, err := w.master()
+	if err != nil {
+		return nil, err
+	}
+	defer master.Zero()
+
+	key, err := master.Derive(DerivationPath(account, 0)[:3])
+	if err != nil {
+		return nil, err
+	}
+
+	w.accountKeys[account] = key.Neuter()
+	key.Zero()
+
+	return w.accountKeys[account], nil
+}
+
+// derivedSigner re-derives its private key from the encrypted seed for every signature
+type derivedSigner struct {
+	wallet  *Wallet
+	path    accounts.DerivationPath
+	address common.Address
+}
+
+func (s *derivedSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *derivedSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	master, err := s.wallet.master()
+	if err != nil {
+		return nil, err
+	}
+	defer master.Zero()
+
+	key, err := master.Derive(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer key.Zero()
+
+	priv, err := key.ECDSA()
+	if err != nil {
+		return nil, err
+	}
+
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), priv)
+}
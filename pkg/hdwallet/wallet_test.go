@@ -0,0 +1,50 @@
+package hdwallet
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.json")
+	seed, err := NewSeed()
+	require.NoError(t, err)
+	require.NoError(t, CreateSeedFile(path, "secret", seed, keystore.LightScryptN, keystore.LightScryptP))
+
+	// Test case 1: wrong passphrase is rejected
+	_, err = LoadWallet(path, "wrong")
+	require.Error(t, err)
+
+	w, err := LoadWallet(path, "secret")
+	require.NoError(t, err)
+
+	// Test case 2: every user and asset account gets its own address
+	a1, err := w.Address(0, 1)
+	require.NoError(t, err)
+	a2, err := w.Address(0, 2)
+	require.NoError(t, err)
+	b1, err := w.Address(1, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, a1, a2)
+	require.NotEqual(t, a1, b1)
+
+	// Test case 3: the derived signer signs for the derived address
+	s, err := w.Signer(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, a1, s.Address())
+
+	chainID := big.NewInt(1337)
+	tx := types.NewTransaction(0, common.HexToAddress("0x02"), big.NewInt(10), 21000, big.NewInt(1), nil)
+	signedTx, err := s.SignTx(tx, chainID)
+	require.NoError(t, err)
+
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signedTx)
+	require.NoError(t, err)
+	require.Equal(t, a1, sender)
+}
@@ -0,0 +1,220 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strings"
+)
+
+// Schema is the JSON schema of a value
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// OneOf is a value of one of several types, Schema describes it with a oneOf schema
+type OneOf []interface{}
+
+// Enum registers the values a type may take, schemas of the type list them. All values must have the same type.
+func (d *Document) Enum(values ...interface{}) {
+	if len(values) == 0 {
+		return
+	}
+
+	t := reflect.TypeOf(values[0])
+	d.enums[t] = append(d.enums[t], values...)
+}
+
+// Schema returns the schema of the type of v. Named struct types and enums are added to the components and the
+// returned schema refers to them.
+func (d *Document) Schema(v interface{}) *Schema {
+	if oneOf, ok := v.(OneOf); ok {
+		schema := &Schema{}
+		for _, value := range oneOf {
+			schema.OneOf = append(schema.OneOf, d.Schema(value))
+		}
+		return schema
+	}
+
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+// Body returns the schema of the JSON body of a request type, or nil if all its fields are parameters
+func (d *Document) Body(v interface{}) *Schema {
+	if !hasBody(indirect(reflect.TypeOf(v))) {
+		return nil
+	}
+
+	return d.Schema(v)
+}
+
+// Parameters returns the path and query parameters of a request type, taken from its `param` and `query` field tags
+func (d *Document) Parameters(v interface{}) []*Parameter {
+	t := indirect(reflect.TypeOf(v))
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := f.Tag.Get("param"); name != "" {
+			params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: d.schemaOf(f.Type)})
+		}
+		if name := f.Tag.Get("query"); name != "" {
+			params = append(params, &Parameter{Name: name, In: "query", Schema: d.schemaOf(f.Type)})
+		}
+	}
+
+	return params
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	t = indirect(t)
+	if values, ok := d.enums[t]; ok {
+		return d.component(t, func() *Schema {
+			schema := primitive(t)
+			schema.Enum = values
+			return schema
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.object(t)
+		}
+		return d.component(t, func() *Schema { return d.object(t) })
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	}
+
+	return primitive(t)
+}
+
+// component returns a reference to the component schema of t, building it on first use. The component is registered
+// before it is built so recursive types refer to themselves.
+func (d *Document) component(t reflect.Type, build func() *Schema) *Schema {
+	name, ok := d.names[t]
+	if !ok {
+		name = d.componentName(t)
+		d.names[t] = name
+
+		schema := &Schema{}
+		d.Components.Schemas[name] = schema
+		*schema = *build()
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// componentName is the name of the type, prefixed with its package if another type took the name already
+func (d *Document) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := d.Components.Schemas[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+// object is the schema of a struct as encoding/json writes it, fields that are request parameters are left out
+func (d *Document) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.addFields(schema, t)
+	return schema
+}
+
+func (d *Document) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+
+		// Embedded structs without a name are flattened like encoding/json does
+		if f.Anonymous && name == "" && indirect(f.Type).Kind() == reflect.Struct {
+			d.addFields(schema, indirect(f.Type))
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		schema.Properties[name] = d.schemaOf(f.Type)
+	}
+}
+
+// jsonName returns the JSON name given by the field tag, and false if the field is not part of a JSON body
+func jsonName(f reflect.StructField) (string, bool) {
+	if f.Tag.Get("param") != "" || f.Tag.Get("query") != "" {
+		return "", false
+	}
+	if !f.IsExported() && !f.Anonymous {
+		return "", false
+	}
+
+	name := f.Tag.Get("json")
+	if i := strings.Index(name, ","); i >= 0 {
+		name = name[:i]
+	}
+	if name == "-" && f.Tag.Get("json") == "-" {
+		return "", false
+	}
+
+	return name, true
+}
+
+// hasBody reports whether a request type has fields read from a JSON body
+func hasBody(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := jsonName(t.Field(i)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func primitive(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int32, reflect.Uint32, reflect.Int16, reflect.Uint16, reflect.Int8, reflect.Uint8:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	}
+
+	// Interfaces and anything else may hold any value
+	return &Schema{}
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
@@ -0,0 +1,112 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type side string
+
+type base struct {
+	ID uint64 `json:"id"`
+}
+
+type order struct {
+	base
+	Side     side             `json:"side"`
+	Price    float64          `json:"price,omitempty"`
+	Tags     []string         `json:"tags"`
+	Meta     map[string]int32 `json:"meta"`
+	Secret   string           `json:"-"`
+	Children []*order         `json:"children"`
+	Raw      []byte           `json:"raw"`
+	Any      interface{}      `json:"any"`
+	Untagged bool
+	hidden   bool
+}
+
+type orderRequest struct {
+	Market string `param:"market"`
+	Limit  int    `query:"limit"`
+	Side   side   `json:"side"`
+}
+
+type marketRequest struct {
+	Market string `param:"market"`
+}
+
+func TestSchema(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+	doc.Enum(side("BUY"), side("SELL"))
+
+	// Test case 1: named structs become components, fields are named like encoding/json does
+	require.Equal(t, &Schema{Ref: "#/components/schemas/order"}, doc.Schema(&order{}))
+	schema := doc.Components.Schemas["order"]
+	require.Equal(t, "object", schema.Type)
+	require.Equal(t, &Schema{Type: "integer", Format: "int64"}, schema.Properties["id"])
+	require.Equal(t, &Schema{Ref: "#/components/schemas/side"}, schema.Properties["side"])
+	require.Equal(t, &Schema{Type: "number", Format: "double"}, schema.Properties["price"])
+	require.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}}, schema.Properties["tags"])
+	require.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "integer", Format: "int32"}}, schema.Properties["meta"])
+	require.Equal(t, &Schema{Type: "string", Format: "byte"}, schema.Properties["raw"])
+	require.Equal(t, &Schema{}, schema.Properties["any"])
+	require.Equal(t, &Schema{Type: "boolean"}, schema.Properties["Untagged"])
+	require.NotContains(t, schema.Properties, "Secret")
+	require.NotContains(t, schema.Properties, "hidden")
+
+	// Test case 2: recursive types refer to their own component
+	require.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/order"}}, schema.Properties["children"])
+
+	// Test case 3: enums list their values
+	require.Equal(t, &Schema{Type: "string", Enum: []interface{}{side("BUY"), side("SELL")}}, doc.Components.Schemas["side"])
+
+	// Test case 4: one of several types
+	oneOf := doc.Schema(OneOf{base{}, []base{}})
+	require.Len(t, oneOf.OneOf, 2)
+	require.Equal(t, "#/components/schemas/base", oneOf.OneOf[0].Ref)
+	require.Equal(t, "array", oneOf.OneOf[1].Type)
+}
+
+func TestParameters(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+
+	// Test case 1: `param` fields are required path parameters and `query` fields query parameters
+	params := doc.Parameters(orderRequest{})
+	require.Equal(t, []*Parameter{
+		{Name: "market", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Format: "int64"}},
+	}, params)
+
+	// Test case 2: the body holds the other fields
+	require.Equal(t, &Schema{Ref: "#/components/schemas/orderRequest"}, doc.Body(orderRequest{}))
+	require.Equal(t, map[string]*Schema{"side": {Type: "string"}}, doc.Components.Schemas["orderRequest"].Properties)
+
+	// Test case 3: requests made of parameters only have no body
+	require.Nil(t, doc.Body(marketRequest{}))
+	require.NotContains(t, doc.Components.Schemas, "marketRequest")
+}
+
+func TestDocument(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+	op := &Operation{Responses: map[string]*Response{"200": {Description: "OK"}}}
+
+	// Test case 1: operations are added by path and method
+	require.NoError(t, doc.Add(http.MethodGet, PathTemplate("/v1/books/:market/best/:side"), op))
+	require.Same(t, op, doc.Operation(http.MethodGet, "/v1/books/{market}/best/{side}"))
+	require.Nil(t, doc.Operation(http.MethodPost, "/v1/books/{market}/best/{side}"))
+
+	// Test case 2: an operation is described once
+	require.Error(t, doc.Add(http.MethodGet, "/v1/books/{market}/best/{side}", op))
+
+	// Test case 3: the document is written with OpenAPI field names
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var written map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &written))
+	require.Equal(t, Version, written["openapi"])
+	require.Contains(t, written["paths"], "/v1/books/{market}/best/{side}")
+	require.NotContains(t, written, "names")
+}
@@ -0,0 +1,57 @@
+package signer
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// KeystoreSigner signs transactions with a key held in an encrypted go-ethereum keystore directory.
+// The key is decrypted only for the duration of a single signature and zeroed right after.
+type KeystoreSigner struct {
+	ks         *keystore.KeyStore
+	account    accounts.Account
+	passphrase string
+}
+
+// OpenKeystore opens (or creates) the keystore directory at the given path using the standard scrypt parameters
+func OpenKeystore(dir string) *keystore.KeyStore {
+	return keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+// NewKeystoreSigner creates a signer for the given address, checking that the account exists in the keystore and
+// that the passphrase decrypts it.
+func NewKeystoreSigner(ks *keystore.KeyStore, address common.Address, passphrase string) (*KeystoreSigner, error) {
+	account, err := ks.Find(accounts.Account{Address: address})
+	if err != nil {
+		return nil, fmt.Errorf("account %s not found in keystore: %w", address.Hex(), err)
+	}
+
+	// Unlocking and locking straight away validates the passphrase without leaving the key decrypted in memory.
+	if err := ks.Unlock(account, passphrase); err != nil {
+		return nil, fmt.Errorf("failed to unlock account %s: %w", address.Hex(), err)
+	}
+	if err := ks.Lock(account.Address); err != nil {
+		return nil, err
+	}
+
+	return &KeystoreSigner{
+		ks:         ks,
+		account:    account,
+		passphrase: passphrase,
+	}, nil
+}
+
+// Address returns the address of the keystore account
+func (s *KeystoreSigner) Address() common.Address {
+	return s.account.Address
+}
+
+// SignTx signs the transaction by decrypting the keystore file with the configured passphrase
+func (s *KeystoreSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.ks.SignTxWithPassphrase(s.account, s.passphrase, tx, chainID)
+}
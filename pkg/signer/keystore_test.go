@@ -0,0 +1,39 @@
+package signer
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystoreSigner(t *testing.T) {
+	ks := keystore.NewKeyStore(t.TempDir(), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := ks.NewAccount("secret")
+	require.NoError(t, err)
+
+	// Test case 1: wrong passphrase is rejected up front
+	_, err = NewKeystoreSigner(ks, account.Address, "wrong")
+	require.Error(t, err)
+
+	// Test case 2: unknown account is rejected
+	_, err = NewKeystoreSigner(ks, common.HexToAddress("0x01"), "secret")
+	require.Error(t, err)
+
+	// Test case 3: signed transaction recovers to the keystore account
+	s, err := NewKeystoreSigner(ks, account.Address, "secret")
+	require.NoError(t, err)
+	require.Equal(t, account.Address, s.Address())
+
+	chainID := big.NewInt(1337)
+	tx := types.NewTransaction(0, common.HexToAddress("0x02"), big.NewInt(10), 21000, big.NewInt(1), nil)
+	signedTx, err := s.SignTx(tx, chainID)
+	require.NoError(t, err)
+
+	sender, err := types.Sender(types.NewEIP155Signer(chainID), signedTx)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, sender)
+}
@@ -0,0 +1,18 @@
+package signer
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Signer signs transactions on behalf of a single Ethereum account. Implementations are expected to keep the
+// underlying private key out of memory between signatures, so callers only ever deal with addresses.
+type Signer interface {
+	// Address returns the Ethereum address of the account this signer signs for.
+	Address() common.Address
+
+	// SignTx signs the given transaction for the given chain and returns the signed copy.
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
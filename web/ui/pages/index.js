@@ -3,7 +3,7 @@ import Web3 from 'web3';
 import {ethers} from 'ethers';
 
 const OrderBook = ({ orderData }) => {
-  const { TotalAsksVolume, TotalBidsVolume, Asks, Bids } = orderData;
+  const { total_asks_volume: totalAsksVolume, total_bids_volume: totalBidsVolume, asks, bids } = orderData;
 
  
   const formatDate = (timestamp) => {
@@ -22,7 +22,7 @@ const OrderBook = ({ orderData }) => {
           <p className="text-2xl font-semibold mb-2 text-red-800">
             Total Asks Volume
           </p>
-          <p className="text-xl font-semibold text-center">{TotalAsksVolume}</p>
+          <p className="text-xl font-semibold text-center">{totalAsksVolume}</p>
         </div>
       </div>
       <div className="bg-green-200 rounded-lg p-6 flex items-center justify-center">
@@ -30,7 +30,7 @@ const OrderBook = ({ orderData }) => {
           <p className="text-2xl font-semibold mb-2 text-green-800">
             Total Bids Volume
           </p>
-          <p className="text-xl font-semibold text-center">{TotalBidsVolume}</p>
+          <p className="text-xl font-semibold text-center">{totalBidsVolume}</p>
         </div>
       </div>
     </div>
@@ -51,16 +51,16 @@ const OrderBook = ({ orderData }) => {
               </tr>
             </thead>
             <tbody>
-              {Asks.map((ask) => (
-                <tr key={ask.ID} className="border-b border-gray-300">
-                  <td className="py-2 px-3">{ask.UserID}</td>
-                  <td className="py-2 px-3">{ask.ID}</td>
-                  <td className="py-2 px-3">{ask.Amount}</td>
+              {asks.map((ask) => (
+                <tr key={ask.id} className="border-b border-gray-300">
+                  <td className="py-2 px-3">{ask.user_id}</td>
+                  <td className="py-2 px-3">{ask.id}</td>
+                  <td className="py-2 px-3">{ask.amount}</td>
                   <td className="py-2 px-3 text-red-600 font-semibold">
                     Ask
                   </td>
-                  <td className="py-2 px-3">{ask.Price}</td>
-                  <td className="py-2 px-3">{formatDate(ask.Timestamp)}</td>
+                  <td className="py-2 px-3">{ask.price}</td>
+                  <td className="py-2 px-3">{formatDate(ask.timestamp)}</td>
                 </tr>
               ))}
             </tbody>
@@ -81,16 +81,16 @@ const OrderBook = ({ orderData }) => {
               </tr>
             </thead>
             <tbody>
-              {Bids.map((bid) => (
-                <tr key={bid.ID} className="border-b border-gray-300">
-                  <td className="py-2 px-3">{bid.UserID}</td>
-                  <td className="py-2 px-3">{bid.ID}</td>
-                  <td className="py-2 px-3">{bid.Amount}</td>
+              {bids.map((bid) => (
+                <tr key={bid.id} className="border-b border-gray-300">
+                  <td className="py-2 px-3">{bid.user_id}</td>
+                  <td className="py-2 px-3">{bid.id}</td>
+                  <td className="py-2 px-3">{bid.amount}</td>
                   <td className="py-2 px-3 text-green-600 font-semibold">
                     Bid
                   </td>
-                  <td className="py-2 px-3">{bid.Price}</td>
-                  <td className="py-2 px-3">{formatDate(bid.Timestamp)}</td>
+                  <td className="py-2 px-3">{bid.price}</td>
+                  <td className="py-2 px-3">{formatDate(bid.timestamp)}</td>
                 </tr>
               ))}
             </tbody>
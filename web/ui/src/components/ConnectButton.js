@@ -1,5 +1,6 @@
 import React, { useState, useEffect } from 'react';
 import { ethers } from 'ethers';
+import { signIn } from '../services/api';
 
 const ConnectButton = ({ onConnect }) => {
   const [isMetaMaskInstalled, setIsMetaMaskInstalled] = useState(false);
@@ -27,6 +28,11 @@ const ConnectButton = ({ onConnect }) => {
         method: "eth_requestAccounts",
       });
       setAccounts(accounts);
+
+      // Prove ownership of the wallet to get a trading session
+      const provider = new ethers.providers.Web3Provider(window.ethereum);
+      await signIn(provider.getSigner());
+
       setIsConnected(true);
       getBalance();
     } catch (error) {
@@ -1,14 +1,7 @@
 import React from 'react';
 
 const OrderBook = ({ orderData }) => {
-  const { TotalAsksVolume, TotalBidsVolume, Asks, Bids } = orderData;
-
-  const formatDate = (timestamp) => {
-    const dateTime = new Date(timestamp / 1000000); // divide by 1 million for microseconds
-    // Format the date and time as desired using the Date object methods
-    const formattedDate = `${dateTime.toLocaleDateString()} ${dateTime.toLocaleTimeString()}`;
-    return formattedDate;
-  };
+  const { total_asks_volume: totalAsksVolume, total_bids_volume: totalBidsVolume, asks, bids } = orderData;
 
   return (
     <div className="bg-white rounded-lg shadow-md p-6 my-5">
@@ -19,7 +12,7 @@ const OrderBook = ({ orderData }) => {
             <p className="text-2xl font-semibold mb-2 text-red-800">
               Total Asks Volume
             </p>
-            <p className="text-xl font-semibold text-center">{TotalAsksVolume}</p>
+            <p className="text-xl font-semibold text-center">{totalAsksVolume}</p>
           </div>
         </div>
         <div className="bg-green-200 rounded-lg p-6 flex items-center justify-center">
@@ -27,7 +20,7 @@ const OrderBook = ({ orderData }) => {
             <p className="text-2xl font-semibold mb-2 text-green-800">
               Total Bids Volume
             </p>
-            <p className="text-xl font-semibold text-center">{TotalBidsVolume}</p>
+            <p className="text-xl font-semibold text-center">{totalBidsVolume}</p>
           </div>
         </div>
       </div>
@@ -38,25 +31,21 @@ const OrderBook = ({ orderData }) => {
           <table className="w-full border-collapse">
             <thead>
               <tr className="bg-gray-100">
-                <th className="text-left py-2 px-3">UserID</th>
-                <th className="text-left py-2 px-3">ID</th>
-                <th className="text-left py-2 px-3">Amount</th>
-                <th className="text-left py-2 px-3">Type</th>
                 <th className="text-left py-2 px-3">Price</th>
-                <th className="text-left py-2 px-3">Timestamp</th>
+                <th className="text-left py-2 px-3">Size</th>
+                <th className="text-left py-2 px-3">Orders</th>
+                <th className="text-left py-2 px-3">Type</th>
               </tr>
             </thead>
             <tbody>
-              {Asks.map((ask) => (
-                <tr key={ask.ID} className="border-b border-gray-300">
-                  <td className="py-2 px-3">{ask.UserID}</td>
-                  <td className="py-2 px-3">{ask.ID}</td>
-                  <td className="py-2 px-3">{ask.Amount}</td>
+              {asks.map((ask) => (
+                <tr key={ask.price} className="border-b border-gray-300">
+                  <td className="py-2 px-3">{ask.price}</td>
+                  <td className="py-2 px-3">{ask.size}</td>
+                  <td className="py-2 px-3">{ask.orders}</td>
                   <td className="py-2 px-3 text-red-600 font-semibold">
                     Ask
                   </td>
-                  <td className="py-2 px-3">{ask.Price}</td>
-                  <td className="py-2 px-3">{formatDate(ask.Timestamp)}</td>
                 </tr>
               ))}
             </tbody>
@@ -68,25 +57,21 @@ const OrderBook = ({ orderData }) => {
           <table className="w-full border-collapse">
             <thead>
               <tr className="bg-gray-100">
-                <th className="text-left py-2 px-3">UserID</th>
-                <th className="text-left py-2 px-3">ID</th>
-                <th className="text-left py-2 px-3">Amount</th>
-                <th className="text-left py-2 px-3">Type</th>
                 <th className="text-left py-2 px-3">Price</th>
-                <th className="text-left py-2 px-3">Timestamp</th>
+                <th className="text-left py-2 px-3">Size</th>
+                <th className="text-left py-2 px-3">Orders</th>
+                <th className="text-left py-2 px-3">Type</th>
               </tr>
             </thead>
             <tbody>
-              {Bids.map((bid) => (
-                <tr key={bid.ID} className="border-b border-gray-300">
-                  <td className="py-2 px-3">{bid.UserID}</td>
-                  <td className="py-2 px-3">{bid.ID}</td>
-                  <td className="py-2 px-3">{bid.Amount}</td>
+              {bids.map((bid) => (
+                <tr key={bid.price} className="border-b border-gray-300">
+                  <td className="py-2 px-3">{bid.price}</td>
+                  <td className="py-2 px-3">{bid.size}</td>
+                  <td className="py-2 px-3">{bid.orders}</td>
                   <td className="py-2 px-3 text-green-600 font-semibold">
                     Bid
                   </td>
-                  <td className="py-2 px-3">{bid.Price}</td>
-                  <td className="py-2 px-3">{formatDate(bid.Timestamp)}</td>
                 </tr>
               ))}
             </tbody>
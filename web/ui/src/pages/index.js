@@ -45,9 +45,8 @@ export default function Home() {
     
     try {
       await placeMarketOrder({
-        UserID: 1, // Default user for demo
-        IsBid: true,
-        Amount: parseFloat(amount)
+        is_bid: true,
+        amount: parseFloat(amount)
       });
       
       // Reset form
@@ -1,4 +1,76 @@
-const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:3000';
+const API_BASE_URL = `${process.env.NEXT_PUBLIC_API_URL || 'http://localhost:3000'}/v1`;
+
+// Session tokens issued by the wallet login, the browser never holds an API secret
+let session = null;
+
+const postJSON = (path, body) =>
+  fetch(`${API_BASE_URL}${path}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body),
+  });
+
+const refreshSession = async () => {
+  const response = await postJSON('/auth/refresh', { refresh_token: session.refresh_token });
+  if (!response.ok) {
+    session = null;
+    throw new Error(`HTTP error ${response.status}`);
+  }
+  session = await response.json();
+};
+
+const authHeaders = async () => {
+  if (!session) {
+    return {};
+  }
+  // Renew the access token a little before it expires
+  if (Date.now() * 1e6 > session.access_expires_at - 30e9) {
+    await refreshSession();
+  }
+  return { Authorization: `Bearer ${session.access_token}` };
+};
+
+// signIn logs in with a wallet signature (sign-in-with-Ethereum style), registering the wallet on first use
+export const signIn = async (signer) => {
+  const address = await signer.getAddress();
+
+  const login = async () => {
+    const nonceResponse = await postJSON('/auth/nonce', { address });
+    if (!nonceResponse.ok) {
+      throw new Error(`HTTP error ${nonceResponse.status}`);
+    }
+    const challenge = await nonceResponse.json();
+    const signature = await signer.signMessage(challenge.message);
+    return postJSON('/auth/login', { nonce: challenge.nonce, signature });
+  };
+
+  try {
+    let response = await login();
+    if (response.status === 401) {
+      const registerResponse = await postJSON('/users', { address });
+      if (!registerResponse.ok) {
+        throw new Error(`HTTP error ${registerResponse.status}`);
+      }
+      response = await login();
+    }
+    if (!response.ok) {
+      throw new Error(`HTTP error ${response.status}`);
+    }
+    session = await response.json();
+    return session;
+  } catch (error) {
+    console.error('Error signing in:', error);
+    throw error;
+  }
+};
+
+export const signOut = async () => {
+  if (!session) {
+    return;
+  }
+  await postJSON('/auth/logout', { refresh_token: session.refresh_token });
+  session = null;
+};
 
 export const fetchOrderBook = async (market = 'ETH') => {
   try {
@@ -41,7 +113,9 @@ export const fetchBestBid = async (market = 'ETH') => {
 
 export const fetchUserOrders = async (userId) => {
   try {
-    const response = await fetch(`${API_BASE_URL}/orders/${userId}`);
+    const response = await fetch(`${API_BASE_URL}/orders/${userId}`, {
+      headers: await authHeaders(),
+    });
     if (!response.ok) {
       throw new Error(`HTTP error ${response.status}`);
     }
@@ -58,11 +132,12 @@ export const placeLimitOrder = async (orderData) => {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...(await authHeaders()),
       },
       body: JSON.stringify({
         ...orderData,
-        Type: 'LIMIT',
-        Market: 'ETH',
+        type: 'LIMIT',
+        market: 'ETH',
       }),
     });
     if (!response.ok) {
@@ -81,11 +156,12 @@ export const placeMarketOrder = async (orderData) => {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...(await authHeaders()),
       },
       body: JSON.stringify({
         ...orderData,
-        Type: 'MARKET',
-        Market: 'ETH',
+        type: 'MARKET',
+        market: 'ETH',
       }),
     });
     if (!response.ok) {
@@ -102,6 +178,7 @@ export const cancelOrder = async (orderId) => {
   try {
     const response = await fetch(`${API_BASE_URL}/orders/${orderId}`, {
       method: 'DELETE',
+      headers: await authHeaders(),
     });
     if (!response.ok) {
       throw new Error(`HTTP error ${response.status}`);